@@ -0,0 +1,61 @@
+package main
+
+import (
+	"benlang/internal/lexer"
+	"benlang/internal/parser"
+	"benlang/internal/transpiler"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runAST prints the AST of a BenLang file as JSON. With --js it reads
+// such a JSON file instead and prints the generated JavaScript.
+func runAST(args []string) {
+	fs := flag.NewFlagSet("ast", flag.ExitOnError)
+	toJS := fs.Bool("js", false, "AST-JSON einlesen und als JavaScript ausgeben")
+	fs.Usage = func() {
+		fmt.Println("Verwendung:")
+		fmt.Println("  benlang ast <datei.ben>          AST als JSON ausgeben")
+		fmt.Println("  benlang ast --js <datei.json>    AST-JSON in JavaScript übersetzen")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *toJS {
+		program, err := parser.DecodeJSON(data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(transpiler.New().Transpile(program))
+		return
+	}
+
+	p := parser.New(lexer.New(string(data)))
+	program := p.ParseProgram()
+
+	out, err := parser.EncodeJSON(program)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(out))
+
+	if len(p.Errors()) > 0 {
+		for _, msg := range p.Errors() {
+			fmt.Fprintln(os.Stderr, msg)
+		}
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"benlang/internal/auth"
+	"benlang/internal/project"
+	"flag"
+	"fmt"
+	"html/template"
+	"os"
+)
+
+// runBenutzer manages the users of the web IDE without the interactive
+// menu, so that it can be scripted. workDir is the --workdir given before
+// the subcommand; like the server, it looks for the credentials there.
+func runBenutzer(args []string, workDir string) {
+	fs := flag.NewFlagSet("benutzer", flag.ExitOnError)
+	password := fs.String("passwort", "", "Passwort (ohne wird ein kinderleichtes erzeugt)")
+	roleFlag := fs.String("rolle", "schueler", "Rolle: lehrer, schueler oder gast")
+	sheet := fs.String("blatt", "zugangsdaten.html", "Beim Import: Datei für das Blatt mit den Zugangsdaten zum Ausdrucken")
+	address := fs.String("adresse", "", "Beim Import: Adresse des Servers, die auf dem Blatt steht")
+	credsFlag := fs.String("creds", "", "Datei mit den Zugangsdaten (Standard wie beim Server)")
+	workDirFlag := fs.String("workdir", workDir, "Basis-Verzeichnis des Servers, in dem die Zugangsdaten gesucht werden")
+	fs.Usage = func() {
+		fmt.Println("Verwendung:")
+		fmt.Println("  benlang benutzer liste                                  Alle Benutzer mit Rolle zeigen")
+		fmt.Println("  benlang benutzer anlegen <name> [--rolle r] [--passwort p]  Benutzer anlegen")
+		fmt.Println("  benlang benutzer passwort <name> [--passwort p]         Neues Passwort setzen")
+		fmt.Println("  benlang benutzer loeschen <name>                        Benutzer löschen (Projekte bleiben)")
+		fmt.Println("  benlang benutzer import <klasse.csv> [--rolle r] [--blatt datei.html]  Klasse anlegen")
+		fmt.Println()
+		fmt.Println("Ohne --passwort wird ein Passwort wie \"tiger-apfel-42\" erzeugt und angezeigt.")
+		fmt.Println("Die CSV-Datei hat die Spalten benutzername[,passwort[,rolle]]; \";\" geht auch.")
+		fmt.Println()
+		fmt.Println("Optionen:")
+		fs.PrintDefaults()
+	}
+	args = parseInterspersed(fs, args)
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	role, err := auth.ParseRole(*roleFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(2)
+	}
+
+	creds, err := auth.LoadCredentials(credentialsPath(*credsFlag, *workDirFlag))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler beim Laden der Zugangsdaten: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch {
+	case args[0] == "liste" && len(args) == 1:
+		listUsers(creds)
+		return
+	case args[0] == "anlegen" && len(args) == 2:
+		err = addUser(creds, args[1], *password, role)
+	case args[0] == "passwort" && len(args) == 2:
+		err = setPassword(creds, args[1], *password)
+	case args[0] == "loeschen" && len(args) == 2:
+		err = creds.DeleteUser(args[1])
+		if err == nil {
+			fmt.Printf("✅ Benutzer '%s' wurde gelöscht.\n", args[1])
+		}
+	case args[0] == "import" && len(args) == 2:
+		err = importUsers(creds, args[1], role, *sheet, *address)
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err == nil {
+		err = creds.Save()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func listUsers(creds *auth.Credentials) {
+	users := creds.List()
+	if len(users) == 0 {
+		fmt.Println("Keine Benutzer gefunden.")
+		return
+	}
+	for _, user := range users {
+		fmt.Printf("%-20s %s\n", user.Username, user.UserRole())
+	}
+}
+
+// passwordOrGenerated returns the given password, or a generated one
+func passwordOrGenerated(password string) (string, bool, error) {
+	if password != "" {
+		return password, false, nil
+	}
+	password, err := auth.GeneratePassword()
+	return password, true, err
+}
+
+func addUser(creds *auth.Credentials, name, password string, role auth.Role) error {
+	// Every user gets a folder of the same name in the web IDE
+	if !project.ValidName(name) {
+		return fmt.Errorf("'%s' geht nicht als Benutzername", name)
+	}
+	password, generated, err := passwordOrGenerated(password)
+	if err != nil {
+		return err
+	}
+	if err := creds.AddUser(name, password, role); err != nil {
+		return err
+	}
+	fmt.Printf("✅ %s '%s' wurde angelegt.\n", role, name)
+	if generated {
+		fmt.Printf("   Passwort: %s\n", password)
+	}
+	return nil
+}
+
+func setPassword(creds *auth.Credentials, name, password string) error {
+	password, generated, err := passwordOrGenerated(password)
+	if err != nil {
+		return err
+	}
+	if err := creds.UpdateUser(name, password); err != nil {
+		return err
+	}
+	fmt.Printf("✅ Passwort für '%s' wurde geändert.\n", name)
+	if generated {
+		fmt.Printf("   Neues Passwort: %s\n", password)
+	}
+	return nil
+}
+
+func importUsers(creds *auth.Credentials, path string, role auth.Role, sheet, address string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	entries, err := auth.ReadClassList(f, role)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	var created []auth.ImportResult
+	for _, result := range creds.Import(entries, project.ValidName) {
+		if result.Err != nil {
+			fmt.Printf("❌ Zeile %d: %s: %v\n", result.Line, result.Username, result.Err)
+			continue
+		}
+		fmt.Printf("✅ %-20s %-10s %s\n", result.Username, result.Role, result.Password)
+		created = append(created, result)
+	}
+	fmt.Printf("\n%d von %d Benutzern angelegt.\n", len(created), len(entries))
+
+	if len(created) == 0 || sheet == "" {
+		return nil
+	}
+	if err := writeSheet(sheet, created, address); err != nil {
+		return err
+	}
+	fmt.Printf("🖨️  Zugangsdaten zum Ausdrucken: %s\n", sheet)
+	return nil
+}
+
+// sheetTemplate is a page of cards to cut out, one per child
+var sheetTemplate = template.Must(template.New("blatt").Parse(`<!DOCTYPE html>
+<html lang="de">
+<head>
+<meta charset="UTF-8">
+<title>BenLang - Zugangsdaten</title>
+<style>
+    body { font-family: -apple-system, "Segoe UI", Roboto, Helvetica, Arial, sans-serif; margin: 20px; }
+    .cards { display: grid; grid-template-columns: repeat(2, 1fr); gap: 12px; }
+    .card { border: 2px dashed #888; border-radius: 10px; padding: 14px 18px; break-inside: avoid; }
+    .card h2 { margin: 0 0 10px; font-size: 1.1em; }
+    .field { margin: 4px 0; }
+    .value { font-family: "Courier New", monospace; font-size: 1.3em; font-weight: bold; }
+    .address { color: #555; font-size: 0.9em; margin-top: 8px; }
+    @media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<div class="cards">
+{{range .Users}}<div class="card">
+    <h2>🎮 BenLang</h2>
+    <div class="field">Benutzername: <span class="value">{{.Username}}</span></div>
+    <div class="field">Passwort: <span class="value">{{.Password}}</span></div>
+    {{if $.Address}}<div class="address">Adresse: {{$.Address}}</div>{{end}}
+</div>
+{{end}}</div>
+</body>
+</html>
+`))
+
+func writeSheet(path string, users []auth.ImportResult, address string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := sheetTemplate.Execute(f, map[string]interface{}{"Users": users, "Address": address}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"benlang/internal/export"
+	"benlang/internal/project"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runExport writes a project as a single HTML file that runs offline
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	target := fs.String("ziel", "", "Name der HTML-Datei (Standard: <projektname>.html)")
+	fs.Usage = func() {
+		fmt.Println("Verwendung:")
+		fmt.Println("  benlang export [--ziel spiel.html] <projektordner>")
+		fmt.Println()
+		fmt.Println("Die HTML-Datei enthält das Spiel mit allen Bildern und Tönen")
+		fmt.Println("und läuft in jedem Browser, auch ohne Internet.")
+	}
+
+	positional := parseInterspersed(fs, args)
+
+	if len(positional) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	proj, err := project.New(positional[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: Konnte Projekt nicht öffnen: %v\n", err)
+		os.Exit(1)
+	}
+
+	page, err := export.HTML(proj)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *target == "" {
+		*target = proj.Name + ".html"
+	}
+	if err := os.WriteFile(*target, page, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Spiel exportiert: %s (%d KB)\n", *target, (len(page)+1023)/1024)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"benlang/internal/lsp"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runLSP starts the language server on stdin and stdout. Everything else
+// goes to stderr, because stdout belongs to the protocol.
+func runLSP(args []string) {
+	fs := flag.NewFlagSet("lsp", flag.ExitOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Verwendung:")
+		fmt.Fprintln(os.Stderr, "  benlang lsp    Language Server (LSP) über stdin/stdout starten")
+	}
+	fs.Parse(args)
+
+	if err := lsp.New(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
 )
 
 const version = "1.0.0"
@@ -24,6 +25,16 @@ func main() {
 	workDirFlag := flag.String("workdir", "", "Basis-Verzeichnis für Projekte")
 	enableAuth := flag.Bool("enable-auth", false, "Einfache Authentifizierung (Basic Auth) aktivieren")
 	manageUsers := flag.Bool("manage-users", false, "Benutzer für die Web-IDE verwalten")
+	examplesFlag := flag.String("beispiele", "", "Ordner mit Beispielen, die alle als Kopie öffnen können")
+	sessionIdle := flag.Duration("sitzung-pause", auth.DefaultIdleTimeout, "Abmelden nach so langer Pause (0 = nie)")
+	sessionMaxAge := flag.Duration("sitzung-max", auth.DefaultMaxAge, "Abmelden spätestens so lange nach der Anmeldung (0 = nie)")
+	rememberSessions := flag.Bool("sitzungen-merken", false, "Anmeldungen neben den Zugangsdaten speichern, damit ein Neustart niemanden abmeldet")
+	credsFlag := flag.String("creds", "", "Datei mit den Zugangsdaten (Standard: $"+auth.CredentialsEnv+", sonst .bencreds im workdir oder hier, sonst im Konfigurationsordner)")
+	tlsCert := flag.String("tls-cert", "", "Zertifikat (PEM) für HTTPS")
+	tlsKey := flag.String("tls-key", "", "Privater Schlüssel (PEM) zum Zertifikat")
+	tlsSelf := flag.Bool("tls-selbst", false, "HTTPS mit einem selbst erzeugten Zertifikat, das neben den Zugangsdaten gespeichert wird")
+	tlsNames := flag.String("tls-namen", "", "Weitere Namen oder Adressen für das Zertifikat von --tls-selbst, durch Komma getrennt")
+	httpPort := flag.Int("http-port", 0, "Mit HTTPS: Port, dessen HTTP-Anfragen auf HTTPS umgeleitet werden (0 = keiner)")
 
 	flag.Usage = func() {
 		fmt.Println("BenLang - Eine Programmiersprache für Kinder")
@@ -31,6 +42,14 @@ func main() {
 		fmt.Println("Verwendung:")
 		fmt.Println("  benlang [optionen] <projektordner>")
 		fmt.Println("  benlang neu <projektordner>       Neues Projekt erstellen")
+		fmt.Println("  benlang ast <datei.ben>           Programmstruktur als JSON ausgeben")
+		fmt.Println("  benlang lsp                       Language Server für Editoren wie VS Code")
+		fmt.Println("  benlang umbenennen <projekt> <alt> <neu>  Namen im ganzen Projekt umbenennen")
+		fmt.Println("  benlang export <projekt> --ziel spiel.html  Spiel als einzelne HTML-Datei speichern")
+		fmt.Println("  benlang export-zip <projekt>      Projekt als ZIP-Datei packen")
+		fmt.Println("  benlang import-zip <datei.zip>    Projekt aus einer ZIP-Datei anlegen")
+		fmt.Println("  benlang verlauf <projektordner>   Gespeicherte Versionen zeigen und zurückholen")
+		fmt.Println("  benlang benutzer liste|anlegen|passwort|loeschen|import  Benutzer der Web-IDE verwalten")
 		fmt.Println()
 		fmt.Println("Optionen:")
 		flag.PrintDefaults()
@@ -40,14 +59,18 @@ func main() {
 		fmt.Println("  benlang --port 8080 ./meinspiel")
 		fmt.Println("  benlang --workdir ./meine-spiele projekt1")
 		fmt.Println("  benlang --enable-auth ./meinspiel")
+		fmt.Println("  benlang --enable-auth --workdir ./klasse --beispiele ./beispiele")
+		fmt.Println("  benlang --enable-auth --sitzungen-merken --sitzung-pause 45m ./meinspiel")
 		fmt.Println("  benlang --manage-users")
+		fmt.Println("  benlang benutzer import klasse.csv --adresse https://192.168.1.20:3443")
+		fmt.Println("  benlang --enable-auth --tls-selbst --tls-namen schule.local --port 3443 --http-port 3000 --workdir ./klasse")
 		fmt.Println("  benlang neu ./neues-spiel")
 	}
 
 	flag.Parse()
 
 	if *manageUsers {
-		auth.HandleUserManagement()
+		auth.HandleUserManagement(credentialsPath(*credsFlag, *workDirFlag))
 		return
 	}
 
@@ -69,6 +92,36 @@ func main() {
 		return
 	}
 
+	// Handle tool subcommands
+	if len(args) >= 1 {
+		switch args[0] {
+		case "ast":
+			runAST(args[1:])
+			return
+		case "lsp":
+			runLSP(args[1:])
+			return
+		case "umbenennen":
+			runUmbenennen(args[1:])
+			return
+		case "export":
+			runExport(args[1:])
+			return
+		case "export-zip":
+			runExportZip(args[1:])
+			return
+		case "import-zip":
+			runImportZip(args[1:])
+			return
+		case "verlauf":
+			runVerlauf(args[1:])
+			return
+		case "benutzer":
+			runBenutzer(args[1:], *workDirFlag)
+			return
+		}
+	}
+
 	var projectPath string
 	if len(args) > 0 {
 		projectPath = args[0]
@@ -102,6 +155,9 @@ func main() {
 			os.Exit(1)
 		}
 		proj = p
+		if proj.ManifestError != nil {
+			fmt.Printf("⚠️  %v, es gelten die Standardwerte\n", proj.ManifestError)
+		}
 
 		// Check if project has any .ben files, if not create default
 		files, _ := proj.ListFiles()
@@ -142,22 +198,85 @@ func main() {
 	// Set embedded web content for server
 	server.WebContent = web.Content
 
-	// Open browser
-	if !*noBrowser {
-		url := fmt.Sprintf("http://localhost:%d", *port)
-		go openBrowser(url)
-	}
-
 	// Start server
 	srv := server.New(proj, *port)
 	srv.WorkDir = workDir
 	srv.AuthEnabled = *enableAuth
+	if *enableAuth {
+		if err := srv.LoadCredentials(credentialsPath(*credsFlag, workDir)); err != nil {
+			fmt.Printf("Fehler: Zugangsdaten konnten nicht geladen werden: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	srv.SessionIdle = *sessionIdle
+	srv.SessionMaxAge = *sessionMaxAge
+	srv.RememberSessions = *rememberSessions
+	if *examplesFlag != "" {
+		examples, err := filepath.Abs(*examplesFlag)
+		if err != nil {
+			fmt.Printf("Fehler: Ungültiger Beispielordner: %v\n", err)
+			os.Exit(1)
+		}
+		srv.Examples = examples
+	}
+	switch {
+	case *tlsSelf && (*tlsCert != "" || *tlsKey != ""):
+		fmt.Println("Fehler: --tls-selbst oder --tls-cert/--tls-key, nicht beides")
+		os.Exit(1)
+	case *tlsNames != "" && !*tlsSelf:
+		fmt.Println("Fehler: --tls-namen gibt es nur mit --tls-selbst")
+		os.Exit(1)
+	case (*tlsCert == "") != (*tlsKey == ""):
+		fmt.Println("Fehler: --tls-cert und --tls-key gehören zusammen")
+		os.Exit(1)
+	case *tlsSelf:
+		dir := filepath.Dir(credentialsPath(*credsFlag, workDir))
+		var names []string
+		for _, name := range strings.Split(*tlsNames, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		cert, key, err := server.SelfSignedCertificate(dir, names)
+		if err != nil {
+			fmt.Printf("Fehler: Zertifikat konnte nicht erzeugt werden: %v\n", err)
+			os.Exit(1)
+		}
+		srv.TLSCert, srv.TLSKey = cert, key
+	default:
+		srv.TLSCert, srv.TLSKey = *tlsCert, *tlsKey
+	}
+	srv.HTTPPort = *httpPort
+
+	// Open browser
+	if !*noBrowser {
+		go openBrowser(srv.URL("localhost"))
+	}
+
 	if err := srv.Start(); err != nil {
 		fmt.Printf("Fehler: Server konnte nicht gestartet werden: %v\n", err)
 		os.Exit(1)
 	}
 }
 
+// credentialsPath returns the credentials file given with --creds, or
+// else the default one
+func credentialsPath(path, workDir string) string {
+	if path == "" {
+		var err error
+		if path, err = auth.DefaultPath(workDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: Ungültiger Pfad für die Zugangsdaten: %v\n", err)
+		os.Exit(1)
+	}
+	return abs
+}
+
 func createNewProject(path string) {
 	proj, err := project.New(path)
 	if err != nil {
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"benlang/internal/analysis"
+	"benlang/internal/project"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// runUmbenennen renames a function, variable, figure, parameter or loop
+// variable in all .ben files of a project
+func runUmbenennen(args []string) {
+	fs := flag.NewFlagSet("umbenennen", flag.ExitOnError)
+	file := fs.String("datei", "", "Datei, in der der Name steht (nur nötig, wenn er mehrfach vorkommt)")
+	line := fs.Int("zeile", 0, "Zeile des Namens")
+	column := fs.Int("spalte", 0, "Spalte des Namens")
+	fs.Usage = func() {
+		fmt.Println("Verwendung:")
+		fmt.Println("  benlang umbenennen [optionen] <projektordner> <alter-name> <neuer-name>")
+		fmt.Println()
+		fmt.Println("Optionen:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Beispiele:")
+		fmt.Println("  benlang umbenennen ./meinspiel x spielerX")
+		fmt.Println("  benlang umbenennen --datei hauptspiel.ben --zeile 12 --spalte 9 ./meinspiel i zeile")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 3 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	oldName, newName := fs.Arg(1), fs.Arg(2)
+
+	proj, err := project.New(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: Konnte Projekt nicht öffnen: %v\n", err)
+		os.Exit(1)
+	}
+
+	sym, err := findSymbol(proj, oldName, *file, *line, *column)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+
+	changed, err := proj.RenameSymbol(sym.File, sym.Line, sym.Column, newName, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+
+	names := make([]string, 0, len(changed))
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf("'%s' heißt jetzt '%s'. Geänderte Dateien:\n", oldName, newName)
+	for _, name := range names {
+		fmt.Printf("  %s\n", name)
+	}
+}
+
+// findSymbol picks the symbol to rename: the one at the given position, or
+// the only symbol with that name
+func findSymbol(proj *project.Project, name, file string, line, column int) (*analysis.Symbol, error) {
+	sources, err := proj.Sources()
+	if err != nil {
+		return nil, err
+	}
+	result := analysis.Analyze(proj.AnalysisFiles(sources))
+
+	if line > 0 {
+		if file == "" {
+			file = proj.Manifest.Entry
+		}
+		sym := result.Definition(file, line, column)
+		if sym == nil || sym.Name != name {
+			return nil, fmt.Errorf("'%s' steht nicht in %s, Zeile %d, Spalte %d", name, file, line, column)
+		}
+		return sym, nil
+	}
+
+	var found []*analysis.Symbol
+	for _, sym := range result.Symbols {
+		if sym.Name == name && (file == "" || sym.File == file) {
+			found = append(found, sym)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("'%s' wurde im Projekt nicht gefunden", name)
+	case 1:
+		return found[0], nil
+	}
+
+	msg := fmt.Sprintf("'%s' gibt es mehrmals. Wähle eine Stelle mit --datei, --zeile und --spalte:", name)
+	for _, sym := range found {
+		msg += fmt.Sprintf("\n  --datei %s --zeile %d --spalte %d  (%s)", sym.File, sym.Line, sym.Column, sym.Signature())
+	}
+	return nil, fmt.Errorf("%s", msg)
+}
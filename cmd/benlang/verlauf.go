@@ -0,0 +1,146 @@
+package main
+
+import (
+	"benlang/internal/diff"
+	"benlang/internal/project"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runVerlauf lists, compares and restores the saved versions of a project
+func runVerlauf(args []string) {
+	fs := flag.NewFlagSet("verlauf", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Verwendung:")
+		fmt.Println("  benlang verlauf <projektordner>                          Versionen auflisten")
+		fmt.Println("  benlang verlauf sichern <projektordner>                  Jetzigen Stand als Version speichern")
+		fmt.Println("  benlang verlauf diff <projektordner> <version> [<bis>]   Änderungen seit einer Version zeigen")
+		fmt.Println("  benlang verlauf zurueck <projektordner> <version> [datei]  Projekt oder Datei zurückholen")
+		fmt.Println()
+		fmt.Println("Die Web-IDE speichert bei jedem Speichern eine Version in .verlauf im Projektordner.")
+	}
+	fs.Parse(args)
+	args = fs.Args()
+
+	action := "liste"
+	if len(args) > 0 {
+		switch args[0] {
+		case "liste", "sichern", "diff", "zurueck":
+			action, args = args[0], args[1:]
+		}
+	}
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if info, err := os.Stat(args[0]); err != nil || !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "Fehler: '%s' ist kein Projektordner\n", args[0])
+		os.Exit(1)
+	}
+	proj, err := project.New(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: Konnte Projekt nicht öffnen: %v\n", err)
+		os.Exit(1)
+	}
+	args = args[1:]
+
+	switch {
+	case action == "liste" && len(args) == 0:
+		err = listVersions(proj)
+	case action == "sichern" && len(args) == 0:
+		err = saveVersion(proj)
+	case action == "diff" && (len(args) == 1 || len(args) == 2):
+		to := ""
+		if len(args) == 2 {
+			to = args[1]
+		}
+		err = printVersionDiff(proj, args[0], to)
+	case action == "zurueck" && (len(args) == 1 || len(args) == 2):
+		name := ""
+		if len(args) == 2 {
+			name = args[1]
+		}
+		err = restoreVersion(proj, args[0], name)
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func listVersions(proj *project.Project) error {
+	versions, err := proj.History()
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		fmt.Println("Noch keine Versionen gespeichert.")
+		return nil
+	}
+	for _, v := range versions {
+		fmt.Printf("%s  %s  %s\n", v.ID, v.Time.Format("02.01.2006 15:04"), v.Reason)
+		if len(v.Changed) > 0 {
+			fmt.Printf("    %s\n", strings.Join(v.Changed, ", "))
+		}
+	}
+	return nil
+}
+
+func saveVersion(proj *project.Project) error {
+	v, created, err := proj.Snapshot("Von Hand gesichert")
+	if err != nil {
+		return err
+	}
+	if !created {
+		fmt.Println("Nichts geändert seit der letzten Version.")
+		return nil
+	}
+	fmt.Printf("✅ Version gespeichert: %s\n", v.ID)
+	return nil
+}
+
+func printVersionDiff(proj *project.Project, from, to string) error {
+	diffs, err := proj.CompareVersions(from, to)
+	if err != nil {
+		return err
+	}
+	if len(diffs) == 0 {
+		fmt.Println("Keine Änderungen.")
+		return nil
+	}
+
+	toName := to
+	if toName == "" {
+		toName = "jetzt"
+	}
+	for _, d := range diffs {
+		if d.Lines == nil {
+			fmt.Printf("%s: %s\n", d.Name, d.Change)
+			continue
+		}
+		fmt.Print(diff.Unified(d.Name+" ("+from+")", d.Name+" ("+toName+")", d.Lines, 3))
+	}
+	return nil
+}
+
+func restoreVersion(proj *project.Project, id, name string) error {
+	changed, err := proj.RestoreVersion(id, name)
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		fmt.Println("Schon auf dem Stand dieser Version.")
+		return nil
+	}
+	for _, f := range changed {
+		fmt.Printf("  %s\n", f)
+	}
+	fmt.Printf("✅ Zurückgeholt aus Version %s. Der vorherige Stand ist ebenfalls gespeichert.\n", id)
+	return nil
+}
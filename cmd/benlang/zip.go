@@ -0,0 +1,111 @@
+package main
+
+import (
+	"benlang/internal/project"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// runExportZip packs a project into a ZIP file
+func runExportZip(args []string) {
+	fs := flag.NewFlagSet("export-zip", flag.ExitOnError)
+	target := fs.String("ziel", "", "Name der ZIP-Datei (Standard: <projektname>.zip)")
+	fs.Usage = func() {
+		fmt.Println("Verwendung:")
+		fmt.Println("  benlang export-zip [--ziel spiel.zip] <projektordner>")
+	}
+	positional := parseInterspersed(fs, args)
+
+	if len(positional) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	if info, err := os.Stat(positional[0]); err != nil || !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "Fehler: '%s' ist kein Projektordner\n", positional[0])
+		os.Exit(1)
+	}
+	proj, err := project.New(positional[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: Konnte Projekt nicht öffnen: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *target == "" {
+		*target = proj.Name + ".zip"
+	}
+	out, err := os.Create(*target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+	if err := proj.WriteZip(out); err != nil {
+		out.Close()
+		os.Remove(*target)
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+	if err := out.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Projekt gepackt: %s\n", *target)
+}
+
+// runImportZip unpacks a ZIP file as a new project
+func runImportZip(args []string) {
+	fs := flag.NewFlagSet("import-zip", flag.ExitOnError)
+	workDir := fs.String("workdir", ".", "Ordner, in dem das Projekt angelegt wird")
+	fs.Usage = func() {
+		fmt.Println("Verwendung:")
+		fmt.Println("  benlang import-zip [--workdir ./meine-spiele] <datei.zip>")
+		fmt.Println()
+		fmt.Println("Gibt es schon ein Projekt mit dem Namen, wird eine Nummer angehängt.")
+	}
+	positional := parseInterspersed(fs, args)
+
+	if len(positional) != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	file, err := os.Open(positional[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.MkdirAll(*workDir, 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+	proj, err := project.ImportZip(file, info.Size(), *workDir, filepath.Base(positional[0]))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Fehler: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Projekt importiert: %s\n", proj.Path)
+}
+
+// parseInterspersed parses flags that may also follow the positional
+// arguments, e.g. benlang export meinspiel --ziel spiel.html
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for len(args) > 0 {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) > 0 {
+			positional = append(positional, args[0])
+			args = args[1:]
+		}
+	}
+	return positional
+}
@@ -2,4 +2,4 @@ module benlang
 
 go 1.25.5
 
-require golang.org/x/crypto v0.48.0 // indirect
+require golang.org/x/crypto v0.48.0
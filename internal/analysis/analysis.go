@@ -0,0 +1,367 @@
+package analysis
+
+import (
+	"benlang/internal/lexer"
+	"benlang/internal/parser"
+	"fmt"
+	"math"
+	"reflect"
+	"unicode/utf8"
+)
+
+// Kind is the kind of a user-defined symbol
+type Kind string
+
+const (
+	KindFunction     Kind = "funktion"
+	KindVariable     Kind = "variable"
+	KindFigure       Kind = "figur"
+	KindParameter    Kind = "parameter"
+	KindLoopVariable Kind = "zaehlvariable"
+)
+
+// Severity of a diagnostic
+const (
+	SeverityError   = "fehler"
+	SeverityWarning = "warnung"
+)
+
+// File is a source file of a project
+type File struct {
+	Name string
+	Code string
+}
+
+// Position is a 1-based line and column; columns count characters
+type Position struct {
+	Line   int `json:"zeile"`
+	Column int `json:"spalte"`
+}
+
+func (p Position) before(o Position) bool {
+	return p.Line < o.Line || (p.Line == o.Line && p.Column < o.Column)
+}
+
+// Symbol is a function, variable, figure, parameter or loop variable
+type Symbol struct {
+	Name      string   `json:"name"`
+	Kind      Kind     `json:"art"`
+	File      string   `json:"datei"`
+	Line      int      `json:"zeile"`
+	Column    int      `json:"spalte"`
+	Params    []string `json:"parameter,omitempty"`
+	Container string   `json:"in,omitempty"` // function the symbol belongs to
+	End       Position `json:"-"`            // end of a function body
+
+	scope *scope
+}
+
+// Occurrence is a place where a symbol is declared or used
+type Occurrence struct {
+	Symbol      *Symbol
+	File        string
+	Line        int
+	Column      int
+	Declaration bool
+
+	scope *scope
+}
+
+// Length returns the number of characters of the occurrence
+func (o Occurrence) Length() int {
+	return utf8.RuneCountInString(o.Symbol.Name)
+}
+
+// Diagnostic is a syntax error or a problem found by the checker
+type Diagnostic struct {
+	File     string `json:"datei"`
+	Line     int    `json:"zeile"`
+	Column   int    `json:"spalte"`
+	Length   int    `json:"laenge"`
+	Severity string `json:"schwere"`
+	Message  string `json:"meldung"`
+}
+
+// Result holds the symbol tables of a project
+type Result struct {
+	Files       []File
+	Programs    map[string]*parser.Program
+	Symbols     []*Symbol
+	Occurrences []Occurrence
+	Diagnostics []Diagnostic
+
+	global     *scope
+	scopes     []*scope
+	unresolved []reference
+}
+
+// scope is a set of names. VAR declarations go to the nearest function
+// scope because the transpiler emits them as JavaScript var.
+type scope struct {
+	parent   *scope
+	symbols  map[string]*Symbol
+	function bool
+	file     string
+	from, to Position
+}
+
+func newScope(parent *scope, function bool) *scope {
+	return &scope{parent: parent, symbols: map[string]*Symbol{}, function: function}
+}
+
+func (s *scope) functionScope() *scope {
+	for s.parent != nil && !s.function {
+		s = s.parent
+	}
+	return s
+}
+
+func (s *scope) lookup(name string) *Symbol {
+	for ; s != nil; s = s.parent {
+		if sym, ok := s.symbols[name]; ok {
+			return sym
+		}
+	}
+	return nil
+}
+
+func (s *scope) contains(file string, pos Position) bool {
+	return s.file == file && !pos.before(s.from) && !s.to.before(pos)
+}
+
+// reference is an identifier use that is resolved once all files are read
+type reference struct {
+	ident *parser.Identifier
+	scope *scope
+	file  string
+	call  bool
+}
+
+type analyzer struct {
+	result     *Result
+	file       string
+	container  string
+	references []reference
+}
+
+// Analyze parses all files and builds one symbol table for the project.
+// Top-level names are shared between files because the compiler joins them.
+func Analyze(files []File) *Result {
+	r := &Result{Files: files, Programs: map[string]*parser.Program{}}
+	r.global = newScope(nil, true)
+	a := &analyzer{result: r}
+
+	for _, f := range files {
+		p := parser.New(lexer.New(f.Code))
+		program := p.ParseProgram()
+		r.Programs[f.Name] = program
+
+		for _, e := range p.ErrorList() {
+			r.Diagnostics = append(r.Diagnostics, Diagnostic{
+				File: f.Name, Line: e.Line, Column: e.Column, Length: 1,
+				Severity: SeverityError, Message: e.Message,
+			})
+		}
+
+		a.file = f.Name
+		a.statements(program.Statements, r.global)
+	}
+
+	a.resolve()
+	return r
+}
+
+// isNil also catches typed nil pointers left behind by syntax errors
+func isNil(node interface{}) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+func (a *analyzer) declare(s *scope, ident *parser.Identifier, kind Kind) *Symbol {
+	if isNil(ident) {
+		return nil
+	}
+	sym, ok := s.symbols[ident.Value]
+	if !ok {
+		sym = &Symbol{
+			Name: ident.Value, Kind: kind, File: a.file,
+			Line: ident.Token.Line, Column: ident.Token.Column, Container: a.container,
+			scope: s,
+		}
+		s.symbols[ident.Value] = sym
+		a.result.Symbols = append(a.result.Symbols, sym)
+	}
+	a.result.Occurrences = append(a.result.Occurrences, Occurrence{
+		Symbol: sym, File: a.file, Line: ident.Token.Line, Column: ident.Token.Column, Declaration: true,
+		scope: s,
+	})
+	return sym
+}
+
+// openScope starts a scope that covers the source from start to the end
+// of body; an unclosed body reaches to the end of the file
+func (a *analyzer) openScope(parent *scope, function bool, start lexer.Token, body *parser.BlockStatement) *scope {
+	s := newScope(parent, function)
+	s.file = a.file
+	s.from = Position{start.Line, start.Column}
+	s.to = Position{math.MaxInt32, 0}
+	if !isNil(body) && body.End.Line > 0 {
+		s.to = Position{body.End.Line, body.End.Column}
+	}
+	a.result.scopes = append(a.result.scopes, s)
+	return s
+}
+
+func (a *analyzer) statements(stmts []parser.Statement, s *scope) {
+	for _, stmt := range stmts {
+		a.statement(stmt, s)
+	}
+}
+
+func (a *analyzer) block(b *parser.BlockStatement, s *scope) {
+	if !isNil(b) {
+		a.statements(b.Statements, s)
+	}
+}
+
+func (a *analyzer) statement(stmt parser.Statement, s *scope) {
+	if isNil(stmt) {
+		return
+	}
+
+	switch st := stmt.(type) {
+	case *parser.VariableDeclaration:
+		a.expression(st.Value, s)
+		a.declare(s.functionScope(), st.Name, KindVariable)
+	case *parser.FigurDeclaration:
+		a.expression(st.Value, s)
+		a.declare(s.functionScope(), st.Name, KindFigure)
+	case *parser.FunctionDeclaration:
+		sym := a.declare(s.functionScope(), st.Name, KindFunction)
+		if sym == nil {
+			return
+		}
+		inner := a.openScope(s, true, st.Token, st.Body)
+		sym.End = inner.to
+
+		outer := a.container
+		a.container = sym.Name
+		sym.Params = nil
+		for _, param := range st.Parameters {
+			if !isNil(param) {
+				sym.Params = append(sym.Params, param.Value)
+			}
+			a.declare(inner, param, KindParameter)
+		}
+		a.block(st.Body, inner)
+		a.container = outer
+	case *parser.ReturnStatement:
+		a.expression(st.ReturnValue, s)
+	case *parser.ExpressionStatement:
+		a.expression(st.Expression, s)
+	case *parser.IfStatement:
+		a.expression(st.Condition, s)
+		a.block(st.Consequence, s)
+		a.block(st.Alternative, s)
+	case *parser.WhileStatement:
+		a.expression(st.Condition, s)
+		a.block(st.Body, s)
+	case *parser.ForStatement:
+		a.expression(st.Start, s)
+		a.expression(st.End, s)
+		inner := a.openScope(s, false, st.Token, st.Body)
+		a.declare(inner, st.Variable, KindLoopVariable)
+		a.block(st.Body, inner)
+	case *parser.RepeatStatement:
+		a.expression(st.Count, s)
+		a.block(st.Body, s)
+	case *parser.EventHandler:
+		for _, param := range st.Parameters {
+			a.expression(param, s)
+		}
+		a.block(st.Body, a.openScope(s, true, st.Token, st.Body))
+	}
+}
+
+func (a *analyzer) expression(expr parser.Expression, s *scope) {
+	if isNil(expr) {
+		return
+	}
+
+	switch e := expr.(type) {
+	case *parser.Identifier:
+		a.references = append(a.references, reference{ident: e, scope: s, file: a.file})
+	case *parser.ArrayLiteral:
+		for _, el := range e.Elements {
+			a.expression(el, s)
+		}
+	case *parser.IndexExpression:
+		a.expression(e.Left, s)
+		a.expression(e.Index, s)
+	case *parser.PrefixExpression:
+		a.expression(e.Right, s)
+	case *parser.InfixExpression:
+		a.expression(e.Left, s)
+		a.expression(e.Right, s)
+	case *parser.CallExpression:
+		if ident, ok := e.Function.(*parser.Identifier); ok && ident != nil {
+			a.references = append(a.references, reference{ident: ident, scope: s, file: a.file, call: true})
+		} else {
+			a.expression(e.Function, s)
+		}
+		for _, arg := range e.Arguments {
+			a.expression(arg, s)
+		}
+	case *parser.MemberExpression:
+		// Properties belong to the runtime object, only the object is a name
+		a.expression(e.Object, s)
+	case *parser.AssignmentExpression:
+		a.expression(e.Left, s)
+		a.expression(e.Value, s)
+	case *parser.GroupedExpression:
+		a.expression(e.Expression, s)
+	}
+}
+
+// resolve links every identifier to its symbol and reports unknown names
+func (a *analyzer) resolve() {
+	for _, ref := range a.references {
+		name := ref.ident.Value
+		tok := ref.ident.Token
+
+		if sym := ref.scope.lookup(name); sym != nil {
+			a.result.Occurrences = append(a.result.Occurrences, Occurrence{
+				Symbol: sym, File: ref.file, Line: tok.Line, Column: tok.Column,
+				scope: ref.scope,
+			})
+			continue
+		}
+		a.result.unresolved = append(a.result.unresolved, ref)
+		if _, ok := LookupBuiltin(name); ok {
+			continue
+		}
+
+		message := fmt.Sprintf("'%s' ist unbekannt. Fehlt ein VAR?", name)
+		if ref.call {
+			message = fmt.Sprintf("Die Funktion '%s' gibt es nicht", name)
+		}
+		a.result.Diagnostics = append(a.result.Diagnostics, Diagnostic{
+			File: ref.file, Line: tok.Line, Column: tok.Column, Length: utf8.RuneCountInString(name),
+			Severity: SeverityWarning, Message: message,
+		})
+	}
+}
+
+// DiagnosticsFor returns the diagnostics of one file
+func (r *Result) DiagnosticsFor(file string) []Diagnostic {
+	list := []Diagnostic{}
+	for _, d := range r.Diagnostics {
+		if d.File == file {
+			list = append(list, d)
+		}
+	}
+	return list
+}
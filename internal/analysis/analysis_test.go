@@ -0,0 +1,173 @@
+package analysis
+
+import (
+	"benlang/internal/lexer"
+	"benlang/internal/transpiler"
+	"strings"
+	"testing"
+)
+
+const haupt = `VAR punkte = 0
+FIGUR spieler = LADE_BILD("held.png")
+
+FUNKTION addiere(a, b) {
+    VAR summe = a + b
+    ZURUECK summe
+}
+
+WENN_IMMER {
+    FUER i VON 1 BIS 3 {
+        punkte = addiere(punkte, i)
+    }
+    spieler.x = bonus
+}
+`
+
+const extra = `VAR bonus = 5
+WENN_START {
+    SCHREIBE(punkte)
+    unbekannt = 1
+    FLIEGE()
+}
+`
+
+func analyze() *Result {
+	return Analyze([]File{{"hauptspiel.ben", haupt}, {"extra.ben", extra}})
+}
+
+func TestBuiltinsDocumented(t *testing.T) {
+	for name := range transpiler.Builtins {
+		if _, ok := LookupBuiltin(name); !ok {
+			t.Errorf("built-in %s has no documentation", name)
+		}
+	}
+	for name := range Builtins {
+		if _, ok := transpiler.Builtins[name]; !ok {
+			t.Errorf("documented built-in %s is not known to the transpiler", name)
+		}
+	}
+	for _, kw := range lexer.Keywords() {
+		if _, ok := Keywords[kw]; !ok {
+			t.Errorf("keyword %s has no documentation", kw)
+		}
+	}
+}
+
+func TestDefinitionAcrossFiles(t *testing.T) {
+	r := analyze()
+
+	tests := []struct {
+		file         string
+		line, column int
+		name         string
+		kind         Kind
+		defFile      string
+		defLine      int
+	}{
+		{"hauptspiel.ben", 11, 22, "addiere", KindFunction, "hauptspiel.ben", 4},
+		{"hauptspiel.ben", 11, 34, "i", KindLoopVariable, "hauptspiel.ben", 10},
+		{"hauptspiel.ben", 13, 17, "bonus", KindVariable, "extra.ben", 1},
+		{"hauptspiel.ben", 13, 5, "spieler", KindFigure, "hauptspiel.ben", 2},
+		{"hauptspiel.ben", 6, 15, "summe", KindVariable, "hauptspiel.ben", 5},
+		{"hauptspiel.ben", 5, 17, "a", KindParameter, "hauptspiel.ben", 4},
+		{"extra.ben", 3, 15, "punkte", KindVariable, "hauptspiel.ben", 1},
+	}
+
+	for _, tt := range tests {
+		sym := r.Definition(tt.file, tt.line, tt.column)
+		if sym == nil {
+			t.Errorf("%s:%d:%d: no symbol found", tt.file, tt.line, tt.column)
+			continue
+		}
+		if sym.Name != tt.name || sym.Kind != tt.kind || sym.File != tt.defFile || sym.Line != tt.defLine {
+			t.Errorf("%s:%d:%d: got %+v", tt.file, tt.line, tt.column, sym)
+		}
+	}
+
+	if sym := r.Definition("hauptspiel.ben", 13, 13); sym != nil {
+		t.Errorf("property resolved to %+v", sym)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	r := analyze()
+	got := r.DiagnosticsFor("extra.ben")
+	if len(got) != 2 {
+		t.Fatalf("expected 2 warnings, got %+v", got)
+	}
+	if got[0].Line != 4 || !strings.Contains(got[0].Message, "unbekannt") {
+		t.Errorf("unexpected diagnostic %+v", got[0])
+	}
+	if got[1].Line != 5 || got[1].Column != 5 || !strings.Contains(got[1].Message, "Funktion 'FLIEGE'") {
+		t.Errorf("unexpected diagnostic %+v", got[1])
+	}
+	if len(r.DiagnosticsFor("hauptspiel.ben")) != 0 {
+		t.Errorf("unexpected diagnostics %+v", r.DiagnosticsFor("hauptspiel.ben"))
+	}
+
+	r = Analyze([]File{{"a.ben", "VAR x = \n"}})
+	if d := r.DiagnosticsFor("a.ben"); len(d) == 0 || d[0].Severity != SeverityError {
+		t.Errorf("expected syntax error, got %+v", d)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	r := analyze()
+	sym := r.Definition("hauptspiel.ben", 1, 5)
+	refs := r.References(sym)
+	// declaration, two uses in WENN_IMMER, one in extra.ben
+	if len(refs) != 4 {
+		t.Errorf("expected 4 references to punkte, got %+v", refs)
+	}
+}
+
+func labels(list []Completion) map[string]Kind {
+	m := map[string]Kind{}
+	for _, c := range list {
+		m[c.Label] = c.Kind
+	}
+	return m
+}
+
+func TestCompletions(t *testing.T) {
+	r := Analyze([]File{{"a.ben", "VAR punkte = 0\nFUNKTION f(preis) {\n    p\n}\np\nspieler.\nZUF"}})
+
+	inside := labels(r.Completions("a.ben", 3, 6))
+	for _, want := range []string{"punkte", "preis"} {
+		if _, ok := inside[want]; !ok {
+			t.Errorf("missing completion %s inside function: %v", want, inside)
+		}
+	}
+
+	outside := labels(r.Completions("a.ben", 5, 2))
+	if _, ok := outside["preis"]; ok {
+		t.Error("parameter offered outside its function")
+	}
+
+	props := labels(r.Completions("a.ben", 6, 9))
+	if props["x"] != KindProperty || props["GEHE_ZU"] != KindProperty {
+		t.Errorf("expected figure properties, got %v", props)
+	}
+
+	builtins := r.Completions("a.ben", 7, 4)
+	if len(builtins) != 1 || builtins[0].Label != "ZUFALL" || builtins[0].Insert != "ZUFALL(min, max)" {
+		t.Errorf("unexpected completions %+v", builtins)
+	}
+}
+
+func TestHover(t *testing.T) {
+	r := analyze()
+
+	if h := r.Hover("hauptspiel.ben", 11, 22); !strings.Contains(h, "FUNKTION addiere(a, b)") {
+		t.Errorf("unexpected hover for function: %q", h)
+	}
+	if h := r.Hover("hauptspiel.ben", 2, 20); !strings.Contains(h, "LADE_BILD(pfad)") {
+		t.Errorf("unexpected hover for built-in: %q", h)
+	}
+	if h := r.Hover("hauptspiel.ben", 9, 3); !strings.Contains(h, "WENN_IMMER") {
+		t.Errorf("unexpected hover for keyword: %q", h)
+	}
+	if h := r.Hover("hauptspiel.ben", 13, 13); !strings.Contains(h, "X-Position") {
+		t.Errorf("unexpected hover for property: %q", h)
+	}
+}
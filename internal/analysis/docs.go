@@ -0,0 +1,108 @@
+package analysis
+
+import (
+	"benlang/internal/lexer"
+	"benlang/internal/transpiler"
+	"strings"
+)
+
+// BuiltinDoc describes a built-in function
+type BuiltinDoc struct {
+	Params      string `json:"parameter"`
+	Description string `json:"beschreibung"`
+}
+
+// Builtins documents every function in transpiler.Builtins. Spellings with
+// umlauts share the entry of their ASCII spelling.
+var Builtins = map[string]BuiltinDoc{
+	"LADE_BILD":        {"(pfad)", "Lädt ein Bild und gibt eine Figur zurück"},
+	"BILD_WECHSELN":    {"(figur, pfad)", "Wechselt das Bild einer Figur"},
+	"SPIELE_TON":       {"(pfad)", "Spielt eine Sound-Datei ab"},
+	"ZEIGE_TEXT":       {"(text, x, y, farbe, groesse)", "Zeigt Text an"},
+	"ZEICHNE_RECHTECK": {"(x, y, breite, hoehe, farbe)", "Zeichnet ein gefülltes Rechteck"},
+	"ZEICHNE_KREIS":    {"(x, y, radius, farbe)", "Zeichnet einen gefüllten Kreis"},
+	"ZEICHNE_LINIE":    {"(x1, y1, x2, y2, farbe)", "Zeichnet eine Linie"},
+	"TASTE_GEDRUECKT":  {"(taste)", "Gibt WAHR zurück, solange die Taste gedrückt ist"},
+	"TASTE_GETIPPT":    {"(taste)", "Gibt nur beim ersten Drücken der Taste WAHR zurück"},
+	"GEDRUECKTE_TASTE": {"()", "Gibt die zuletzt gedrückte Taste zurück"},
+	"MAUS_X":           {"()", "X-Position der Maus"},
+	"MAUS_Y":           {"()", "Y-Position der Maus"},
+	"MAUS_GEDRUECKT":   {"()", "Gibt WAHR zurück, wenn die Maustaste gedrückt ist"},
+	"ZUFALL":           {"(min, max)", "Zufällige Ganzzahl zwischen min und max"},
+	"WARTE":            {"(millisekunden)", "Wartet die angegebene Zeit"},
+	"RUNDEN":           {"(zahl)", "Rundet zur nächsten Ganzzahl"},
+	"ABSOLUT":          {"(zahl)", "Gibt den positiven Wert zurück"},
+	"WURZEL":           {"(zahl)", "Quadratwurzel der Zahl"},
+	"SINUS":            {"(zahl)", "Sinus der Zahl (im Bogenmaß)"},
+	"KOSINUS":          {"(zahl)", "Kosinus der Zahl (im Bogenmaß)"},
+	"SCHREIBE":         {"(text)", "Schreibt Text in die Konsole"},
+	"FRAGE":            {"(frage)", "Stellt eine Frage und gibt die Antwort zurück"},
+	"LOESCHEN":         {"(figur)", "Entfernt eine Figur aus dem Spiel"},
+	"LAENGE":           {"(text)", "Gibt die Länge eines Textes oder einer Liste zurück"},
+	"ZEICHEN":          {"(text, index)", "Gibt das Zeichen an einer Position zurück"},
+	"GROSSBUCHSTABEN":  {"(text)", "Wandelt Text in Großbuchstaben um"},
+	"GEHE_ZU":          {"(figur, x, y)", "Bewegt eine Figur zu einer Position"},
+	"DREHE":            {"(figur, winkel)", "Dreht eine Figur"},
+	"SKALIERE":         {"(figur, faktor)", "Skaliert eine Figur"},
+}
+
+// Keywords documents the keywords of the language
+var Keywords = map[string]string{
+	"WENN":           "Führt den Block nur aus, wenn die Bedingung WAHR ist",
+	"SONST":          "Wird ausgeführt, wenn die Bedingung von WENN FALSCH ist",
+	"SOLANGE":        "Wiederholt den Block, solange die Bedingung WAHR ist",
+	"FUER":           "Zählschleife: FUER i VON 1 BIS 10 { ... }",
+	"VON":            "Startwert einer FUER-Schleife",
+	"BIS":            "Endwert einer FUER-Schleife",
+	"WIEDERHOLE":     "Wiederholt den Block so oft wie angegeben",
+	"FUNKTION":       "Definiert eine eigene Funktion: FUNKTION name(a, b) { ... }",
+	"ZURUECK":        "Beendet die Funktion und gibt einen Wert zurück",
+	"VAR":            "Legt eine Variable an: VAR punkte = 0",
+	"VARIABLE":       "Legt eine Variable an (Langform von VAR)",
+	"WAHR":           "Wahrheitswert: wahr",
+	"FALSCH":         "Wahrheitswert: falsch",
+	"UND":            "WAHR, wenn beide Seiten WAHR sind",
+	"ODER":           "WAHR, wenn mindestens eine Seite WAHR ist",
+	"NICHT":          "Kehrt WAHR und FALSCH um",
+	"SPIEL":          "Gibt dem Spiel einen Namen: SPIEL \"Mein Spiel\"",
+	"FIGUR":          "Legt eine Figur an: FIGUR spieler = LADE_BILD(\"spieler.png\")",
+	"WENN_START":     "Wird einmal beim Spielstart ausgeführt",
+	"WENN_IMMER":     "Wird in jedem Bild ausgeführt (etwa 60 Mal pro Sekunde)",
+	"WENN_TASTE":     "Wird ausgeführt, wenn die Taste gedrückt wird: WENN_TASTE(\"leertaste\") { ... }",
+	"WENN_KOLLISION": "Wird ausgeführt, wenn sich zwei Figuren berühren: WENN_KOLLISION(a, b) { ... }",
+}
+
+// FigureProperties are the properties and methods every figure has
+var FigureProperties = map[string]string{
+	"x":        "X-Position der Figur",
+	"y":        "Y-Position der Figur",
+	"breite":   "Breite der Figur",
+	"hoehe":    "Höhe der Figur",
+	"sichtbar": "WAHR, wenn die Figur gezeichnet wird",
+	"drehung":  "Drehung der Figur in Grad",
+	"GEHE_ZU":  "(x, y) Bewegt die Figur zu einer Position",
+	"DREHE":    "(winkel) Dreht die Figur weiter",
+	"SKALIERE": "(faktor) Skaliert die Figur",
+	"LOESCHEN": "() Entfernt die Figur aus dem Spiel",
+}
+
+var umlauts = strings.NewReplacer("Ä", "AE", "Ö", "OE", "Ü", "UE", "ä", "ae", "ö", "oe", "ü", "ue", "ß", "ss")
+
+// LookupBuiltin returns the documentation of a built-in function
+func LookupBuiltin(name string) (BuiltinDoc, bool) {
+	if _, ok := transpiler.Builtins[name]; !ok {
+		return BuiltinDoc{}, false
+	}
+	doc, ok := Builtins[umlauts.Replace(name)]
+	return doc, ok
+}
+
+// LookupKeyword returns the documentation of a keyword in any spelling
+func LookupKeyword(word string) (string, string, bool) {
+	tok := lexer.LookupIdent(word)
+	if tok == lexer.TOKEN_IDENT {
+		return "", "", false
+	}
+	doc, ok := Keywords[string(tok)]
+	return string(tok), doc, ok
+}
@@ -0,0 +1,242 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Completion kinds besides the symbol kinds
+const (
+	KindKeyword  Kind = "schluesselwort"
+	KindBuiltin  Kind = "befehl"
+	KindProperty Kind = "eigenschaft"
+)
+
+// Completion is a suggestion for the word at the cursor
+type Completion struct {
+	Label  string `json:"label"`
+	Kind   Kind   `json:"art"`
+	Detail string `json:"detail,omitempty"`
+	Doc    string `json:"doku,omitempty"`
+	Insert string `json:"einfuegen"`
+}
+
+// OccurrenceAt returns the symbol occurrence under the cursor. The cursor
+// may also sit directly behind the name.
+func (r *Result) OccurrenceAt(file string, line, column int) *Occurrence {
+	for i, o := range r.Occurrences {
+		if o.File == file && o.Line == line && column >= o.Column && column <= o.Column+o.Length() {
+			return &r.Occurrences[i]
+		}
+	}
+	return nil
+}
+
+// Definition returns the symbol under the cursor
+func (r *Result) Definition(file string, line, column int) *Symbol {
+	if o := r.OccurrenceAt(file, line, column); o != nil {
+		return o.Symbol
+	}
+	return nil
+}
+
+// References returns all declarations and uses of a symbol
+func (r *Result) References(sym *Symbol) []Occurrence {
+	var list []Occurrence
+	for _, o := range r.Occurrences {
+		if o.Symbol == sym {
+			list = append(list, o)
+		}
+	}
+	return list
+}
+
+// FileSymbols returns the symbols declared in a file in source order
+func (r *Result) FileSymbols(file string) []*Symbol {
+	var list []*Symbol
+	for _, sym := range r.Symbols {
+		if sym.File == file {
+			list = append(list, sym)
+		}
+	}
+	return list
+}
+
+// Signature returns how a symbol is declared, e.g. "FUNKTION f(a, b)"
+func (sym *Symbol) Signature() string {
+	switch sym.Kind {
+	case KindFunction:
+		return fmt.Sprintf("FUNKTION %s(%s)", sym.Name, strings.Join(sym.Params, ", "))
+	case KindFigure:
+		return "FIGUR " + sym.Name
+	case KindParameter:
+		return fmt.Sprintf("%s (Parameter von %s)", sym.Name, sym.Container)
+	case KindLoopVariable:
+		return fmt.Sprintf("FUER %s VON ... BIS ...", sym.Name)
+	default:
+		return "VAR " + sym.Name
+	}
+}
+
+// wordAt returns the word under the cursor, where it starts, and the
+// character in front of it
+func (r *Result) wordAt(file string, line, column int) (string, int, rune) {
+	text := r.line(file, line)
+	if column < 1 || column > len(text)+1 {
+		return "", column, 0
+	}
+
+	start := column - 1
+	for start > 0 && isWordRune(text[start-1]) {
+		start--
+	}
+	end := column - 1
+	for end < len(text) && isWordRune(text[end]) {
+		end++
+	}
+
+	var before rune
+	if start > 0 {
+		before = text[start-1]
+	}
+	return string(text[start:end]), start + 1, before
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// line returns a line of a file as characters
+func (r *Result) line(file string, line int) []rune {
+	for _, f := range r.Files {
+		if f.Name == file {
+			lines := strings.Split(f.Code, "\n")
+			if line >= 1 && line <= len(lines) {
+				return []rune(strings.TrimRight(lines[line-1], "\r"))
+			}
+		}
+	}
+	return nil
+}
+
+// Hover returns Markdown documentation for the word under the cursor
+func (r *Result) Hover(file string, line, column int) string {
+	if sym := r.Definition(file, line, column); sym != nil {
+		return fmt.Sprintf("```benlang\n%s\n```\nDefiniert in %s, Zeile %d", sym.Signature(), sym.File, sym.Line)
+	}
+
+	word, _, before := r.wordAt(file, line, column)
+	if word == "" {
+		return ""
+	}
+	if before == '.' {
+		if doc, ok := FigureProperties[word]; ok {
+			return fmt.Sprintf("**.%s**\n\n%s", word, doc)
+		}
+		return ""
+	}
+	if doc, ok := LookupBuiltin(word); ok {
+		return fmt.Sprintf("```benlang\n%s%s\n```\n%s", word, doc.Params, doc.Description)
+	}
+	if canonical, doc, ok := LookupKeyword(word); ok {
+		return fmt.Sprintf("**%s**\n\n%s", canonical, doc)
+	}
+	return ""
+}
+
+// Visible returns the symbols that can be used at a position, inner
+// declarations first
+func (r *Result) Visible(file string, line, column int) []*Symbol {
+	pos := Position{line, column}
+	var inner []*scope
+	for _, s := range r.scopes {
+		if s.contains(file, pos) {
+			inner = append(inner, s)
+		}
+	}
+	// Scopes are recorded outside-in, so walk them backwards
+	seen := map[string]bool{}
+	var list []*Symbol
+	add := func(s *scope) {
+		names := make([]string, 0, len(s.symbols))
+		for name := range s.symbols {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if !seen[name] {
+				seen[name] = true
+				list = append(list, s.symbols[name])
+			}
+		}
+	}
+	for i := len(inner) - 1; i >= 0; i-- {
+		add(inner[i])
+	}
+	add(r.global)
+	return list
+}
+
+// Completions returns suggestions for the word in front of the cursor
+func (r *Result) Completions(file string, line, column int) []Completion {
+	word, start, before := r.wordAt(file, line, column)
+	// Only the part in front of the cursor has been typed yet
+	prefix := strings.ToLower(string([]rune(word)[:column-start]))
+	matches := func(label string) bool {
+		return strings.HasPrefix(strings.ToLower(label), prefix)
+	}
+
+	list := []Completion{}
+
+	if before == '.' {
+		for _, name := range sortedKeys(FigureProperties) {
+			if !matches(name) {
+				continue
+			}
+			doc := FigureProperties[name]
+			c := Completion{Label: name, Kind: KindProperty, Doc: doc, Insert: name}
+			if strings.HasPrefix(doc, "(") {
+				params := doc[:strings.Index(doc, ")")+1]
+				c.Detail, c.Doc, c.Insert = params, strings.TrimSpace(doc[len(params):]), name+params
+			}
+			list = append(list, c)
+		}
+		return list
+	}
+
+	for _, sym := range r.Visible(file, line, column) {
+		if !matches(sym.Name) {
+			continue
+		}
+		c := Completion{Label: sym.Name, Kind: sym.Kind, Detail: sym.Signature(), Insert: sym.Name}
+		if sym.Kind == KindFunction {
+			c.Insert = fmt.Sprintf("%s(%s)", sym.Name, strings.Join(sym.Params, ", "))
+		}
+		list = append(list, c)
+	}
+
+	for _, name := range sortedKeys(Builtins) {
+		if matches(name) {
+			doc := Builtins[name]
+			list = append(list, Completion{Label: name, Kind: KindBuiltin, Detail: name + doc.Params, Doc: doc.Description, Insert: name + doc.Params})
+		}
+	}
+
+	for _, name := range sortedKeys(Keywords) {
+		if matches(name) {
+			list = append(list, Completion{Label: name, Kind: KindKeyword, Doc: Keywords[name], Insert: name})
+		}
+	}
+	return list
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,134 @@
+package analysis
+
+import (
+	"benlang/internal/lexer"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Rename renames the symbol under the cursor in every file of the project
+// and returns the new content of the files that changed. Strings, comments
+// and other symbols with the same name stay untouched.
+func (r *Result) Rename(file string, line, column int, newName string) (map[string]string, error) {
+	for _, d := range r.Diagnostics {
+		if d.Severity == SeverityError {
+			return nil, fmt.Errorf("%s enthält Fehler (Zeile %d). Bitte zuerst korrigieren", d.File, d.Line)
+		}
+	}
+
+	sym := r.Definition(file, line, column)
+	if sym == nil {
+		return nil, fmt.Errorf("An dieser Stelle steht kein Name, den man umbenennen kann")
+	}
+	if err := checkName(newName); err != nil {
+		return nil, err
+	}
+	if newName == sym.Name {
+		return map[string]string{}, nil
+	}
+	if err := r.checkConflicts(sym, newName); err != nil {
+		return nil, err
+	}
+
+	byFile := map[string][]Occurrence{}
+	for _, o := range r.References(sym) {
+		byFile[o.File] = append(byFile[o.File], o)
+	}
+
+	changed := map[string]string{}
+	for _, f := range r.Files {
+		occurrences := byFile[f.Name]
+		if len(occurrences) == 0 {
+			continue
+		}
+		code, err := replaceOccurrences(f.Code, occurrences, sym.Name, newName)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+		changed[f.Name] = code
+	}
+	return changed, nil
+}
+
+// checkName reports whether a new name is a plain identifier that is
+// neither a keyword nor a built-in function
+func checkName(name string) error {
+	tokens := lexer.New(name).Tokenize()
+	if len(tokens) == 2 && tokens[0].Literal == name {
+		switch {
+		case tokens[0].Type != lexer.TOKEN_IDENT:
+			return fmt.Errorf("'%s' ist ein Schlüsselwort und kann kein Name sein", name)
+		case isBuiltin(name):
+			return fmt.Errorf("'%s' ist ein eingebauter Befehl und kann kein Name sein", name)
+		}
+		return nil
+	}
+	return fmt.Errorf("'%s' ist kein gültiger Name. Erlaubt sind Buchstaben, Ziffern und _", name)
+}
+
+func isBuiltin(name string) bool {
+	_, ok := LookupBuiltin(name)
+	return ok
+}
+
+// checkConflicts makes sure that after renaming every name still refers to
+// the same symbol as before
+func (r *Result) checkConflicts(sym *Symbol, newName string) error {
+	conflict := fmt.Errorf("Der Name '%s' ist hier schon vergeben", newName)
+
+	// The renamed symbol must not be hidden by another newName
+	for _, o := range r.References(sym) {
+		if other := o.scope.lookup(newName); other != nil && other != sym {
+			return conflict
+		}
+	}
+
+	// Existing uses of newName must not end up pointing at the renamed symbol
+	inScope := func(s *scope) bool {
+		for ; s != nil; s = s.parent {
+			if s == sym.scope {
+				return true
+			}
+			if _, ok := s.symbols[newName]; ok {
+				return false
+			}
+		}
+		return false
+	}
+	for _, o := range r.Occurrences {
+		if o.Symbol.Name == newName && inScope(o.scope) {
+			return conflict
+		}
+	}
+	for _, ref := range r.unresolved {
+		if ref.ident.Value == newName && inScope(ref.scope) {
+			return conflict
+		}
+	}
+	return nil
+}
+
+// replaceOccurrences replaces the name at each occurrence, starting at the
+// end so earlier positions stay valid
+func replaceOccurrences(code string, occurrences []Occurrence, oldName, newName string) (string, error) {
+	lines := strings.Split(code, "\n")
+	sort.Slice(occurrences, func(i, j int) bool {
+		a, b := occurrences[i], occurrences[j]
+		return a.Line > b.Line || (a.Line == b.Line && a.Column > b.Column)
+	})
+
+	old := []rune(oldName)
+	for _, o := range occurrences {
+		if o.Line < 1 || o.Line > len(lines) {
+			return "", fmt.Errorf("Zeile %d gibt es nicht", o.Line)
+		}
+		text := []rune(lines[o.Line-1])
+		start, end := o.Column-1, o.Column-1+len(old)
+		if start < 0 || end > len(text) || string(text[start:end]) != oldName {
+			return "", fmt.Errorf("Zeile %d: '%s' nicht an der erwarteten Stelle", o.Line, oldName)
+		}
+		lines[o.Line-1] = string(text[:start]) + newName + string(text[end:])
+	}
+	return strings.Join(lines, "\n"), nil
+}
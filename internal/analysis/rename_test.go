@@ -0,0 +1,108 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRename(t *testing.T) {
+	files := []File{
+		{"hauptspiel.ben", `VAR x = 10
+FUNKTION schiebe(x, schritt) {
+    ZURUECK x + schritt
+}
+WENN_IMMER {
+    x = schiebe(x, 2)
+    ZEIGE_TEXT("x = " + x, 10, 10) // x anzeigen
+    FUER i VON 1 BIS 3 {
+        SCHREIBE(i)
+    }
+    FUER i VON 1 BIS 2 {
+        SCHREIBE(i * x)
+    }
+}
+`},
+		{"anzeige.ben", "WENN_START {\n    SCHREIBE(x)\n}\n"},
+	}
+	r := Analyze(files)
+
+	changed, err := r.Rename("hauptspiel.ben", 1, 5, "spielerX")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantMain := strings.Join([]string{
+		"VAR spielerX = 10",
+		"FUNKTION schiebe(x, schritt) {",
+		"    ZURUECK x + schritt",
+		"}",
+		"WENN_IMMER {",
+		"    spielerX = schiebe(spielerX, 2)",
+		`    ZEIGE_TEXT("x = " + spielerX, 10, 10) // x anzeigen`,
+	}, "\n")
+	if !strings.HasPrefix(changed["hauptspiel.ben"], wantMain) {
+		t.Errorf("unexpected result:\n%s", changed["hauptspiel.ben"])
+	}
+	if !strings.Contains(changed["hauptspiel.ben"], "SCHREIBE(i * spielerX)") {
+		t.Errorf("use inside loop not renamed:\n%s", changed["hauptspiel.ben"])
+	}
+	if changed["anzeige.ben"] != "WENN_START {\n    SCHREIBE(spielerX)\n}\n" {
+		t.Errorf("other file not renamed: %q", changed["anzeige.ben"])
+	}
+
+	// The parameter only changes inside its function
+	changed, err = r.Rename("hauptspiel.ben", 3, 13, "wert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(changed["hauptspiel.ben"], "FUNKTION schiebe(wert, schritt) {\n    ZURUECK wert + schritt") ||
+		!strings.Contains(changed["hauptspiel.ben"], "VAR x = 10") {
+		t.Errorf("unexpected result:\n%s", changed["hauptspiel.ben"])
+	}
+	if _, ok := changed["anzeige.ben"]; ok {
+		t.Error("unrelated file changed")
+	}
+
+	// Only the first loop variable is renamed
+	changed, err = r.Rename("hauptspiel.ben", 9, 18, "zahl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(changed["hauptspiel.ben"], "FUER zahl VON 1 BIS 3 {\n        SCHREIBE(zahl)") ||
+		!strings.Contains(changed["hauptspiel.ben"], "FUER i VON 1 BIS 2 {\n        SCHREIBE(i * x)") {
+		t.Errorf("unexpected result:\n%s", changed["hauptspiel.ben"])
+	}
+}
+
+func TestRenameErrors(t *testing.T) {
+	r := Analyze([]File{{"a.ben", `VAR x = 1
+VAR y = 2
+FUNKTION f(a) {
+    ZURUECK a + y
+}
+SCHREIBE("x")
+`}})
+
+	tests := []struct {
+		line, column int
+		name         string
+		want         string
+	}{
+		{1, 5, "y", "schon vergeben"},
+		{3, 12, "y", "schon vergeben"}, // the parameter would hide y
+		{1, 5, "WENN", "Schlüsselwort"},
+		{1, 5, "ZUFALL", "eingebauter Befehl"},
+		{1, 5, "neu name", "kein gültiger Name"},
+		{6, 12, "z", "kein Name"},
+	}
+	for _, tt := range tests {
+		_, err := r.Rename("a.ben", tt.line, tt.column, tt.name)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("rename at %d:%d to %q: got %v, want %q", tt.line, tt.column, tt.name, err, tt.want)
+		}
+	}
+
+	broken := Analyze([]File{{"a.ben", "VAR x = 1\nVAR = 2\n"}})
+	if _, err := broken.Rename("a.ben", 1, 5, "z"); err == nil || !strings.Contains(err.Error(), "Fehler") {
+		t.Errorf("expected refusal for code with syntax errors, got %v", err)
+	}
+}
@@ -1,63 +1,90 @@
 package auth
 
 import (
-	"crypto/rand"
-	"encoding/hex"
-	"encoding/json"
 	"errors"
-	"os"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
-const CredentialsFile = ".bencreds"
+// Role decides what a user may do in the web IDE
+type Role string
 
-type User struct {
-	Username string `json:"username"`
-	Password string `json:"password"` // Hashed
-}
+const (
+	RoleTeacher Role = "lehrer"   // manages users and sees every student's projects
+	RoleStudent Role = "schueler" // works on their own projects
+	RoleGuest   Role = "gast"     // looks around but cannot change anything
+)
 
-type Credentials struct {
-	Users    map[string]User   `json:"users"`
-	Sessions map[string]string `json:"-"` // token -> username
-	mu       sync.RWMutex
+// Roles lists all roles, teachers first
+var Roles = []Role{RoleTeacher, RoleStudent, RoleGuest}
+
+// ParseRole reads a role as typed by a person; "" means student
+func ParseRole(s string) (Role, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "lehrer", "lehrerin", "lehrkraft":
+		return RoleTeacher, nil
+	case "", "schueler", "schüler", "schuelerin", "schülerin":
+		return RoleStudent, nil
+	case "gast":
+		return RoleGuest, nil
+	}
+	return "", fmt.Errorf("Unbekannte Rolle '%s' (lehrer, schueler oder gast)", s)
 }
 
-func LoadCredentials() (*Credentials, error) {
-	creds := &Credentials{
-		Users:    make(map[string]User),
-		Sessions: make(map[string]string),
+// String returns the name of the role for people
+func (r Role) String() string {
+	switch r {
+	case RoleTeacher:
+		return "Lehrer"
+	case RoleGuest:
+		return "Gast"
 	}
+	return "Schüler"
+}
 
-	data, err := os.ReadFile(CredentialsFile)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return creds, nil
-		}
-		return nil, err
-	}
+type User struct {
+	Username string `json:"username"`
+	Password string `json:"password"` // Hashed
+	Role     Role   `json:"rolle,omitempty"`
+}
 
-	if err := json.Unmarshal(data, &creds.Users); err != nil {
-		return nil, err
+// UserRole returns the user's role. Users saved before there were roles
+// are students.
+func (u User) UserRole() Role {
+	if u.Role == "" {
+		return RoleStudent
 	}
-
-	return creds, nil
+	return u.Role
 }
 
-func (c *Credentials) Save() error {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+type Credentials struct {
+	Users        map[string]User     `json:"users"`
+	Sessions     map[string]*Session `json:"-"` // by sessionKey of the token
+	IdleTimeout  time.Duration       `json:"-"` // 0 keeps idle sessions
+	MaxAge       time.Duration       `json:"-"` // 0 keeps sessions forever
+	SessionsPath string              `json:"-"` // where sessions are saved, "" keeps them in memory
+	Path         string              `json:"-"` // where Save writes the users
+	mu           sync.RWMutex
+	now          func() time.Time // for tests
+}
 
-	data, err := json.MarshalIndent(c.Users, "", "  ")
-	if err != nil {
-		return err
+// NewCredentials returns credentials without users and with the default
+// session timeouts
+func NewCredentials() *Credentials {
+	return &Credentials{
+		Users:       make(map[string]User),
+		Sessions:    make(map[string]*Session),
+		IdleTimeout: DefaultIdleTimeout,
+		MaxAge:      DefaultMaxAge,
 	}
-
-	return os.WriteFile(CredentialsFile, data, 0600)
 }
 
-func (c *Credentials) AddUser(username, password string) error {
+func (c *Credentials) AddUser(username, password string, role Role) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -73,16 +100,20 @@ func (c *Credentials) AddUser(username, password string) error {
 	c.Users[username] = User{
 		Username: username,
 		Password: string(hashed),
+		Role:     role,
 	}
 
 	return nil
 }
 
+// UpdateUser sets a new password and logs the user out everywhere, so
+// whoever knew the old one is not left logged in
 func (c *Credentials) UpdateUser(username, password string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	if _, exists := c.Users[username]; !exists {
+	user, exists := c.Users[username]
+	if !exists {
 		return errors.New("Benutzer existiert nicht")
 	}
 
@@ -91,14 +122,48 @@ func (c *Credentials) UpdateUser(username, password string) error {
 		return err
 	}
 
-	c.Users[username] = User{
-		Username: username,
-		Password: string(hashed),
+	user.Password = string(hashed)
+	c.Users[username] = user
+	c.revokeSessions(username)
+	return c.saveSessions()
+}
+
+// SetRole changes the role of a user
+func (c *Credentials) SetRole(username string, role Role) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	user, exists := c.Users[username]
+	if !exists {
+		return errors.New("Benutzer existiert nicht")
 	}
 
+	user.Role = role
+	c.Users[username] = user
 	return nil
 }
 
+// Lookup returns a user
+func (c *Credentials) Lookup(username string) (User, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	user, exists := c.Users[username]
+	return user, exists
+}
+
+// List returns all users sorted by name
+func (c *Credentials) List() []User {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	users := make([]User, 0, len(c.Users))
+	for _, user := range c.Users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users
+}
+
 func (c *Credentials) DeleteUser(username string) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -108,7 +173,8 @@ func (c *Credentials) DeleteUser(username string) error {
 	}
 
 	delete(c.Users, username)
-	return nil
+	c.revokeSessions(username)
+	return c.saveSessions()
 }
 
 func (c *Credentials) Verify(username, password string) bool {
@@ -123,30 +189,3 @@ func (c *Credentials) Verify(username, password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
 	return err == nil
 }
-
-func (c *Credentials) CreateSession(username string) (string, error) {
-	b := make([]byte, 32)
-	if _, err := rand.Read(b); err != nil {
-		return "", err
-	}
-	token := hex.EncodeToString(b)
-
-	c.mu.Lock()
-	c.Sessions[token] = username
-	c.mu.Unlock()
-
-	return token, nil
-}
-
-func (c *Credentials) GetUsernameFromSession(token string) (string, bool) {
-	c.mu.RLock()
-	username, exists := c.Sessions[token]
-	c.mu.RUnlock()
-	return username, exists
-}
-
-func (c *Credentials) DeleteSession(token string) {
-	c.mu.Lock()
-	delete(c.Sessions, token)
-	c.mu.Unlock()
-}
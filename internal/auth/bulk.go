@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// passwordWords are short words without umlauts that children can spell
+var passwordWords = []string{
+	"affe", "apfel", "ball", "banane", "baum", "berg", "biene", "birne",
+	"blume", "boot", "burg", "drache", "eule", "fisch", "frosch", "fuchs",
+	"hase", "hund", "igel", "insel", "katze", "keks", "kirsche", "kuchen",
+	"maus", "mond", "nuss", "pferd", "pinguin", "rabe", "raupe", "regen",
+	"ritter", "robbe", "schnee", "sonne", "stern", "tiger", "turm", "vogel",
+	"wal", "wolke", "zebra", "zug",
+}
+
+// GeneratePassword returns a password children can type: two easy words
+// and a number, like "tiger-apfel-42"
+func GeneratePassword() (string, error) {
+	pick := func(n int) (int, error) {
+		i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+		if err != nil {
+			return 0, err
+		}
+		return int(i.Int64()), nil
+	}
+
+	first, err := pick(len(passwordWords))
+	if err != nil {
+		return "", err
+	}
+	second, err := pick(len(passwordWords) - 1)
+	if err != nil {
+		return "", err
+	}
+	if second >= first {
+		second++ // never the same word twice
+	}
+	number, err := pick(90)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s-%d", passwordWords[first], passwordWords[second], number+10), nil
+}
+
+// ImportEntry is one user of a class list
+type ImportEntry struct {
+	Line     int
+	Username string
+	Password string // "" gets a generated password
+	Role     Role
+}
+
+// ReadClassList reads a CSV class list with the columns benutzername,
+// passwort and rolle; only the first is needed. A header line and ";" as
+// separator, as spreadsheets write it in Germany, are recognized. Rows
+// without role get defaultRole.
+func ReadClassList(r io.Reader, defaultRole Role) ([]ImportEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.TrimPrefix(string(data), "\ufeff") // spreadsheets like to start with a BOM
+	firstLine, _, _ := strings.Cut(text, "\n")
+
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		reader.Comma = ';'
+	}
+
+	var entries []ImportEntry
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		for i := range record {
+			record[i] = strings.TrimSpace(record[i])
+		}
+		if line == 1 {
+			switch strings.ToLower(record[0]) {
+			case "benutzername", "benutzer", "name", "username":
+				continue
+			}
+		}
+		if len(record) == 0 || record[0] == "" {
+			continue
+		}
+
+		entry := ImportEntry{Line: line, Username: record[0], Role: defaultRole}
+		if len(record) > 1 {
+			entry.Password = record[1]
+		}
+		if len(record) > 2 && record[2] != "" {
+			if entry.Role, err = ParseRole(record[2]); err != nil {
+				return nil, fmt.Errorf("Zeile %d: %w", line, err)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// ImportResult is what became of one entry of a class list
+type ImportResult struct {
+	ImportEntry
+	Err error // nil if the user was created
+}
+
+// Import creates the users of a class list and generates the missing
+// passwords. Names that validName refuses and users that exist already
+// are left out.
+func (c *Credentials) Import(entries []ImportEntry, validName func(string) bool) []ImportResult {
+	results := make([]ImportResult, len(entries))
+	for i, entry := range entries {
+		results[i].ImportEntry = entry
+		if !validName(entry.Username) {
+			results[i].Err = fmt.Errorf("'%s' geht nicht als Benutzername", entry.Username)
+			continue
+		}
+		if entry.Password == "" {
+			password, err := GeneratePassword()
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			results[i].Password = password
+		}
+		results[i].Err = c.AddUser(entry.Username, results[i].Password, entry.Role)
+	}
+	return results
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestGeneratePassword(t *testing.T) {
+	format := regexp.MustCompile(`^([a-z]+)-([a-z]+)-[1-9][0-9]$`)
+	seen := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		password, err := GeneratePassword()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := format.FindStringSubmatch(password)
+		if m == nil || m[1] == m[2] {
+			t.Fatalf("password %q", password)
+		}
+		seen[password] = true
+	}
+	if len(seen) < 45 {
+		t.Errorf("only %d different passwords out of 50", len(seen))
+	}
+}
+
+func TestReadClassList(t *testing.T) {
+	tests := []struct {
+		name, csv string
+		want      []ImportEntry
+	}{
+		{"names only", "anna\nben\n\n", []ImportEntry{
+			{Line: 1, Username: "anna", Role: RoleStudent},
+			{Line: 2, Username: "ben", Role: RoleStudent},
+		}},
+		{"spreadsheet", "\ufeffBenutzername;Passwort;Rolle\r\nanna; pferd ;\r\nfrau-meier;tafel;Lehrerin\r\n", []ImportEntry{
+			{Line: 2, Username: "anna", Password: "pferd", Role: RoleStudent},
+			{Line: 3, Username: "frau-meier", Password: "tafel", Role: RoleTeacher},
+		}},
+		{"commas", "name,passwort\nanna,\"a,b\"\n", []ImportEntry{
+			{Line: 2, Username: "anna", Password: "a,b", Role: RoleStudent},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadClassList(strings.NewReader(tt.csv), RoleStudent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %+v", got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("entry %d: %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := ReadClassList(strings.NewReader("anna\nben,x,chef\n"), RoleStudent); err == nil || !strings.Contains(err.Error(), "Zeile 2") {
+		t.Errorf("unknown role: %v", err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	c := NewCredentials()
+	c.AddUser("anna", "pferd", RoleStudent)
+	entries := []ImportEntry{
+		{Line: 1, Username: "anna"},
+		{Line: 2, Username: "ben", Role: RoleStudent},
+		{Line: 3, Username: "../carla"},
+		{Line: 4, Username: "dora", Password: "eigenes", Role: RoleGuest},
+	}
+	results := c.Import(entries, func(name string) bool { return !strings.Contains(name, "/") })
+
+	if results[0].Err == nil || results[2].Err == nil {
+		t.Errorf("existing and invalid users were imported: %+v", results)
+	}
+	if results[1].Err != nil || results[1].Password == "" || !c.Verify("ben", results[1].Password) {
+		t.Errorf("ben: %+v", results[1])
+	}
+	if results[3].Err != nil || !c.Verify("dora", "eigenes") {
+		t.Errorf("dora: %+v", results[3])
+	}
+	if user, _ := c.Lookup("dora"); user.Role != RoleGuest {
+		t.Errorf("dora's role: %s", user.Role)
+	}
+	if !c.Verify("anna", "pferd") {
+		t.Error("anna's password changed")
+	}
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// CredentialsFile is the name older versions kept the users under, in
+// the folder the server was started from
+const CredentialsFile = ".bencreds"
+
+// CredentialsEnv names a credentials file for all commands
+const CredentialsEnv = "BENLANG_CREDS"
+
+// FormatVersion is the version of the credentials file written by Save.
+// Files without version are plain maps of users as older versions wrote
+// them.
+const FormatVersion = 1
+
+// credentialsFile is what Save writes
+type credentialsFile struct {
+	Version int             `json:"version"`
+	Users   map[string]User `json:"benutzer"`
+}
+
+// DefaultPath finds the credentials file when none is given: the one in
+// $BENLANG_CREDS, an existing .bencreds in workDir or the current folder
+// as older versions used, or else zugangsdaten.json in the user's config
+// folder, so that it does not matter where a command is started from.
+func DefaultPath(workDir string) (string, error) {
+	if path := os.Getenv(CredentialsEnv); path != "" {
+		return filepath.Abs(path)
+	}
+	for _, dir := range []string{workDir, "."} {
+		if dir == "" {
+			continue
+		}
+		path := filepath.Join(dir, CredentialsFile)
+		if _, err := os.Stat(path); err == nil {
+			return filepath.Abs(path)
+		}
+	}
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("Kein Ort für die Zugangsdaten gefunden, bitte mit --creds angeben: %w", err)
+	}
+	return filepath.Join(config, "benlang", "zugangsdaten.json"), nil
+}
+
+// LoadCredentials reads the users from path. A missing file means no
+// users yet; Save creates it.
+func LoadCredentials(path string) (*Credentials, error) {
+	creds := NewCredentials()
+	creds.Path = path
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return creds, nil
+		}
+		return nil, err
+	}
+
+	users, err := parseCredentials(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	creds.Users = users
+	return creds, nil
+}
+
+func parseCredentials(data []byte) (map[string]User, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	// A user called "version" in an old file is an object, not a number
+	var version int
+	if raw, ok := fields["version"]; !ok || json.Unmarshal(raw, &version) != nil {
+		users := map[string]User{}
+		return users, json.Unmarshal(data, &users)
+	}
+	if version > FormatVersion {
+		return nil, fmt.Errorf("Die Datei ist von einer neueren BenLang-Version (Format %d)", version)
+	}
+
+	file := credentialsFile{Users: map[string]User{}}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Users == nil {
+		file.Users = map[string]User{}
+	}
+	return file.Users, nil
+}
+
+// Save writes the users to Path in the current format
+func (c *Credentials) Save() error {
+	if c.Path == "" {
+		return errors.New("Kein Speicherort für die Zugangsdaten")
+	}
+
+	c.mu.RLock()
+	data, err := json.MarshalIndent(credentialsFile{FormatVersion, c.Users}, "", "  ")
+	c.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0700); err != nil {
+		return err
+	}
+	return WriteFileAtomic(c.Path, data, 0600)
+}
+
+// WriteFileAtomic replaces a file so that readers and a crash in between
+// see either the old or the new content, never half of it
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails once renamed
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Beside returns the path of a file next to the credentials file, like
+// the saved sessions and the audit log
+func (c *Credentials) Beside(name string) string {
+	return filepath.Join(filepath.Dir(c.Path), name)
+}
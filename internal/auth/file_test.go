@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadOldCredentialsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), CredentialsFile)
+	// Older versions wrote a plain map; a user may even be called "version"
+	old := `{"anna": {"username": "anna", "password": "x"}, "version": {"username": "version", "password": "y", "rolle": "gast"}}`
+	os.WriteFile(path, []byte(old), 0600)
+
+	creds, err := LoadCredentials(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, ok := creds.Lookup("version"); !ok || user.Role != RoleGuest || len(creds.Users) != 2 {
+		t.Fatalf("users: %+v", creds.Users)
+	}
+
+	// Saving upgrades the file
+	if err := creds.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), `"version": 1`) || !strings.Contains(string(data), `"benutzer"`) {
+		t.Errorf("saved file:\n%s", data)
+	}
+	again, err := LoadCredentials(path)
+	if err != nil || len(again.Users) != 2 || again.Users["anna"].Password != "x" {
+		t.Errorf("reloaded: %v, %+v", err, again.Users)
+	}
+}
+
+func TestCredentialsFileVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zugangsdaten.json")
+	os.WriteFile(path, []byte(`{"version": 99, "benutzer": {}}`), 0600)
+	if _, err := LoadCredentials(path); err == nil || !strings.Contains(err.Error(), "neueren") {
+		t.Errorf("newer format: %v", err)
+	}
+
+	creds, err := LoadCredentials(filepath.Join(t.TempDir(), "fehlt", "zugangsdaten.json"))
+	if err != nil || len(creds.Users) != 0 {
+		t.Fatalf("missing file: %v", err)
+	}
+	creds.AddUser("anna", "pferd", RoleStudent)
+	if err := creds.Save(); err != nil {
+		t.Fatalf("save into a new folder: %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(creds.Path))
+	if len(entries) != 1 {
+		t.Errorf("files left next to the credentials: %v", entries)
+	}
+	if info, _ := os.Stat(creds.Path); info.Mode().Perm() != 0600 {
+		t.Errorf("permissions %v", info.Mode().Perm())
+	}
+
+	if err := NewCredentials().Save(); err == nil {
+		t.Error("saved without a path")
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	home, workDir := t.TempDir(), t.TempDir()
+	t.Chdir(t.TempDir())
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("AppData", filepath.Join(home, "AppData"))
+	t.Setenv(CredentialsEnv, "")
+
+	config, _ := os.UserConfigDir()
+	if path, err := DefaultPath(workDir); err != nil || path != filepath.Join(config, "benlang", "zugangsdaten.json") {
+		t.Errorf("without files: %q, %v", path, err)
+	}
+
+	os.WriteFile(CredentialsFile, []byte("{}"), 0600)
+	here, _ := filepath.Abs(CredentialsFile)
+	if path, _ := DefaultPath(workDir); path != here {
+		t.Errorf("old file in the current folder: %q", path)
+	}
+	os.WriteFile(filepath.Join(workDir, CredentialsFile), []byte("{}"), 0600)
+	if path, _ := DefaultPath(workDir); path != filepath.Join(workDir, CredentialsFile) {
+		t.Errorf("old file in the workdir: %q", path)
+	}
+
+	t.Setenv(CredentialsEnv, "klasse.json")
+	if path, _ := DefaultPath(workDir); !filepath.IsAbs(path) || filepath.Base(path) != "klasse.json" {
+		t.Errorf("from the environment: %q", path)
+	}
+}
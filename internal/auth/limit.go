@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditFile is where the server writes down logins, wrong passwords,
+// lockouts and unlocks
+const AuditFile = ".benaudit.log"
+
+// Limiter slows down guessing passwords. After a few wrong passwords for
+// a user or from an address, each further try has to wait twice as long
+// as the last one. After too many, the account is locked for a while or
+// until a teacher unlocks it.
+type Limiter struct {
+	UserAttempts int           // wrong passwords for a user before waiting
+	AddrAttempts int           // wrong passwords from an address before waiting; a class may share one
+	BaseDelay    time.Duration // the first wait
+	MaxDelay     time.Duration // waits grow up to this
+	LockAfter    int           // wrong passwords in a row that lock the account, 0 never locks
+	LockFor      time.Duration
+	Audit        *log.Logger // nil writes no audit log
+
+	mu       sync.Mutex
+	attempts map[string]*attempt // by "benutzer:<name>" or "adresse:<ip>"
+	now      func() time.Time    // for tests
+}
+
+// forgetAfter is how long wrong passwords count
+const forgetAfter = 24 * time.Hour
+
+type attempt struct {
+	failures int
+	last     time.Time
+	blocked  time.Time // no tries before this
+	locked   bool      // blocked because of LockAfter
+}
+
+// NewLimiter returns a limiter with settings for a classroom
+func NewLimiter() *Limiter {
+	return &Limiter{
+		UserAttempts: 3,
+		AddrAttempts: 10,
+		BaseDelay:    time.Second,
+		MaxDelay:     5 * time.Minute,
+		LockAfter:    10,
+		LockFor:      15 * time.Minute,
+		attempts:     make(map[string]*attempt),
+	}
+}
+
+// LockedError is returned for a login that has to wait
+type LockedError struct {
+	Wait   time.Duration
+	Locked bool // the account is locked, not just slowed down
+}
+
+func (e *LockedError) Error() string {
+	seconds := int((e.Wait + time.Second - 1) / time.Second)
+	if e.Locked {
+		return fmt.Sprintf("Zu viele falsche Passwörter: Das Konto ist für %d Minuten gesperrt. Deine Lehrkraft kann es entsperren.", (seconds+59)/60)
+	}
+	return fmt.Sprintf("Zu viele falsche Versuche. Bitte warte %d Sekunden.", seconds)
+}
+
+func (l *Limiter) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
+
+func (l *Limiter) logf(format string, args ...interface{}) {
+	if l.Audit != nil {
+		l.Audit.Printf(format, args...)
+	}
+}
+
+// Check returns a *LockedError if a login for the user from the address
+// has to wait, or nil if it may try
+func (l *Limiter) Check(username, addr string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock()
+	var refused *LockedError
+	for _, key := range []string{"benutzer:" + username, "adresse:" + addr} {
+		a, exists := l.attempts[key]
+		if !exists || !now.Before(a.blocked) {
+			continue
+		}
+		if refused == nil || a.blocked.Sub(now) > refused.Wait {
+			refused = &LockedError{Wait: a.blocked.Sub(now), Locked: a.locked}
+		}
+	}
+	if refused != nil {
+		l.logf("abgewiesen benutzer=%q adresse=%s warten=%s", username, addr, refused.Wait.Round(time.Second))
+		return refused
+	}
+	return nil
+}
+
+// Fail records a wrong password
+func (l *Limiter) Fail(username, addr string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock()
+	for key, a := range l.attempts {
+		if now.Sub(a.last) > forgetAfter && !now.Before(a.blocked) {
+			delete(l.attempts, key)
+		}
+	}
+	user := l.fail("benutzer:"+username, l.UserAttempts, now)
+	l.fail("adresse:"+addr, l.AddrAttempts, now)
+	l.logf("falsches-passwort benutzer=%q adresse=%s fehlversuche=%d", username, addr, user.failures)
+
+	if l.LockAfter > 0 && user.failures >= l.LockAfter && !user.locked {
+		user.locked = true
+		user.blocked = now.Add(l.LockFor)
+		l.logf("gesperrt benutzer=%q bis=%s", username, user.blocked.Format(time.RFC3339))
+	}
+}
+
+func (l *Limiter) fail(key string, free int, now time.Time) *attempt {
+	a, exists := l.attempts[key]
+	if !exists {
+		a = &attempt{}
+		l.attempts[key] = a
+	}
+	a.failures++
+	a.last = now
+	if a.locked && !now.Before(a.blocked) {
+		// The next wrong password after a lockout locks again
+		a.locked = false
+	}
+	if a.locked || a.failures <= free {
+		return a
+	}
+
+	delay := l.BaseDelay
+	for i := free + 1; i < a.failures && delay < l.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > l.MaxDelay {
+		delay = l.MaxDelay
+	}
+	a.blocked = now.Add(delay)
+	return a
+}
+
+// Succeed records a login and forgets the wrong passwords for the user.
+// Those from the address still count: otherwise a child could log into
+// their own account between guesses at other accounts.
+func (l *Limiter) Succeed(username, addr string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.attempts, "benutzer:"+username)
+	l.logf("angemeldet benutzer=%q adresse=%s", username, addr)
+}
+
+// Unlock lets a user try again right away. It reports whether the user
+// had to wait.
+func (l *Limiter) Unlock(username, by string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a, exists := l.attempts["benutzer:"+username]
+	delete(l.attempts, "benutzer:"+username)
+	waiting := exists && l.clock().Before(a.blocked)
+	l.logf("entsperrt benutzer=%q von=%q", username, by)
+	return waiting
+}
+
+// LockedUser is an account that has to wait before the next login
+type LockedUser struct {
+	Username string    `json:"benutzername"`
+	Until    time.Time `json:"bis"`
+	Locked   bool      `json:"gesperrt"` // locked, not just slowed down
+}
+
+// Locked returns the accounts that have to wait, sorted by name
+func (l *Limiter) Locked() []LockedUser {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock()
+	locked := []LockedUser{}
+	for key, a := range l.attempts {
+		name, isUser := strings.CutPrefix(key, "benutzer:")
+		if isUser && now.Before(a.blocked) {
+			locked = append(locked, LockedUser{name, a.blocked, a.locked})
+		}
+	}
+	sort.Slice(locked, func(i, j int) bool { return locked[i].Username < locked[j].Username })
+	return locked
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLimiter() (*Limiter, *time.Time, *bytes.Buffer) {
+	l := NewLimiter()
+	now := time.Date(2026, 9, 14, 8, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	var audit bytes.Buffer
+	l.Audit = log.New(&audit, "", 0)
+	return l, &now, &audit
+}
+
+// waitFor returns how long the next try has to wait, 0 if it may try
+func waitFor(l *Limiter, user, addr string) time.Duration {
+	var refused *LockedError
+	if errors.As(l.Check(user, addr), &refused) {
+		return refused.Wait
+	}
+	return 0
+}
+
+func TestLimiterBacksOff(t *testing.T) {
+	l, now, _ := newTestLimiter()
+
+	var waits []time.Duration
+	for i := 0; i < 6; i++ {
+		l.Fail("anna", "10.0.0.5")
+		waits = append(waits, waitFor(l, "anna", "10.0.0.5"))
+		*now = now.Add(waits[i])
+	}
+	want := []time.Duration{0, 0, 0, time.Second, 2 * time.Second, 4 * time.Second}
+	for i := range want {
+		if waits[i] != want[i] {
+			t.Errorf("wait after %d wrong passwords: %v, want %v", i+1, waits[i], want[i])
+		}
+	}
+
+	// Other children at the same computer are not slowed down yet
+	if wait := waitFor(l, "ben", "10.0.0.5"); wait != 0 {
+		t.Errorf("ben waits %v", wait)
+	}
+	l.Succeed("anna", "10.0.0.5")
+	l.Fail("anna", "10.0.0.5")
+	if wait := waitFor(l, "anna", "10.0.0.5"); wait != 0 {
+		t.Errorf("after a login anna waits %v", wait)
+	}
+}
+
+func TestLimiterPerAddress(t *testing.T) {
+	l, now, _ := newTestLimiter()
+	for i := 0; i < l.AddrAttempts+1; i++ {
+		*now = now.Add(time.Minute)
+		l.Fail("kind"+string(rune('a'+i)), "10.0.0.9")
+	}
+	if wait := waitFor(l, "neu", "10.0.0.9"); wait == 0 {
+		t.Error("guessing many names from one address is not slowed down")
+	}
+	if wait := waitFor(l, "neu", "10.0.0.10"); wait != 0 {
+		t.Errorf("other address waits %v", wait)
+	}
+
+	// Logging into an own account in between does not reset the address
+	*now = now.Add(time.Hour)
+	l.Succeed("kinda", "10.0.0.9")
+	l.Fail("frau-meier", "10.0.0.9")
+	if wait := waitFor(l, "neu", "10.0.0.9"); wait == 0 {
+		t.Error("a successful login resets the address")
+	}
+}
+
+func TestLockoutAndUnlock(t *testing.T) {
+	l, now, audit := newTestLimiter()
+	l.LockAfter = 5
+	for i := 0; i < 5; i++ {
+		*now = now.Add(time.Hour) // whatever the backoff was
+		l.Fail("anna", "10.0.0."+string(rune('1'+i)))
+	}
+
+	var refused *LockedError
+	if !errors.As(l.Check("anna", "10.0.0.99"), &refused) || !refused.Locked || refused.Wait != l.LockFor {
+		t.Fatalf("anna is not locked: %v", refused)
+	}
+	if !strings.Contains(refused.Error(), "15 Minuten gesperrt") {
+		t.Errorf("message: %s", refused.Error())
+	}
+	if locked := l.Locked(); len(locked) != 1 || locked[0].Username != "anna" || !locked[0].Locked {
+		t.Errorf("locked accounts: %v", locked)
+	}
+
+	if !l.Unlock("anna", "frau-meier") {
+		t.Error("unlock did not find the lock")
+	}
+	if err := l.Check("anna", "10.0.0.99"); err != nil {
+		t.Errorf("after unlock: %v", err)
+	}
+
+	for _, entry := range []string{`falsches-passwort benutzer="anna"`, `gesperrt benutzer="anna"`, `abgewiesen benutzer="anna"`, `entsperrt benutzer="anna" von="frau-meier"`} {
+		if !strings.Contains(audit.String(), entry) {
+			t.Errorf("audit log misses %q:\n%s", entry, audit.String())
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// SessionsFile keeps the logins across server restarts if the server is
+// told to remember them
+const SessionsFile = ".bensessions"
+
+const (
+	DefaultIdleTimeout = 2 * time.Hour  // a forgotten browser is logged out after a break
+	DefaultMaxAge      = 12 * time.Hour // and after a school day at the latest
+)
+
+// Session is a login of a user in one browser
+type Session struct {
+	Username string    `json:"benutzername"`
+	Created  time.Time `json:"erstellt"`
+	LastSeen time.Time `json:"zuletztAktiv"`
+}
+
+// expired reports whether the session ran out at the given time. A zero
+// timeout never runs out.
+func (s *Session) expired(now time.Time, idle, maxAge time.Duration) bool {
+	return idle > 0 && now.Sub(s.LastSeen) > idle || maxAge > 0 && now.Sub(s.Created) > maxAge
+}
+
+// sessionKey is what sessions are stored under, so that the tokens
+// themselves never end up on disk
+func sessionKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CSRFToken returns the token pages have to send along with changes, so
+// that other websites cannot make changes in the name of a logged-in
+// user. It belongs to the session token and needs no storage.
+func CSRFToken(sessionToken string) string {
+	mac := hmac.New(sha256.New, []byte(sessionToken))
+	mac.Write([]byte("benlang-csrf"))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// LoadSessions reads the sessions saved in path, and from now on saves
+// them there whenever they change. A missing file means no sessions.
+func (c *Credentials) LoadSessions(path string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.SessionsPath = path
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	sessions := map[string]*Session{}
+	if err := json.Unmarshal(data, &sessions); err != nil {
+		return err
+	}
+	now := c.clock()
+	for key, session := range sessions {
+		if _, exists := c.Users[session.Username]; exists && !session.expired(now, c.IdleTimeout, c.MaxAge) {
+			c.Sessions[key] = session
+		}
+	}
+	return nil
+}
+
+// saveSessions writes the sessions if they are persisted. The caller holds
+// c.mu.
+func (c *Credentials) saveSessions() error {
+	if c.SessionsPath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(c.Sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(c.SessionsPath, data, 0600)
+}
+
+func (c *Credentials) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func (c *Credentials) CreateSession(username string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock()
+	c.Sessions[sessionKey(token)] = &Session{Username: username, Created: now, LastSeen: now}
+	if err := c.saveSessions(); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// GetUsernameFromSession returns who is logged in with the token and
+// counts the request as activity. Expired sessions are removed.
+func (c *Credentials) GetUsernameFromSession(token string) (string, bool) {
+	key := sessionKey(token)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	session, exists := c.Sessions[key]
+	if !exists {
+		return "", false
+	}
+	now := c.clock()
+	if session.expired(now, c.IdleTimeout, c.MaxAge) {
+		delete(c.Sessions, key)
+		return "", false
+	}
+	session.LastSeen = now
+	return session.Username, true
+}
+
+func (c *Credentials) DeleteSession(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.Sessions, sessionKey(token))
+	c.saveSessions()
+}
+
+// RevokeSessions logs a user out in every browser and returns how many
+// sessions were ended
+func (c *Credentials) RevokeSessions(username string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.revokeSessions(username)
+	return n, c.saveSessions()
+}
+
+func (c *Credentials) revokeSessions(username string) int {
+	n := 0
+	for key, session := range c.Sessions {
+		if session.Username == username {
+			delete(c.Sessions, key)
+			n++
+		}
+	}
+	return n
+}
+
+// CountSessions returns how many browsers a user is logged in with
+func (c *Credentials) CountSessions(username string) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	now := c.clock()
+	n := 0
+	for _, session := range c.Sessions {
+		if session.Username == username && !session.expired(now, c.IdleTimeout, c.MaxAge) {
+			n++
+		}
+	}
+	return n
+}
+
+// CleanSessions removes expired sessions and saves the activity of the
+// others. It returns how many were removed.
+func (c *Credentials) CleanSessions() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.clock()
+	n := 0
+	for key, session := range c.Sessions {
+		if session.expired(now, c.IdleTimeout, c.MaxAge) {
+			delete(c.Sessions, key)
+			n++
+		}
+	}
+	return n, c.saveSessions()
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestCredentials has the users anna and ben and a clock the test moves
+func newTestCredentials(t *testing.T) (*Credentials, *time.Time) {
+	c := NewCredentials()
+	now := time.Date(2026, 9, 14, 8, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	for _, name := range []string{"anna", "ben"} {
+		c.Users[name] = User{Username: name}
+	}
+	return c, &now
+}
+
+func TestSessionTimeouts(t *testing.T) {
+	c, now := newTestCredentials(t)
+	c.IdleTimeout, c.MaxAge = time.Hour, 3*time.Hour
+
+	token, err := c.CreateSession("anna")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Working keeps the session alive, up to the maximum age
+	for i := 0; i < 5; i++ {
+		*now = now.Add(50 * time.Minute)
+		name, ok := c.GetUsernameFromSession(token)
+		if want := i < 3; ok != want || ok && name != "anna" {
+			t.Errorf("after %v: %q, %v", time.Duration(i+1)*50*time.Minute, name, ok)
+		}
+	}
+	if len(c.Sessions) != 0 {
+		t.Error("expired session was not removed")
+	}
+
+	token, _ = c.CreateSession("anna")
+	*now = now.Add(61 * time.Minute)
+	if _, ok := c.GetUsernameFromSession(token); ok {
+		t.Error("idle session is still valid")
+	}
+
+	c.IdleTimeout, c.MaxAge = 0, 0
+	token, _ = c.CreateSession("anna")
+	*now = now.Add(1000 * time.Hour)
+	if _, ok := c.GetUsernameFromSession(token); !ok {
+		t.Error("session without timeouts expired")
+	}
+}
+
+func TestRevokeAndCleanSessions(t *testing.T) {
+	c, now := newTestCredentials(t)
+	first, _ := c.CreateSession("anna")
+	c.CreateSession("anna")
+	other, _ := c.CreateSession("ben")
+	if n := c.CountSessions("anna"); n != 2 {
+		t.Errorf("anna has %d sessions", n)
+	}
+
+	if n, err := c.RevokeSessions("anna"); n != 2 || err != nil {
+		t.Errorf("revoked %d sessions: %v", n, err)
+	}
+	if _, ok := c.GetUsernameFromSession(first); ok {
+		t.Error("revoked session is still valid")
+	}
+	if _, ok := c.GetUsernameFromSession(other); !ok {
+		t.Error("ben was logged out too")
+	}
+
+	// A new password logs out whoever knew the old one
+	stolen, _ := c.CreateSession("anna")
+	if err := c.UpdateUser("anna", "neu"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.GetUsernameFromSession(stolen); ok {
+		t.Error("session is still valid after a new password")
+	}
+
+	c.CreateSession("anna")
+	*now = now.Add(c.IdleTimeout - time.Minute)
+	c.GetUsernameFromSession(other)
+	*now = now.Add(2 * time.Minute)
+	if n, _ := c.CleanSessions(); n != 1 || c.CountSessions("ben") != 1 {
+		t.Errorf("cleaned %d sessions, ben has %d", n, c.CountSessions("ben"))
+	}
+}
+
+func TestPersistedSessions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), SessionsFile)
+	c, now := newTestCredentials(t)
+	if err := c.LoadSessions(path); err != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+	anna, _ := c.CreateSession("anna")
+	ben, _ := c.CreateSession("ben")
+
+	data, err := os.ReadFile(path)
+	if err != nil || strings.Contains(string(data), anna) {
+		t.Fatalf("saved sessions: %v: %s", err, data)
+	}
+
+	// After a restart anna is still logged in; ben was deleted meanwhile
+	restarted, _ := newTestCredentials(t)
+	restarted.now = func() time.Time { return *now }
+	delete(restarted.Users, "ben")
+	if err := restarted.LoadSessions(path); err != nil {
+		t.Fatal(err)
+	}
+	if name, ok := restarted.GetUsernameFromSession(anna); !ok || name != "anna" {
+		t.Errorf("anna after restart: %q, %v", name, ok)
+	}
+	if _, ok := restarted.GetUsernameFromSession(ben); ok {
+		t.Error("session of a deleted user was loaded")
+	}
+
+	restarted.DeleteSession(anna)
+	c, _ = newTestCredentials(t)
+	c.LoadSessions(path)
+	if _, ok := c.GetUsernameFromSession(anna); ok {
+		t.Error("logout was not saved")
+	}
+}
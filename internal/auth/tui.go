@@ -4,12 +4,13 @@ import (
 	"bufio"
 	"fmt"
 	"os"
-	"sort"
 	"strings"
 )
 
-func HandleUserManagement() {
-	creds, err := LoadCredentials()
+// HandleUserManagement is a menu to manage the users in the credentials
+// file at path
+func HandleUserManagement(path string) {
+	creds, err := LoadCredentials(path)
 	if err != nil {
 		fmt.Printf("Fehler beim Laden der Zugangsdaten: %v\n", err)
 		return
@@ -19,6 +20,7 @@ func HandleUserManagement() {
 
 	fmt.Println("👤 BenLang Benutzerverwaltung")
 	fmt.Println("---------------------------")
+	fmt.Printf("Zugangsdaten: %s\n", path)
 
 	for {
 		fmt.Println("\nWas möchtest du tun?")
@@ -26,6 +28,7 @@ func HandleUserManagement() {
 		fmt.Println("2) Neuen Benutzer anlegen")
 		fmt.Println("3) Passwort ändern")
 		fmt.Println("4) Benutzer löschen")
+		fmt.Println("5) Rolle ändern")
 		fmt.Println("q) Beenden")
 		fmt.Print("\nAuswahl: ")
 
@@ -41,6 +44,8 @@ func HandleUserManagement() {
 			updatePassword(creds, reader)
 		case "4":
 			deleteUser(creds, reader)
+		case "5":
+			changeRole(creds, reader)
 		case "q", "quit", "exit":
 			return
 		default:
@@ -50,22 +55,28 @@ func HandleUserManagement() {
 }
 
 func listUsers(c *Credentials) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-
-	if len(c.Users) == 0 {
+	users := c.List()
+	if len(users) == 0 {
 		fmt.Println("Keine Benutzer gefunden.")
 		return
 	}
 
 	fmt.Println("\nRegistrierte Benutzer:")
-	var names []string
-	for name := range c.Users {
-		names = append(names, name)
+	for _, user := range users {
+		fmt.Printf("- %s (%s)\n", user.Username, user.UserRole())
 	}
-	sort.Strings(names)
-	for _, name := range names {
-		fmt.Printf("- %s\n", name)
+}
+
+// readRole asks for a role until a valid one is given
+func readRole(r *bufio.Reader) Role {
+	for {
+		fmt.Print("Rolle (lehrer/schueler/gast) [schueler]: ")
+		input, _ := r.ReadString('\n')
+		role, err := ParseRole(input)
+		if err == nil {
+			return role
+		}
+		fmt.Printf("❌ %v\n", err)
 	}
 }
 
@@ -83,7 +94,9 @@ func addUser(c *Credentials, r *bufio.Reader) {
 	password, _ := r.ReadString('\n')
 	password = strings.TrimSpace(password)
 
-	if err := c.AddUser(username, password); err != nil {
+	role := readRole(r)
+
+	if err := c.AddUser(username, password, role); err != nil {
 		fmt.Printf("❌ Fehler: %v\n", err)
 		return
 	}
@@ -144,3 +157,23 @@ func deleteUser(c *Credentials, r *bufio.Reader) {
 
 	fmt.Printf("✅ Benutzer '%s' wurde gelöscht.\n", username)
 }
+
+func changeRole(c *Credentials, r *bufio.Reader) {
+	fmt.Print("Benutzername: ")
+	username, _ := r.ReadString('\n')
+	username = strings.TrimSpace(username)
+
+	role := readRole(r)
+
+	if err := c.SetRole(username, role); err != nil {
+		fmt.Printf("❌ Fehler: %v\n", err)
+		return
+	}
+
+	if err := c.Save(); err != nil {
+		fmt.Printf("❌ Fehler beim Speichern: %v\n", err)
+		return
+	}
+
+	fmt.Printf("✅ '%s' ist jetzt %s.\n", username, role)
+}
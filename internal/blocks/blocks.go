@@ -0,0 +1,767 @@
+package blocks
+
+import (
+	"benlang/internal/formatter"
+	"benlang/internal/lexer"
+	"benlang/internal/parser"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Workspace is a block workspace in the JSON format of Blockly's
+// serialization API, so the IDE can load it directly
+type Workspace struct {
+	Blocks TopBlocks `json:"blocks"`
+}
+
+// TopBlocks holds the blocks that are not plugged into another block
+type TopBlocks struct {
+	LanguageVersion int      `json:"languageVersion"`
+	Blocks          []*Block `json:"blocks"`
+}
+
+// Block is a single statement or expression block
+type Block struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id,omitempty"`
+	X          int                    `json:"x,omitempty"`
+	Y          int                    `json:"y,omitempty"`
+	ExtraState map[string]interface{} `json:"extraState,omitempty"`
+	Fields     map[string]string      `json:"fields,omitempty"`
+	Inputs     map[string]*Connection `json:"inputs,omitempty"`
+	Next       *Connection            `json:"next,omitempty"`
+}
+
+// Connection plugs a block into an input or below another block
+type Connection struct {
+	Block *Block `json:"block"`
+}
+
+// Statement block types
+const (
+	TypeSpiel         = "ben_spiel"
+	TypeVariable      = "ben_variable"
+	TypeFigur         = "ben_figur"
+	TypeFunktion      = "ben_funktion"
+	TypeZurueck       = "ben_zurueck"
+	TypeAnweisung     = "ben_anweisung"
+	TypeWenn          = "ben_wenn"
+	TypeSolange       = "ben_solange"
+	TypeFuer          = "ben_fuer"
+	TypeWiederhole    = "ben_wiederhole"
+	TypeWennStart     = "ben_wenn_start"
+	TypeWennImmer     = "ben_wenn_immer"
+	TypeWennTaste     = "ben_wenn_taste"
+	TypeWennKollision = "ben_wenn_kollision"
+	TypeKommentar     = "ben_kommentar"
+)
+
+// Expression block types
+const (
+	TypeZahl          = "ben_zahl"
+	TypeText          = "ben_text"
+	TypeWahrheitswert = "ben_wahrheitswert"
+	TypeName          = "ben_name"
+	TypeListe         = "ben_liste"
+	TypeIndex         = "ben_index"
+	TypePraefix       = "ben_praefix"
+	TypeRechnung      = "ben_rechnung"
+	TypeAufruf        = "ben_aufruf"
+	TypeEigenschaft   = "ben_eigenschaft"
+	TypeZuweisung     = "ben_zuweisung"
+	TypeKlammer       = "ben_klammer"
+)
+
+// Input names and extra state keys shared by several block types
+const (
+	inputStatements = "DO"
+	inputElse       = "SONST"
+	stateBlankLine  = "leerzeile"
+	stateKeyword    = "wort"
+	stateTrailing   = "angehaengt"
+	stateElseIf     = "sonstWenn"
+	stateParameters = "parameter"
+	stateArguments  = "argumente"
+	stateElements   = "elemente"
+)
+
+// Position of the stack created by FromProgram
+const topBlockX, topBlockY = 20, 20
+
+// eventTypes maps event handler blocks to the parser's event names
+var eventTypes = map[string]string{
+	TypeWennStart:     "start",
+	TypeWennImmer:     "immer",
+	TypeWennTaste:     "taste",
+	TypeWennKollision: "kollision",
+}
+
+// FromSource parses BenLang code and converts it to a workspace
+func FromSource(code string) (*Workspace, []parser.ParseError) {
+	p := parser.New(lexer.New(code))
+	program := p.ParseProgram()
+	if len(p.ErrorList()) > 0 {
+		return nil, p.ErrorList()
+	}
+	return FromProgram(program), nil
+}
+
+// ToSource converts a workspace back to formatted BenLang code
+func ToSource(ws *Workspace) (string, error) {
+	program, err := ToProgram(ws)
+	if err != nil {
+		return "", err
+	}
+	return formatter.Format(program), nil
+}
+
+// FromProgram converts a program to a workspace. All top-level statements
+// form one stack so their order is kept.
+func FromProgram(program *parser.Program) *Workspace {
+	c := &fromConverter{}
+	ws := &Workspace{Blocks: TopBlocks{Blocks: []*Block{}}}
+
+	if head := c.chain(program.Statements); head != nil {
+		head.X, head.Y = topBlockX, topBlockY
+		ws.Blocks.Blocks = append(ws.Blocks.Blocks, head)
+	}
+	return ws
+}
+
+// fromConverter turns AST nodes into blocks with sequential IDs
+type fromConverter struct {
+	nextID int
+}
+
+func (c *fromConverter) newBlock(typ string) *Block {
+	c.nextID++
+	return &Block{Type: typ, ID: "b" + strconv.Itoa(c.nextID)}
+}
+
+func (b *Block) setField(name, value string) {
+	if b.Fields == nil {
+		b.Fields = map[string]string{}
+	}
+	b.Fields[name] = value
+}
+
+func (b *Block) setInput(name string, child *Block) {
+	if child == nil {
+		return
+	}
+	if b.Inputs == nil {
+		b.Inputs = map[string]*Connection{}
+	}
+	b.Inputs[name] = &Connection{Block: child}
+}
+
+func (b *Block) setState(key string, value interface{}) {
+	if b.ExtraState == nil {
+		b.ExtraState = map[string]interface{}{}
+	}
+	b.ExtraState[key] = value
+}
+
+// setKeyword remembers a keyword spelling that differs from the canonical one
+func (b *Block) setKeyword(tok lexer.Token, canonical string) {
+	if tok.Literal != "" && tok.Literal != canonical {
+		b.setState(stateKeyword, tok.Literal)
+	}
+}
+
+// chain converts a statement list into blocks linked through Next
+func (c *fromConverter) chain(stmts []parser.Statement) *Block {
+	var head, tail *Block
+	for i, stmt := range stmts {
+		b := c.statement(stmt)
+		if b == nil {
+			continue
+		}
+		if i > 0 && formatter.NeedsBlankLine(stmts[i-1], stmt) {
+			b.setState(stateBlankLine, true)
+		}
+		if head == nil {
+			head = b
+		} else {
+			tail.Next = &Connection{Block: b}
+		}
+		tail = b
+	}
+	return head
+}
+
+func (c *fromConverter) body(b *Block, name string, block *parser.BlockStatement) {
+	if block != nil {
+		b.setInput(name, c.chain(block.Statements))
+	}
+}
+
+func (c *fromConverter) statement(stmt parser.Statement) *Block {
+	switch s := stmt.(type) {
+	case *parser.GameDeclaration:
+		b := c.newBlock(TypeSpiel)
+		b.setKeyword(s.Token, "SPIEL")
+		b.setField("NAME", s.Name)
+		return b
+	case *parser.VariableDeclaration:
+		b := c.newBlock(TypeVariable)
+		b.setKeyword(s.Token, "VAR")
+		b.setField("NAME", s.Name.Value)
+		b.setInput("WERT", c.expression(s.Value))
+		return b
+	case *parser.FigurDeclaration:
+		b := c.newBlock(TypeFigur)
+		b.setKeyword(s.Token, "FIGUR")
+		b.setField("NAME", s.Name.Value)
+		b.setInput("WERT", c.expression(s.Value))
+		return b
+	case *parser.FunctionDeclaration:
+		b := c.newBlock(TypeFunktion)
+		b.setKeyword(s.Token, "FUNKTION")
+		b.setField("NAME", s.Name.Value)
+		params := make([]string, len(s.Parameters))
+		for i, param := range s.Parameters {
+			params[i] = param.Value
+		}
+		b.setState(stateParameters, params)
+		c.body(b, inputStatements, s.Body)
+		return b
+	case *parser.ReturnStatement:
+		b := c.newBlock(TypeZurueck)
+		b.setKeyword(s.Token, "ZURUECK")
+		b.setInput("WERT", c.expression(s.ReturnValue))
+		return b
+	case *parser.ExpressionStatement:
+		b := c.newBlock(TypeAnweisung)
+		b.setInput("AUSDRUCK", c.expression(s.Expression))
+		return b
+	case *parser.IfStatement:
+		b := c.newBlock(TypeWenn)
+		b.setKeyword(s.Token, "WENN")
+		b.setInput("BEDINGUNG", c.expression(s.Condition))
+		c.body(b, inputStatements, s.Consequence)
+		if s.Alternative != nil {
+			b.setState(inputElse, true)
+			if formatter.IsElseIf(s) {
+				b.setState(stateElseIf, true)
+			}
+			c.body(b, inputElse, s.Alternative)
+		}
+		return b
+	case *parser.WhileStatement:
+		b := c.newBlock(TypeSolange)
+		b.setKeyword(s.Token, "SOLANGE")
+		b.setInput("BEDINGUNG", c.expression(s.Condition))
+		c.body(b, inputStatements, s.Body)
+		return b
+	case *parser.ForStatement:
+		b := c.newBlock(TypeFuer)
+		b.setKeyword(s.Token, "FUER")
+		b.setField("VAR", s.Variable.Value)
+		b.setInput("VON", c.expression(s.Start))
+		b.setInput("BIS", c.expression(s.End))
+		c.body(b, inputStatements, s.Body)
+		return b
+	case *parser.RepeatStatement:
+		b := c.newBlock(TypeWiederhole)
+		b.setKeyword(s.Token, "WIEDERHOLE")
+		b.setInput("ANZAHL", c.expression(s.Count))
+		c.body(b, inputStatements, s.Body)
+		return b
+	case *parser.EventHandler:
+		typ := "ben_wenn_" + s.EventType
+		b := c.newBlock(typ)
+		b.setKeyword(s.Token, "WENN_"+strings.ToUpper(s.EventType))
+		names := parameterInputs(typ, len(s.Parameters))
+		if s.Parameters != nil && (typ == TypeWennStart || typ == TypeWennImmer) {
+			b.setState(stateParameters, len(s.Parameters))
+		}
+		for i, param := range s.Parameters {
+			b.setInput(names[i], c.expression(param))
+		}
+		c.body(b, inputStatements, s.Body)
+		return b
+	case *parser.CommentStatement:
+		b := c.newBlock(TypeKommentar)
+		b.setField("TEXT", s.Text)
+		if s.Trailing {
+			b.setState(stateTrailing, true)
+		}
+		return b
+	default:
+		return nil
+	}
+}
+
+// parameterInputs returns the input names for the parameters of an event block
+func parameterInputs(typ string, n int) []string {
+	switch typ {
+	case TypeWennTaste:
+		return []string{"TASTE"}
+	case TypeWennKollision:
+		return []string{"FIGUR1", "FIGUR2"}
+	}
+	return numberedInputs("PARAM", n)
+}
+
+func numberedInputs(prefix string, n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = prefix + strconv.Itoa(i)
+	}
+	return names
+}
+
+func (c *fromConverter) expression(expr parser.Expression) *Block {
+	switch e := expr.(type) {
+	case *parser.NumberLiteral:
+		b := c.newBlock(TypeZahl)
+		b.setField("NUM", e.Token.Literal)
+		return b
+	case *parser.StringLiteral:
+		b := c.newBlock(TypeText)
+		b.setField("TEXT", e.Value)
+		return b
+	case *parser.BooleanLiteral:
+		b := c.newBlock(TypeWahrheitswert)
+		b.setField("BOOL", e.Token.Literal)
+		return b
+	case *parser.Identifier:
+		b := c.newBlock(TypeName)
+		b.setField("NAME", e.Value)
+		return b
+	case *parser.ArrayLiteral:
+		b := c.newBlock(TypeListe)
+		b.setState(stateElements, len(e.Elements))
+		for i, el := range e.Elements {
+			b.setInput("ELEMENT"+strconv.Itoa(i), c.expression(el))
+		}
+		return b
+	case *parser.IndexExpression:
+		b := c.newBlock(TypeIndex)
+		b.setInput("LISTE", c.expression(e.Left))
+		b.setInput("INDEX", c.expression(e.Index))
+		return b
+	case *parser.PrefixExpression:
+		b := c.newBlock(TypePraefix)
+		b.setField("OP", e.Operator)
+		b.setInput("WERT", c.expression(e.Right))
+		return b
+	case *parser.InfixExpression:
+		b := c.newBlock(TypeRechnung)
+		b.setField("OP", e.Operator)
+		b.setInput("A", c.expression(e.Left))
+		b.setInput("B", c.expression(e.Right))
+		return b
+	case *parser.CallExpression:
+		b := c.newBlock(TypeAufruf)
+		if ident, ok := e.Function.(*parser.Identifier); ok {
+			b.setField("NAME", ident.Value)
+		} else {
+			b.setInput("FUNKTION", c.expression(e.Function))
+		}
+		b.setState(stateArguments, len(e.Arguments))
+		for i, arg := range e.Arguments {
+			b.setInput("ARG"+strconv.Itoa(i), c.expression(arg))
+		}
+		return b
+	case *parser.MemberExpression:
+		b := c.newBlock(TypeEigenschaft)
+		b.setInput("OBJEKT", c.expression(e.Object))
+		b.setField("NAME", e.Property.Value)
+		return b
+	case *parser.AssignmentExpression:
+		b := c.newBlock(TypeZuweisung)
+		b.setInput("ZIEL", c.expression(e.Left))
+		b.setInput("WERT", c.expression(e.Value))
+		return b
+	case *parser.GroupedExpression:
+		b := c.newBlock(TypeKlammer)
+		b.setInput("WERT", c.expression(e.Expression))
+		return b
+	default:
+		return nil
+	}
+}
+
+// ToProgram converts a workspace to a program. Top-level stacks are read
+// from top to bottom. Line numbers are assigned so that the formatter
+// reproduces the blank lines and trailing comments of the blocks.
+func ToProgram(ws *Workspace) (*parser.Program, error) {
+	var tops []*Block
+	for _, b := range ws.Blocks.Blocks {
+		if b != nil {
+			tops = append(tops, b)
+		}
+	}
+	sort.SliceStable(tops, func(i, j int) bool {
+		if tops[i].Y != tops[j].Y {
+			return tops[i].Y < tops[j].Y
+		}
+		return tops[i].X < tops[j].X
+	})
+
+	var all []*Block
+	for _, top := range tops {
+		all = append(all, stack(top)...)
+	}
+
+	c := &toConverter{line: 1}
+	stmts := c.statements(all, 0)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &parser.Program{Statements: stmts}, nil
+}
+
+// stack returns a block and all blocks connected below it
+func stack(head *Block) []*Block {
+	var list []*Block
+	for b := head; b != nil; {
+		list = append(list, b)
+		if b.Next == nil {
+			break
+		}
+		b = b.Next.Block
+	}
+	return list
+}
+
+// toConverter builds AST nodes from blocks and remembers the first error
+type toConverter struct {
+	line int
+	err  error
+}
+
+func (c *toConverter) fail(b *Block, format string, args ...interface{}) {
+	if c.err == nil {
+		c.err = fmt.Errorf("Block %s (%s): %s", b.ID, b.Type, fmt.Sprintf(format, args...))
+	}
+}
+
+func (c *toConverter) token(t lexer.TokenType, literal string) lexer.Token {
+	return lexer.Token{Type: t, Literal: literal, Line: c.line, Column: 1}
+}
+
+// statements converts a list of statement blocks. openLine is the line of
+// the enclosing '{', or 0 at the top level.
+func (c *toConverter) statements(list []*Block, openLine int) []parser.Statement {
+	stmts := []parser.Statement{}
+	prevEnd := openLine
+
+	for _, b := range list {
+		if b == nil {
+			continue
+		}
+		if b.Type == TypeKommentar && boolState(b, stateTrailing) && prevEnd > 0 {
+			stmts = append(stmts, &parser.CommentStatement{
+				Token:    lexer.Token{Type: lexer.TOKEN_COMMENT, Literal: c.comment(b), Line: prevEnd, Column: 1},
+				Text:     b.Fields["TEXT"],
+				Trailing: true,
+			})
+			continue
+		}
+
+		if len(stmts) > 0 && boolState(b, stateBlankLine) {
+			c.line++
+		}
+		if stmt := c.statement(b); stmt != nil {
+			stmts = append(stmts, stmt)
+		}
+		prevEnd = c.line
+		c.line++
+	}
+	return stmts
+}
+
+// block converts a statement input to a BlockStatement opened on the
+// current line; it leaves c.line on the line of the closing brace
+func (c *toConverter) block(b *Block, name string, open lexer.TokenType) *parser.BlockStatement {
+	block := &parser.BlockStatement{Token: c.token(open, string(open))}
+	openLine := c.line
+	c.line++
+
+	var list []*Block
+	if conn := b.Inputs[name]; conn != nil {
+		list = stack(conn.Block)
+	}
+	block.Statements = c.statements(list, openLine)
+	block.End = c.token(lexer.TOKEN_RBRACE, "}")
+	return block
+}
+
+// keyword returns the token for a statement keyword, honouring a spelling
+// stored in the block's extra state
+func (c *toConverter) keyword(b *Block, canonical string) lexer.Token {
+	literal := canonical
+	if s, ok := b.ExtraState[stateKeyword].(string); ok && s != "" {
+		literal = s
+	}
+	t := lexer.LookupIdent(literal)
+	if t != lexer.LookupIdent(canonical) && !(canonical == "VAR" && t == lexer.TOKEN_VARIABLE) {
+		c.fail(b, "'%s' ist kein gültiges Schlüsselwort", literal)
+	}
+	return c.token(t, literal)
+}
+
+// name reads an identifier field and checks that it is a valid name
+func (c *toConverter) name(b *Block, field string) *parser.Identifier {
+	value := b.Fields[field]
+	if !parser.IsIdentifier(value) {
+		c.fail(b, "'%s' ist kein gültiger Name", value)
+	}
+	return &parser.Identifier{Token: c.token(lexer.TOKEN_IDENT, value), Value: value}
+}
+
+// comment reads the text of a comment block, which must stay on one line
+func (c *toConverter) comment(b *Block) string {
+	text := b.Fields["TEXT"]
+	if !parser.IsCommentText(text) {
+		c.fail(b, "Kommentar darf keinen Zeilenumbruch enthalten")
+	}
+	return text
+}
+
+// input converts the expression plugged into a required input
+func (c *toConverter) input(b *Block, name string) parser.Expression {
+	conn := b.Inputs[name]
+	if conn == nil || conn.Block == nil {
+		c.fail(b, "Eingang '%s' ist leer", name)
+		return nil
+	}
+	return c.expression(conn.Block)
+}
+
+// optionalInput converts the expression plugged into an input, if any
+func (c *toConverter) optionalInput(b *Block, name string) parser.Expression {
+	if conn := b.Inputs[name]; conn == nil || conn.Block == nil {
+		return nil
+	}
+	return c.input(b, name)
+}
+
+func boolState(b *Block, key string) bool {
+	v, _ := b.ExtraState[key].(bool)
+	return v
+}
+
+// stringsState reads a list of names from the extra state. FromSource
+// stores []string; after JSON it is []interface{}, and entries that are no
+// strings come back empty, which is reported as a missing name.
+func stringsState(b *Block, key string) []string {
+	switch list := b.ExtraState[key].(type) {
+	case []string:
+		return list
+	case []interface{}:
+		names := make([]string, len(list))
+		for i, v := range list {
+			names[i], _ = v.(string)
+		}
+		return names
+	}
+	return nil
+}
+
+// intState reads a count of numbered inputs from the extra state; JSON
+// numbers are float64. Every counted input has to be connected, so a count
+// above the number of inputs is cut to one more than that: the first
+// missing input is still reported, without allocating for all the others.
+func intState(b *Block, key string) (int, bool) {
+	var v float64
+	switch n := b.ExtraState[key].(type) {
+	case float64:
+		v = n
+	case int:
+		v = float64(n)
+	default:
+		return 0, false
+	}
+	if v < 0 {
+		return 0, false
+	}
+	if limit := len(b.Inputs) + 1; v > float64(limit) {
+		return limit, true
+	}
+	return int(v), true
+}
+
+func (c *toConverter) statement(b *Block) parser.Statement {
+	switch b.Type {
+	case TypeSpiel:
+		return &parser.GameDeclaration{Token: c.keyword(b, "SPIEL"), Name: escapeQuotes(b.Fields["NAME"])}
+	case TypeVariable:
+		return &parser.VariableDeclaration{Token: c.keyword(b, "VAR"), Name: c.name(b, "NAME"), Value: c.input(b, "WERT")}
+	case TypeFigur:
+		return &parser.FigurDeclaration{Token: c.keyword(b, "FIGUR"), Name: c.name(b, "NAME"), Value: c.input(b, "WERT")}
+	case TypeFunktion:
+		stmt := &parser.FunctionDeclaration{Token: c.keyword(b, "FUNKTION"), Name: c.name(b, "NAME"), Parameters: []*parser.Identifier{}}
+		for _, name := range stringsState(b, stateParameters) {
+			p := &Block{ID: b.ID, Type: b.Type, Fields: map[string]string{"PARAM": name}}
+			stmt.Parameters = append(stmt.Parameters, c.name(p, "PARAM"))
+		}
+		stmt.Body = c.block(b, inputStatements, lexer.TOKEN_LBRACE)
+		return stmt
+	case TypeZurueck:
+		return &parser.ReturnStatement{Token: c.keyword(b, "ZURUECK"), ReturnValue: c.optionalInput(b, "WERT")}
+	case TypeAnweisung:
+		tok := c.token(lexer.TOKEN_IDENT, "")
+		return &parser.ExpressionStatement{Token: tok, Expression: c.input(b, "AUSDRUCK")}
+	case TypeWenn:
+		return c.ifStatement(b)
+	case TypeSolange:
+		tok := c.keyword(b, "SOLANGE")
+		return &parser.WhileStatement{Token: tok, Condition: c.input(b, "BEDINGUNG"), Body: c.block(b, inputStatements, lexer.TOKEN_LBRACE)}
+	case TypeFuer:
+		return &parser.ForStatement{
+			Token:    c.keyword(b, "FUER"),
+			Variable: c.name(b, "VAR"),
+			Start:    c.input(b, "VON"),
+			End:      c.input(b, "BIS"),
+			Body:     c.block(b, inputStatements, lexer.TOKEN_LBRACE),
+		}
+	case TypeWiederhole:
+		tok := c.keyword(b, "WIEDERHOLE")
+		return &parser.RepeatStatement{Token: tok, Count: c.input(b, "ANZAHL"), Body: c.block(b, inputStatements, lexer.TOKEN_LBRACE)}
+	case TypeWennStart, TypeWennImmer, TypeWennTaste, TypeWennKollision:
+		return c.eventHandler(b)
+	case TypeKommentar:
+		return &parser.CommentStatement{Token: c.token(lexer.TOKEN_COMMENT, c.comment(b)), Text: b.Fields["TEXT"]}
+	default:
+		c.fail(b, "unbekannter Anweisungsblock")
+		return nil
+	}
+}
+
+func (c *toConverter) ifStatement(b *Block) *parser.IfStatement {
+	stmt := &parser.IfStatement{Token: c.keyword(b, "WENN"), Condition: c.input(b, "BEDINGUNG")}
+	stmt.Consequence = c.block(b, inputStatements, lexer.TOKEN_LBRACE)
+
+	if !boolState(b, inputElse) && b.Inputs[inputElse] == nil {
+		return stmt
+	}
+
+	// "} SONST WENN" continues on the line of the closing brace
+	if boolState(b, stateElseIf) {
+		if conn := b.Inputs[inputElse]; conn != nil && conn.Block != nil && conn.Block.Type == TypeWenn && conn.Block.Next == nil {
+			open := c.token(lexer.TOKEN_RBRACE, "}")
+			nested := c.ifStatement(conn.Block)
+			stmt.Alternative = &parser.BlockStatement{Token: open, Statements: []parser.Statement{nested}, End: c.token(lexer.TOKEN_RBRACE, "}")}
+			return stmt
+		}
+	}
+
+	stmt.Alternative = c.block(b, inputElse, lexer.TOKEN_LBRACE)
+	return stmt
+}
+
+func (c *toConverter) eventHandler(b *Block) *parser.EventHandler {
+	eventType := eventTypes[b.Type]
+	stmt := &parser.EventHandler{Token: c.keyword(b, "WENN_"+strings.ToUpper(eventType)), EventType: eventType}
+
+	count := 0
+	switch b.Type {
+	case TypeWennTaste:
+		count = 1
+	case TypeWennKollision:
+		count = 2
+	default:
+		count, _ = intState(b, stateParameters)
+	}
+
+	if _, ok := intState(b, stateParameters); ok || count > 0 {
+		stmt.Parameters = []parser.Expression{}
+		for _, name := range parameterInputs(b.Type, count) {
+			stmt.Parameters = append(stmt.Parameters, c.input(b, name))
+		}
+	}
+
+	stmt.Body = c.block(b, inputStatements, lexer.TOKEN_LBRACE)
+	return stmt
+}
+
+// singleToken lexes a field value that must be exactly one token
+func (c *toConverter) singleToken(b *Block, field string, allowed ...lexer.TokenType) lexer.Token {
+	value := b.Fields[field]
+	if tok, ok := parser.SingleToken(value); ok {
+		for _, t := range allowed {
+			if tok == t {
+				return c.token(t, value)
+			}
+		}
+	}
+	c.fail(b, "'%s' ist im Feld %s nicht erlaubt", value, field)
+	return c.token(lexer.TOKEN_ILLEGAL, value)
+}
+
+func (c *toConverter) expression(b *Block) parser.Expression {
+	switch b.Type {
+	case TypeZahl:
+		tok := c.singleToken(b, "NUM", lexer.TOKEN_NUMBER)
+		value, _ := strconv.ParseFloat(tok.Literal, 64)
+		return &parser.NumberLiteral{Token: tok, Value: value}
+	case TypeText:
+		value := escapeQuotes(b.Fields["TEXT"])
+		return &parser.StringLiteral{Token: c.token(lexer.TOKEN_STRING, value), Value: value}
+	case TypeWahrheitswert:
+		tok := c.singleToken(b, "BOOL", lexer.TOKEN_WAHR, lexer.TOKEN_FALSCH)
+		return &parser.BooleanLiteral{Token: tok, Value: tok.Type == lexer.TOKEN_WAHR}
+	case TypeName:
+		return c.name(b, "NAME")
+	case TypeListe:
+		n, _ := intState(b, stateElements)
+		lit := &parser.ArrayLiteral{Token: c.token(lexer.TOKEN_LBRACKET, "["), Elements: []parser.Expression{}}
+		for _, name := range numberedInputs("ELEMENT", n) {
+			lit.Elements = append(lit.Elements, c.input(b, name))
+		}
+		return lit
+	case TypeIndex:
+		return &parser.IndexExpression{Token: c.token(lexer.TOKEN_LBRACKET, "["), Left: c.input(b, "LISTE"), Index: c.input(b, "INDEX")}
+	case TypePraefix:
+		tok := c.singleToken(b, "OP", lexer.TOKEN_MINUS, lexer.TOKEN_NICHT)
+		return &parser.PrefixExpression{Token: tok, Operator: tok.Literal, Right: c.input(b, "WERT")}
+	case TypeRechnung:
+		tok := c.singleToken(b, "OP",
+			lexer.TOKEN_PLUS, lexer.TOKEN_MINUS, lexer.TOKEN_ASTERISK, lexer.TOKEN_SLASH, lexer.TOKEN_MODULO,
+			lexer.TOKEN_LT, lexer.TOKEN_GT, lexer.TOKEN_LTE, lexer.TOKEN_GTE, lexer.TOKEN_EQ, lexer.TOKEN_NOT_EQ,
+			lexer.TOKEN_UND, lexer.TOKEN_ODER)
+		return &parser.InfixExpression{Token: tok, Operator: tok.Literal, Left: c.input(b, "A"), Right: c.input(b, "B")}
+	case TypeAufruf:
+		call := &parser.CallExpression{Token: c.token(lexer.TOKEN_LPAREN, "("), Arguments: []parser.Expression{}}
+		if _, ok := b.Fields["NAME"]; ok {
+			call.Function = c.name(b, "NAME")
+		} else {
+			call.Function = c.input(b, "FUNKTION")
+		}
+		n, _ := intState(b, stateArguments)
+		for _, name := range numberedInputs("ARG", n) {
+			call.Arguments = append(call.Arguments, c.input(b, name))
+		}
+		return call
+	case TypeEigenschaft:
+		return &parser.MemberExpression{Token: c.token(lexer.TOKEN_DOT, "."), Object: c.input(b, "OBJEKT"), Property: c.name(b, "NAME")}
+	case TypeZuweisung:
+		return &parser.AssignmentExpression{Token: c.token(lexer.TOKEN_ASSIGN, "="), Left: c.input(b, "ZIEL"), Value: c.input(b, "WERT")}
+	case TypeKlammer:
+		return &parser.GroupedExpression{Token: c.token(lexer.TOKEN_LPAREN, "("), Expression: c.input(b, "WERT")}
+	default:
+		c.fail(b, "unbekannter Ausdrucksblock")
+		return nil
+	}
+}
+
+// escapeQuotes adds a backslash to quotes typed into a text field, so the
+// text cannot end the string literal early
+func escapeQuotes(s string) string {
+	var out strings.Builder
+	escaped := false
+	for _, r := range s {
+		if r == '"' && !escaped {
+			out.WriteRune('\\')
+		}
+		escaped = r == '\\' && !escaped
+		out.WriteRune(r)
+	}
+	return out.String()
+}
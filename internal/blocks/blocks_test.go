@@ -0,0 +1,158 @@
+package blocks
+
+import (
+	"benlang/internal/formatter"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// roundTrip converts code to blocks, through JSON, and back to code
+// roundTrip converts code to blocks and back, once directly as
+// /api/bloecke does and once through JSON as the block editor does. Both
+// have to give the same code.
+func roundTrip(t *testing.T, code string) string {
+	t.Helper()
+	ws, errs := FromSource(code)
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	direct, err := ToSource(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Workspace
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := ToSource(&decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if direct != source {
+		t.Errorf("without JSON the blocks give other code\n%s", firstDiff(direct, source))
+	}
+	return source
+}
+
+func TestRoundTripExamples(t *testing.T) {
+	files, err := filepath.Glob("../../beispiele/*/*.ben")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no example programs found: %v", err)
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, errs := formatter.Source(string(content))
+		if len(errs) > 0 {
+			t.Errorf("%s: %v", file, errs)
+			continue
+		}
+		if got := roundTrip(t, string(content)); got != want {
+			t.Errorf("%s: block round trip differs from formatted source\n%s", file, firstDiff(got, want))
+		}
+	}
+}
+
+func TestRoundTripKeepsSpelling(t *testing.T) {
+	code := "VARIABLE x = 1 // eins\n\nwenn x > 0 {\n    x = x - 1\n} SONST wenn x < 0 {\n    x = (x + 1) * 2\n} SONST {\n}\n\nWENN_TASTE(\"a\") {\n    ZURUECK\n}\n"
+	if got := roundTrip(t, code); got != code {
+		t.Errorf("got:\n%s\nwant:\n%s", got, code)
+	}
+}
+
+func TestRoundTripKeepsParameters(t *testing.T) {
+	code := "FUNKTION ladeLevel(levelNummer, neu) {\n    ZURUECK levelNummer\n}\n"
+	if got := roundTrip(t, code); got != code {
+		t.Errorf("got:\n%s\nwant:\n%s", got, code)
+	}
+}
+
+func TestToSourceStacks(t *testing.T) {
+	ws := &Workspace{Blocks: TopBlocks{Blocks: []*Block{
+		{Type: TypeAnweisung, ID: "unten", Y: 200, Inputs: map[string]*Connection{
+			"AUSDRUCK": {Block: &Block{Type: TypeAufruf, Fields: map[string]string{"NAME": "SCHREIBE"},
+				ExtraState: map[string]interface{}{"argumente": float64(1)},
+				Inputs:     map[string]*Connection{"ARG0": {Block: &Block{Type: TypeText, Fields: map[string]string{"TEXT": `sag "hallo"`}}}}}},
+		}},
+		{Type: TypeVariable, ID: "oben", Y: 10, Fields: map[string]string{"NAME": "punkte"}, Inputs: map[string]*Connection{
+			"WERT": {Block: &Block{Type: TypeZahl, Fields: map[string]string{"NUM": "0"}}},
+		}},
+	}}}
+
+	got, err := ToSource(ws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "VAR punkte = 0\nSCHREIBE(\"sag \\\"hallo\\\"\")\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestToSourceErrors(t *testing.T) {
+	tests := []struct {
+		block *Block
+		want  string
+	}{
+		{&Block{Type: TypeVariable, ID: "b1", Fields: map[string]string{"NAME": "punkte"}}, "Eingang 'WERT' ist leer"},
+		{&Block{Type: TypeVariable, ID: "b1", Fields: map[string]string{"NAME": "2x"}, Inputs: map[string]*Connection{
+			"WERT": {Block: &Block{Type: TypeZahl, Fields: map[string]string{"NUM": "1"}}},
+		}}, "kein gültiger Name"},
+		{&Block{Type: TypeAnweisung, ID: "b1", Inputs: map[string]*Connection{
+			"AUSDRUCK": {Block: &Block{Type: TypeRechnung, Fields: map[string]string{"OP": "^"}}},
+		}}, "nicht erlaubt"},
+		{&Block{Type: "ben_unbekannt", ID: "b1"}, "unbekannter Anweisungsblock"},
+		{&Block{Type: TypeKommentar, ID: "b1", Fields: map[string]string{"TEXT": " x\nalert(1)"}}, "Zeilenumbruch"},
+		{&Block{Type: TypeAnweisung, ID: "b1", Inputs: map[string]*Connection{
+			"AUSDRUCK": {Block: &Block{Type: TypeListe, ID: "b2", ExtraState: map[string]interface{}{"elemente": float64(3e7)},
+				Inputs: map[string]*Connection{"ELEMENT0": {Block: &Block{Type: TypeZahl, Fields: map[string]string{"NUM": "1"}}}}}},
+		}}, "Eingang 'ELEMENT1' ist leer"},
+	}
+
+	for _, tt := range tests {
+		ws := &Workspace{Blocks: TopBlocks{Blocks: []*Block{tt.block}}}
+		_, err := ToSource(ws)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want %q", tt.block.Type, err, tt.want)
+		}
+	}
+}
+
+func TestToSourceMalformedWorkspace(t *testing.T) {
+	tests := []*Workspace{
+		{Blocks: TopBlocks{Blocks: []*Block{nil, {Type: TypeKommentar}}}},
+		{Blocks: TopBlocks{Blocks: []*Block{{Type: TypeAnweisung, Inputs: map[string]*Connection{
+			"AUSDRUCK": {Block: &Block{Type: TypeAufruf, Fields: map[string]string{"NAME": "f"},
+				ExtraState: map[string]interface{}{"argumente": float64(-1)}}},
+		}}}}},
+	}
+
+	for i, ws := range tests {
+		if _, err := ToSource(ws); err != nil {
+			t.Errorf("workspace %d: %v", i, err)
+		}
+	}
+}
+
+func firstDiff(got, want string) string {
+	g, w := strings.Split(got, "\n"), strings.Split(want, "\n")
+	for i := 0; i < len(g) && i < len(w); i++ {
+		if g[i] != w[i] {
+			return "line " + strconv.Itoa(i+1) + ":\n  got:  " + g[i] + "\n  want: " + w[i]
+		}
+	}
+	return "length differs"
+}
@@ -0,0 +1,59 @@
+package compiler
+
+import (
+	"benlang/internal/analysis"
+	"benlang/internal/lexer"
+	"benlang/internal/parser"
+)
+
+// Asset is a file that the game loads at runtime
+type Asset struct {
+	File     string `json:"datei"`
+	Line     int    `json:"zeile"`
+	Column   int    `json:"spalte"`
+	Path     string `json:"pfad"`
+	Function string `json:"funktion"`
+}
+
+// assetArguments tells which argument of a builtin is a file path
+var assetArguments = map[string]int{
+	"LADE_BILD":     0,
+	"BILD_WECHSELN": 1,
+	"SPIELE_TON":    0,
+}
+
+// Assets lists the files loaded with a fixed path. Paths that are only
+// known at runtime cannot be checked and are left out.
+func Assets(files []analysis.File) []Asset {
+	var assets []Asset
+	for _, f := range files {
+		p := parser.New(lexer.New(f.Code))
+		program := p.ParseProgram()
+
+		parser.Inspect(program, func(n parser.Node) bool {
+			call, ok := n.(*parser.CallExpression)
+			if !ok {
+				return true
+			}
+			fn, ok := call.Function.(*parser.Identifier)
+			if !ok {
+				return true
+			}
+			arg, ok := assetArguments[fn.Value]
+			if !ok || arg >= len(call.Arguments) {
+				return true
+			}
+			if s, ok := call.Arguments[arg].(*parser.StringLiteral); ok {
+				assets = append(assets, Asset{
+					File:     f.Name,
+					Line:     s.Token.Line,
+					Column:   s.Token.Column,
+					Path:     s.Value,
+					Function: fn.Value,
+				})
+			}
+			return true
+		})
+	}
+	return assets
+}
@@ -0,0 +1,28 @@
+package compiler
+
+import (
+	"benlang/internal/analysis"
+	"testing"
+)
+
+func TestAssets(t *testing.T) {
+	assets := Assets([]analysis.File{
+		{Name: "hauptspiel.ben", Code: "FIGUR held = LADE_BILD(\"held.png\")\nWENN_START {\n  SPIELE_TON(\"toene/sprung.wav\")\n}"},
+		{Name: "level.ben", Code: "VAR name = \"boss.png\"\nFIGUR boss = LADE_BILD(name)\nBILD_WECHSELN(boss, \"bilder/boss2.png\")"},
+	})
+
+	want := []Asset{
+		{File: "hauptspiel.ben", Line: 1, Path: "held.png", Function: "LADE_BILD"},
+		{File: "hauptspiel.ben", Line: 3, Path: "toene/sprung.wav", Function: "SPIELE_TON"},
+		{File: "level.ben", Line: 3, Path: "bilder/boss2.png", Function: "BILD_WECHSELN"},
+	}
+	if len(assets) != len(want) {
+		t.Fatalf("got %d assets, want %d: %+v", len(assets), len(want), assets)
+	}
+	for i, w := range want {
+		a := assets[i]
+		if a.File != w.File || a.Line != w.Line || a.Path != w.Path || a.Function != w.Function {
+			t.Errorf("asset %d = %+v, want %+v", i, a, w)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package compiler
+
+import (
+	"benlang/internal/analysis"
+	"benlang/internal/lexer"
+	"benlang/internal/parser"
+	"benlang/internal/transpiler"
+	"fmt"
+	"strings"
+)
+
+// Error is a syntax error in one file of a project
+type Error struct {
+	File    string `json:"datei"`
+	Line    int    `json:"zeile"`
+	Column  int    `json:"spalte"`
+	Message string `json:"meldung"`
+}
+
+// Error formats the error the way it is shown to the user
+func (e Error) Error() string {
+	return fmt.Sprintf("%s, Zeile %d: %s", e.File, e.Line, e.Message)
+}
+
+// Compile joins the files in the given order, like the IDE does, and
+// transpiles them to JavaScript. Error positions refer to the single files.
+func Compile(files []analysis.File) (string, []Error) {
+	var code strings.Builder
+	starts := make([]int, len(files))
+	line := 1
+	for i, f := range files {
+		starts[i] = line
+		code.WriteString(f.Code)
+		code.WriteString("\n")
+		line += strings.Count(f.Code, "\n") + 1
+	}
+
+	p := parser.New(lexer.New(code.String()))
+	program := p.ParseProgram()
+
+	if list := p.ErrorList(); len(list) > 0 {
+		errs := make([]Error, len(list))
+		for i, e := range list {
+			errs[i] = Error{Line: e.Line, Column: e.Column, Message: e.Message}
+			for j := len(files) - 1; j >= 0; j-- {
+				if e.Line >= starts[j] {
+					errs[i].File = files[j].Name
+					errs[i].Line = e.Line - starts[j] + 1
+					break
+				}
+			}
+		}
+		return "", errs
+	}
+
+	return transpiler.New().Transpile(program), nil
+}
@@ -0,0 +1,35 @@
+package compiler
+
+import (
+	"benlang/internal/analysis"
+	"strings"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	js, errs := Compile([]analysis.File{
+		{Name: "hauptspiel.ben", Code: "VAR x = 1\nhallo()"},
+		{Name: "hilfe.ben", Code: "FUNKTION hallo() {\n  SCHREIBE(x)\n}"},
+	})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	for _, want := range []string{"var x = 1;", "async function hallo()", "console.log(x)"} {
+		if !strings.Contains(js, want) {
+			t.Errorf("output lacks %q:\n%s", want, js)
+		}
+	}
+}
+
+func TestCompileErrorPositions(t *testing.T) {
+	_, errs := Compile([]analysis.File{
+		{Name: "hauptspiel.ben", Code: "VAR x = 1\nVAR y = 2\n"},
+		{Name: "level.ben", Code: "// Level\nVAR = 3"},
+	})
+	if len(errs) == 0 {
+		t.Fatal("expected an error")
+	}
+	if errs[0].File != "level.ben" || errs[0].Line != 2 {
+		t.Errorf("error at %s:%d, want level.ben:2 (%v)", errs[0].File, errs[0].Line, errs[0])
+	}
+}
@@ -0,0 +1,237 @@
+// Package diff compares two versions of a text line by line.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Op says what happened to a line
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+var opNames = map[Op]string{Equal: "gleich", Delete: "entfernt", Insert: "neu"}
+
+func (o Op) String() string { return opNames[o] }
+
+// MarshalText writes the operation by its German name
+func (o Op) MarshalText() ([]byte, error) { return []byte(o.String()), nil }
+
+// UnmarshalText reads the operation from its German name
+func (o *Op) UnmarshalText(text []byte) error {
+	for op, name := range opNames {
+		if name == string(text) {
+			*o = op
+			return nil
+		}
+	}
+	return fmt.Errorf("unbekannte Art '%s'", text)
+}
+
+// Line is one line of the comparison
+type Line struct {
+	Op   Op     `json:"art"`
+	Old  int    `json:"alt,omitempty"` // line number in the old text, 0 for new lines
+	New  int    `json:"neu,omitempty"` // line number in the new text, 0 for removed lines
+	Text string `json:"text"`
+	// OldText is set for equal lines whose old text looks different, such
+	// as re-indented lines in Source
+	OldText string `json:"altText,omitempty"`
+}
+
+// Hunk is a group of changes with the unchanged lines around them
+type Hunk struct {
+	OldStart int    `json:"altStart"`
+	OldCount int    `json:"altAnzahl"`
+	NewStart int    `json:"neuStart"`
+	NewCount int    `json:"neuAnzahl"`
+	Section  string `json:"bereich,omitempty"` // e.g. the function the change is in
+	Lines    []Line `json:"zeilen"`
+}
+
+// maxTable limits the memory for comparing the changed middle of two texts.
+// Larger changes are shown as removing all old and adding all new lines.
+const maxTable = 4 << 20
+
+// Lines compares two texts line by line
+func Lines(old, new string) []Line {
+	a, b := splitLines(old), splitLines(new)
+	return compare(a, b, a, b)
+}
+
+// splitLines splits a text into lines; a final line break does not start
+// another line
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// compare finds the longest common subsequence of the keys and returns the
+// lines with their texts. Keys let callers ignore differences such as
+// indentation.
+func compare(oldKeys, newKeys, oldText, newText []string) []Line {
+	var lines []Line
+	equal := func(i, j int) {
+		l := Line{Op: Equal, Old: i + 1, New: j + 1, Text: newText[j]}
+		if oldText[i] != newText[j] {
+			l.OldText = oldText[i]
+		}
+		lines = append(lines, l)
+	}
+
+	// Common lines at the start and the end need no table
+	start := 0
+	for start < len(oldKeys) && start < len(newKeys) && oldKeys[start] == newKeys[start] {
+		equal(start, start)
+		start++
+	}
+	endOld, endNew := len(oldKeys), len(newKeys)
+	for endOld > start && endNew > start && oldKeys[endOld-1] == newKeys[endNew-1] {
+		endOld--
+		endNew--
+	}
+
+	n, m := endOld-start, endNew-start
+	if n*m > maxTable {
+		for i := start; i < endOld; i++ {
+			lines = append(lines, Line{Op: Delete, Old: i + 1, Text: oldText[i]})
+		}
+		for j := start; j < endNew; j++ {
+			lines = append(lines, Line{Op: Insert, New: j + 1, Text: newText[j]})
+		}
+	} else {
+		// table[i][j] is the length of the common subsequence of the
+		// remaining lines old[i:] and new[j:]
+		table := make([][]int, n+1)
+		for i := range table {
+			table[i] = make([]int, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if oldKeys[start+i] == newKeys[start+j] {
+					table[i][j] = table[i+1][j+1] + 1
+				} else {
+					table[i][j] = max(table[i+1][j], table[i][j+1])
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && oldKeys[start+i] == newKeys[start+j]:
+				equal(start+i, start+j)
+				i++
+				j++
+			case i < n && (j == m || table[i+1][j] >= table[i][j+1]):
+				// Removed lines come before the lines that replace them
+				lines = append(lines, Line{Op: Delete, Old: start + i + 1, Text: oldText[start+i]})
+				i++
+			default:
+				lines = append(lines, Line{Op: Insert, New: start + j + 1, Text: newText[start+j]})
+				j++
+			}
+		}
+	}
+
+	for k := 0; endOld+k < len(oldKeys); k++ {
+		equal(endOld+k, endNew+k)
+	}
+	return lines
+}
+
+// Changed reports whether the comparison contains any change
+func Changed(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// Unified formats the comparison like diff -u with the given number of
+// unchanged lines around each change
+func Unified(oldName, newName string, lines []Line, context int) string {
+	if !Changed(lines) {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	prefix := map[Op]string{Equal: " ", Delete: "-", Insert: "+"}
+	for _, h := range Hunks(lines, context) {
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@", h.OldStart, h.OldCount, h.NewStart, h.NewCount)
+		if h.Section != "" {
+			b.WriteString(" " + h.Section)
+		}
+		b.WriteString("\n")
+		for _, l := range h.Lines {
+			b.WriteString(prefix[l.Op] + l.Text + "\n")
+		}
+	}
+	return b.String()
+}
+
+// Hunks groups the changes with the given number of unchanged lines
+// around them. Changes that are at most 2*context lines apart share a hunk.
+func Hunks(lines []Line, context int) []Hunk {
+	var hunks []Hunk
+	from, to := -1, -1
+	for i, l := range lines {
+		if l.Op == Equal {
+			continue
+		}
+		start, end := max(i-context, 0), min(i+1+context, len(lines))
+		if from >= 0 && start <= to {
+			to = end
+			continue
+		}
+		if from >= 0 {
+			hunks = append(hunks, newHunk(lines, from, to))
+		}
+		from, to = start, end
+	}
+	if from >= 0 {
+		hunks = append(hunks, newHunk(lines, from, to))
+	}
+	return hunks
+}
+
+// newHunk makes a hunk of lines[from:to]. Like diff -u, a side without
+// lines starts at the line before the hunk.
+func newHunk(lines []Line, from, to int) Hunk {
+	h := Hunk{Lines: lines[from:to]}
+	oldBefore, newBefore := 0, 0
+	for _, l := range lines[:from] {
+		if l.Op != Insert {
+			oldBefore++
+		}
+		if l.Op != Delete {
+			newBefore++
+		}
+	}
+	for _, l := range h.Lines {
+		if l.Op != Insert {
+			h.OldCount++
+		}
+		if l.Op != Delete {
+			h.NewCount++
+		}
+	}
+	h.OldStart, h.NewStart = oldBefore, newBefore
+	if h.OldCount > 0 {
+		h.OldStart++
+	}
+	if h.NewCount > 0 {
+		h.NewStart++
+	}
+	return h
+}
@@ -0,0 +1,67 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func render(lines []Line) string {
+	prefix := map[Op]string{Equal: " ", Delete: "-", Insert: "+"}
+	var b strings.Builder
+	for _, l := range lines {
+		b.WriteString(prefix[l.Op] + l.Text + "\n")
+	}
+	return b.String()
+}
+
+func TestLines(t *testing.T) {
+	old := "VAR x = 1\nVAR y = 2\nSCHREIBE(x)\n"
+	new := "VAR x = 1\nVAR z = 3\nSCHREIBE(x)\nSCHREIBE(z)\n"
+
+	got := render(Lines(old, new))
+	want := " VAR x = 1\n-VAR y = 2\n+VAR z = 3\n SCHREIBE(x)\n+SCHREIBE(z)\n"
+	if got != want {
+		t.Errorf("Lines() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLineNumbers(t *testing.T) {
+	lines := Lines("a\nb\nc", "a\nc\nd")
+	want := []Line{
+		{Op: Equal, Old: 1, New: 1, Text: "a"},
+		{Op: Delete, Old: 2, Text: "b"},
+		{Op: Equal, Old: 3, New: 2, Text: "c"},
+		{Op: Insert, New: 3, Text: "d"},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %+v", lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, lines[i], want[i])
+		}
+	}
+}
+
+func TestUnified(t *testing.T) {
+	var old, new []string
+	for i := 1; i <= 20; i++ {
+		line := strings.Repeat("x", i)
+		old = append(old, line)
+		if i == 3 || i == 18 {
+			line += "!"
+		}
+		new = append(new, line)
+	}
+
+	got := Unified("alt", "neu", Lines(strings.Join(old, "\n"), strings.Join(new, "\n")), 2)
+	if strings.Count(got, "@@ ") != 2 {
+		t.Errorf("want two hunks:\n%s", got)
+	}
+	if !strings.Contains(got, "@@ -1,5 +1,5 @@\n") || !strings.Contains(got, "@@ -16,5 +16,5 @@\n") {
+		t.Errorf("unexpected hunk headers:\n%s", got)
+	}
+	if Unified("alt", "neu", Lines("a\n", "a\n"), 3) != "" {
+		t.Error("equal texts should have no diff")
+	}
+}
@@ -0,0 +1,117 @@
+package diff
+
+import (
+	"benlang/internal/formatter"
+	"benlang/internal/lexer"
+	"benlang/internal/parser"
+	"strings"
+)
+
+// Source compares two versions of a BenLang file. Lines are compared by
+// their tokens, so indentation and spaces between tokens do not count as
+// changes, while spaces inside texts and comments do.
+func Source(old, new string) []Line {
+	a, b := splitLines(old), splitLines(new)
+	return compare(lineKeys(old, a), lineKeys(new, b), a, b)
+}
+
+// SourceHunks compares two versions of a BenLang file and names the
+// function or event each hunk is in
+func SourceHunks(old, new string, context int) []Hunk {
+	hunks := Hunks(Source(old, new), context)
+	oldSections, newSections := sections(old), sections(new)
+	for i, h := range hunks {
+		for _, l := range h.Lines {
+			if l.Op == Equal {
+				continue
+			}
+			if l.New > 0 {
+				hunks[i].Section = newSections.at(l.New)
+			} else {
+				hunks[i].Section = oldSections.at(l.Old)
+			}
+			break
+		}
+	}
+	return hunks
+}
+
+// lineKeys describes each line by its tokens and comments. Lines without
+// tokens of their own, such as the inside of a long text, keep their
+// trimmed text.
+func lineKeys(text string, lines []string) []string {
+	parts := make([][]string, len(lines))
+	add := func(line int, part string) {
+		if line >= 1 && line <= len(lines) {
+			parts[line-1] = append(parts[line-1], part)
+		}
+	}
+
+	l := lexer.New(text)
+	for _, tok := range l.Tokenize() {
+		if tok.Type != lexer.TOKEN_EOF {
+			add(tok.Line, string(tok.Type)+" "+tok.Literal)
+		}
+	}
+	for _, c := range l.Comments() {
+		add(c.Line, "// "+c.Literal)
+	}
+
+	keys := make([]string, len(lines))
+	for i, p := range parts {
+		if len(p) == 0 {
+			keys[i] = strings.TrimSpace(lines[i])
+		} else {
+			keys[i] = strings.Join(p, "\x00")
+		}
+	}
+	return keys
+}
+
+// section is a top-level statement and the line it starts on. Only
+// functions and events have a name.
+type section struct {
+	line int
+	name string
+}
+
+type sectionList []section
+
+// sections lists the top-level statements of a file
+func sections(text string) sectionList {
+	program := parser.New(lexer.New(text)).ParseProgram()
+	var list sectionList
+	for _, stmt := range program.Statements {
+		s := section{line: formatter.StartLine(stmt)}
+		switch n := stmt.(type) {
+		case *parser.FunctionDeclaration:
+			s.name = "FUNKTION"
+			if n.Name != nil {
+				s.name += " " + n.Name.Value
+			}
+		case *parser.EventHandler:
+			s.name = n.Token.Literal
+			if len(n.Parameters) > 0 {
+				if key, ok := n.Parameters[0].(*parser.StringLiteral); ok {
+					s.name += `("` + key.Value + `")`
+				}
+			}
+		}
+		if s.line > 0 {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// at returns the name of the section a line belongs to
+func (l sectionList) at(line int) string {
+	name := ""
+	for _, s := range l {
+		if s.line > line {
+			break
+		}
+		name = s.name
+	}
+	return name
+}
@@ -0,0 +1,71 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSourceIgnoresIndentation(t *testing.T) {
+	old := "WENN_START {\nSCHREIBE(\"hallo\")\nx=1\n}\n"
+	new := "WENN_START {\n    SCHREIBE(\"hallo\")\n    x = 1\n}\n"
+
+	lines := Source(old, new)
+	if Changed(lines) {
+		t.Errorf("re-indentation shown as change:\n%s", render(lines))
+	}
+	if lines[1].OldText != `SCHREIBE("hallo")` || lines[1].Text != `    SCHREIBE("hallo")` {
+		t.Errorf("line texts = %+v", lines[1])
+	}
+	if !Changed(Lines(old, new)) {
+		t.Error("plain line diff should see the indentation")
+	}
+}
+
+func TestSourceSeesTextAndComments(t *testing.T) {
+	for _, c := range []struct{ old, new string }{
+		{`SCHREIBE("a b")`, `SCHREIBE("a  b")`},
+		{"x = 1 // alt", "x = 1 // neu"},
+		{"x = 1", "x = 2"},
+	} {
+		if !Changed(Source(c.old, c.new)) {
+			t.Errorf("no change found between %q and %q", c.old, c.new)
+		}
+	}
+}
+
+func TestSourceHunks(t *testing.T) {
+	old := strings.Join([]string{
+		"VAR x = 0",
+		"",
+		"FUNKTION springe() {",
+		"  y = y - 10",
+		"}",
+		"",
+		"WENN_TASTE(\"links\") {",
+		"  x = x - 5",
+		"}",
+	}, "\n")
+	new := strings.Join([]string{
+		"VAR x = 0",
+		"",
+		"FUNKTION springe() {",
+		"    y = y - 20",
+		"}",
+		"",
+		"WENN_TASTE(\"links\") {",
+		"        x = x - 5",
+		"}",
+		"VAR ende = 1",
+	}, "\n")
+
+	hunks := SourceHunks(old, new, 1)
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks: %+v", len(hunks), hunks)
+	}
+	if h := hunks[0]; h.Section != "FUNKTION springe" || h.OldStart != 3 || h.OldCount != 3 || h.NewCount != 3 {
+		t.Errorf("first hunk = %+v", h)
+	}
+	if h := hunks[1]; h.Section != "" || h.NewStart != 9 || h.NewCount != 2 || h.OldCount != 1 {
+		t.Errorf("second hunk = %+v", h)
+	}
+}
@@ -0,0 +1,160 @@
+package export
+
+import (
+	"benlang/internal/project"
+	"benlang/web"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// mediaTypes lists the project files that are embedded into an export
+var mediaTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".wav":  "audio/wav",
+	".mp3":  "audio/mpeg",
+}
+
+// closingScript matches text that would end an inline script too early
+var closingScript = regexp.MustCompile(`(?i)</(script)`)
+
+var page = template.Must(template.New("spiel").Parse(`<!DOCTYPE html>
+<html lang="de">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>{{.Title}}</title>
+<style>
+  html, body { margin: 0; height: 100%; background: #1a1a2e; }
+  body { display: flex; align-items: center; justify-content: center; font-family: Arial, sans-serif; }
+  canvas { max-width: 100vw; max-height: 100vh; background: #000; box-shadow: 0 0 30px rgba(0, 0, 0, 0.5); }
+  #fehler { position: fixed; bottom: 0; left: 0; right: 0; margin: 0; padding: 10px; color: #fff; background: #c0392b; display: none; }
+</style>
+</head>
+<body>
+<canvas id="gameCanvas" width="{{.Canvas.Width}}" height="{{.Canvas.Height}}" tabindex="0"></canvas>
+<pre id="fehler"></pre>
+<script>{{.Runtime}}</script>
+<script>
+_benlang.dateien = {{.Files}};
+window.addEventListener('error', function (e) {
+  var box = document.getElementById('fehler');
+  box.textContent = 'Fehler: ' + e.message;
+  box.style.display = 'block';
+});
+_benlang.init('gameCanvas');
+(function () {
+{{.Game}}
+})();
+_benlang.starten();
+document.title = _benlang.spielName || document.title;
+document.getElementById('gameCanvas').focus();
+</script>
+</body>
+</html>
+`))
+
+// HTML compiles a project into a single HTML page that contains the
+// runtime, the game and all images and sounds, so it runs without the server
+func HTML(p *project.Project) ([]byte, error) {
+	// The defaults that apply meanwhile may not be what the game needs
+	if p.ManifestError != nil {
+		return nil, p.ManifestError
+	}
+	game, errs, err := p.Compile()
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("Das Spiel enthält Fehler: %s", errs[0].Error())
+	}
+
+	runtime, err := web.Content.ReadFile("js/runtime/benlang-runtime.js")
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := dataURIs(p)
+	if err != nil {
+		return nil, err
+	}
+	filesJSON, err := json.Marshal(files)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	title := p.Manifest.Title
+	if title == "" {
+		title = p.Name
+	}
+	err = page.Execute(&out, map[string]interface{}{
+		"Title":   title,
+		"Canvas":  p.Manifest.Canvas,
+		"Runtime": inlineScript(string(runtime)),
+		"Files":   template.JS(filesJSON),
+		"Game":    inlineScript(game),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// dataURIs reads the images and sounds of the project, keyed by the path
+// that LADE_BILD and SPIELE_TON use. As the IDE finds "held.png" in
+// bilder/, files in the AssetFolders are also keyed without the folder,
+// unless a file of that name exists. If the manifest lists assets, only
+// those are embedded.
+func dataURIs(p *project.Project) (map[string]string, error) {
+	names := p.Manifest.Assets
+	if len(names) == 0 {
+		list, err := p.ListFiles()
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range list {
+			if !f.IsDir {
+				names = append(names, filepath.ToSlash(f.Name))
+			}
+		}
+	}
+
+	files := map[string]string{}
+	for _, name := range names {
+		mediaType, ok := mediaTypes[strings.ToLower(path.Ext(name))]
+		if !ok {
+			continue
+		}
+		data, err := p.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("%s kann nicht gelesen werden: %v", name, err)
+		}
+		files[name] = "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString([]byte(data))
+	}
+
+	// The folders are searched in order, so the first one wins
+	for _, folder := range project.AssetFolders {
+		for _, name := range names {
+			short, ok := strings.CutPrefix(name, folder+"/")
+			if _, taken := files[short]; ok && !taken && files[name] != "" {
+				files[short] = files[name]
+			}
+		}
+	}
+	return files, nil
+}
+
+// inlineScript makes JavaScript safe to place between script tags
+func inlineScript(js string) template.JS {
+	return template.JS(closingScript.ReplaceAllString(js, `<\/$1`))
+}
@@ -0,0 +1,127 @@
+package export
+
+import (
+	"benlang/internal/project"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "bilder"), 0755)
+	os.WriteFile(filepath.Join(dir, "hauptspiel.ben"), []byte(`SPIEL "Test"
+FIGUR held = LADE_BILD("bilder/held.png", 10, 10)
+SCHREIBE("</script>")
+`), 0644)
+	os.WriteFile(filepath.Join(dir, "bilder", "held.png"), []byte("\x89PNG"), 0644)
+
+	proj, err := project.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := HTML(proj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(out)
+
+	for _, want := range []string{
+		"global._benlang = _benlang",
+		`"bilder/held.png":"data:image/png;base64,iVBORw=="`,
+		`_benlang.spielName = "Test";`,
+		`<\/script>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("export lacks %q", want)
+		}
+	}
+	if strings.Count(html, "</script>") != 2 {
+		t.Errorf("expected exactly two closing script tags, got %d", strings.Count(html, "</script>"))
+	}
+	if strings.Contains(html, "/projekt/bilder") {
+		t.Error("export still loads files from the server")
+	}
+}
+
+func TestHTMLWithErrors(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "hauptspiel.ben"), []byte("VAR = 1\n"), 0644)
+
+	proj, err := project.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := HTML(proj); err == nil || !strings.Contains(err.Error(), "hauptspiel.ben, Zeile 1") {
+		t.Errorf("expected compile error, got %v", err)
+	}
+}
+
+func TestHTMLFollowsManifest(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "bilder"), 0755)
+	os.WriteFile(filepath.Join(dir, "projekt.json"), []byte(`{
+  "titel": "Mein Spiel",
+  "einstieg": "start.ben",
+  "leinwand": {"breite": 400, "hoehe": 300},
+  "medien": ["bilder/held.png"]
+}`), 0644)
+	os.WriteFile(filepath.Join(dir, "start.ben"), []byte("VAR x = 1\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "bilder", "held.png"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, "bilder", "alt.png"), []byte("b"), 0644)
+
+	proj, err := project.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := HTML(proj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(out)
+
+	for _, want := range []string{`<title>Mein Spiel</title>`, `width="400" height="300"`, `"bilder/held.png"`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("export lacks %q", want)
+		}
+	}
+	if strings.Contains(html, "bilder/alt.png") {
+		t.Error("export contains an asset that is not listed in the manifest")
+	}
+}
+
+func TestHTMLFindsAssetsByName(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "bilder"), 0755)
+	os.MkdirAll(filepath.Join(dir, "toene"), 0755)
+	os.WriteFile(filepath.Join(dir, "hauptspiel.ben"), []byte(`FIGUR held = LADE_BILD("held.png")
+SPIELE_TON("sprung.wav")
+`), 0644)
+	os.WriteFile(filepath.Join(dir, "bilder", "held.png"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, "bilder", "stein.png"), []byte("b"), 0644)
+	os.WriteFile(filepath.Join(dir, "stein.png"), []byte("c"), 0644)
+	os.WriteFile(filepath.Join(dir, "toene", "sprung.wav"), []byte("d"), 0644)
+
+	proj, err := project.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := dataURIs(proj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"held.png":        "data:image/png;base64,YQ==",
+		"bilder/held.png": "data:image/png;base64,YQ==",
+		"sprung.wav":      "data:audio/wav;base64,ZA==",
+		// A file of the name itself comes first, as in the IDE
+		"stein.png": "data:image/png;base64,Yw==",
+	}
+	for name, uri := range want {
+		if files[name] != uri {
+			t.Errorf("%s = %q, want %q", name, files[name], uri)
+		}
+	}
+}
@@ -0,0 +1,400 @@
+package formatter
+
+import (
+	"benlang/internal/lexer"
+	"benlang/internal/parser"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// indentUnit is used for every nesting level, like in the examples
+const indentUnit = "    "
+
+// formatter prints an AST as canonical BenLang source
+type formatter struct {
+	lines  []string
+	indent int
+}
+
+// Format prints a program as canonical BenLang source. Comments, keyword
+// spelling, parentheses and single blank lines between statements are
+// kept; indentation and spacing are normalised.
+func Format(program *parser.Program) string {
+	f := &formatter{}
+	f.statements(program.Statements)
+	if len(f.lines) == 0 {
+		return ""
+	}
+	return strings.Join(f.lines, "\n") + "\n"
+}
+
+// Source parses and formats BenLang code. If the code has syntax errors
+// it is returned unchanged together with the errors.
+func Source(code string) (string, []parser.ParseError) {
+	p := parser.New(lexer.New(code))
+	program := p.ParseProgram()
+	if len(p.ErrorList()) > 0 {
+		return code, p.ErrorList()
+	}
+	return Format(program), nil
+}
+
+// NeedsBlankLine reports whether the source had an empty line between two
+// consecutive statements. Several empty lines count as one.
+func NeedsBlankLine(prev, next parser.Statement) bool {
+	return StartLine(next) > EndLine(prev)+1
+}
+
+// StartLine returns the source line a statement starts on
+func StartLine(stmt parser.Statement) int {
+	switch s := stmt.(type) {
+	case *parser.VariableDeclaration:
+		return s.Token.Line
+	case *parser.FigurDeclaration:
+		return s.Token.Line
+	case *parser.FunctionDeclaration:
+		return s.Token.Line
+	case *parser.ReturnStatement:
+		return s.Token.Line
+	case *parser.ExpressionStatement:
+		return s.Token.Line
+	case *parser.BlockStatement:
+		return s.Token.Line
+	case *parser.IfStatement:
+		return s.Token.Line
+	case *parser.WhileStatement:
+		return s.Token.Line
+	case *parser.ForStatement:
+		return s.Token.Line
+	case *parser.RepeatStatement:
+		return s.Token.Line
+	case *parser.GameDeclaration:
+		return s.Token.Line
+	case *parser.EventHandler:
+		return s.Token.Line
+	case *parser.CommentStatement:
+		return s.Token.Line
+	}
+	return 0
+}
+
+// EndLine returns the last source line a statement occupies
+func EndLine(stmt parser.Statement) int {
+	line := StartLine(stmt)
+	visitTokens(stmt, func(tok lexer.Token) {
+		line = max(line, tok.Line)
+	})
+	return line
+}
+
+// visitTokens calls fn for every token stored in a node
+func visitTokens(node parser.Node, fn func(lexer.Token)) {
+	visitBlock := func(b *parser.BlockStatement) {
+		if b != nil {
+			visitTokens(b, fn)
+		}
+	}
+	visitAll := func(exprs []parser.Expression) {
+		for _, e := range exprs {
+			visitTokens(e, fn)
+		}
+	}
+
+	switch n := node.(type) {
+	case *parser.VariableDeclaration:
+		fn(n.Token)
+		visitTokens(n.Value, fn)
+	case *parser.FigurDeclaration:
+		fn(n.Token)
+		visitTokens(n.Value, fn)
+	case *parser.FunctionDeclaration:
+		fn(n.Token)
+		visitBlock(n.Body)
+	case *parser.ReturnStatement:
+		fn(n.Token)
+		visitTokens(n.ReturnValue, fn)
+	case *parser.ExpressionStatement:
+		fn(n.Token)
+		visitTokens(n.Expression, fn)
+	case *parser.BlockStatement:
+		fn(n.Token)
+		for _, s := range n.Statements {
+			visitTokens(s, fn)
+		}
+		fn(n.End)
+	case *parser.IfStatement:
+		fn(n.Token)
+		visitTokens(n.Condition, fn)
+		visitBlock(n.Consequence)
+		visitBlock(n.Alternative)
+	case *parser.WhileStatement:
+		fn(n.Token)
+		visitTokens(n.Condition, fn)
+		visitBlock(n.Body)
+	case *parser.ForStatement:
+		fn(n.Token)
+		visitTokens(n.Start, fn)
+		visitTokens(n.End, fn)
+		visitBlock(n.Body)
+	case *parser.RepeatStatement:
+		fn(n.Token)
+		visitTokens(n.Count, fn)
+		visitBlock(n.Body)
+	case *parser.GameDeclaration:
+		fn(n.Token)
+	case *parser.EventHandler:
+		fn(n.Token)
+		visitAll(n.Parameters)
+		visitBlock(n.Body)
+	case *parser.CommentStatement:
+		fn(n.Token)
+	case *parser.Identifier:
+		if n != nil {
+			fn(n.Token)
+		}
+	case *parser.NumberLiteral:
+		fn(n.Token)
+	case *parser.StringLiteral:
+		fn(n.Token)
+	case *parser.BooleanLiteral:
+		fn(n.Token)
+	case *parser.ArrayLiteral:
+		fn(n.Token)
+		visitAll(n.Elements)
+	case *parser.IndexExpression:
+		visitTokens(n.Left, fn)
+		visitTokens(n.Index, fn)
+	case *parser.PrefixExpression:
+		fn(n.Token)
+		visitTokens(n.Right, fn)
+	case *parser.InfixExpression:
+		visitTokens(n.Left, fn)
+		visitTokens(n.Right, fn)
+	case *parser.CallExpression:
+		visitTokens(n.Function, fn)
+		visitAll(n.Arguments)
+	case *parser.MemberExpression:
+		visitTokens(n.Object, fn)
+		if n.Property != nil {
+			fn(n.Property.Token)
+		}
+	case *parser.AssignmentExpression:
+		visitTokens(n.Left, fn)
+		visitTokens(n.Value, fn)
+	case *parser.GroupedExpression:
+		fn(n.Token)
+		visitTokens(n.Expression, fn)
+	}
+}
+
+// line writes one indented line
+func (f *formatter) line(text string) {
+	f.lines = append(f.lines, strings.Repeat(indentUnit, f.indent)+text)
+}
+
+func (f *formatter) statements(stmts []parser.Statement) {
+	var prev parser.Statement
+	for _, stmt := range stmts {
+		if c, ok := stmt.(*parser.CommentStatement); ok && c.Trailing && len(f.lines) > 0 {
+			// Put the comment back behind the code it belongs to
+			f.lines[len(f.lines)-1] += " //" + c.Text
+			prev = stmt
+			continue
+		}
+
+		if prev != nil && NeedsBlankLine(prev, stmt) {
+			f.lines = append(f.lines, "")
+		}
+		f.statement(stmt)
+		prev = stmt
+	}
+}
+
+// block writes the statements of a block one level deeper
+func (f *formatter) block(b *parser.BlockStatement) {
+	if b == nil {
+		return
+	}
+	f.indent++
+	f.statements(b.Statements)
+	f.indent--
+}
+
+// keyword returns the spelling used in the source, or the canonical one
+// for nodes that were not parsed from text
+func keyword(tok lexer.Token, canonical string) string {
+	if tok.Literal != "" {
+		return tok.Literal
+	}
+	return canonical
+}
+
+func (f *formatter) statement(stmt parser.Statement) {
+	switch s := stmt.(type) {
+	case *parser.VariableDeclaration:
+		f.line(keyword(s.Token, "VAR") + " " + s.Name.Value + " = " + f.expr(s.Value))
+	case *parser.FigurDeclaration:
+		f.line(keyword(s.Token, "FIGUR") + " " + s.Name.Value + " = " + f.expr(s.Value))
+	case *parser.FunctionDeclaration:
+		params := make([]string, len(s.Parameters))
+		for i, param := range s.Parameters {
+			params[i] = param.Value
+		}
+		f.line(keyword(s.Token, "FUNKTION") + " " + s.Name.Value + "(" + strings.Join(params, ", ") + ") {")
+		f.block(s.Body)
+		f.line("}")
+	case *parser.ReturnStatement:
+		if s.ReturnValue == nil {
+			f.line(keyword(s.Token, "ZURUECK"))
+		} else {
+			f.line(keyword(s.Token, "ZURUECK") + " " + f.expr(s.ReturnValue))
+		}
+	case *parser.ExpressionStatement:
+		f.line(f.expr(s.Expression))
+	case *parser.BlockStatement:
+		f.line("{")
+		f.block(s)
+		f.line("}")
+	case *parser.IfStatement:
+		f.ifStatement(s, "")
+	case *parser.WhileStatement:
+		f.line(keyword(s.Token, "SOLANGE") + " " + f.expr(s.Condition) + " {")
+		f.block(s.Body)
+		f.line("}")
+	case *parser.ForStatement:
+		f.line(keyword(s.Token, "FUER") + " " + s.Variable.Value + " VON " + f.expr(s.Start) + " BIS " + f.expr(s.End) + " {")
+		f.block(s.Body)
+		f.line("}")
+	case *parser.RepeatStatement:
+		f.line(keyword(s.Token, "WIEDERHOLE") + " " + f.expr(s.Count) + " {")
+		f.block(s.Body)
+		f.line("}")
+	case *parser.GameDeclaration:
+		f.line(keyword(s.Token, "SPIEL") + " \"" + s.Name + "\"")
+	case *parser.EventHandler:
+		header := keyword(s.Token, "WENN_"+strings.ToUpper(s.EventType))
+		if s.Parameters != nil {
+			header += "(" + f.exprList(s.Parameters) + ")"
+		}
+		f.line(header + " {")
+		f.block(s.Body)
+		f.line("}")
+	case *parser.CommentStatement:
+		f.line("//" + s.Text)
+	}
+}
+
+// IsElseIf reports whether the SONST part of an if statement was written
+// as "SONST WENN" rather than as a block containing a single WENN
+func IsElseIf(is *parser.IfStatement) bool {
+	alt := is.Alternative
+	if alt == nil || alt.Token.Type == lexer.TOKEN_LBRACE || len(alt.Statements) != 1 {
+		return false
+	}
+	_, ok := alt.Statements[0].(*parser.IfStatement)
+	return ok
+}
+
+// ifStatement writes a WENN chain; prefix is "} SONST " for else-if parts
+func (f *formatter) ifStatement(is *parser.IfStatement, prefix string) {
+	f.line(prefix + keyword(is.Token, "WENN") + " " + f.expr(is.Condition) + " {")
+	f.block(is.Consequence)
+
+	if is.Alternative == nil {
+		f.line("}")
+		return
+	}
+
+	if IsElseIf(is) {
+		f.ifStatement(is.Alternative.Statements[0].(*parser.IfStatement), "} SONST ")
+		return
+	}
+
+	f.line("} SONST {")
+	f.block(is.Alternative)
+	f.line("}")
+}
+
+// Binding strength of nodes that are not operators
+const atomPrecedence = parser.MEMBER + 1
+
+func precedence(expr parser.Expression) int {
+	switch e := expr.(type) {
+	case *parser.InfixExpression:
+		return parser.Precedence(e.Token.Type)
+	case *parser.AssignmentExpression:
+		return parser.ASSIGN
+	case *parser.PrefixExpression:
+		return parser.PREFIX
+	case *parser.CallExpression:
+		return parser.CALL
+	case *parser.IndexExpression:
+		return parser.INDEX
+	case *parser.MemberExpression:
+		return parser.MEMBER
+	default:
+		return atomPrecedence
+	}
+}
+
+// operand prints expr and adds parentheses if it binds weaker than min.
+// Parsed programs keep their own GroupedExpressions, so this only matters
+// for trees built by other tools.
+func (f *formatter) operand(expr parser.Expression, min int) string {
+	text := f.expr(expr)
+	if expr != nil && precedence(expr) < min {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+func (f *formatter) exprList(exprs []parser.Expression) string {
+	parts := make([]string, len(exprs))
+	for i, e := range exprs {
+		parts[i] = f.expr(e)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (f *formatter) expr(expr parser.Expression) string {
+	switch e := expr.(type) {
+	case *parser.Identifier:
+		return e.Value
+	case *parser.NumberLiteral:
+		if e.Token.Literal != "" {
+			return e.Token.Literal
+		}
+		return strconv.FormatFloat(e.Value, 'f', -1, 64)
+	case *parser.StringLiteral:
+		return "\"" + e.Value + "\""
+	case *parser.BooleanLiteral:
+		if e.Value {
+			return keyword(e.Token, "WAHR")
+		}
+		return keyword(e.Token, "FALSCH")
+	case *parser.ArrayLiteral:
+		return "[" + f.exprList(e.Elements) + "]"
+	case *parser.IndexExpression:
+		return f.operand(e.Left, parser.INDEX) + "[" + f.expr(e.Index) + "]"
+	case *parser.PrefixExpression:
+		right := f.operand(e.Right, parser.PREFIX)
+		if strings.IndexFunc(e.Operator, unicode.IsLetter) >= 0 {
+			return e.Operator + " " + right
+		}
+		return e.Operator + right
+	case *parser.InfixExpression:
+		p := precedence(e)
+		return f.operand(e.Left, p) + " " + e.Operator + " " + f.operand(e.Right, p+1)
+	case *parser.CallExpression:
+		return f.operand(e.Function, parser.CALL) + "(" + f.exprList(e.Arguments) + ")"
+	case *parser.MemberExpression:
+		return f.operand(e.Object, parser.MEMBER) + "." + e.Property.Value
+	case *parser.AssignmentExpression:
+		return f.operand(e.Left, parser.ASSIGN+1) + " = " + f.operand(e.Value, parser.ASSIGN)
+	case *parser.GroupedExpression:
+		return "(" + f.expr(e.Expression) + ")"
+	default:
+		return ""
+	}
+}
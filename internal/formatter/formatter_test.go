@@ -0,0 +1,92 @@
+package formatter
+
+import (
+	"benlang/internal/lexer"
+	"benlang/internal/parser"
+	"benlang/internal/transpiler"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// parseExamples returns the source of every example program that parses
+func parseExamples(t *testing.T) map[string]string {
+	files, err := filepath.Glob("../../beispiele/*/*.ben")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no example programs found: %v", err)
+	}
+
+	sources := map[string]string{}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := parser.New(lexer.New(string(content)))
+		p.ParseProgram()
+		if len(p.Errors()) == 0 {
+			sources[file] = string(content)
+		}
+	}
+	return sources
+}
+
+var jsComment = regexp.MustCompile(`(?m)^\s*//.*\n`)
+
+func transpile(t *testing.T, code string) string {
+	p := parser.New(lexer.New(code))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors: %v", p.Errors())
+	}
+	return jsComment.ReplaceAllString(transpiler.New().Transpile(program), "")
+}
+
+func TestFormatExamples(t *testing.T) {
+	for file, code := range parseExamples(t) {
+		formatted, errs := Source(code)
+		if len(errs) > 0 {
+			t.Fatalf("%s: %v", file, errs)
+		}
+
+		again, _ := Source(formatted)
+		if again != formatted {
+			t.Errorf("%s: formatting is not idempotent", file)
+		}
+
+		if transpile(t, code) != transpile(t, formatted) {
+			t.Errorf("%s: formatted program transpiles differently", file)
+		}
+
+		if strings.Count(formatted, "//") < strings.Count(code, "//") {
+			t.Errorf("%s: comments were lost", file)
+		}
+	}
+}
+
+func TestFormatLayout(t *testing.T) {
+	input := "VAR   x=1 // Start\n\n\n\nWENN x>1{SCHREIBE( \"a\" )}SONST WENN (x<0){\n// nichts\n}SONST{x=-x}\n"
+	want := "VAR x = 1 // Start\n\n" +
+		"WENN x > 1 {\n    SCHREIBE(\"a\")\n} SONST WENN (x < 0) {\n    // nichts\n} SONST {\n    x = -x\n}\n"
+
+	got, errs := Source(input)
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFormatKeepsInvalidCode(t *testing.T) {
+	input := "VAR x = \n"
+	got, errs := Source(input)
+	if len(errs) == 0 {
+		t.Fatal("expected parse errors")
+	}
+	if got != input {
+		t.Errorf("invalid code was changed: %q", got)
+	}
+}
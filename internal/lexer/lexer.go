@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"strings"
 	"unicode"
 )
 
@@ -12,6 +13,7 @@ type Lexer struct {
 	ch           rune // current char under examination
 	line         int  // current line number
 	column       int  // current column number
+	comments     []Token
 }
 
 // New creates a new Lexer for the given input
@@ -76,10 +78,14 @@ func (l *Lexer) NextToken() Token {
 		tok = l.newToken(TOKEN_ASTERISK, l.ch)
 	case '/':
 		if l.peekChar() == '/' {
-			// Skip comment
-			for l.ch != '\n' && l.ch != 0 {
+			// Skip comment, but keep it for tools like the formatter
+			comment := Token{Type: TOKEN_COMMENT, Line: l.line, Column: l.column}
+			start := l.position + 2
+			for l.ch != '\n' && !l.atEOF() {
 				l.readChar()
 			}
+			comment.Literal = strings.TrimSuffix(string(l.input[start:min(l.position, len(l.input))]), "\r")
+			l.comments = append(l.comments, comment)
 			// Return next token after comment
 			return l.NextToken()
 		}
@@ -128,23 +134,34 @@ func (l *Lexer) NextToken() Token {
 	case ']':
 		tok = l.newToken(TOKEN_RBRACKET, l.ch)
 	case '"':
-		tok.Type = TOKEN_STRING
-		tok.Literal = l.readString()
-		tok.Line = l.line
+		str, terminated := l.readString()
+		if terminated {
+			tok.Type = TOKEN_STRING
+			tok.Literal = str
+		} else {
+			// Unterminated strings are reported with their opening quote
+			tok.Type = TOKEN_ILLEGAL
+			tok.Literal = "\"" + str
+		}
 		return tok
 	case 0:
+		if !l.atEOF() {
+			// A literal NUL character in the input must not end the file early
+			tok = l.newToken(TOKEN_ILLEGAL, l.ch)
+			break
+		}
 		tok.Literal = ""
 		tok.Type = TOKEN_EOF
 	default:
+		// Keep the start position: reading up to a line break already
+		// advances l.line
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = LookupIdent(tok.Literal)
-			tok.Line = l.line
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Literal = l.readNumber()
 			tok.Type = TOKEN_NUMBER
-			tok.Line = l.line
 			return tok
 		} else {
 			tok = l.newToken(TOKEN_ILLEGAL, l.ch)
@@ -202,19 +219,27 @@ func (l *Lexer) readNumber() string {
 	return string(l.input[start:l.position])
 }
 
-// readString reads a string literal
-func (l *Lexer) readString() string {
+// readString reads a string literal and reports whether it was terminated
+func (l *Lexer) readString() (string, bool) {
 	l.readChar() // skip opening quote
 	start := l.position
-	for l.ch != '"' && l.ch != 0 {
+	for l.ch != '"' && !l.atEOF() {
 		if l.ch == '\\' && l.peekChar() == '"' {
 			l.readChar() // skip backslash
 		}
 		l.readChar()
 	}
-	str := string(l.input[start:l.position])
+	str := string(l.input[start:min(l.position, len(l.input))])
+	if l.atEOF() {
+		return str, false
+	}
 	l.readChar() // skip closing quote
-	return str
+	return str, true
+}
+
+// atEOF returns true once the whole input has been consumed
+func (l *Lexer) atEOF() bool {
+	return l.position >= len(l.input)
 }
 
 // isLetter returns true if the rune is a letter (including German umlauts)
@@ -227,6 +252,12 @@ func isDigit(ch rune) bool {
 	return ch >= '0' && ch <= '9'
 }
 
+// Comments returns the comments read so far. The literal of a comment
+// token is the text after the leading "//".
+func (l *Lexer) Comments() []Token {
+	return l.comments
+}
+
 // Tokenize returns all tokens from the input
 func (l *Lexer) Tokenize() []Token {
 	var tokens []Token
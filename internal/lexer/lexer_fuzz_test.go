@@ -0,0 +1,91 @@
+package lexer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// addExampleSeeds adds all example programs as fuzzing seeds
+func addExampleSeeds(f *testing.F) {
+	files, _ := filepath.Glob("../../beispiele/*/*.ben")
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err == nil {
+			f.Add(string(content))
+		}
+	}
+}
+
+func FuzzNextToken(f *testing.F) {
+	addExampleSeeds(f)
+	f.Add(`VAR x = "offen`)
+	f.Add(`!`)
+	f.Add("VAR a = 1\x00VAR b = 2")
+	f.Add(`// nur ein Kommentar`)
+
+	f.Fuzz(func(t *testing.T, input string) {
+		maxLine := strings.Count(input, "\n") + 1
+		// Every token consumes at least one character, so EOF must come
+		// after at most one token per rune
+		limit := utf8.RuneCountInString(input) + 1
+
+		l := New(input)
+		for i := 0; ; i++ {
+			if i > limit {
+				t.Fatalf("no EOF after %d tokens", i)
+			}
+
+			tok := l.NextToken()
+			if tok.Line < 1 || tok.Line > maxLine {
+				t.Fatalf("token %q has line %d, input has %d lines", tok.Literal, tok.Line, maxLine)
+			}
+			if tok.Column < 0 {
+				t.Fatalf("token %q has negative column %d", tok.Literal, tok.Column)
+			}
+			if tok.Type == TOKEN_EOF {
+				break
+			}
+			if tok.Literal == "" && tok.Type != TOKEN_STRING {
+				t.Fatalf("token of type %q has an empty literal", tok.Type)
+			}
+		}
+	})
+}
+
+func TestUnterminatedString(t *testing.T) {
+	l := New(`ZEIGE_TEXT("Hallo`)
+
+	tests := []struct {
+		expectedType    TokenType
+		expectedLiteral string
+	}{
+		{TOKEN_IDENT, "ZEIGE_TEXT"},
+		{TOKEN_LPAREN, "("},
+		{TOKEN_ILLEGAL, `"Hallo`},
+		{TOKEN_EOF, ""},
+	}
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestNulCharacter(t *testing.T) {
+	l := New("a\x00b")
+
+	expected := []TokenType{TOKEN_IDENT, TOKEN_ILLEGAL, TOKEN_IDENT, TOKEN_EOF}
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+	}
+}
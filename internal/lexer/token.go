@@ -1,5 +1,7 @@
 package lexer
 
+import "sort"
+
 // TokenType represents the type of a token
 type TokenType string
 
@@ -7,6 +9,7 @@ const (
 	// Special tokens
 	TOKEN_ILLEGAL TokenType = "ILLEGAL"
 	TOKEN_EOF     TokenType = "EOF"
+	TOKEN_COMMENT TokenType = "COMMENT" // only collected, never returned by NextToken
 
 	// Identifiers and literals
 	TOKEN_IDENT  TokenType = "IDENT"  // variable names, function names
@@ -133,6 +136,20 @@ func LookupIdent(ident string) TokenType {
 	return TOKEN_IDENT
 }
 
+// Keywords returns the canonical spelling of every keyword, sorted
+func Keywords() []string {
+	seen := map[TokenType]bool{}
+	var names []string
+	for _, tok := range keywords {
+		if !seen[tok] {
+			seen[tok] = true
+			names = append(names, string(tok))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // toLower converts a string to lowercase (handling German umlauts)
 func toLower(s string) string {
 	result := make([]rune, 0, len(s))
@@ -0,0 +1,437 @@
+package lsp
+
+import (
+	"benlang/internal/analysis"
+	"benlang/internal/formatter"
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Server is a language server for BenLang that talks JSON-RPC over a
+// reader and writer, normally stdin and stdout
+type Server struct {
+	in   *bufio.Reader
+	out  io.Writer
+	docs map[string]string // open documents by URI
+}
+
+// New creates a server reading from in and writing to out
+func New(in io.Reader, out io.Writer) *Server {
+	return &Server{in: bufio.NewReader(in), out: out, docs: map[string]string{}}
+}
+
+// Run handles messages until the client sends "exit" or closes the input
+func (s *Server) Run() error {
+	for {
+		body, err := readMessage(s.in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.reply(nil, nil, &rpcError{Code: codeParseError, Message: err.Error()})
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+
+		result, rpcErr := s.handle(req)
+		if req.ID != nil {
+			s.reply(req.ID, result, rpcErr)
+		}
+	}
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, rpcErr *rpcError) {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+	if rpcErr != nil {
+		msg["error"] = rpcErr
+	} else {
+		msg["result"] = result
+	}
+	writeMessage(s.out, msg)
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	writeMessage(s.out, map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (s *Server) handle(req request) (interface{}, *rpcError) {
+	decode := func(v interface{}) *rpcError {
+		if err := json.Unmarshal(req.Params, v); err != nil {
+			return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return nil
+	}
+
+	switch req.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":           1, // full text on every change
+				"completionProvider":         map[string]interface{}{"triggerCharacters": []string{"."}},
+				"hoverProvider":              true,
+				"definitionProvider":         true,
+				"documentSymbolProvider":     true,
+				"documentFormattingProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "benlang"},
+		}, nil
+	case "initialized", "$/cancelRequest", "$/setTrace", "workspace/didChangeConfiguration":
+		return nil, nil
+	case "shutdown":
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		s.docs[p.TextDocument.URI] = p.TextDocument.Text
+		s.publishDiagnostics(p.TextDocument.URI)
+		return nil, nil
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		if n := len(p.ContentChanges); n > 0 {
+			s.docs[p.TextDocument.URI] = p.ContentChanges[n-1].Text
+		}
+		s.publishDiagnostics(p.TextDocument.URI)
+		return nil, nil
+	case "textDocument/didSave":
+		var p documentParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		s.publishDiagnostics(p.TextDocument.URI)
+		return nil, nil
+	case "textDocument/didClose":
+		var p documentParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		delete(s.docs, p.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []diagnostic{}})
+		return nil, nil
+
+	case "textDocument/completion":
+		var p textDocumentPositionParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return s.completion(p), nil
+	case "textDocument/hover":
+		var p textDocumentPositionParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return s.hover(p), nil
+	case "textDocument/definition":
+		var p textDocumentPositionParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return s.definition(p), nil
+	case "textDocument/documentSymbol":
+		var p documentParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return s.documentSymbols(p.TextDocument.URI), nil
+	case "textDocument/formatting":
+		var p documentParams
+		if err := decode(&p); err != nil {
+			return nil, err
+		}
+		return s.formatting(p.TextDocument.URI), nil
+	}
+
+	if req.ID == nil {
+		return nil, nil
+	}
+	return nil, &rpcError{Code: codeMethodNotFound, Message: "Unbekannte Methode: " + req.Method}
+}
+
+// uriToPath returns the file path of a file:// URI, or "" for other URIs
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	path := u.Path
+	// file:///C:/... on Windows
+	if runtime.GOOS == "windows" && len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
+func pathToURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// analyze builds the symbol table for the project of a document: all .ben
+// files in the same folder, with open documents taking precedence over
+// the files on disk. It returns the analysis name of the document.
+func (s *Server) analyze(uri string) (*analysis.Result, string) {
+	path := uriToPath(uri)
+	if path == "" {
+		return analysis.Analyze([]analysis.File{{Name: uri, Code: s.docs[uri]}}), uri
+	}
+
+	names := []string{path}
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.ben"))
+	for _, m := range matches {
+		if m != path {
+			names = append(names, m)
+		}
+	}
+	sort.Strings(names[1:])
+
+	files := make([]analysis.File, 0, len(names))
+	for _, name := range names {
+		code, open := s.docs[s.uriFor(name)]
+		if !open {
+			data, err := os.ReadFile(name)
+			if err != nil {
+				continue
+			}
+			code = string(data)
+		}
+		files = append(files, analysis.File{Name: name, Code: code})
+	}
+	return analysis.Analyze(files), path
+}
+
+// uriFor maps an analysis file name back to its URI. Open documents keep
+// the URI the client used, since clients encode paths differently.
+func (s *Server) uriFor(name string) string {
+	if _, open := s.docs[name]; open {
+		return name
+	}
+	for uri := range s.docs {
+		if uriToPath(uri) == name {
+			return uri
+		}
+	}
+	return pathToURI(name)
+}
+
+// Positions: LSP counts lines from 0 and characters in UTF-16 code units,
+// the analysis counts both from 1 and columns in characters
+
+func lineText(r *analysis.Result, file string, line int) string {
+	for _, f := range r.Files {
+		if f.Name == file {
+			lines := strings.Split(f.Code, "\n")
+			if line >= 1 && line <= len(lines) {
+				return lines[line-1]
+			}
+		}
+	}
+	return ""
+}
+
+func toColumn(text string, character int) int {
+	units := 0
+	column := 1
+	for _, r := range text {
+		if units >= character {
+			break
+		}
+		units += len(utf16.Encode([]rune{r}))
+		column++
+	}
+	return column
+}
+
+func toCharacter(text string, column int) int {
+	units := 0
+	for i, r := range []rune(text) {
+		if i >= column-1 {
+			break
+		}
+		units += len(utf16.Encode([]rune{r}))
+	}
+	return units
+}
+
+func toPosition(r *analysis.Result, file string, line, column int) position {
+	if line < 1 {
+		line = 1
+	}
+	return position{Line: line - 1, Character: toCharacter(lineText(r, file, line), column)}
+}
+
+func toRange(r *analysis.Result, file string, line, column, length int) lspRange {
+	start := toPosition(r, file, line, column)
+	end := toPosition(r, file, line, column+length)
+	return lspRange{Start: start, End: end}
+}
+
+// cursor converts an LSP position into an analysis line and column
+func cursor(r *analysis.Result, file string, p position) (int, int) {
+	line := p.Line + 1
+	return line, toColumn(lineText(r, file, line), p.Character)
+}
+
+// publishDiagnostics sends diagnostics for every open document in the
+// project of uri, since a change in one file can affect the others
+func (s *Server) publishDiagnostics(uri string) {
+	r, _ := s.analyze(uri)
+
+	for _, f := range r.Files {
+		docURI := s.uriFor(f.Name)
+		if _, open := s.docs[docURI]; !open {
+			continue
+		}
+		list := []diagnostic{}
+		for _, d := range r.DiagnosticsFor(f.Name) {
+			severity := severityWarning
+			if d.Severity == analysis.SeverityError {
+				severity = severityError
+			}
+			list = append(list, diagnostic{
+				Range:    toRange(r, f.Name, d.Line, max(d.Column, 1), max(d.Length, 1)),
+				Severity: severity,
+				Source:   "benlang",
+				Message:  d.Message,
+			})
+		}
+		s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: docURI, Diagnostics: list})
+	}
+}
+
+func completionKind(kind analysis.Kind, detail string) int {
+	switch kind {
+	case analysis.KindFunction, analysis.KindBuiltin:
+		return completionFunction
+	case analysis.KindKeyword:
+		return completionKeyword
+	case analysis.KindProperty:
+		if strings.HasPrefix(detail, "(") {
+			return completionMethod
+		}
+		return completionProperty
+	default:
+		return completionVariable
+	}
+}
+
+func (s *Server) completion(p textDocumentPositionParams) []completionItem {
+	r, file := s.analyze(p.TextDocument.URI)
+	line, column := cursor(r, file, p.Position)
+
+	items := []completionItem{}
+	for _, c := range r.Completions(file, line, column) {
+		items = append(items, completionItem{
+			Label:         c.Label,
+			Kind:          completionKind(c.Kind, c.Detail),
+			Detail:        c.Detail,
+			Documentation: c.Doc,
+			InsertText:    c.Insert,
+		})
+	}
+	return items
+}
+
+func (s *Server) hover(p textDocumentPositionParams) *hover {
+	r, file := s.analyze(p.TextDocument.URI)
+	line, column := cursor(r, file, p.Position)
+
+	text := r.Hover(file, line, column)
+	if text == "" {
+		return nil
+	}
+	return &hover{Contents: markupContent{Kind: "markdown", Value: text}}
+}
+
+func (s *Server) definition(p textDocumentPositionParams) *location {
+	r, file := s.analyze(p.TextDocument.URI)
+	line, column := cursor(r, file, p.Position)
+
+	sym := r.Definition(file, line, column)
+	if sym == nil {
+		return nil
+	}
+	return &location{
+		URI:   s.uriFor(sym.File),
+		Range: toRange(r, sym.File, sym.Line, sym.Column, len([]rune(sym.Name))),
+	}
+}
+
+// documentSymbols lists functions, variables and figures; the parameters
+// and local variables of a function are its children
+func (s *Server) documentSymbols(uri string) []documentSymbol {
+	r, file := s.analyze(uri)
+
+	list := []documentSymbol{}
+	children := map[string][]documentSymbol{}
+	var order []*analysis.Symbol
+
+	for _, sym := range r.FileSymbols(file) {
+		if sym.Kind == analysis.KindLoopVariable {
+			continue
+		}
+		nameRange := toRange(r, file, sym.Line, sym.Column, len([]rune(sym.Name)))
+		ds := documentSymbol{Name: sym.Name, Detail: sym.Signature(), Kind: symbolVariable, Range: nameRange, SelectionRange: nameRange}
+
+		switch sym.Kind {
+		case analysis.KindFunction:
+			ds.Kind = symbolFunction
+			if sym.End.Line > 0 && sym.End.Line < 1<<30 {
+				ds.Range.End = toPosition(r, file, sym.End.Line, sym.End.Column+1)
+			}
+		case analysis.KindFigure:
+			ds.Kind = symbolObject
+		}
+
+		if sym.Container != "" {
+			children[sym.Container] = append(children[sym.Container], ds)
+			continue
+		}
+		order = append(order, sym)
+		list = append(list, ds)
+	}
+
+	for i, sym := range order {
+		if sym.Kind == analysis.KindFunction {
+			list[i].Children = children[sym.Name]
+		}
+	}
+	return list
+}
+
+// formatting replaces the whole document with its formatted source; code
+// with syntax errors is left alone
+func (s *Server) formatting(uri string) []textEdit {
+	code := s.docs[uri]
+	formatted, errs := formatter.Source(code)
+	if len(errs) > 0 || formatted == code {
+		return []textEdit{}
+	}
+	lines := strings.Count(code, "\n")
+	return []textEdit{{
+		Range:   lspRange{Start: position{0, 0}, End: position{Line: lines + 1, Character: 0}},
+		NewText: formatted,
+	}}
+}
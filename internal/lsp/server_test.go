@@ -0,0 +1,165 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// session runs the server on a scripted list of messages and returns all
+// messages it wrote
+func session(t *testing.T, messages ...map[string]interface{}) []map[string]json.RawMessage {
+	var in bytes.Buffer
+	for _, msg := range messages {
+		msg["jsonrpc"] = "2.0"
+		if err := writeMessage(&in, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeMessage(&in, map[string]interface{}{"jsonrpc": "2.0", "method": "exit"})
+
+	var out bytes.Buffer
+	if err := New(&in, &out).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var replies []map[string]json.RawMessage
+	r := bufio.NewReader(&out)
+	for {
+		body, err := readMessage(r)
+		if err != nil {
+			break
+		}
+		var msg map[string]json.RawMessage
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, msg)
+	}
+	return replies
+}
+
+// result returns the result of the reply with the given id
+func result(t *testing.T, replies []map[string]json.RawMessage, id int, v interface{}) {
+	for _, msg := range replies {
+		if string(msg["id"]) == strings.TrimSpace(string(mustJSON(id))) {
+			if errMsg, ok := msg["error"]; ok {
+				t.Fatalf("request %d failed: %s", id, errMsg)
+			}
+			if err := json.Unmarshal(msg["result"], v); err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+	}
+	t.Fatalf("no reply for request %d", id)
+}
+
+func mustJSON(v interface{}) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+func at(uri string, line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]string{"uri": uri},
+		"position":     map[string]int{"line": line, "character": character},
+	}
+}
+
+func TestSession(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "figuren.ben"), []byte("FIGUR held = LADE_BILD(\"held.png\")\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mainPath := filepath.Join(dir, "hauptspiel.ben")
+	uri := pathToURI(mainPath)
+	code := "VAR größe = 1\nWENN_START {\n  held.x = größe\n  FLIEGE()\n}\n"
+
+	replies := session(t,
+		map[string]interface{}{"id": 1, "method": "initialize", "params": map[string]interface{}{}},
+		map[string]interface{}{"method": "textDocument/didOpen", "params": map[string]interface{}{
+			"textDocument": map[string]string{"uri": uri, "languageId": "benlang", "text": code},
+		}},
+		map[string]interface{}{"id": 2, "method": "textDocument/definition", "params": at(uri, 2, 3)},
+		map[string]interface{}{"id": 3, "method": "textDocument/hover", "params": at(uri, 2, 4)},
+		map[string]interface{}{"id": 4, "method": "textDocument/completion", "params": at(uri, 2, 8)},
+		map[string]interface{}{"id": 5, "method": "textDocument/documentSymbol", "params": map[string]interface{}{"textDocument": map[string]string{"uri": uri}}},
+		map[string]interface{}{"id": 6, "method": "textDocument/formatting", "params": map[string]interface{}{"textDocument": map[string]string{"uri": uri}}},
+		map[string]interface{}{"id": 7, "method": "textDocument/definition", "params": at(uri, 2, 13)},
+		map[string]interface{}{"id": 8, "method": "unbekannt"},
+	)
+
+	var init struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	result(t, replies, 1, &init)
+	if init.Capabilities["definitionProvider"] != true {
+		t.Errorf("unexpected capabilities %v", init.Capabilities)
+	}
+
+	var published publishDiagnosticsParams
+	for _, msg := range replies {
+		if string(msg["method"]) == `"textDocument/publishDiagnostics"` {
+			json.Unmarshal(msg["params"], &published)
+		}
+	}
+	if published.URI != uri || len(published.Diagnostics) != 1 || !strings.Contains(published.Diagnostics[0].Message, "FLIEGE") {
+		t.Errorf("unexpected diagnostics %+v", published)
+	}
+
+	var def location
+	result(t, replies, 2, &def)
+	if def.URI != pathToURI(filepath.Join(dir, "figuren.ben")) || def.Range.Start != (position{0, 6}) {
+		t.Errorf("unexpected definition %+v", def)
+	}
+
+	// "größe" in line 3 is preceded by a multi-byte name in UTF-16 units
+	var def2 location
+	result(t, replies, 7, &def2)
+	if def2.URI != uri || def2.Range.Start != (position{0, 4}) || def2.Range.End != (position{0, 9}) {
+		t.Errorf("unexpected definition %+v", def2)
+	}
+
+	var h hover
+	result(t, replies, 3, &h)
+	if !strings.Contains(h.Contents.Value, "Definiert in") {
+		t.Errorf("unexpected hover %+v", h)
+	}
+
+	var items []completionItem
+	result(t, replies, 4, &items)
+	found := false
+	for _, item := range items {
+		if item.Label == "x" && item.Kind == completionProperty {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected property completion, got %+v", items)
+	}
+
+	var symbols []documentSymbol
+	result(t, replies, 5, &symbols)
+	if len(symbols) != 1 || symbols[0].Name != "größe" {
+		t.Errorf("unexpected symbols %+v", symbols)
+	}
+
+	var edits []textEdit
+	result(t, replies, 6, &edits)
+	if len(edits) != 1 || !strings.Contains(edits[0].NewText, "    held.x = größe") {
+		t.Errorf("unexpected edits %+v", edits)
+	}
+
+	for _, msg := range replies {
+		if string(msg["id"]) == "8" {
+			if _, ok := msg["error"]; !ok {
+				t.Error("expected error for unknown method")
+			}
+		}
+	}
+}
@@ -127,6 +127,15 @@ type MemberExpression struct {
 func (me *MemberExpression) expressionNode()      {}
 func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
 
+// GroupedExpression represents an expression in parentheses: (a + b)
+type GroupedExpression struct {
+	Token      lexer.Token // the '(' token
+	Expression Expression
+}
+
+func (ge *GroupedExpression) expressionNode()      {}
+func (ge *GroupedExpression) TokenLiteral() string { return ge.Token.Literal }
+
 // AssignmentExpression represents x = 5 or objekt.eigenschaft = wert
 type AssignmentExpression struct {
 	Token lexer.Token
@@ -190,6 +199,7 @@ func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
 type BlockStatement struct {
 	Token      lexer.Token // the '{' token
 	Statements []Statement
+	End        lexer.Token // the '}' token
 }
 
 func (bs *BlockStatement) statementNode()       {}
@@ -257,3 +267,14 @@ type EventHandler struct {
 
 func (eh *EventHandler) statementNode()       {}
 func (eh *EventHandler) TokenLiteral() string { return eh.Token.Literal }
+
+// CommentStatement represents a // comment. Trailing comments follow code
+// on the same line, all others stand on their own line.
+type CommentStatement struct {
+	Token    lexer.Token // the COMMENT token
+	Text     string      // text after the "//"
+	Trailing bool
+}
+
+func (cs *CommentStatement) statementNode()       {}
+func (cs *CommentStatement) TokenLiteral() string { return cs.Token.Literal }
@@ -0,0 +1,90 @@
+package parser
+
+import "reflect"
+
+// Inspect walks the tree in source order and calls fn for every node. If
+// fn returns false, the children of that node are skipped. Nodes left
+// empty by syntax errors are not visited.
+func Inspect(node Node, fn func(Node) bool) {
+	if node == nil {
+		return
+	}
+	if v := reflect.ValueOf(node); v.Kind() == reflect.Ptr && v.IsNil() {
+		return
+	}
+	if !fn(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			Inspect(s, fn)
+		}
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			Inspect(s, fn)
+		}
+	case *ArrayLiteral:
+		for _, e := range n.Elements {
+			Inspect(e, fn)
+		}
+	case *IndexExpression:
+		Inspect(n.Left, fn)
+		Inspect(n.Index, fn)
+	case *PrefixExpression:
+		Inspect(n.Right, fn)
+	case *InfixExpression:
+		Inspect(n.Left, fn)
+		Inspect(n.Right, fn)
+	case *CallExpression:
+		Inspect(n.Function, fn)
+		for _, a := range n.Arguments {
+			Inspect(a, fn)
+		}
+	case *MemberExpression:
+		Inspect(n.Object, fn)
+		Inspect(n.Property, fn)
+	case *GroupedExpression:
+		Inspect(n.Expression, fn)
+	case *AssignmentExpression:
+		Inspect(n.Left, fn)
+		Inspect(n.Value, fn)
+	case *VariableDeclaration:
+		Inspect(n.Name, fn)
+		Inspect(n.Value, fn)
+	case *FigurDeclaration:
+		Inspect(n.Name, fn)
+		Inspect(n.Value, fn)
+	case *FunctionDeclaration:
+		Inspect(n.Name, fn)
+		for _, p := range n.Parameters {
+			Inspect(p, fn)
+		}
+		Inspect(n.Body, fn)
+	case *ReturnStatement:
+		Inspect(n.ReturnValue, fn)
+	case *ExpressionStatement:
+		Inspect(n.Expression, fn)
+	case *IfStatement:
+		Inspect(n.Condition, fn)
+		Inspect(n.Consequence, fn)
+		Inspect(n.Alternative, fn)
+	case *WhileStatement:
+		Inspect(n.Condition, fn)
+		Inspect(n.Body, fn)
+	case *ForStatement:
+		Inspect(n.Variable, fn)
+		Inspect(n.Start, fn)
+		Inspect(n.End, fn)
+		Inspect(n.Body, fn)
+	case *RepeatStatement:
+		Inspect(n.Count, fn)
+		Inspect(n.Body, fn)
+	case *EventHandler:
+		for _, p := range n.Parameters {
+			Inspect(p, fn)
+		}
+		Inspect(n.Body, fn)
+	}
+}
@@ -0,0 +1,48 @@
+package parser
+
+import (
+	"benlang/internal/lexer"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	program := New(lexer.New(`FUNKTION f(a) {
+  WENN a > 1 {
+    SCHREIBE("gross")
+  } SONST {
+    WIEDERHOLE 2 { SCHREIBE("klein") }
+  }
+}
+WENN_START { f([1, 2][0]) }
+`)).ParseProgram()
+
+	var strings, calls int
+	Inspect(program, func(n Node) bool {
+		switch n.(type) {
+		case *StringLiteral:
+			strings++
+		case *CallExpression:
+			calls++
+		}
+		return true
+	})
+	if strings != 2 || calls != 3 {
+		t.Errorf("found %d strings and %d calls, want 2 and 3", strings, calls)
+	}
+
+	strings, calls = 0, 0
+	Inspect(program, func(n Node) bool {
+		switch n.(type) {
+		case *FunctionDeclaration:
+			return false
+		case *StringLiteral:
+			strings++
+		case *CallExpression:
+			calls++
+		}
+		return true
+	})
+	if strings != 0 || calls != 1 {
+		t.Errorf("skipping the function found %d strings and %d calls, want 0 and 1", strings, calls)
+	}
+}
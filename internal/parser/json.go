@@ -0,0 +1,626 @@
+package parser
+
+import (
+	"benlang/internal/lexer"
+	"encoding/json"
+	"fmt"
+)
+
+// JSONVersion is the version of the AST JSON format. It is increased
+// whenever a change would break existing readers.
+const JSONVersion = 1
+
+// jsonDocument is the top-level object of the AST JSON format as read
+type jsonDocument struct {
+	Version int             `json:"version"`
+	Program json.RawMessage `json:"program"`
+}
+
+// jsonToken is the JSON form of a lexer token, carrying its position
+type jsonToken struct {
+	Type    lexer.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+	Line    int             `json:"line"`
+	Column  int             `json:"column"`
+}
+
+// jsonObject is a single encoded node. Map keys are sorted by
+// encoding/json, which keeps the output stable.
+type jsonObject map[string]interface{}
+
+// EncodeJSON serialises a program to the versioned AST JSON format
+func EncodeJSON(program *Program) ([]byte, error) {
+	return json.MarshalIndent(jsonObject{
+		"version": JSONVersion,
+		"program": encodeProgram(program),
+	}, "", "  ")
+}
+
+// DecodeJSON reads a program from the AST JSON format
+func DecodeJSON(data []byte) (*Program, error) {
+	var doc jsonDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Version != JSONVersion {
+		return nil, fmt.Errorf("AST-Version %d wird nicht unterstützt (erwartet %d)", doc.Version, JSONVersion)
+	}
+
+	d := &jsonDecoder{}
+	program := d.program(doc.Program)
+	if d.err != nil {
+		return nil, d.err
+	}
+	return program, nil
+}
+
+func encodeProgram(program *Program) jsonObject {
+	return jsonObject{
+		"type":       "Program",
+		"statements": encodeStatements(program.Statements),
+	}
+}
+
+func encodeToken(tok lexer.Token) jsonToken {
+	return jsonToken{Type: tok.Type, Literal: tok.Literal, Line: tok.Line, Column: tok.Column}
+}
+
+func encodeStatements(stmts []Statement) []interface{} {
+	out := make([]interface{}, len(stmts))
+	for i, stmt := range stmts {
+		out[i] = encodeStatement(stmt)
+	}
+	return out
+}
+
+func encodeExpressions(exprs []Expression) []interface{} {
+	if exprs == nil {
+		return nil
+	}
+	out := make([]interface{}, len(exprs))
+	for i, expr := range exprs {
+		out[i] = encodeExpression(expr)
+	}
+	return out
+}
+
+func encodeIdentifier(ident *Identifier) interface{} {
+	if ident == nil {
+		return nil
+	}
+	return jsonObject{
+		"type":  "Identifier",
+		"token": encodeToken(ident.Token),
+		"value": ident.Value,
+	}
+}
+
+func encodeBlock(block *BlockStatement) interface{} {
+	if block == nil {
+		return nil
+	}
+	return jsonObject{
+		"type":       "BlockStatement",
+		"token":      encodeToken(block.Token),
+		"statements": encodeStatements(block.Statements),
+		"end":        encodeToken(block.End),
+	}
+}
+
+func encodeStatement(stmt Statement) interface{} {
+	switch s := stmt.(type) {
+	case *VariableDeclaration:
+		return jsonObject{
+			"type":  "VariableDeclaration",
+			"token": encodeToken(s.Token),
+			"name":  encodeIdentifier(s.Name),
+			"value": encodeExpression(s.Value),
+		}
+	case *FigurDeclaration:
+		return jsonObject{
+			"type":  "FigurDeclaration",
+			"token": encodeToken(s.Token),
+			"name":  encodeIdentifier(s.Name),
+			"value": encodeExpression(s.Value),
+		}
+	case *FunctionDeclaration:
+		params := make([]interface{}, len(s.Parameters))
+		for i, param := range s.Parameters {
+			params[i] = encodeIdentifier(param)
+		}
+		return jsonObject{
+			"type":       "FunctionDeclaration",
+			"token":      encodeToken(s.Token),
+			"name":       encodeIdentifier(s.Name),
+			"parameters": params,
+			"body":       encodeBlock(s.Body),
+		}
+	case *ReturnStatement:
+		return jsonObject{
+			"type":        "ReturnStatement",
+			"token":       encodeToken(s.Token),
+			"returnValue": encodeExpression(s.ReturnValue),
+		}
+	case *ExpressionStatement:
+		return jsonObject{
+			"type":       "ExpressionStatement",
+			"token":      encodeToken(s.Token),
+			"expression": encodeExpression(s.Expression),
+		}
+	case *BlockStatement:
+		return encodeBlock(s)
+	case *IfStatement:
+		return jsonObject{
+			"type":        "IfStatement",
+			"token":       encodeToken(s.Token),
+			"condition":   encodeExpression(s.Condition),
+			"consequence": encodeBlock(s.Consequence),
+			"alternative": encodeBlock(s.Alternative),
+		}
+	case *WhileStatement:
+		return jsonObject{
+			"type":      "WhileStatement",
+			"token":     encodeToken(s.Token),
+			"condition": encodeExpression(s.Condition),
+			"body":      encodeBlock(s.Body),
+		}
+	case *ForStatement:
+		return jsonObject{
+			"type":     "ForStatement",
+			"token":    encodeToken(s.Token),
+			"variable": encodeIdentifier(s.Variable),
+			"start":    encodeExpression(s.Start),
+			"end":      encodeExpression(s.End),
+			"body":     encodeBlock(s.Body),
+		}
+	case *RepeatStatement:
+		return jsonObject{
+			"type":  "RepeatStatement",
+			"token": encodeToken(s.Token),
+			"count": encodeExpression(s.Count),
+			"body":  encodeBlock(s.Body),
+		}
+	case *GameDeclaration:
+		return jsonObject{
+			"type":  "GameDeclaration",
+			"token": encodeToken(s.Token),
+			"name":  s.Name,
+		}
+	case *EventHandler:
+		return jsonObject{
+			"type":       "EventHandler",
+			"token":      encodeToken(s.Token),
+			"eventType":  s.EventType,
+			"parameters": encodeExpressions(s.Parameters),
+			"body":       encodeBlock(s.Body),
+		}
+	case *CommentStatement:
+		return jsonObject{
+			"type":     "CommentStatement",
+			"token":    encodeToken(s.Token),
+			"text":     s.Text,
+			"trailing": s.Trailing,
+		}
+	default:
+		return nil
+	}
+}
+
+func encodeExpression(expr Expression) interface{} {
+	switch e := expr.(type) {
+	case *Identifier:
+		return encodeIdentifier(e)
+	case *NumberLiteral:
+		return jsonObject{
+			"type":  "NumberLiteral",
+			"token": encodeToken(e.Token),
+			"value": e.Value,
+		}
+	case *StringLiteral:
+		return jsonObject{
+			"type":  "StringLiteral",
+			"token": encodeToken(e.Token),
+			"value": e.Value,
+		}
+	case *BooleanLiteral:
+		return jsonObject{
+			"type":  "BooleanLiteral",
+			"token": encodeToken(e.Token),
+			"value": e.Value,
+		}
+	case *ArrayLiteral:
+		return jsonObject{
+			"type":     "ArrayLiteral",
+			"token":    encodeToken(e.Token),
+			"elements": encodeExpressions(e.Elements),
+		}
+	case *IndexExpression:
+		return jsonObject{
+			"type":  "IndexExpression",
+			"token": encodeToken(e.Token),
+			"left":  encodeExpression(e.Left),
+			"index": encodeExpression(e.Index),
+		}
+	case *PrefixExpression:
+		return jsonObject{
+			"type":     "PrefixExpression",
+			"token":    encodeToken(e.Token),
+			"operator": e.Operator,
+			"right":    encodeExpression(e.Right),
+		}
+	case *InfixExpression:
+		return jsonObject{
+			"type":     "InfixExpression",
+			"token":    encodeToken(e.Token),
+			"left":     encodeExpression(e.Left),
+			"operator": e.Operator,
+			"right":    encodeExpression(e.Right),
+		}
+	case *CallExpression:
+		return jsonObject{
+			"type":      "CallExpression",
+			"token":     encodeToken(e.Token),
+			"function":  encodeExpression(e.Function),
+			"arguments": encodeExpressions(e.Arguments),
+		}
+	case *MemberExpression:
+		return jsonObject{
+			"type":     "MemberExpression",
+			"token":    encodeToken(e.Token),
+			"object":   encodeExpression(e.Object),
+			"property": encodeIdentifier(e.Property),
+		}
+	case *AssignmentExpression:
+		return jsonObject{
+			"type":  "AssignmentExpression",
+			"token": encodeToken(e.Token),
+			"left":  encodeExpression(e.Left),
+			"value": encodeExpression(e.Value),
+		}
+	case *GroupedExpression:
+		return jsonObject{
+			"type":       "GroupedExpression",
+			"token":      encodeToken(e.Token),
+			"expression": encodeExpression(e.Expression),
+		}
+	default:
+		return nil
+	}
+}
+
+// jsonDecoder rebuilds AST nodes and remembers the first error it hits
+type jsonDecoder struct {
+	err error
+}
+
+// jsonNode is an encoded node whose fields have not been decoded yet
+type jsonNode map[string]json.RawMessage
+
+func (d *jsonDecoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, args...)
+	}
+}
+
+// node unmarshals raw into a jsonNode; it returns nil for JSON null
+func (d *jsonDecoder) node(raw json.RawMessage) jsonNode {
+	if d.err != nil || len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	var n jsonNode
+	if err := json.Unmarshal(raw, &n); err != nil {
+		d.fail("Ungültiger AST-Knoten: %v", err)
+		return nil
+	}
+	return n
+}
+
+// require records an error when a mandatory child node is missing
+func (d *jsonDecoder) require(present bool, node, child string) {
+	if !present {
+		d.fail("%s ohne '%s'", node, child)
+	}
+}
+
+func (d *jsonDecoder) field(n jsonNode, key string, v interface{}) {
+	raw, ok := n[key]
+	if d.err != nil || !ok {
+		return
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		d.fail("Ungültiges Feld '%s' in %s: %v", key, n.typeName(), err)
+	}
+}
+
+func (n jsonNode) typeName() string {
+	var t string
+	json.Unmarshal(n["type"], &t)
+	return t
+}
+
+func (d *jsonDecoder) token(n jsonNode) lexer.Token {
+	return d.tokenField(n, "token")
+}
+
+func (d *jsonDecoder) tokenField(n jsonNode, key string) lexer.Token {
+	var tok jsonToken
+	d.field(n, key, &tok)
+	return lexer.Token{Type: tok.Type, Literal: tok.Literal, Line: tok.Line, Column: tok.Column}
+}
+
+func (d *jsonDecoder) list(n jsonNode, key string) []json.RawMessage {
+	var raws []json.RawMessage
+	d.field(n, key, &raws)
+	return raws
+}
+
+func (d *jsonDecoder) program(raw json.RawMessage) *Program {
+	n := d.node(raw)
+	if n == nil {
+		d.fail("AST enthält kein Programm")
+		return nil
+	}
+	if n.typeName() != "Program" {
+		d.fail("Erwartet 'Program', aber '%s' gefunden", n.typeName())
+		return nil
+	}
+	return &Program{Statements: d.statements(n, "statements")}
+}
+
+func (d *jsonDecoder) statements(n jsonNode, key string) []Statement {
+	stmts := []Statement{}
+	for _, raw := range d.list(n, key) {
+		stmt := d.statement(raw)
+		if stmt == nil {
+			d.fail("Ungültige Anweisung in '%s'", key)
+			return stmts
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+func (d *jsonDecoder) expressions(n jsonNode, key string) []Expression {
+	raws := d.list(n, key)
+	if raws == nil {
+		return nil
+	}
+	exprs := make([]Expression, len(raws))
+	for i, raw := range raws {
+		exprs[i] = d.expression(raw)
+	}
+	return exprs
+}
+
+func (d *jsonDecoder) identifier(raw json.RawMessage) *Identifier {
+	n := d.node(raw)
+	if n == nil {
+		return nil
+	}
+	if n.typeName() != "Identifier" {
+		d.fail("Erwartet 'Identifier', aber '%s' gefunden", n.typeName())
+		return nil
+	}
+	ident := &Identifier{Token: d.token(n)}
+	d.field(n, "value", &ident.Value)
+	if d.err == nil && !IsIdentifier(ident.Value) {
+		d.fail("'%s' ist kein gültiger Name", ident.Value)
+	}
+	return ident
+}
+
+func (d *jsonDecoder) block(raw json.RawMessage) *BlockStatement {
+	n := d.node(raw)
+	if n == nil {
+		return nil
+	}
+	if n.typeName() != "BlockStatement" {
+		d.fail("Erwartet 'BlockStatement', aber '%s' gefunden", n.typeName())
+		return nil
+	}
+	return &BlockStatement{
+		Token:      d.token(n),
+		Statements: d.statements(n, "statements"),
+		End:        d.tokenField(n, "end"),
+	}
+}
+
+func (d *jsonDecoder) statement(raw json.RawMessage) Statement {
+	n := d.node(raw)
+	if n == nil {
+		return nil
+	}
+
+	switch t := n.typeName(); t {
+	case "VariableDeclaration":
+		stmt := &VariableDeclaration{
+			Token: d.token(n),
+			Name:  d.identifier(n["name"]),
+			Value: d.expression(n["value"]),
+		}
+		d.require(stmt.Name != nil, t, "name")
+		return stmt
+	case "FigurDeclaration":
+		stmt := &FigurDeclaration{
+			Token: d.token(n),
+			Name:  d.identifier(n["name"]),
+			Value: d.expression(n["value"]),
+		}
+		d.require(stmt.Name != nil, t, "name")
+		return stmt
+	case "FunctionDeclaration":
+		stmt := &FunctionDeclaration{
+			Token:      d.token(n),
+			Name:       d.identifier(n["name"]),
+			Parameters: []*Identifier{},
+			Body:       d.block(n["body"]),
+		}
+		for _, param := range d.list(n, "parameters") {
+			ident := d.identifier(param)
+			d.require(ident != nil, t, "parameters")
+			stmt.Parameters = append(stmt.Parameters, ident)
+		}
+		d.require(stmt.Name != nil, t, "name")
+		d.require(stmt.Body != nil, t, "body")
+		return stmt
+	case "ReturnStatement":
+		return &ReturnStatement{
+			Token:       d.token(n),
+			ReturnValue: d.expression(n["returnValue"]),
+		}
+	case "ExpressionStatement":
+		return &ExpressionStatement{
+			Token:      d.token(n),
+			Expression: d.expression(n["expression"]),
+		}
+	case "BlockStatement":
+		return d.block(raw)
+	case "IfStatement":
+		stmt := &IfStatement{
+			Token:       d.token(n),
+			Condition:   d.expression(n["condition"]),
+			Consequence: d.block(n["consequence"]),
+			Alternative: d.block(n["alternative"]),
+		}
+		d.require(stmt.Consequence != nil, t, "consequence")
+		return stmt
+	case "WhileStatement":
+		stmt := &WhileStatement{
+			Token:     d.token(n),
+			Condition: d.expression(n["condition"]),
+			Body:      d.block(n["body"]),
+		}
+		d.require(stmt.Body != nil, t, "body")
+		return stmt
+	case "ForStatement":
+		stmt := &ForStatement{
+			Token:    d.token(n),
+			Variable: d.identifier(n["variable"]),
+			Start:    d.expression(n["start"]),
+			End:      d.expression(n["end"]),
+			Body:     d.block(n["body"]),
+		}
+		d.require(stmt.Variable != nil, t, "variable")
+		d.require(stmt.Body != nil, t, "body")
+		return stmt
+	case "RepeatStatement":
+		stmt := &RepeatStatement{
+			Token: d.token(n),
+			Count: d.expression(n["count"]),
+			Body:  d.block(n["body"]),
+		}
+		d.require(stmt.Body != nil, t, "body")
+		return stmt
+	case "GameDeclaration":
+		stmt := &GameDeclaration{Token: d.token(n)}
+		d.field(n, "name", &stmt.Name)
+		return stmt
+	case "EventHandler":
+		stmt := &EventHandler{
+			Token:      d.token(n),
+			Parameters: d.expressions(n, "parameters"),
+			Body:       d.block(n["body"]),
+		}
+		d.field(n, "eventType", &stmt.EventType)
+		d.require(stmt.Body != nil, t, "body")
+		switch stmt.EventType {
+		case "start", "immer":
+		case "taste":
+			d.require(len(stmt.Parameters) == 1, t, "parameters")
+		case "kollision":
+			d.require(len(stmt.Parameters) == 2, t, "parameters")
+		default:
+			d.fail("Unbekanntes Ereignis '%s'", stmt.EventType)
+		}
+		return stmt
+	case "CommentStatement":
+		stmt := &CommentStatement{Token: d.token(n)}
+		d.field(n, "text", &stmt.Text)
+		d.field(n, "trailing", &stmt.Trailing)
+		if d.err == nil && !IsCommentText(stmt.Text) {
+			d.fail("Kommentar darf keinen Zeilenumbruch enthalten")
+		}
+		return stmt
+	default:
+		d.fail("Unbekannte Anweisung '%s'", t)
+		return nil
+	}
+}
+
+func (d *jsonDecoder) expression(raw json.RawMessage) Expression {
+	n := d.node(raw)
+	if n == nil {
+		return nil
+	}
+
+	switch t := n.typeName(); t {
+	case "Identifier":
+		return d.identifier(raw)
+	case "NumberLiteral":
+		lit := &NumberLiteral{Token: d.token(n)}
+		d.field(n, "value", &lit.Value)
+		if t, ok := SingleToken(lit.Token.Literal); d.err == nil && (!ok || t != lexer.TOKEN_NUMBER) {
+			d.fail("'%s' ist keine gültige Zahl", lit.Token.Literal)
+		}
+		return lit
+	case "StringLiteral":
+		lit := &StringLiteral{Token: d.token(n)}
+		d.field(n, "value", &lit.Value)
+		return lit
+	case "BooleanLiteral":
+		lit := &BooleanLiteral{Token: d.token(n)}
+		d.field(n, "value", &lit.Value)
+		return lit
+	case "ArrayLiteral":
+		return &ArrayLiteral{Token: d.token(n), Elements: d.expressions(n, "elements")}
+	case "IndexExpression":
+		return &IndexExpression{
+			Token: d.token(n),
+			Left:  d.expression(n["left"]),
+			Index: d.expression(n["index"]),
+		}
+	case "PrefixExpression":
+		exp := &PrefixExpression{Token: d.token(n), Right: d.expression(n["right"])}
+		d.field(n, "operator", &exp.Operator)
+		if d.err == nil && !IsPrefixOperator(exp.Operator) {
+			d.fail("Unbekannter Operator '%s'", exp.Operator)
+		}
+		return exp
+	case "InfixExpression":
+		exp := &InfixExpression{
+			Token: d.token(n),
+			Left:  d.expression(n["left"]),
+			Right: d.expression(n["right"]),
+		}
+		d.field(n, "operator", &exp.Operator)
+		if d.err == nil && !IsInfixOperator(exp.Operator) {
+			d.fail("Unbekannter Operator '%s'", exp.Operator)
+		}
+		return exp
+	case "CallExpression":
+		return &CallExpression{
+			Token:     d.token(n),
+			Function:  d.expression(n["function"]),
+			Arguments: d.expressions(n, "arguments"),
+		}
+	case "MemberExpression":
+		exp := &MemberExpression{
+			Token:    d.token(n),
+			Object:   d.expression(n["object"]),
+			Property: d.identifier(n["property"]),
+		}
+		d.require(exp.Property != nil, t, "property")
+		return exp
+	case "AssignmentExpression":
+		return &AssignmentExpression{
+			Token: d.token(n),
+			Left:  d.expression(n["left"]),
+			Value: d.expression(n["value"]),
+		}
+	case "GroupedExpression":
+		return &GroupedExpression{Token: d.token(n), Expression: d.expression(n["expression"])}
+	default:
+		d.fail("Unbekannter Ausdruck '%s'", t)
+		return nil
+	}
+}
@@ -0,0 +1,141 @@
+package parser
+
+import (
+	"benlang/internal/lexer"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	files, err := filepath.Glob("../../beispiele/*/*.ben")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no example programs found: %v", err)
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		p := New(lexer.New(string(content)))
+		program := p.ParseProgram()
+
+		encoded, err := EncodeJSON(program)
+		if err != nil {
+			t.Fatalf("%s: encode failed: %v", file, err)
+		}
+
+		decoded, err := DecodeJSON(encoded)
+		if err != nil {
+			t.Fatalf("%s: decode failed: %v", file, err)
+		}
+
+		if !reflect.DeepEqual(program, decoded) {
+			t.Errorf("%s: decoded AST differs from parsed AST", file)
+		}
+
+		again, err := EncodeJSON(decoded)
+		if err != nil {
+			t.Fatalf("%s: second encode failed: %v", file, err)
+		}
+		if !bytes.Equal(encoded, again) {
+			t.Errorf("%s: JSON output is not stable", file)
+		}
+	}
+}
+
+func TestJSONPositions(t *testing.T) {
+	p := New(lexer.New("VAR x = 1\n  x = x + 2"))
+	program := p.ParseProgram()
+
+	decoded, err := DecodeJSON(mustEncode(t, program))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, ok := decoded.Statements[1].(*ExpressionStatement)
+	if !ok {
+		t.Fatalf("expected ExpressionStatement, got %T", decoded.Statements[1])
+	}
+	if stmt.Token.Line != 2 || stmt.Token.Column != 3 {
+		t.Errorf("expected position 2:3, got %d:%d", stmt.Token.Line, stmt.Token.Column)
+	}
+}
+
+func TestJSONDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"wrong version", `{"version": 99, "program": {"type": "Program", "statements": []}}`},
+		{"no program", `{"version": 1}`},
+		{"unknown statement", `{"version": 1, "program": {"type": "Program", "statements": [{"type": "Zauberei"}]}}`},
+		{"missing body", `{"version": 1, "program": {"type": "Program", "statements": [{"type": "WhileStatement"}]}}`},
+		{"key without parameter", `{"version": 1, "program": {"type": "Program", "statements": [
+			{"type": "EventHandler", "eventType": "taste", "body": {"type": "BlockStatement", "statements": []}}]}}`},
+	}
+
+	for _, tt := range tests {
+		if _, err := DecodeJSON([]byte(tt.input)); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+// The transpiler prints names, number literals, operators and comments
+// unchanged, so an imported AST must not smuggle JavaScript in through them
+func TestJSONDecodeRejectsInjection(t *testing.T) {
+	statement := func(stmt string) string {
+		return `{"version": 1, "program": {"type": "Program", "statements": [` + stmt + `]}}`
+	}
+	expression := func(expr string) string {
+		return statement(`{"type": "ExpressionStatement", "expression": ` + expr + `}`)
+	}
+	number := `{"type": "NumberLiteral", "token": {"type": "NUMBER", "literal": "1"}, "value": 1}`
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"variable name", statement(`{"type": "VariableDeclaration",
+			"name": {"type": "Identifier", "value": "x = 1; fetch('/api/benutzer');//"}, "value": ` + number + `}`)},
+		{"keyword as name", statement(`{"type": "VariableDeclaration",
+			"name": {"type": "Identifier", "value": "WENN"}, "value": ` + number + `}`)},
+		{"identifier expression", expression(`{"type": "Identifier", "value": "alert(1)"}`)},
+		{"member property", expression(`{"type": "MemberExpression",
+			"object": {"type": "Identifier", "value": "x"}, "property": {"type": "Identifier", "value": "y;alert(1)"}}`)},
+		{"number literal", expression(`{"type": "NumberLiteral",
+			"token": {"type": "NUMBER", "literal": "1;alert(1)"}, "value": 1}`)},
+		{"number literal with comment", expression(`{"type": "NumberLiteral",
+			"token": {"type": "NUMBER", "literal": "1//x"}, "value": 1}`)},
+		{"name as number", expression(`{"type": "NumberLiteral",
+			"token": {"type": "NUMBER", "literal": "alert"}, "value": 1}`)},
+		{"infix operator", expression(`{"type": "InfixExpression", "operator": ");alert(1);(",
+			"left": ` + number + `, "right": ` + number + `}`)},
+		{"assignment as infix operator", expression(`{"type": "InfixExpression", "operator": "=",
+			"left": {"type": "Identifier", "value": "x"}, "right": ` + number + `}`)},
+		{"prefix operator", expression(`{"type": "PrefixExpression", "operator": "alert(1)||",
+			"right": ` + number + `}`)},
+		{"comment with line break", statement(`{"type": "CommentStatement", "text": " x\nalert(1)"}`)},
+		{"comment with line separator", statement(`{"type": "CommentStatement", "text": " x\u2028alert(1)"}`)},
+	}
+
+	for _, tt := range tests {
+		if _, err := DecodeJSON([]byte(tt.input)); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+}
+
+func mustEncode(t *testing.T, program *Program) []byte {
+	t.Helper()
+	data, err := EncodeJSON(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
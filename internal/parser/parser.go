@@ -4,6 +4,7 @@ import (
 	"benlang/internal/lexer"
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 // Operator precedence levels
@@ -43,19 +44,69 @@ var precedences = map[lexer.TokenType]int{
 	lexer.TOKEN_DOT:      MEMBER,
 }
 
+// SingleToken lexes s and returns its type if s is exactly one token
+func SingleToken(s string) (lexer.TokenType, bool) {
+	tokens := lexer.New(s).Tokenize()
+	if len(tokens) != 2 || tokens[0].Literal != s {
+		return lexer.TOKEN_ILLEGAL, false
+	}
+	return tokens[0].Type, true
+}
+
+// IsIdentifier reports whether s lexes as a single name
+func IsIdentifier(s string) bool {
+	t, ok := SingleToken(s)
+	return ok && t == lexer.TOKEN_IDENT
+}
+
+// IsCommentText reports whether s fits on one comment line, also for
+// JavaScript, which ends lines at U+2028 and U+2029 as well
+func IsCommentText(s string) bool {
+	return !strings.ContainsAny(s, "\r\n\u2028\u2029")
+}
+
+// IsPrefixOperator reports whether s is an operator written before a value
+func IsPrefixOperator(s string) bool {
+	t, ok := SingleToken(s)
+	return ok && (t == lexer.TOKEN_MINUS || t == lexer.TOKEN_NICHT)
+}
+
+// IsInfixOperator reports whether s is an operator between two values.
+// Assignment, calls, indexing and member access have their own nodes.
+func IsInfixOperator(s string) bool {
+	t, ok := SingleToken(s)
+	p := precedences[t]
+	return ok && p >= OR && p <= PRODUCT
+}
+
 type (
 	prefixParseFn func() Expression
 	infixParseFn  func(Expression) Expression
 )
 
+// ParseError describes a syntax error at a position in the source
+type ParseError struct {
+	Line    int    `json:"zeile"`
+	Column  int    `json:"spalte"`
+	Message string `json:"meldung"`
+}
+
+// Error formats the error the way it is shown to the user
+func (e ParseError) Error() string {
+	return fmt.Sprintf("Zeile %d: %s", e.Line, e.Message)
+}
+
 // Parser parses BenLang tokens into an AST
 type Parser struct {
 	l      *lexer.Lexer
-	errors []string
+	errors []ParseError
 
 	curToken  lexer.Token
 	peekToken lexer.Token
 
+	// commentIndex is the number of lexer comments already placed in the AST
+	commentIndex int
+
 	prefixParseFns map[lexer.TokenType]prefixParseFn
 	infixParseFns  map[lexer.TokenType]infixParseFn
 }
@@ -64,7 +115,7 @@ type Parser struct {
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []ParseError{},
 	}
 
 	// Register prefix parse functions
@@ -136,20 +187,41 @@ func (p *Parser) expectPeek(t lexer.TokenType) bool {
 	return false
 }
 
+func (p *Parser) addError(tok lexer.Token, format string, args ...interface{}) {
+	p.errors = append(p.errors, ParseError{
+		Line:    tok.Line,
+		Column:  tok.Column,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
 func (p *Parser) peekError(t lexer.TokenType) {
-	msg := fmt.Sprintf("Zeile %d: Erwartet '%s', aber '%s' gefunden",
-		p.peekToken.Line, t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, "Erwartet '%s', aber '%s' gefunden", t, p.peekToken.Type)
 }
 
 func (p *Parser) noPrefixParseFnError(t lexer.TokenType) {
-	msg := fmt.Sprintf("Zeile %d: Unerwartetes Token '%s'",
-		p.curToken.Line, t)
-	p.errors = append(p.errors, msg)
+	if t == lexer.TOKEN_ILLEGAL {
+		if strings.HasPrefix(p.curToken.Literal, "\"") {
+			p.addError(p.curToken, "Text ohne schließendes Anführungszeichen")
+		} else {
+			p.addError(p.curToken, "Unbekanntes Zeichen '%s'", p.curToken.Literal)
+		}
+		return
+	}
+	p.addError(p.curToken, "Unerwartetes Token '%s'", t)
 }
 
-// Errors returns the parser errors
+// Errors returns the parser errors as user-facing messages
 func (p *Parser) Errors() []string {
+	msgs := make([]string, len(p.errors))
+	for i, e := range p.errors {
+		msgs[i] = e.Error()
+	}
+	return msgs
+}
+
+// ErrorList returns the parser errors including their positions
+func (p *Parser) ErrorList() []ParseError {
 	return p.errors
 }
 
@@ -160,6 +232,14 @@ func (p *Parser) peekPrecedence() int {
 	return LOWEST
 }
 
+// Precedence returns the binding strength of an operator token
+func Precedence(t lexer.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
+}
+
 func (p *Parser) curPrecedence() int {
 	if p, ok := precedences[p.curToken.Type]; ok {
 		return p
@@ -172,17 +252,38 @@ func (p *Parser) ParseProgram() *Program {
 	program := &Program{}
 	program.Statements = []Statement{}
 
+	lastLine := 0
 	for !p.curTokenIs(lexer.TOKEN_EOF) {
+		program.Statements = p.appendComments(program.Statements, lastLine)
 		stmt := p.parseStatement()
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
+		lastLine = p.curToken.Line
 		p.nextToken()
 	}
+	program.Statements = p.appendComments(program.Statements, lastLine)
 
 	return program
 }
 
+// appendComments adds a CommentStatement for every comment in front of the
+// current token. Comments on lastLine, the line where the previous
+// statement ended, are marked as trailing.
+func (p *Parser) appendComments(stmts []Statement, lastLine int) []Statement {
+	comments := p.l.Comments()
+	for p.commentIndex < len(comments) {
+		c := comments[p.commentIndex]
+		if !p.curTokenIs(lexer.TOKEN_EOF) &&
+			(c.Line > p.curToken.Line || (c.Line == p.curToken.Line && c.Column > p.curToken.Column)) {
+			break
+		}
+		stmts = append(stmts, &CommentStatement{Token: c, Text: c.Literal, Trailing: c.Line == lastLine})
+		p.commentIndex++
+	}
+	return stmts
+}
+
 func (p *Parser) parseStatement() Statement {
 	switch p.curToken.Type {
 	case lexer.TOKEN_VAR, lexer.TOKEN_VARIABLE:
@@ -210,7 +311,7 @@ func (p *Parser) parseStatement() Statement {
 	}
 }
 
-func (p *Parser) parseVariableDeclaration() *VariableDeclaration {
+func (p *Parser) parseVariableDeclaration() Statement {
 	stmt := &VariableDeclaration{Token: p.curToken}
 
 	if !p.expectPeek(lexer.TOKEN_IDENT) {
@@ -229,7 +330,7 @@ func (p *Parser) parseVariableDeclaration() *VariableDeclaration {
 	return stmt
 }
 
-func (p *Parser) parseFigurDeclaration() *FigurDeclaration {
+func (p *Parser) parseFigurDeclaration() Statement {
 	stmt := &FigurDeclaration{Token: p.curToken}
 
 	if !p.expectPeek(lexer.TOKEN_IDENT) {
@@ -248,7 +349,7 @@ func (p *Parser) parseFigurDeclaration() *FigurDeclaration {
 	return stmt
 }
 
-func (p *Parser) parseFunctionDeclaration() *FunctionDeclaration {
+func (p *Parser) parseFunctionDeclaration() Statement {
 	stmt := &FunctionDeclaration{Token: p.curToken}
 
 	if !p.expectPeek(lexer.TOKEN_IDENT) {
@@ -262,6 +363,9 @@ func (p *Parser) parseFunctionDeclaration() *FunctionDeclaration {
 	}
 
 	stmt.Parameters = p.parseFunctionParameters()
+	if stmt.Parameters == nil {
+		return nil
+	}
 
 	if !p.expectPeek(lexer.TOKEN_LBRACE) {
 		return nil
@@ -280,14 +384,18 @@ func (p *Parser) parseFunctionParameters() []*Identifier {
 		return identifiers
 	}
 
-	p.nextToken()
+	if !p.expectPeek(lexer.TOKEN_IDENT) {
+		return nil
+	}
 
 	ident := &Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	identifiers = append(identifiers, ident)
 
 	for p.peekTokenIs(lexer.TOKEN_COMMA) {
 		p.nextToken()
-		p.nextToken()
+		if !p.expectPeek(lexer.TOKEN_IDENT) {
+			return nil
+		}
 		ident := &Identifier{Token: p.curToken, Value: p.curToken.Literal}
 		identifiers = append(identifiers, ident)
 	}
@@ -302,9 +410,9 @@ func (p *Parser) parseFunctionParameters() []*Identifier {
 func (p *Parser) parseReturnStatement() *ReturnStatement {
 	stmt := &ReturnStatement{Token: p.curToken}
 
-	p.nextToken()
-
-	if !p.curTokenIs(lexer.TOKEN_RBRACE) && !p.curTokenIs(lexer.TOKEN_EOF) {
+	// Leave a closing brace for the enclosing block
+	if !p.peekTokenIs(lexer.TOKEN_RBRACE) && !p.peekTokenIs(lexer.TOKEN_EOF) {
+		p.nextToken()
 		stmt.ReturnValue = p.parseExpression(LOWEST)
 	}
 
@@ -321,20 +429,25 @@ func (p *Parser) parseBlockStatement() *BlockStatement {
 	block := &BlockStatement{Token: p.curToken}
 	block.Statements = []Statement{}
 
+	lastLine := p.curToken.Line
 	p.nextToken()
 
 	for !p.curTokenIs(lexer.TOKEN_RBRACE) && !p.curTokenIs(lexer.TOKEN_EOF) {
+		block.Statements = p.appendComments(block.Statements, lastLine)
 		stmt := p.parseStatement()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
+		lastLine = p.curToken.Line
 		p.nextToken()
 	}
+	block.Statements = p.appendComments(block.Statements, lastLine)
+	block.End = p.curToken
 
 	return block
 }
 
-func (p *Parser) parseIfStatement() *IfStatement {
+func (p *Parser) parseIfStatement() Statement {
 	stmt := &IfStatement{Token: p.curToken}
 
 	p.nextToken()
@@ -353,9 +466,13 @@ func (p *Parser) parseIfStatement() *IfStatement {
 		if p.peekTokenIs(lexer.TOKEN_WENN) {
 			p.nextToken()
 			elseIfStmt := p.parseIfStatement()
+			if elseIfStmt == nil {
+				return nil
+			}
 			stmt.Alternative = &BlockStatement{
 				Token:      p.curToken,
 				Statements: []Statement{elseIfStmt},
+				End:        p.curToken,
 			}
 		} else {
 			if !p.expectPeek(lexer.TOKEN_LBRACE) {
@@ -368,7 +485,7 @@ func (p *Parser) parseIfStatement() *IfStatement {
 	return stmt
 }
 
-func (p *Parser) parseWhileStatement() *WhileStatement {
+func (p *Parser) parseWhileStatement() Statement {
 	stmt := &WhileStatement{Token: p.curToken}
 
 	p.nextToken()
@@ -383,7 +500,7 @@ func (p *Parser) parseWhileStatement() *WhileStatement {
 	return stmt
 }
 
-func (p *Parser) parseForStatement() *ForStatement {
+func (p *Parser) parseForStatement() Statement {
 	stmt := &ForStatement{Token: p.curToken}
 
 	if !p.expectPeek(lexer.TOKEN_IDENT) {
@@ -415,7 +532,7 @@ func (p *Parser) parseForStatement() *ForStatement {
 	return stmt
 }
 
-func (p *Parser) parseRepeatStatement() *RepeatStatement {
+func (p *Parser) parseRepeatStatement() Statement {
 	stmt := &RepeatStatement{Token: p.curToken}
 
 	p.nextToken()
@@ -430,7 +547,7 @@ func (p *Parser) parseRepeatStatement() *RepeatStatement {
 	return stmt
 }
 
-func (p *Parser) parseGameDeclaration() *GameDeclaration {
+func (p *Parser) parseGameDeclaration() Statement {
 	stmt := &GameDeclaration{Token: p.curToken}
 
 	if !p.expectPeek(lexer.TOKEN_STRING) {
@@ -442,7 +559,7 @@ func (p *Parser) parseGameDeclaration() *GameDeclaration {
 	return stmt
 }
 
-func (p *Parser) parseEventHandler() *EventHandler {
+func (p *Parser) parseEventHandler() Statement {
 	stmt := &EventHandler{Token: p.curToken}
 
 	switch p.curToken.Type {
@@ -460,6 +577,23 @@ func (p *Parser) parseEventHandler() *EventHandler {
 	if p.peekTokenIs(lexer.TOKEN_LPAREN) {
 		p.nextToken()
 		stmt.Parameters = p.parseExpressionList(lexer.TOKEN_RPAREN)
+		if stmt.Parameters == nil {
+			return nil
+		}
+	}
+
+	// The transpiler relies on these handlers having their parameters
+	switch stmt.EventType {
+	case "taste":
+		if len(stmt.Parameters) != 1 {
+			p.addError(stmt.Token, "WENN_TASTE braucht genau eine Taste, z.B. WENN_TASTE(\"leertaste\")")
+			return nil
+		}
+	case "kollision":
+		if len(stmt.Parameters) != 2 {
+			p.addError(stmt.Token, "WENN_KOLLISION braucht genau zwei Figuren, z.B. WENN_KOLLISION(spieler, stern)")
+			return nil
+		}
 	}
 
 	if !p.expectPeek(lexer.TOKEN_LBRACE) {
@@ -501,9 +635,7 @@ func (p *Parser) parseNumberLiteral() Expression {
 
 	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
 	if err != nil {
-		msg := fmt.Sprintf("Zeile %d: Konnte '%s' nicht als Zahl lesen",
-			p.curToken.Line, p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, "Konnte '%s' nicht als Zahl lesen", p.curToken.Literal)
 		return nil
 	}
 
@@ -579,8 +711,10 @@ func (p *Parser) parseInfixExpression(left Expression) Expression {
 }
 
 func (p *Parser) parseGroupedExpression() Expression {
+	exp := &GroupedExpression{Token: p.curToken}
+
 	p.nextToken()
-	exp := p.parseExpression(LOWEST)
+	exp.Expression = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(lexer.TOKEN_RPAREN) {
 		return nil
@@ -0,0 +1,118 @@
+package parser
+
+import (
+	"benlang/internal/lexer"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func FuzzParseProgram(f *testing.F) {
+	files, _ := filepath.Glob("../../beispiele/*/*.ben")
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err == nil {
+			f.Add(string(content))
+		}
+	}
+	f.Add(`WENN_TASTE { x = 1 }`)
+	f.Add(`WENN_KOLLISION(a) { }`)
+	f.Add(`WENN a { } SONST WENN { }`)
+	f.Add(`FUNKTION f(1, 2) { }`)
+	f.Add(`VAR x = `)
+
+	f.Fuzz(func(t *testing.T, input string) {
+		done := make(chan struct{})
+		var program *Program
+		var errs []ParseError
+
+		go func() {
+			defer close(done)
+			p := New(lexer.New(input))
+			program = p.ParseProgram()
+			errs = p.ErrorList()
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("parser did not terminate")
+		}
+
+		maxLine := strings.Count(input, "\n") + 1
+		for _, e := range errs {
+			if e.Line < 1 || e.Line > maxLine || e.Column < 0 {
+				t.Fatalf("error %q has invalid position %d:%d (%d lines)", e.Message, e.Line, e.Column, maxLine)
+			}
+			if e.Message == "" {
+				t.Fatalf("error at %d:%d has no message", e.Line, e.Column)
+			}
+		}
+
+		checkStatements(t, program.Statements)
+	})
+}
+
+// checkStatements fails if the AST contains statements the transpiler cannot handle
+func checkStatements(t *testing.T, stmts []Statement) {
+	t.Helper()
+	for i, stmt := range stmts {
+		switch s := stmt.(type) {
+		case nil:
+			t.Fatalf("statement %d is nil", i)
+		case *VariableDeclaration:
+			if s == nil || s.Name == nil {
+				t.Fatalf("statement %d: incomplete variable declaration", i)
+			}
+		case *FigurDeclaration:
+			if s == nil || s.Name == nil {
+				t.Fatalf("statement %d: incomplete figure declaration", i)
+			}
+		case *FunctionDeclaration:
+			if s == nil || s.Name == nil || s.Body == nil {
+				t.Fatalf("statement %d: incomplete function declaration", i)
+			}
+			checkStatements(t, s.Body.Statements)
+		case *IfStatement:
+			if s == nil || s.Consequence == nil {
+				t.Fatalf("statement %d: incomplete WENN", i)
+			}
+			checkStatements(t, s.Consequence.Statements)
+			if s.Alternative != nil {
+				checkStatements(t, s.Alternative.Statements)
+			}
+		case *WhileStatement:
+			if s == nil || s.Body == nil {
+				t.Fatalf("statement %d: incomplete SOLANGE", i)
+			}
+			checkStatements(t, s.Body.Statements)
+		case *ForStatement:
+			if s == nil || s.Variable == nil || s.Body == nil {
+				t.Fatalf("statement %d: incomplete FUER", i)
+			}
+			checkStatements(t, s.Body.Statements)
+		case *RepeatStatement:
+			if s == nil || s.Body == nil {
+				t.Fatalf("statement %d: incomplete WIEDERHOLE", i)
+			}
+			checkStatements(t, s.Body.Statements)
+		case *EventHandler:
+			if s == nil || s.Body == nil {
+				t.Fatalf("statement %d: incomplete event handler", i)
+			}
+			if s.EventType == "taste" && len(s.Parameters) != 1 {
+				t.Fatalf("statement %d: WENN_TASTE with %d parameters", i, len(s.Parameters))
+			}
+			if s.EventType == "kollision" && len(s.Parameters) != 2 {
+				t.Fatalf("statement %d: WENN_KOLLISION with %d parameters", i, len(s.Parameters))
+			}
+			checkStatements(t, s.Body.Statements)
+		case *GameDeclaration, *ReturnStatement, *ExpressionStatement, *CommentStatement:
+			if s == nil {
+				t.Fatalf("statement %d is a nil %T", i, stmt)
+			}
+		}
+	}
+}
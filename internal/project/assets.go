@@ -0,0 +1,167 @@
+package project
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Upload quotas
+const (
+	MaxAssetSize   = 10 << 20  // one image or sound
+	MaxProjectSize = 100 << 20 // all files of a project together
+)
+
+// ErrTooLarge is matched by uploads that exceed a quota
+var ErrTooLarge = errors.New("zu groß")
+
+// Asset describes an uploaded image or sound
+type Asset struct {
+	Path      string `json:"pfad"`
+	Kind      string `json:"art"` // "bild" or "ton"
+	MediaType string `json:"typ"`
+	Size      int64  `json:"groesse"`
+	Width     int    `json:"breite,omitempty"`
+	Height    int    `json:"hoehe,omitempty"`
+}
+
+// assetType is what the content of a file with a given extension must be
+type assetType struct {
+	folder    string
+	kind      string
+	mediaType string
+}
+
+var assetTypes = map[string]assetType{
+	".png":  {"bilder", "bild", "image/png"},
+	".jpg":  {"bilder", "bild", "image/jpeg"},
+	".jpeg": {"bilder", "bild", "image/jpeg"},
+	".gif":  {"bilder", "bild", "image/gif"},
+	".wav":  {"toene", "ton", "audio/wav"},
+	".mp3":  {"toene", "ton", "audio/mpeg"},
+}
+
+// AssetFolders are searched for images and sounds that the code loads by
+// a name not found in the project folder itself
+var AssetFolders = []string{"bilder", "toene"}
+
+// SaveAsset checks an uploaded image or sound and saves it in bilder/ or
+// toene/. Only the base name of the upload is used, and the content must
+// match the file extension.
+func (p *Project) SaveAsset(name string, data []byte) (Asset, error) {
+	name = filepath.Base(filepath.FromSlash(strings.ReplaceAll(name, `\`, "/")))
+	t, ok := assetTypes[strings.ToLower(filepath.Ext(name))]
+	if strings.HasPrefix(name, ".") || !ok {
+		return Asset{}, &fileError{fmt.Sprintf("'%s' ist kein Bild und kein Ton", name), fs.ErrInvalid}
+	}
+
+	if len(data) > MaxAssetSize {
+		return Asset{}, &fileError{fmt.Sprintf("'%s' ist zu groß, erlaubt sind %d MB", name, MaxAssetSize>>20), ErrTooLarge}
+	}
+
+	asset := Asset{
+		Path:      t.folder + "/" + name,
+		Kind:      t.kind,
+		MediaType: t.mediaType,
+		Size:      int64(len(data)),
+	}
+	if sniffType(data) != t.mediaType {
+		return Asset{}, &fileError{fmt.Sprintf("'%s' ist kein echtes %s", name, strings.ToUpper(strings.TrimPrefix(filepath.Ext(name), "."))), fs.ErrInvalid}
+	}
+	if t.kind == "bild" {
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return Asset{}, &fileError{fmt.Sprintf("'%s' ist beschädigt", name), fs.ErrInvalid}
+		}
+		asset.Width, asset.Height = config.Width, config.Height
+	}
+
+	if err := p.checkQuota(asset.Path, asset.Size); err != nil {
+		return Asset{}, err
+	}
+	return asset, p.WriteFile(asset.Path, string(data))
+}
+
+// SaveImage saves an uploaded image in bilder/ and returns its path in the
+// project. Only the base name of the upload is used.
+func (p *Project) SaveImage(name string, data []byte) (string, error) {
+	if t := assetTypes[strings.ToLower(filepath.Ext(name))]; t.kind != "bild" {
+		return "", &fileError{fmt.Sprintf("'%s' ist kein erlaubter Bildname", filepath.Base(name)), fs.ErrInvalid}
+	}
+	asset, err := p.SaveAsset(name, data)
+	return asset.Path, err
+}
+
+// SaveText saves a code file written in the editor. Images and
+// sounds only come in through SaveAsset, which checks their content; the
+// size limits are the same for both.
+func (p *Project) SaveText(name, content string) error {
+	clean, err := localName(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := assetTypes[strings.ToLower(filepath.Ext(clean))]; ok {
+		return &fileError{fmt.Sprintf("'%s' ist ein Bild oder Ton und muss hochgeladen werden", filepath.Base(clean)), fs.ErrInvalid}
+	}
+	// Other types would neither be listed nor count against the quota
+	if !IsProjectFile(clean) {
+		return &fileError{fmt.Sprintf("'%s' ist kein erlaubter Dateityp", filepath.Base(clean)), fs.ErrInvalid}
+	}
+	if len(content) > MaxAssetSize {
+		return &fileError{fmt.Sprintf("'%s' ist zu groß, erlaubt sind %d MB", filepath.Base(clean), MaxAssetSize>>20), ErrTooLarge}
+	}
+	if err := p.checkQuota(filepath.ToSlash(clean), int64(len(content))); err != nil {
+		return err
+	}
+	return p.WriteFile(clean, content)
+}
+
+// sniffType finds the media type from the content of a file
+func sniffType(data []byte) string {
+	switch {
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WAVE":
+		return "audio/wav"
+	case bytes.HasPrefix(data, []byte("ID3")):
+		return "audio/mpeg"
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		// MP3 without tags starts with a frame header
+		return "audio/mpeg"
+	}
+	return http.DetectContentType(data)
+}
+
+// checkQuota makes sure the project stays below MaxProjectSize when the
+// file name is written with the given size. The trash and the history
+// count as well. A file that is replaced does not count twice.
+func (p *Project) checkQuota(name string, size int64) error {
+	total := size
+	err := p.withRoot(".", func(root *os.Root, _ string) error {
+		return fs.WalkDir(root.FS(), ".", func(file string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() || file == name {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if total > MaxProjectSize {
+		return &fileError{fmt.Sprintf("Das Projekt ist voll: Alle Dateien zusammen, auch im Papierkorb und in früheren Versionen, dürfen höchstens %d MB groß sein. Leere den Papierkorb, um Platz zu schaffen.", MaxProjectSize>>20), ErrTooLarge}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package project
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveAsset(t *testing.T) {
+	proj := newTestProject(t, nil)
+
+	asset, err := proj.SaveAsset("held.png", testPNG(t, 32, 48))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset.Path != "bilder/held.png" || asset.Kind != "bild" || asset.Width != 32 || asset.Height != 48 {
+		t.Errorf("image asset = %+v", asset)
+	}
+
+	wav := append([]byte("RIFF\x24\x00\x00\x00WAVEfmt "), make([]byte, 32)...)
+	asset, err = proj.SaveAsset("sprung.WAV", wav)
+	if err != nil || asset.Path != "toene/sprung.WAV" || asset.Kind != "ton" {
+		t.Errorf("sound asset = %+v, %v", asset, err)
+	}
+	asset, err = proj.SaveAsset("musik.mp3", append([]byte("ID3\x04\x00"), make([]byte, 32)...))
+	if err != nil || asset.Path != "toene/musik.mp3" {
+		t.Errorf("mp3 asset = %+v, %v", asset, err)
+	}
+	if !proj.Exists("toene/sprung.WAV") || !proj.Exists("bilder/held.png") {
+		t.Error("assets not written")
+	}
+}
+
+func TestSaveAssetChecksContent(t *testing.T) {
+	proj := newTestProject(t, nil)
+
+	for name, data := range map[string][]byte{
+		"verkleidet.png": []byte("<html><script>alert(1)</script></html>"),
+		"bild.jpg":       testPNG(t, 4, 4),
+		"ton.wav":        testPNG(t, 4, 4),
+		"ton.mp3":        []byte("kein ton"),
+		"seite.html":     []byte("<html></html>"),
+		"kaputt.png":     testPNG(t, 4, 4)[:20],
+	} {
+		if _, err := proj.SaveAsset(name, data); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("SaveAsset(%q) = %v, want fs.ErrInvalid", name, err)
+		}
+		if proj.Exists("bilder/"+name) || proj.Exists("toene/"+name) {
+			t.Errorf("%s was written", name)
+		}
+	}
+}
+
+func TestSaveAssetQuota(t *testing.T) {
+	proj := newTestProject(t, nil)
+
+	big := append(testPNG(t, 1, 1), make([]byte, MaxAssetSize)...)
+	if _, err := proj.SaveAsset("riesig.png", big); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("oversized file: %v, want ErrTooLarge", err)
+	}
+
+	// Fill the project up to the limit with other files
+	filler := string(make([]byte, MaxAssetSize))
+	for i := 0; i < MaxProjectSize/MaxAssetSize; i++ {
+		proj.WriteFile(filepath.Join("bilder", string(rune('a'+i))+".png"), filler)
+	}
+	if _, err := proj.SaveAsset("noch.png", testPNG(t, 1, 1)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("full project: %v, want ErrTooLarge", err)
+	}
+
+	// Replacing a file does not count its old size
+	if _, err := proj.SaveAsset("a.png", testPNG(t, 1, 1)); err != nil {
+		t.Errorf("replacing a file in a full project: %v", err)
+	}
+}
+
+func TestQuotaCountsTrashAndHistory(t *testing.T) {
+	proj := newTestProject(t, nil)
+
+	filler := string(make([]byte, MaxAssetSize))
+	for i := 0; i < MaxProjectSize/MaxAssetSize; i++ {
+		name := filepath.Join("level", string(rune('a'+i))+".ben")
+		proj.WriteFile(name, filler)
+		if _, err := proj.Delete(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := proj.SaveText("noch.ben", "VAR y = 2\n"); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("full trash: %v, want ErrTooLarge", err)
+	}
+
+	if err := proj.EmptyTrash(); err != nil {
+		t.Fatal(err)
+	}
+	if items, _ := proj.Trash(); len(items) != 0 {
+		t.Errorf("trash after emptying: %+v", items)
+	}
+	if err := proj.SaveText("noch.ben", "VAR y = 2\n"); err != nil {
+		t.Errorf("after emptying the trash: %v", err)
+	}
+
+	proj.WriteFile(filepath.Join(HistoryFolder, "objekte", "alt"), filler)
+	for i := 1; i < MaxProjectSize/MaxAssetSize; i++ {
+		proj.WriteFile(filepath.Join("level", string(rune('a'+i))+".ben"), filler)
+	}
+	if err := proj.SaveText("mehr.ben", "VAR z = 3\n"); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("full history: %v, want ErrTooLarge", err)
+	}
+}
+
+func TestSaveText(t *testing.T) {
+	proj := newTestProject(t, nil)
+
+	if err := proj.SaveText("level/eins.ben", "VAR x = 1\n"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"bilder/x.png", "toene/sprung.WAV", "x.jpeg", "seite.html", "ohne-endung"} {
+		if err := proj.SaveText(name, "<html></html>"); !errors.Is(err, fs.ErrInvalid) {
+			t.Errorf("SaveText(%q) = %v, want fs.ErrInvalid", name, err)
+		}
+	}
+	if err := proj.SaveText("riesig.ben", string(make([]byte, MaxAssetSize+1))); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("oversized file: %v, want ErrTooLarge", err)
+	}
+
+	filler := string(make([]byte, MaxAssetSize))
+	for i := 0; i < MaxProjectSize/MaxAssetSize; i++ {
+		proj.WriteFile(filepath.Join("level", string(rune('a'+i))+".ben"), filler)
+	}
+	if err := proj.SaveText("noch.ben", "VAR y = 2\n"); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("full project: %v, want ErrTooLarge", err)
+	}
+	if err := proj.SaveText("level/a.ben", "VAR z = 3\n"); err != nil {
+		t.Errorf("replacing a file in a full project: %v", err)
+	}
+}
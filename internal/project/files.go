@@ -0,0 +1,183 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrOutside is returned for paths that lead out of the project folder,
+// either with ../ or through a symbolic link
+var ErrOutside error = &fileError{"Der Pfad führt aus dem Projekt heraus", fs.ErrPermission}
+
+// fileError is a message for the user that still matches the fs errors
+// with errors.Is, so the server can pick the right status code
+type fileError struct {
+	message string
+	kind    error
+}
+
+func (e *fileError) Error() string { return e.message }
+func (e *fileError) Unwrap() error { return e.kind }
+
+func errExists(name string) error {
+	return &fileError{fmt.Sprintf("'%s' gibt es schon", filepath.ToSlash(name)), fs.ErrExist}
+}
+
+// localName cleans a path from a request and makes sure it stays inside
+// the folder it is relative to
+func localName(name string) (string, error) {
+	name = filepath.Clean(filepath.FromSlash(name))
+	if !filepath.IsLocal(name) || strings.Contains(name, `\`) {
+		return "", ErrOutside
+	}
+	return name, nil
+}
+
+// withRoot runs fn on the project folder opened as an os.Root, so the
+// operating system refuses anything outside the project
+func (p *Project) withRoot(name string, fn func(root *os.Root, name string) error) error {
+	name, err := localName(name)
+	if err != nil {
+		return err
+	}
+
+	root, err := os.OpenRoot(p.Path)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	if err := fn(root, name); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) && escapes(p.Path, name) {
+			return ErrOutside
+		}
+		return err
+	}
+	return nil
+}
+
+// escapes reports whether name, with all symbolic links resolved, lies
+// outside of dir. Missing parts of the path are ignored.
+func escapes(dir, name string) bool {
+	base, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	for p := filepath.Join(dir, name); p != dir && p != filepath.Dir(p); p = filepath.Dir(p) {
+		real, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(base, real)
+		return err != nil || !filepath.IsLocal(rel)
+	}
+	return false
+}
+
+// InWorkDir opens the project name inside workDir. Names with path
+// separators and projects that are symbolic links to other places are
+// refused. With create the folder is made if it does not exist.
+func InWorkDir(workDir, name string, create bool) (*Project, error) {
+	if !ValidName(name) {
+		return nil, fmt.Errorf("Ungültiger Projektname")
+	}
+
+	root, err := os.OpenRoot(workDir)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	info, err := root.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) && create {
+		if err := root.Mkdir(name, 0755); err != nil {
+			return nil, err
+		}
+		info, err = root.Stat(name)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("Projekt nicht gefunden: %w", err)
+	}
+	if err != nil {
+		if escapes(workDir, name) {
+			return nil, ErrOutside
+		}
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("'%s' ist kein Projektordner", name)
+	}
+	if escapes(workDir, name) {
+		return nil, ErrOutside
+	}
+
+	return New(filepath.Join(workDir, name))
+}
+
+// HomeDir returns the folder of a user inside workDir, where the user's
+// projects live, and creates it on first use. Names that are no plain
+// folder name and folders that are symbolic links are refused.
+func HomeDir(workDir, user string) (string, error) {
+	if !ValidName(user) {
+		return "", &fileError{fmt.Sprintf("'%s' kann keinen eigenen Ordner bekommen", user), fs.ErrPermission}
+	}
+
+	root, err := os.OpenRoot(workDir)
+	if err != nil {
+		return "", err
+	}
+	defer root.Close()
+
+	if err := root.Mkdir(user, 0755); err != nil && !errors.Is(err, fs.ErrExist) {
+		return "", err
+	}
+	info, err := root.Lstat(user)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", ErrOutside
+	}
+	return filepath.Join(workDir, user), nil
+}
+
+// CopyFrom copies the files of another folder, e.g. an example, into the
+// project. Hidden files and symbolic links are left out.
+func (p *Project) CopyFrom(dir string) error {
+	src, err := os.OpenRoot(dir)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return p.withRoot(".", func(root *os.Root, _ string) error {
+		return fs.WalkDir(src.FS(), ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil || name == "." {
+				return err
+			}
+			if strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+
+			name = filepath.FromSlash(name)
+			switch {
+			case d.IsDir():
+				return root.MkdirAll(name, 0755)
+			case d.Type().IsRegular():
+				data, err := src.ReadFile(name)
+				if err != nil {
+					return err
+				}
+				return root.WriteFile(name, data, 0644)
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,201 @@
+package project
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadFileStaysInProject(t *testing.T) {
+	proj := newTestProject(t, nil, withSandbox)
+	workDir := filepath.Dir(proj.Path)
+
+	for _, name := range []string{
+		"../spiel2/geheim.ben",
+		filepath.Join(workDir, "spiel2", "geheim.ben"),
+		"bilder/../../spiel2/geheim.ben",
+		"link.ben",
+		"relativ.ben",
+		"draussen/geheim.png",
+		"bilder/x.png",
+	} {
+		content, err := proj.ReadFile(name)
+		if !errors.Is(err, ErrOutside) {
+			t.Errorf("ReadFile(%q) = %q, %v; want ErrOutside", name, content, err)
+		}
+	}
+
+	if content, err := proj.ReadFile("innen.ben"); err != nil || content != "VAR x = 1\n" {
+		t.Errorf("link inside the project: %q, %v", content, err)
+	}
+	if _, err := proj.ReadFile("fehlt.ben"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: %v", err)
+	}
+}
+
+func TestWriteFileStaysInProject(t *testing.T) {
+	proj := newTestProject(t, nil, withSandbox)
+	workDir, outside := filepath.Dir(proj.Path), outsideDir(proj)
+
+	for _, name := range []string{
+		"../spiel2/neu.ben",
+		"../spiel2x/neu.ben",
+		filepath.Join(workDir, "spiel2", "neu.ben"),
+		"draussen/neu.ben",
+		"link.ben",
+	} {
+		if err := proj.WriteFile(name, "boese"); !errors.Is(err, ErrOutside) {
+			t.Errorf("WriteFile(%q) = %v; want ErrOutside", name, err)
+		}
+	}
+
+	for _, path := range []string{
+		filepath.Join(workDir, "spiel2", "neu.ben"),
+		filepath.Join(workDir, "spiel2x"),
+		filepath.Join(outside, "neu.ben"),
+	} {
+		if _, err := os.Lstat(path); err == nil {
+			t.Errorf("%s was created", path)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "spiel2", "geheim.ben")); string(data) != "geheim" {
+		t.Error("file behind a symbolic link was overwritten")
+	}
+
+	if err := proj.WriteFile("level/eins.ben", "VAR y = 2\n"); err != nil {
+		t.Errorf("writing into a new folder: %v", err)
+	}
+}
+
+func TestListFilesSkipsEscapingLinks(t *testing.T) {
+	proj := newTestProject(t, nil, withSandbox)
+
+	files, err := proj.ListFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, f := range files {
+		names[f.Name] = true
+	}
+	for _, name := range []string{"link.ben", "relativ.ben", "draussen", filepath.Join("bilder", "x.png")} {
+		if names[name] {
+			t.Errorf("ListFiles contains %s", name)
+		}
+	}
+	for _, name := range []string{"hauptspiel.ben", "innen.ben", filepath.Join("bilder", "held.png")} {
+		if !names[name] {
+			t.Errorf("ListFiles lacks %s", name)
+		}
+	}
+
+	if _, err := proj.Sources(); err != nil {
+		t.Errorf("Sources: %v", err)
+	}
+}
+
+func TestSaveImageUsesBaseName(t *testing.T) {
+	proj := newTestProject(t, nil, withSandbox)
+	workDir := filepath.Dir(proj.Path)
+
+	tests := map[string]string{
+		"../../spiel2/boese.png": "bilder/boese.png",
+		`..\..\boese2.png`:       "bilder/boese2.png",
+		"/etc/boese3.png":        "bilder/boese3.png",
+		"stern.png":              "bilder/stern.png",
+	}
+	for upload, want := range tests {
+		path, err := proj.SaveImage(upload, testPNG(t, 2, 2))
+		if err != nil || path != want {
+			t.Errorf("SaveImage(%q) = %q, %v; want %q", upload, path, err, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "spiel2", "boese.png")); err == nil {
+		t.Error("upload escaped the project")
+	}
+
+	for _, upload := range []string{"..", ".versteckt.png", "skript.ben", "seite.html"} {
+		if _, err := proj.SaveImage(upload, []byte("x")); err == nil {
+			t.Errorf("SaveImage(%q) succeeded", upload)
+		}
+	}
+
+	// bilder/x.png links out of the project and must not be overwritten
+	if _, err := proj.SaveImage("x.png", testPNG(t, 2, 2)); !errors.Is(err, ErrOutside) {
+		t.Errorf("SaveImage through a link: %v", err)
+	}
+}
+
+func TestInWorkDir(t *testing.T) {
+	proj := newTestProject(t, nil, withSandbox)
+	workDir, outside := filepath.Dir(proj.Path), outsideDir(proj)
+	os.Symlink(outside, filepath.Join(workDir, "verlinkt"))
+
+	if proj, err := InWorkDir(workDir, "spiel", false); err != nil || proj.Name != "spiel" {
+		t.Errorf("InWorkDir(spiel) = %v", err)
+	}
+	for _, name := range []string{"../draussen", "spiel/../spiel2", "", ".versteckt"} {
+		if _, err := InWorkDir(workDir, name, true); err == nil {
+			t.Errorf("InWorkDir(%q) succeeded", name)
+		}
+	}
+	if _, err := InWorkDir(workDir, "verlinkt", false); !errors.Is(err, ErrOutside) {
+		t.Errorf("InWorkDir(verlinkt) = %v, want ErrOutside", err)
+	}
+	if _, err := InWorkDir(workDir, "fehlt", false); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("InWorkDir(fehlt) = %v", err)
+	}
+	if _, err := InWorkDir(workDir, "neu", true); err != nil {
+		t.Errorf("InWorkDir(neu, create) = %v", err)
+	}
+}
+
+func TestHomeDir(t *testing.T) {
+	proj := newTestProject(t, nil, withSandbox)
+	workDir, outside := filepath.Dir(proj.Path), outsideDir(proj)
+	os.Symlink(outside, filepath.Join(workDir, "verlinkt"))
+
+	home, err := HomeDir(workDir, "anna")
+	if err != nil || home != filepath.Join(workDir, "anna") {
+		t.Fatalf("HomeDir(anna) = %q, %v", home, err)
+	}
+	if info, err := os.Stat(home); err != nil || !info.IsDir() {
+		t.Errorf("home folder not created: %v", err)
+	}
+	if again, err := HomeDir(workDir, "anna"); err != nil || again != home {
+		t.Errorf("second HomeDir(anna) = %q, %v", again, err)
+	}
+	for _, user := range []string{"../draussen", "a/b", "", ".versteckt", "verlinkt"} {
+		if _, err := HomeDir(workDir, user); !errors.Is(err, os.ErrPermission) {
+			t.Errorf("HomeDir(%q) = %v, want a permission error", user, err)
+		}
+	}
+}
+
+func TestCopyFrom(t *testing.T) {
+	proj := newTestProject(t, nil, withSandbox)
+	workDir := filepath.Dir(proj.Path)
+	os.MkdirAll(filepath.Join(workDir, "spiel", ".verlauf"), 0755)
+	os.WriteFile(filepath.Join(workDir, "spiel", ".verlauf", "alt.json"), []byte("{}"), 0644)
+
+	copied, err := New(filepath.Join(workDir, "kopie"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := copied.CopyFrom(proj.Path); err != nil {
+		t.Fatal(err)
+	}
+
+	if content, err := copied.ReadFile("hauptspiel.ben"); err != nil || content != "VAR x = 1\n" {
+		t.Errorf("hauptspiel.ben = %q, %v", content, err)
+	}
+	if !copied.Exists("bilder/held.png") {
+		t.Error("bilder/held.png not copied")
+	}
+	for _, name := range []string{"link.ben", "draussen", "bilder/x.png", ".verlauf"} {
+		if _, err := os.Lstat(filepath.Join(copied.Path, name)); err == nil {
+			t.Errorf("%s was copied", name)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package project
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testOption sets up more than the files for newTestProject
+type testOption int
+
+const (
+	// withSandbox puts next to the project a sibling spiel2, whose name
+	// starts with the project's name, and outside of its folder a folder
+	// draussen (see outsideDir). The project contains symbolic links into
+	// both.
+	withSandbox testOption = iota
+	// withDefaults creates the default project before the files are written
+	withDefaults
+)
+
+// newTestProject opens the project spiel in a temporary folder, set up as
+// the options say, and writes the files into it
+func newTestProject(t *testing.T, files map[string]string, options ...testOption) *Project {
+	t.Helper()
+	base := t.TempDir()
+	for _, option := range options {
+		switch option {
+		case withSandbox:
+			sandbox(t, base)
+		}
+	}
+
+	proj, err := New(filepath.Join(base, "work", "spiel"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, option := range options {
+		if option == withDefaults {
+			if err := proj.CreateDefaultProject(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for name, content := range files {
+		if err := proj.WriteFile(name, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return proj
+}
+
+// sandbox creates the folders of withSandbox in base
+func sandbox(t *testing.T, base string) {
+	workDir := filepath.Join(base, "work")
+	outside := filepath.Join(base, "draussen")
+	for _, dir := range []string{"work/spiel/bilder", "work/spiel2", "draussen"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(workDir, "spiel", "hauptspiel.ben"), []byte("VAR x = 1\n"), 0644)
+	os.WriteFile(filepath.Join(workDir, "spiel", "bilder", "held.png"), []byte("held"), 0644)
+	os.WriteFile(filepath.Join(workDir, "spiel2", "geheim.ben"), []byte("geheim"), 0644)
+	os.WriteFile(filepath.Join(outside, "geheim.png"), []byte("geheim"), 0644)
+
+	links := map[string]string{
+		"link.ben":     filepath.Join(workDir, "spiel2", "geheim.ben"),
+		"relativ.ben":  "../spiel2/geheim.ben",
+		"draussen":     outside,
+		"bilder/x.png": filepath.Join(outside, "geheim.png"),
+		"innen.ben":    "hauptspiel.ben",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(workDir, "spiel", name)); err != nil {
+			t.Skipf("symbolic links not supported: %v", err)
+		}
+	}
+}
+
+// outsideDir is the folder next to the work folder of a sandbox project
+func outsideDir(proj *Project) string {
+	return filepath.Join(filepath.Dir(filepath.Dir(proj.Path)), "draussen")
+}
+
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,461 @@
+package project
+
+import (
+	"benlang/internal/diff"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HistoryFolder keeps earlier versions of the project. File contents are
+// stored once per content in objekte/, each version in versionen/ lists
+// the files it consists of.
+const HistoryFolder = ".verlauf"
+
+// Retention limits for the history. The newest version is always kept.
+const (
+	MaxVersions   = 200
+	MaxVersionAge = 90 * 24 * time.Hour
+)
+
+// Version is the state of all project files at one point in time
+type Version struct {
+	ID     string                 `json:"id"`
+	Time   time.Time              `json:"zeit"`
+	Reason string                 `json:"anlass"`
+	Files  map[string]VersionFile `json:"dateien"`
+}
+
+// VersionFile points to the stored content of one file. Size and time of
+// change let the next snapshot skip reading files that did not change.
+type VersionFile struct {
+	Hash    string    `json:"hash"`
+	Size    int64     `json:"groesse"`
+	ModTime time.Time `json:"geaendert"`
+}
+
+// VersionInfo summarises a version for lists
+type VersionInfo struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"zeit"`
+	Reason  string    `json:"anlass"`
+	Changed []string  `json:"geaendert"` // files that differ from the version before
+}
+
+var (
+	versionsDir = path.Join(HistoryFolder, "versionen")
+	objectsDir  = path.Join(HistoryFolder, "objekte")
+)
+
+// Snapshot records the current state of the project. If nothing changed
+// since the last version, or the project is empty and has no history yet,
+// no new version is made and false is returned.
+func (p *Project) Snapshot(reason string) (Version, bool, error) {
+	versions, err := p.versions()
+	if err != nil {
+		return Version{}, false, err
+	}
+	var last *Version
+	if len(versions) > 0 {
+		last = &versions[len(versions)-1]
+	}
+
+	current, err := p.scan(last, true)
+	if err != nil {
+		return Version{}, false, err
+	}
+	if last == nil && len(current) == 0 || last != nil && sameContent(last.Files, current) {
+		return Version{}, false, nil
+	}
+
+	now := time.Now()
+	v := Version{Time: now, Reason: reason, Files: current}
+	err = p.withRoot(".", func(root *os.Root, _ string) error {
+		if err := root.MkdirAll(versionsDir, 0755); err != nil {
+			return err
+		}
+
+		// Two snapshots within the same microsecond get different names
+		for {
+			v.ID = now.Format(stampLayout)
+			if _, err := root.Stat(path.Join(versionsDir, v.ID+".json")); errors.Is(err, fs.ErrNotExist) {
+				break
+			}
+			now = now.Add(time.Microsecond)
+		}
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		return root.WriteFile(path.Join(versionsDir, v.ID+".json"), data, 0644)
+	})
+	if err != nil {
+		return Version{}, false, err
+	}
+
+	return v, true, p.pruneHistory(append(versions, v))
+}
+
+// scan hashes the current project files. With store, their contents are
+// saved in objekte/. Files whose size and time of change match the last
+// version are not read again.
+func (p *Project) scan(last *Version, store bool) (map[string]VersionFile, error) {
+	files, err := p.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]VersionFile{}
+	err = p.withRoot(".", func(root *os.Root, _ string) error {
+		if store {
+			if err := root.MkdirAll(objectsDir, 0755); err != nil {
+				return err
+			}
+		}
+
+		for _, f := range files {
+			if f.IsDir {
+				continue
+			}
+			name := filepath.ToSlash(f.Name)
+			info, err := root.Stat(f.Name)
+			if err != nil {
+				return err
+			}
+			if last != nil {
+				if old, ok := last.Files[name]; ok && old.Size == info.Size() && old.ModTime.Equal(info.ModTime()) {
+					result[name] = old
+					continue
+				}
+			}
+
+			data, err := root.ReadFile(f.Name)
+			if err != nil {
+				return err
+			}
+			sum := sha256.Sum256(data)
+			hash := hex.EncodeToString(sum[:])
+			if store {
+				object := path.Join(objectsDir, hash)
+				if _, err := root.Stat(object); errors.Is(err, fs.ErrNotExist) {
+					if err := root.WriteFile(object, data, 0644); err != nil {
+						return err
+					}
+				}
+			}
+			result[name] = VersionFile{Hash: hash, Size: info.Size(), ModTime: info.ModTime()}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// sameContent reports whether two versions consist of the same files with
+// the same contents; times of change do not count
+func sameContent(a, b map[string]VersionFile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, f := range a {
+		if g, ok := b[name]; !ok || g.Hash != f.Hash {
+			return false
+		}
+	}
+	return true
+}
+
+// versions reads all versions, oldest first
+func (p *Project) versions() ([]Version, error) {
+	var versions []Version
+	err := p.withRoot(".", func(root *os.Root, _ string) error {
+		entries, err := fs.ReadDir(root.FS(), versionsDir)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+				continue
+			}
+			data, err := root.ReadFile(path.Join(versionsDir, e.Name()))
+			if err != nil {
+				return err
+			}
+			var v Version
+			if err := json.Unmarshal(data, &v); err != nil {
+				continue
+			}
+			versions = append(versions, v)
+		}
+		return nil
+	})
+	sort.Slice(versions, func(i, j int) bool { return versions[i].ID < versions[j].ID })
+	return versions, err
+}
+
+// pruneHistory removes versions beyond the retention limits and the file
+// contents no version needs any more
+func (p *Project) pruneHistory(versions []Version) error {
+	cutoff := time.Now().Add(-MaxVersionAge)
+	keep := versions[:0:0]
+	var drop []Version
+	for i, v := range versions {
+		newest := i == len(versions)-1
+		if !newest && (len(versions)-i > MaxVersions || v.Time.Before(cutoff)) {
+			drop = append(drop, v)
+			continue
+		}
+		keep = append(keep, v)
+	}
+	if len(drop) == 0 {
+		return nil
+	}
+
+	used := map[string]bool{}
+	for _, v := range keep {
+		for _, f := range v.Files {
+			used[f.Hash] = true
+		}
+	}
+
+	return p.withRoot(".", func(root *os.Root, _ string) error {
+		for _, v := range drop {
+			if err := root.Remove(path.Join(versionsDir, v.ID+".json")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+		entries, err := fs.ReadDir(root.FS(), objectsDir)
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if !used[e.Name()] {
+				root.Remove(path.Join(objectsDir, e.Name()))
+			}
+		}
+		return nil
+	})
+}
+
+// History lists the versions, newest first, with the files that changed
+// compared to the version before
+func (p *Project) History() ([]VersionInfo, error) {
+	versions, err := p.versions()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]VersionInfo, len(versions))
+	for i, v := range versions {
+		var before map[string]VersionFile
+		if i > 0 {
+			before = versions[i-1].Files
+		}
+		infos[len(versions)-1-i] = VersionInfo{
+			ID:      v.ID,
+			Time:    v.Time,
+			Reason:  v.Reason,
+			Changed: changedFiles(before, v.Files),
+		}
+	}
+	return infos, nil
+}
+
+// changedFiles lists the files that were added, removed or changed
+func changedFiles(before, after map[string]VersionFile) []string {
+	changed := []string{}
+	for name, f := range after {
+		if g, ok := before[name]; !ok || g.Hash != f.Hash {
+			changed = append(changed, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Version returns one version
+func (p *Project) Version(id string) (Version, error) {
+	versions, err := p.versions()
+	if err != nil {
+		return Version{}, err
+	}
+	for _, v := range versions {
+		if v.ID == id {
+			return v, nil
+		}
+	}
+	return Version{}, &fileError{fmt.Sprintf("Die Version '%s' gibt es nicht", id), fs.ErrNotExist}
+}
+
+// VersionFileContent returns the content of a file in a version
+func (p *Project) VersionFileContent(id, name string) (string, error) {
+	v, err := p.Version(id)
+	if err != nil {
+		return "", err
+	}
+	return p.versionContent(v, name)
+}
+
+func (p *Project) versionContent(v Version, name string) (string, error) {
+	name = filepath.ToSlash(filepath.Clean(filepath.FromSlash(name)))
+	f, ok := v.Files[name]
+	if !ok {
+		return "", &fileError{fmt.Sprintf("In der Version '%s' gibt es '%s' nicht", v.ID, name), fs.ErrNotExist}
+	}
+	if v.ID == "" {
+		return p.ReadFile(name)
+	}
+	return p.ReadFile(path.Join(objectsDir, f.Hash))
+}
+
+// RestoreVersion brings back a file, or with an empty name the whole
+// project, as it was in a version. The current state is recorded first,
+// and files that did not exist yet are moved into the trash, so a restore
+// can be undone. It returns the files that changed.
+func (p *Project) RestoreVersion(id, name string) ([]string, error) {
+	v, err := p.Version(id)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		if _, err := editableName(name); err != nil {
+			return nil, err
+		}
+		if _, err := p.versionContent(v, name); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, _, err := p.Snapshot("Vor dem Zurückholen von " + id); err != nil {
+		return nil, err
+	}
+	current, err := p.currentVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	changed := []string{}
+	for _, f := range changedFiles(current.Files, v.Files) {
+		if name == "" || f == filepath.ToSlash(filepath.Clean(filepath.FromSlash(name))) {
+			changed = append(changed, f)
+		}
+	}
+
+	for _, f := range changed {
+		if _, ok := v.Files[f]; !ok {
+			if _, err := p.Delete(f); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		content, err := p.versionContent(v, f)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.WriteFile(f, content); err != nil {
+			return nil, err
+		}
+	}
+
+	// projekt.json may have changed
+	if err := p.ReloadManifest(); err != nil {
+		return nil, err
+	}
+
+	if _, _, err := p.Snapshot("Zurückgeholt: " + id); err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// FileDiff is how one file differs between two versions
+type FileDiff struct {
+	Name   string      `json:"datei"`
+	Change string      `json:"art"` // "neu", "geloescht" or "geaendert"
+	Lines  []diff.Line `json:"zeilen,omitempty"`
+}
+
+// CompareVersions compares two versions. An empty id for to means the
+// files as they are now. Line changes are given for .ben files, where
+// re-indentation does not count, and for the manifest; for images and
+// sounds only that they changed.
+func (p *Project) CompareVersions(from, to string) ([]FileDiff, error) {
+	a, err := p.Version(from)
+	if err != nil {
+		return nil, err
+	}
+	var b Version
+	if to == "" {
+		b, err = p.currentVersion()
+	} else {
+		b, err = p.Version(to)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	diffs := []FileDiff{}
+	for _, name := range changedFiles(a.Files, b.Files) {
+		d := FileDiff{Name: name, Change: "geaendert"}
+		_, inA := a.Files[name]
+		_, inB := b.Files[name]
+		switch {
+		case !inA:
+			d.Change = "neu"
+		case !inB:
+			d.Change = "geloescht"
+		}
+
+		if strings.HasSuffix(name, ".ben") || name == ManifestFile {
+			var old, new string
+			if inA {
+				if old, err = p.versionContent(a, name); err != nil {
+					return nil, err
+				}
+			}
+			if inB {
+				if new, err = p.versionContent(b, name); err != nil {
+					return nil, err
+				}
+			}
+			if name == ManifestFile {
+				d.Lines = diff.Lines(old, new)
+			} else {
+				d.Lines = diff.Source(old, new)
+			}
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs, nil
+}
+
+// currentVersion describes the files as they are now without storing
+// them. It has no ID; its contents are read from the project itself.
+func (p *Project) currentVersion() (Version, error) {
+	versions, err := p.versions()
+	if err != nil {
+		return Version{}, err
+	}
+	var last *Version
+	if len(versions) > 0 {
+		last = &versions[len(versions)-1]
+	}
+	files, err := p.scan(last, false)
+	return Version{Time: time.Now(), Files: files}, err
+}
@@ -0,0 +1,166 @@
+package project
+
+import (
+	"benlang/internal/diff"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// historyFiles is a project before its first version
+var historyFiles = map[string]string{
+	"hauptspiel.ben":  "VAR x = 1\n",
+	"bilder/held.png": "held",
+}
+
+func snapshot(t *testing.T, proj *Project, reason string) (Version, bool) {
+	t.Helper()
+	v, created, err := proj.Snapshot(reason)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v, created
+}
+
+func TestSnapshotDeduplicates(t *testing.T) {
+	proj := newTestProject(t, historyFiles)
+
+	first, created := snapshot(t, proj, "erste")
+	if !created || len(first.Files) != 2 {
+		t.Fatalf("first snapshot = %+v, %v", first, created)
+	}
+	if _, created := snapshot(t, proj, "gleich"); created {
+		t.Error("snapshot without changes created a version")
+	}
+
+	proj.WriteFile("hauptspiel.ben", "VAR x = 2\n")
+	second, created := snapshot(t, proj, "zweite")
+	if !created || second.Files["bilder/held.png"].Hash != first.Files["bilder/held.png"].Hash {
+		t.Errorf("unchanged image not shared: %+v", second)
+	}
+
+	// Writing the old content again adds a version but no new object
+	proj.WriteFile("hauptspiel.ben", "VAR x = 1\n")
+	snapshot(t, proj, "dritte")
+	objects, _ := proj.withRootEntries(objectsDir)
+	if objects != 3 {
+		t.Errorf("%d stored objects, want 3", objects)
+	}
+
+	history, err := proj.History()
+	if err != nil || len(history) != 3 {
+		t.Fatalf("History() = %+v, %v", history, err)
+	}
+	if history[0].Reason != "dritte" || len(history[0].Changed) != 1 || history[0].Changed[0] != "hauptspiel.ben" {
+		t.Errorf("newest version = %+v", history[0])
+	}
+}
+
+func TestRestoreVersion(t *testing.T) {
+	proj := newTestProject(t, historyFiles)
+	first, _ := snapshot(t, proj, "erste")
+
+	proj.WriteFile("hauptspiel.ben", "// alles weg\n")
+	proj.WriteFile("level.ben", "VAR level = 1\n")
+	snapshot(t, proj, "kaputt")
+
+	changed, err := proj.RestoreVersion(first.ID, "hauptspiel.ben")
+	if err != nil || len(changed) != 1 {
+		t.Fatalf("RestoreVersion(file) = %v, %v", changed, err)
+	}
+	if content, _ := proj.ReadFile("hauptspiel.ben"); content != "VAR x = 1\n" {
+		t.Errorf("restored content = %q", content)
+	}
+	if !proj.Exists("level.ben") {
+		t.Error("restoring one file touched another")
+	}
+
+	if _, err := proj.RestoreVersion(first.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if proj.Exists("level.ben") {
+		t.Error("file added after the version is still there")
+	}
+	if items, _ := proj.Trash(); len(items) != 1 || items[0].Name != "level.ben" {
+		t.Errorf("removed file not in trash: %+v", items)
+	}
+
+	// The state before the restore can be brought back
+	history, _ := proj.History()
+	var before string
+	for _, v := range history {
+		if v.Reason == "kaputt" {
+			before = v.ID
+		}
+	}
+	if content, err := proj.VersionFileContent(before, "hauptspiel.ben"); err != nil || content != "// alles weg\n" {
+		t.Errorf("VersionFileContent() = %q, %v", content, err)
+	}
+
+	if _, err := proj.RestoreVersion("gibtsnicht", ""); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unknown version: %v", err)
+	}
+	if _, err := proj.RestoreVersion(first.ID, "../draussen.ben"); !errors.Is(err, ErrOutside) {
+		t.Errorf("path outside: %v", err)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	proj := newTestProject(t, historyFiles)
+	first, _ := snapshot(t, proj, "erste")
+
+	proj.WriteFile("hauptspiel.ben", "VAR x = 1\nVAR y = 2\n")
+	proj.WriteFile("bilder/held.png", "neuer held")
+	proj.Delete("bilder/held.png")
+
+	diffs, err := proj.CompareVersions(first.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 2 {
+		t.Fatalf("got %+v", diffs)
+	}
+	if d := diffs[0]; d.Name != "bilder/held.png" || d.Change != "geloescht" || d.Lines != nil {
+		t.Errorf("image diff = %+v", d)
+	}
+	if d := diffs[1]; d.Name != "hauptspiel.ben" || d.Change != "geaendert" || !diff.Changed(d.Lines) {
+		t.Errorf("code diff = %+v", d)
+	}
+	if history, _ := proj.History(); len(history) != 1 {
+		t.Error("comparing with the current files stored a version")
+	}
+}
+
+func TestPruneHistory(t *testing.T) {
+	proj := newTestProject(t, historyFiles)
+	var versions []Version
+	for i := 0; i < MaxVersions+5; i++ {
+		proj.WriteFile("hauptspiel.ben", string(rune('a'+i%26))+string(rune('a'+i/26)))
+		v, _ := snapshot(t, proj, "")
+		versions = append(versions, v)
+	}
+
+	history, _ := proj.History()
+	if len(history) != MaxVersions {
+		t.Errorf("%d versions kept, want %d", len(history), MaxVersions)
+	}
+	if _, err := proj.Version(versions[0].ID); err == nil {
+		t.Error("oldest version was kept")
+	}
+	if n, _ := proj.withRootEntries(objectsDir); n != MaxVersions+1 {
+		t.Errorf("%d objects after pruning, want %d", n, MaxVersions+1)
+	}
+}
+
+// withRootEntries counts the entries of a folder inside the project
+func (p *Project) withRootEntries(dir string) (int, error) {
+	n := 0
+	err := p.withRoot(dir, func(root *os.Root, name string) error {
+		entries, err := fs.ReadDir(root.FS(), filepath.ToSlash(name))
+		n = len(entries)
+		return err
+	})
+	return n, err
+}
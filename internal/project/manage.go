@@ -0,0 +1,331 @@
+package project
+
+import (
+	"benlang/internal/analysis"
+	"benlang/internal/compiler"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TrashFolder holds deleted files until they are restored. Like all
+// hidden folders it does not show up in the file list.
+const TrashFolder = ".papierkorb"
+
+// MaxTrashAge is how long deleted files are kept. Older ones are removed
+// for good when the next file is deleted.
+const MaxTrashAge = 30 * 24 * time.Hour
+
+// stampLayout starts the names of items in the trash and of versions
+const stampLayout = "20060102-150405.000000"
+
+// TrashItem is a deleted file or folder
+type TrashItem struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"` // path before it was deleted
+	Deleted time.Time `json:"geloescht"`
+	IsDir   bool      `json:"isDir"`
+}
+
+// editableName checks a path for operations that change the project:
+// it must be inside the project and must not be hidden, so the trash and
+// other internal folders cannot be touched
+func editableName(name string) (string, error) {
+	name, err := localName(name)
+	if err != nil {
+		return "", err
+	}
+	if name == "." {
+		return "", &fileError{"Bitte gib einen Namen an", fs.ErrInvalid}
+	}
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if strings.HasPrefix(part, ".") {
+			return "", &fileError{fmt.Sprintf("'%s' ist ein versteckter Name", part), fs.ErrPermission}
+		}
+	}
+	return name, nil
+}
+
+// Rename renames or moves a file or folder. Existing files are never
+// overwritten; the manifest follows the change. The manifest itself is
+// only changed through SaveManifest, which checks it.
+func (p *Project) Rename(from, to string) error {
+	from, err := editableName(from)
+	if err != nil {
+		return err
+	}
+	to, err = editableName(to)
+	if err != nil {
+		return err
+	}
+	if from == ManifestFile || to == ManifestFile {
+		return &fileError{fmt.Sprintf("%s kann nicht umbenannt werden", ManifestFile), fs.ErrInvalid}
+	}
+
+	err = p.withRoot(".", func(root *os.Root, _ string) error {
+		info, err := root.Lstat(from)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !IsProjectFile(to) {
+			return &fileError{fmt.Sprintf("'%s': Dieser Dateityp ist in Projekten nicht erlaubt", filepath.ToSlash(to)), fs.ErrInvalid}
+		}
+		if _, err := root.Lstat(to); err == nil {
+			return errExists(to)
+		}
+		if err := root.MkdirAll(filepath.Dir(to), 0755); err != nil {
+			return err
+		}
+		return root.Rename(from, to)
+	})
+	if err != nil {
+		return err
+	}
+
+	return p.renameInManifest(filepath.ToSlash(from), filepath.ToSlash(to))
+}
+
+// renameInManifest updates the paths in the manifest after a rename
+func (p *Project) renameInManifest(from, to string) error {
+	m := p.Manifest
+	changed := false
+	rename := func(name string) string {
+		switch {
+		case name == from:
+			changed = true
+			return to
+		case strings.HasPrefix(name, from+"/"):
+			changed = true
+			return to + strings.TrimPrefix(name, from)
+		}
+		return name
+	}
+
+	m.Entry = rename(m.Entry)
+	m.Files = append([]string(nil), m.Files...)
+	for i, f := range m.Files {
+		m.Files[i] = rename(f)
+	}
+	m.Assets = append([]string(nil), m.Assets...)
+	for i, f := range m.Assets {
+		m.Assets[i] = rename(f)
+	}
+
+	if !changed {
+		return nil
+	}
+	return p.SaveManifest(m)
+}
+
+// Move moves a file or folder into another folder of the project; an
+// empty folder means the top level
+func (p *Project) Move(name, folder string) (string, error) {
+	target := path.Base(filepath.ToSlash(name))
+	if folder != "" && folder != "." {
+		target = path.Join(filepath.ToSlash(folder), target)
+	}
+	if err := p.Rename(name, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// CreateFolder creates a folder, including missing parent folders
+func (p *Project) CreateFolder(name string) error {
+	name, err := editableName(name)
+	if err != nil {
+		return err
+	}
+	return p.withRoot(name, func(root *os.Root, name string) error {
+		if _, err := root.Lstat(name); err == nil {
+			return errExists(name)
+		}
+		return root.MkdirAll(name, 0755)
+	})
+}
+
+// Delete moves a file or folder into the trash
+func (p *Project) Delete(name string) (TrashItem, error) {
+	name, err := editableName(name)
+	if err != nil {
+		return TrashItem{}, err
+	}
+
+	now := time.Now()
+	item := TrashItem{
+		ID:      now.Format(stampLayout) + "_" + url.PathEscape(filepath.ToSlash(name)),
+		Name:    filepath.ToSlash(name),
+		Deleted: now,
+	}
+
+	err = p.withRoot(name, func(root *os.Root, name string) error {
+		info, err := root.Lstat(name)
+		if err != nil {
+			return err
+		}
+		item.IsDir = info.IsDir()
+		if err := root.MkdirAll(TrashFolder, 0755); err != nil {
+			return err
+		}
+		return root.Rename(name, filepath.Join(TrashFolder, item.ID))
+	})
+	if err == nil && name == ManifestFile {
+		err = p.ReloadManifest()
+	}
+	if err == nil {
+		err = p.pruneTrash(now.Add(-MaxTrashAge))
+	}
+	return item, err
+}
+
+// pruneTrash removes the files deleted before cutoff for good
+func (p *Project) pruneTrash(cutoff time.Time) error {
+	items, err := p.Trash()
+	if err != nil {
+		return err
+	}
+	return p.withRoot(TrashFolder, func(root *os.Root, _ string) error {
+		for _, item := range items {
+			if item.Deleted.Before(cutoff) {
+				if err := root.RemoveAll(filepath.Join(TrashFolder, item.ID)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// EmptyTrash removes all deleted files for good, to make room in a full
+// project
+func (p *Project) EmptyTrash() error {
+	return p.withRoot(TrashFolder, func(root *os.Root, name string) error {
+		return root.RemoveAll(name)
+	})
+}
+
+// Trash lists the deleted files, newest first
+func (p *Project) Trash() ([]TrashItem, error) {
+	items := []TrashItem{}
+	err := p.withRoot(TrashFolder, func(root *os.Root, name string) error {
+		entries, err := fs.ReadDir(root.FS(), TrashFolder)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if item, ok := parseTrashID(e.Name()); ok {
+				item.IsDir = e.IsDir()
+				items = append(items, item)
+			}
+		}
+		return nil
+	})
+
+	sort.Slice(items, func(i, j int) bool { return items[i].ID > items[j].ID })
+	return items, err
+}
+
+func parseTrashID(id string) (TrashItem, bool) {
+	stamp, escaped, ok := strings.Cut(id, "_")
+	if !ok {
+		return TrashItem{}, false
+	}
+	deleted, err := time.ParseInLocation(stampLayout, stamp, time.Local)
+	if err != nil {
+		return TrashItem{}, false
+	}
+	name, err := url.PathUnescape(escaped)
+	if err != nil {
+		return TrashItem{}, false
+	}
+	return TrashItem{ID: id, Name: name, Deleted: deleted}, true
+}
+
+// Restore moves a deleted file back to where it was. If that place is
+// taken by now, nothing is changed.
+func (p *Project) Restore(id string) (string, error) {
+	item, ok := parseTrashID(id)
+	if !ok || strings.ContainsAny(id, `/\`) {
+		return "", &fileError{"Diese Datei ist nicht im Papierkorb", fs.ErrNotExist}
+	}
+	name, err := editableName(item.Name)
+	if err != nil {
+		return "", err
+	}
+
+	err = p.withRoot(name, func(root *os.Root, name string) error {
+		if _, err := root.Lstat(filepath.Join(TrashFolder, id)); err != nil {
+			return &fileError{"Diese Datei ist nicht im Papierkorb", fs.ErrNotExist}
+		}
+		if _, err := root.Lstat(name); err == nil {
+			return errExists(name)
+		}
+		if err := root.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return err
+		}
+		return root.Rename(filepath.Join(TrashFolder, id), name)
+	})
+	if err == nil && name == ManifestFile {
+		err = p.ReloadManifest()
+	}
+	return item.Name, err
+}
+
+// inTrash reports whether a file was deleted, on its own or with its folder
+func (p *Project) inTrash(name string) bool {
+	items, _ := p.Trash()
+	for _, item := range items {
+		if item.Name == name || strings.HasPrefix(name, item.Name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// AssetWarnings checks that the images and sounds loaded in the code are
+// still in the project. Like the server, it also looks in AssetFolders.
+func (p *Project) AssetWarnings(files []analysis.File) []compiler.Error {
+	var warnings []compiler.Error
+	for _, a := range compiler.Assets(files) {
+		name := strings.TrimPrefix(a.Path, "/")
+		if p.anyAssetPath(name, p.Exists) {
+			continue
+		}
+
+		message := fmt.Sprintf("'%s' gibt es im Projekt nicht", a.Path)
+		if p.anyAssetPath(name, p.inTrash) {
+			message = fmt.Sprintf("'%s' liegt im Papierkorb, wird aber noch mit %s geladen", a.Path, a.Function)
+		}
+		warnings = append(warnings, compiler.Error{
+			File:    a.File,
+			Line:    a.Line,
+			Column:  a.Column,
+			Message: message,
+		})
+	}
+	return warnings
+}
+
+// anyAssetPath reports whether check holds for name in the project folder
+// or in one of the AssetFolders
+func (p *Project) anyAssetPath(name string, check func(string) bool) bool {
+	if check(name) {
+		return true
+	}
+	for _, folder := range AssetFolders {
+		if check(folder + "/" + name) {
+			return true
+		}
+	}
+	return false
+}
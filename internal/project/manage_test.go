@@ -0,0 +1,215 @@
+package project
+
+import (
+	"benlang/internal/analysis"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// managedFiles are added to the default project
+var managedFiles = map[string]string{
+	"level.ben":       "VAR level = 1\n",
+	"bilder/held.png": "held",
+}
+
+func TestDeleteAndRestore(t *testing.T) {
+	proj := newTestProject(t, managedFiles, withDefaults)
+
+	item, err := proj.Delete("bilder/held.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proj.Exists("bilder/held.png") {
+		t.Error("file still there after delete")
+	}
+	files, _ := proj.ListFiles()
+	for _, f := range files {
+		if strings.Contains(f.Name, TrashFolder) {
+			t.Errorf("trash shows up in file list: %s", f.Name)
+		}
+	}
+
+	items, err := proj.Trash()
+	if err != nil || len(items) != 1 || items[0].Name != "bilder/held.png" || items[0].ID != item.ID {
+		t.Fatalf("Trash() = %+v, %v", items, err)
+	}
+
+	name, err := proj.Restore(item.ID)
+	if err != nil || name != "bilder/held.png" {
+		t.Fatalf("Restore() = %q, %v", name, err)
+	}
+	if content, _ := proj.ReadFile("bilder/held.png"); content != "held" {
+		t.Errorf("restored content = %q", content)
+	}
+	if items, _ := proj.Trash(); len(items) != 0 {
+		t.Errorf("trash not empty after restore: %+v", items)
+	}
+}
+
+func TestDeleteRemovesOldTrash(t *testing.T) {
+	proj := newTestProject(t, managedFiles, withDefaults)
+
+	item, err := proj.Delete("level.ben")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Pretend it was deleted long ago
+	old := time.Now().Add(-MaxTrashAge-time.Hour).Format(stampLayout) + "_level.ben"
+	trash := filepath.Join(proj.Path, TrashFolder)
+	if err := os.Rename(filepath.Join(trash, item.ID), filepath.Join(trash, old)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := proj.Delete("bilder/held.png"); err != nil {
+		t.Fatal(err)
+	}
+	items, _ := proj.Trash()
+	if len(items) != 1 || items[0].Name != "bilder/held.png" {
+		t.Errorf("trash = %+v, want only bilder/held.png", items)
+	}
+}
+
+func TestRestoreDoesNotOverwrite(t *testing.T) {
+	proj := newTestProject(t, managedFiles, withDefaults)
+
+	item, err := proj.Delete("level.ben")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proj.WriteFile("level.ben", "VAR neu = 2\n")
+
+	if _, err := proj.Restore(item.ID); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("Restore() error = %v, want fs.ErrExist", err)
+	}
+	if content, _ := proj.ReadFile("level.ben"); content != "VAR neu = 2\n" {
+		t.Errorf("new file was overwritten: %q", content)
+	}
+	if _, err := proj.Restore("../level.ben"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Restore() with bad id = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestRenameUpdatesManifest(t *testing.T) {
+	proj := newTestProject(t, managedFiles, withDefaults)
+	m := proj.Manifest
+	m.Files = []string{"level.ben"}
+	m.Assets = []string{"bilder/held.png"}
+	if err := proj.SaveManifest(m); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := proj.Rename("hauptspiel.ben", "start.ben"); err != nil {
+		t.Fatal(err)
+	}
+	if err := proj.Rename("bilder", "grafik"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := proj.Move("level.ben", "level"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := New(proj.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := reopened.Manifest
+	if got.Entry != "start.ben" || got.Files[0] != "level/level.ben" || got.Assets[0] != "grafik/held.png" {
+		t.Errorf("manifest not updated: %+v", got)
+	}
+	if !proj.Exists("grafik/held.png") || !proj.Exists("level/level.ben") {
+		t.Error("files not moved")
+	}
+}
+
+func TestRenameRefuses(t *testing.T) {
+	proj := newTestProject(t, managedFiles, withDefaults)
+
+	for _, c := range []struct {
+		from, to string
+		want     error
+	}{
+		{"level.ben", "hauptspiel.ben", fs.ErrExist},
+		{"level.ben", "level.exe", fs.ErrInvalid},
+		{"level.ben", ".papierkorb/level.ben", fs.ErrPermission},
+		{"level.ben", "../level.ben", fs.ErrPermission},
+		{"fehlt.ben", "da.ben", fs.ErrNotExist},
+		{"level.ben", ManifestFile, fs.ErrInvalid},
+		{ManifestFile, "alt.ben", fs.ErrInvalid},
+	} {
+		if err := proj.Rename(c.from, c.to); !errors.Is(err, c.want) {
+			t.Errorf("Rename(%q, %q) = %v, want %v", c.from, c.to, err, c.want)
+		}
+	}
+	if _, err := proj.Delete(TrashFolder); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("Delete(trash) = %v, want fs.ErrPermission", err)
+	}
+}
+
+func TestDeleteManifestReloads(t *testing.T) {
+	proj := newTestProject(t, managedFiles, withDefaults)
+	m := proj.Manifest
+	m.Title = "Pong"
+	if err := proj.SaveManifest(m); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := proj.Delete(ManifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proj.Manifest.Title != proj.Name {
+		t.Errorf("manifest after delete = %+v, want the defaults", proj.Manifest)
+	}
+
+	// Without projekt.json, renaming a file to it would skip the checks
+	proj.WriteFile("kaputt.ben", `{"einstieg": "../fremd.ben"}`)
+	if err := proj.Rename("kaputt.ben", ManifestFile); !errors.Is(err, fs.ErrInvalid) {
+		t.Errorf("rename onto the manifest: %v, want fs.ErrInvalid", err)
+	}
+
+	if _, err := proj.Restore(item.ID); err != nil {
+		t.Fatal(err)
+	}
+	if proj.Manifest.Title != "Pong" {
+		t.Errorf("manifest after restore = %+v", proj.Manifest)
+	}
+}
+
+func TestCreateFolder(t *testing.T) {
+	proj := newTestProject(t, managedFiles, withDefaults)
+
+	if err := proj.CreateFolder("level/welt1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := proj.CreateFolder("level/welt1"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("second CreateFolder() = %v, want fs.ErrExist", err)
+	}
+	if err := proj.CreateFolder("../daneben"); !errors.Is(err, ErrOutside) {
+		t.Errorf("CreateFolder outside = %v, want ErrOutside", err)
+	}
+}
+
+func TestAssetWarnings(t *testing.T) {
+	proj := newTestProject(t, managedFiles, withDefaults)
+	proj.WriteFile("bilder/boss.png", "boss")
+	files := []analysis.File{{Name: "hauptspiel.ben", Code: "FIGUR held = LADE_BILD(\"held.png\")\nFIGUR boss = LADE_BILD(\"bilder/boss.png\")\nSPIELE_TON(\"toene/fehlt.wav\")"}}
+
+	if _, err := proj.Delete("bilder/held.png"); err != nil {
+		t.Fatal(err)
+	}
+	warnings := proj.AssetWarnings(files)
+	if len(warnings) != 2 {
+		t.Fatalf("got %d warnings, want 2: %v", len(warnings), warnings)
+	}
+	if w := warnings[0]; w.Line != 1 || !strings.Contains(w.Message, "Papierkorb") {
+		t.Errorf("warning for deleted image = %v", w)
+	}
+	if w := warnings[1]; w.Line != 3 || !strings.Contains(w.Message, "gibt es im Projekt nicht") {
+		t.Errorf("warning for missing sound = %v", w)
+	}
+}
@@ -0,0 +1,188 @@
+package project
+
+import (
+	"benlang/internal/analysis"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ManifestFile is the name of the project description in the project folder
+const ManifestFile = "projekt.json"
+
+// Canvas limits in pixels
+const (
+	MinCanvasSize = 100
+	MaxCanvasSize = 4000
+)
+
+// Manifest describes a project: which file starts the game, in which order
+// the files are joined, how big the canvas is and who made it
+type Manifest struct {
+	Title       string   `json:"titel"`
+	Entry       string   `json:"einstieg"`
+	Files       []string `json:"dateien"` // order after the entry file; missing files follow alphabetically
+	Canvas      Canvas   `json:"leinwand"`
+	Author      string   `json:"autor"`
+	Description string   `json:"beschreibung"`
+	Age         string   `json:"alter"`  // target age, e.g. "8-12"
+	Assets      []string `json:"medien"` // images and sounds; empty means all
+}
+
+// Canvas is the size of the game area
+type Canvas struct {
+	Width  int `json:"breite"`
+	Height int `json:"hoehe"`
+}
+
+// DefaultManifest returns the settings of a project without projekt.json
+func DefaultManifest(title string) Manifest {
+	return Manifest{
+		Title:  title,
+		Entry:  "hauptspiel.ben",
+		Files:  []string{},
+		Canvas: Canvas{Width: 800, Height: 600},
+		Assets: []string{},
+	}
+}
+
+// loadManifest reads projekt.json from the project folder; missing values
+// get defaults
+func loadManifest(root *os.Root, title string) (Manifest, error) {
+	data, err := root.ReadFile(ManifestFile)
+	if os.IsNotExist(err) {
+		return DefaultManifest(title), nil
+	}
+	if err != nil {
+		return DefaultManifest(title), fmt.Errorf("%s kann nicht gelesen werden: %v", ManifestFile, err)
+	}
+
+	return ParseManifest(data, title)
+}
+
+// ParseManifest reads and checks the content of a projekt.json file
+func ParseManifest(data []byte, title string) (Manifest, error) {
+	m := DefaultManifest(title)
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("%s ist fehlerhaft: %v", ManifestFile, err)
+	}
+	if err := m.Validate(); err != nil {
+		return m, fmt.Errorf("%s ist fehlerhaft: %v", ManifestFile, err)
+	}
+	return m, nil
+}
+
+// Validate checks the manifest and fills in defaults for empty values
+func (m *Manifest) Validate() error {
+	defaults := DefaultManifest("")
+	if m.Entry == "" {
+		m.Entry = defaults.Entry
+	}
+	if m.Canvas.Width == 0 && m.Canvas.Height == 0 {
+		m.Canvas = defaults.Canvas
+	}
+	if m.Files == nil {
+		m.Files = []string{}
+	}
+	if m.Assets == nil {
+		m.Assets = []string{}
+	}
+
+	if !validFile(m.Entry) || !strings.HasSuffix(m.Entry, ".ben") {
+		return fmt.Errorf("'%s' ist keine gültige Startdatei", m.Entry)
+	}
+	for _, f := range m.Files {
+		if !validFile(f) || !strings.HasSuffix(f, ".ben") {
+			return fmt.Errorf("'%s' ist keine gültige .ben-Datei", f)
+		}
+	}
+	for _, f := range m.Assets {
+		if !validFile(f) || !IsProjectFile(f) || strings.HasSuffix(f, ".ben") {
+			return fmt.Errorf("'%s' ist kein gültiges Bild und kein gültiger Ton", f)
+		}
+	}
+	for _, size := range []int{m.Canvas.Width, m.Canvas.Height} {
+		if size < MinCanvasSize || size > MaxCanvasSize {
+			return fmt.Errorf("Die Leinwand muss zwischen %d und %d Pixel groß sein", MinCanvasSize, MaxCanvasSize)
+		}
+	}
+	return nil
+}
+
+// validFile reports whether name is a relative path inside the project
+func validFile(name string) bool {
+	return name != "" && !strings.Contains(name, `\`) && !path.IsAbs(name) &&
+		path.Clean(name) == name && name != ".." && !strings.HasPrefix(name, "../")
+}
+
+// SaveManifest validates m and writes it as projekt.json
+func (p *Project) SaveManifest(m Manifest) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := p.WriteFile(ManifestFile, string(data)+"\n"); err != nil {
+		return err
+	}
+	p.Manifest, p.ManifestError = m, nil
+	return nil
+}
+
+// ReloadManifest reads projekt.json again after it was changed on disk.
+// A broken file is no error here: the defaults apply and ManifestError
+// says what is wrong.
+func (p *Project) ReloadManifest() error {
+	return p.withRoot(".", func(root *os.Root, _ string) error {
+		p.Manifest, p.ManifestError = loadManifest(root, p.Name)
+		if p.ManifestError != nil {
+			p.Manifest = DefaultManifest(p.Name)
+		}
+		return nil
+	})
+}
+
+// AnalysisFiles returns the .ben files in the order the compiler joins
+// them, following the project's manifest
+func (p *Project) AnalysisFiles(sources map[string]string) []analysis.File {
+	return OrderFiles(sources, p.Manifest)
+}
+
+// OrderFiles sorts the files the way the compiler joins them: the entry
+// file first, then the files listed in the manifest, then the rest
+// alphabetically
+func OrderFiles(sources map[string]string, m Manifest) []analysis.File {
+	rank := map[string]int{m.Entry: 0}
+	for i, name := range m.Files {
+		if _, ok := rank[name]; !ok {
+			rank[name] = i + 1
+		}
+	}
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ri, oki := rank[names[i]]
+		rj, okj := rank[names[j]]
+		switch {
+		case oki && okj:
+			return ri < rj
+		case oki != okj:
+			return oki
+		}
+		return names[i] < names[j]
+	})
+
+	files := make([]analysis.File, len(names))
+	for i, name := range names {
+		files[i] = analysis.File{Name: name, Code: sources[name]}
+	}
+	return files
+}
@@ -0,0 +1,135 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestManifestDefaults(t *testing.T) {
+	proj, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := proj.Manifest
+	if m.Entry != "hauptspiel.ben" || m.Canvas != (Canvas{800, 600}) || m.Title != proj.Name {
+		t.Errorf("unexpected defaults: %+v", m)
+	}
+}
+
+func TestCreateDefaultProjectWritesManifest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "spiel")
+	proj, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := proj.CreateDefaultProject(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reopened.Exists(ManifestFile) || reopened.Manifest.Title != "spiel" {
+		t.Errorf("manifest not written: %+v", reopened.Manifest)
+	}
+}
+
+func TestManifestOrder(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ManifestFile), []byte(`{
+  "einstieg": "start.ben",
+  "dateien": ["zuletzt.ben", "figuren.ben"],
+  "leinwand": {"breite": 640, "hoehe": 480}
+}`), 0644)
+	for _, name := range []string{"start.ben", "zuletzt.ben", "figuren.ben", "anderes.ben"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("// "+name+"\n"), 0644)
+	}
+
+	proj, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proj.Manifest.Canvas != (Canvas{640, 480}) {
+		t.Errorf("canvas = %+v", proj.Manifest.Canvas)
+	}
+
+	sources, _ := proj.Sources()
+	var order []string
+	for _, f := range proj.AnalysisFiles(sources) {
+		order = append(order, f.Name)
+	}
+	want := []string{"start.ben", "zuletzt.ben", "figuren.ben", "anderes.ben"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+
+	files, _ := proj.ListFiles()
+	found := false
+	for _, f := range files {
+		found = found || f.Name == ManifestFile
+	}
+	if !found {
+		t.Error("ListFiles does not include the manifest")
+	}
+}
+
+func TestManifestInvalid(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{`{"einstieg": "../fremd.ben"}`, "keine gültige Startdatei"},
+		{`{"einstieg": "spiel.txt"}`, "keine gültige Startdatei"},
+		{`{"dateien": ["/etc/passwd.ben"]}`, "keine gültige .ben-Datei"},
+		{`{"medien": ["hauptspiel.ben"]}`, "kein gültiges Bild"},
+		{`{"leinwand": {"breite": 10, "hoehe": 600}}`, "Leinwand"},
+		{`{"titel": `, "fehlerhaft"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, ManifestFile), []byte(tt.content), 0644)
+
+		// The project still opens, so the file can be fixed in the IDE
+		proj, err := New(dir)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.content, err)
+		}
+		if proj.ManifestError == nil || !strings.Contains(proj.ManifestError.Error(), tt.want) {
+			t.Errorf("%s: got %v, want %q", tt.content, proj.ManifestError, tt.want)
+		}
+		if !reflect.DeepEqual(proj.Manifest, DefaultManifest(proj.Name)) {
+			t.Errorf("%s: manifest = %+v, want the defaults", tt.content, proj.Manifest)
+		}
+	}
+}
+
+func TestManifestRepaired(t *testing.T) {
+	proj, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proj.WriteFile(ManifestFile, `{"titel": "Pong", "leinwand": {"breite": 1, "hoehe": 1}}`)
+	if err := proj.ReloadManifest(); err != nil || proj.ManifestError == nil || proj.Manifest.Title != proj.Name {
+		t.Fatalf("broken manifest: %v, %v, %+v", err, proj.ManifestError, proj.Manifest)
+	}
+
+	proj.WriteFile(ManifestFile, `{"titel": "Pong"}`)
+	if err := proj.ReloadManifest(); err != nil || proj.ManifestError != nil || proj.Manifest.Title != "Pong" {
+		t.Errorf("repaired manifest: %v, %v, %+v", err, proj.ManifestError, proj.Manifest)
+	}
+}
+
+func TestCompileNeedsEntry(t *testing.T) {
+	proj, err := New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proj.WriteFile("level.ben", "VAR x = 1\n")
+	if _, _, err := proj.Compile(); err == nil || !strings.Contains(err.Error(), "hauptspiel.ben fehlt") {
+		t.Errorf("expected missing entry error, got %v", err)
+	}
+}
@@ -1,23 +1,34 @@
 package project
 
 import (
+	"benlang/internal/analysis"
+	"benlang/internal/compiler"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // Project represents a BenLang project
 type Project struct {
-	Path string
-	Name string
+	Path     string
+	Name     string
+	Manifest Manifest
+	// ManifestError says why projekt.json could not be used. The defaults
+	// apply meanwhile, so the project still opens and the file can be fixed.
+	ManifestError error
 }
 
 // FileInfo represents information about a project file
 type FileInfo struct {
-	Name    string `json:"name"`
-	IsDir   bool   `json:"isDir"`
-	Size    int64  `json:"size"`
-	Content string `json:"inhalt,omitempty"`
+	Name    string    `json:"name"`
+	IsDir   bool      `json:"isDir"`
+	Size    int64     `json:"size"`
+	Content string    `json:"inhalt,omitempty"`
+	Hash    string    `json:"hash,omitempty"` // ContentHash of Content
+	ModTime time.Time `json:"geaendert"`
 }
 
 // New creates a new Project instance
@@ -36,60 +47,91 @@ func New(path string) (*Project, error) {
 
 	name := filepath.Base(absPath)
 
+	root, err := os.OpenRoot(absPath)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	manifest, manifestErr := loadManifest(root, name)
+	if manifestErr != nil {
+		manifest = DefaultManifest(name)
+	}
+
 	return &Project{
-		Path: absPath,
-		Name: name,
+		Path:          absPath,
+		Name:          name,
+		Manifest:      manifest,
+		ManifestError: manifestErr,
 	}, nil
 }
 
-// ListFiles returns all files in the project
+// ListFiles returns all files in the project. Symbolic links that lead
+// out of the project are left out.
 func (p *Project) ListFiles() ([]FileInfo, error) {
 	var files []FileInfo
 
-	err := filepath.Walk(p.Path, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+	err := p.withRoot(".", func(root *os.Root, _ string) error {
+		return fs.WalkDir(root.FS(), ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path == "." {
+				return nil
+			}
 
-		// Skip hidden files and directories
-		if strings.HasPrefix(info.Name(), ".") {
-			if info.IsDir() {
-				return filepath.SkipDir
+			// Skip hidden files and directories
+			if strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
 			}
-			return nil
-		}
 
-		// Only include relevant files
-		ext := strings.ToLower(filepath.Ext(info.Name()))
-		if info.IsDir() || ext == ".ben" || ext == ".png" || ext == ".jpg" || ext == ".jpeg" || ext == ".gif" || ext == ".wav" || ext == ".mp3" {
-			relPath, _ := filepath.Rel(p.Path, path)
-			if relPath == "." {
+			info, err := root.Stat(path)
+			if err != nil {
 				return nil
 			}
 
-			files = append(files, FileInfo{
-				Name:  relPath,
-				IsDir: info.IsDir(),
-				Size:  info.Size(),
-			})
-		}
+			// Only include relevant files
+			relPath := filepath.FromSlash(path)
+			if info.IsDir() || IsProjectFile(d.Name()) || relPath == ManifestFile {
+				files = append(files, FileInfo{
+					Name:    relPath,
+					IsDir:   info.IsDir(),
+					Size:    info.Size(),
+					ModTime: info.ModTime(),
+				})
+			}
 
-		return nil
+			return nil
+		})
 	})
 
 	return files, err
 }
 
-// ReadFile reads a file from the project
-func (p *Project) ReadFile(name string) (string, error) {
-	path := filepath.Join(p.Path, name)
-
-	// Security check: ensure the path is within the project
-	if !strings.HasPrefix(path, p.Path) {
-		return "", os.ErrPermission
+// LastSaved returns when a file of the project was last changed, or the
+// zero time for a project without files
+func (p *Project) LastSaved() (time.Time, error) {
+	files, err := p.ListFiles()
+	var last time.Time
+	for _, f := range files {
+		if !f.IsDir && f.ModTime.After(last) {
+			last = f.ModTime
+		}
 	}
+	return last, err
+}
 
-	content, err := os.ReadFile(path)
+// ReadFile reads a file from the project
+func (p *Project) ReadFile(name string) (string, error) {
+	var content []byte
+	err := p.withRoot(name, func(root *os.Root, name string) error {
+		var err error
+		content, err = root.ReadFile(name)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
@@ -97,38 +139,85 @@ func (p *Project) ReadFile(name string) (string, error) {
 	return string(content), nil
 }
 
-// WriteFile writes a file to the project
-func (p *Project) WriteFile(name, content string) error {
-	path := filepath.Join(p.Path, name)
-
-	// Security check: ensure the path is within the project
-	absPath, err := filepath.Abs(path)
+// Sources returns the content of all .ben files in the project
+func (p *Project) Sources() (map[string]string, error) {
+	files, err := p.ListFiles()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if !strings.HasPrefix(absPath, p.Path) {
-		return os.ErrPermission
+
+	sources := map[string]string{}
+	for _, f := range files {
+		if f.IsDir || !strings.HasSuffix(f.Name, ".ben") {
+			continue
+		}
+		content, err := p.ReadFile(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		sources[f.Name] = content
 	}
+	return sources, nil
+}
 
-	// Create directory if needed
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
+// Compile translates all .ben files in the order of the manifest
+func (p *Project) Compile() (string, []compiler.Error, error) {
+	sources, err := p.Sources()
+	if err != nil {
+		return "", nil, err
 	}
+	return CompileSources(sources, p.Manifest)
+}
 
-	return os.WriteFile(path, []byte(content), 0644)
+// CompileSources translates files read with Sources in the order of the
+// manifest. It does not touch the project, so callers can read the files
+// under a lock and compile after releasing it.
+func CompileSources(sources map[string]string, m Manifest) (string, []compiler.Error, error) {
+	if _, ok := sources[m.Entry]; !ok {
+		return "", nil, fmt.Errorf("Die Startdatei %s fehlt", m.Entry)
+	}
+	js, errs := compiler.Compile(OrderFiles(sources, m))
+	return js, errs, nil
 }
 
-// SaveImage saves an uploaded image to the project
-func (p *Project) SaveImage(name string, data []byte) error {
-	// Ensure images directory exists
-	imagesDir := filepath.Join(p.Path, "bilder")
-	if err := os.MkdirAll(imagesDir, 0755); err != nil {
-		return err
+// RenameSymbol renames the symbol at the given position in all .ben files
+// and saves the files that changed. Unsaved editor content can be passed
+// in overrides; it is used instead of the files on disk.
+func (p *Project) RenameSymbol(file string, line, column int, newName string, overrides map[string]string) (map[string]string, error) {
+	sources, err := p.Sources()
+	if err != nil {
+		return nil, err
+	}
+	for name, content := range overrides {
+		if _, err := localName(name); err != nil {
+			return nil, err
+		}
+		sources[name] = content
+	}
+
+	result := analysis.Analyze(p.AnalysisFiles(sources))
+	changed, err := result.Rename(file, line, column, newName)
+	if err != nil {
+		return nil, err
 	}
 
-	path := filepath.Join(imagesDir, name)
-	return os.WriteFile(path, data, 0644)
+	for name, content := range changed {
+		if err := p.WriteFile(name, content); err != nil {
+			return nil, err
+		}
+	}
+	return changed, nil
+}
+
+// WriteFile writes a file to the project
+func (p *Project) WriteFile(name, content string) error {
+	return p.withRoot(name, func(root *os.Root, name string) error {
+		// Create directory if needed
+		if err := root.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return err
+		}
+		return root.WriteFile(name, []byte(content), 0644)
+	})
 }
 
 // CreateDefaultProject creates a default project structure
@@ -136,7 +225,10 @@ func (p *Project) CreateDefaultProject() error {
 	// Create directories
 	dirs := []string{"bilder", "toene"}
 	for _, dir := range dirs {
-		if err := os.MkdirAll(filepath.Join(p.Path, dir), 0755); err != nil {
+		err := p.withRoot(dir, func(root *os.Root, name string) error {
+			return root.MkdirAll(name, 0755)
+		})
+		if err != nil {
 			return err
 		}
 	}
@@ -186,12 +278,17 @@ WENN_TASTE("leertaste") {
 }
 `
 
-	return p.WriteFile("hauptspiel.ben", defaultCode)
+	if err := p.WriteFile("hauptspiel.ben", defaultCode); err != nil {
+		return err
+	}
+
+	return p.SaveManifest(DefaultManifest(p.Name))
 }
 
 // Exists checks if a file exists in the project
 func (p *Project) Exists(name string) bool {
-	path := filepath.Join(p.Path, name)
-	_, err := os.Stat(path)
-	return err == nil
+	return p.withRoot(name, func(root *os.Root, name string) error {
+		_, err := root.Stat(name)
+		return err
+	}) == nil
 }
@@ -0,0 +1,119 @@
+package project
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Change is a file that was changed, added or removed on disk
+type Change struct {
+	Name    string `json:"datei"`
+	Kind    string `json:"art"` // "neu", "geaendert" or "geloescht"
+	Hash    string `json:"hash,omitempty"`
+	Content string `json:"inhalt,omitempty"` // only for .ben files and the manifest
+}
+
+// IsSource reports whether a change affects the compiled game
+func (c Change) IsSource() bool {
+	return strings.HasSuffix(c.Name, ".ben") || c.Name == ManifestFile
+}
+
+// ContentHash identifies the content of a file. The IDE sends it back
+// when saving, so changes made in the meantime are noticed.
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Watcher notices changes to the project files made outside the IDE, for
+// example by a teacher with another editor. It compares the files each
+// time Poll is called; file systems do not need to support notifications.
+type Watcher struct {
+	p     *Project
+	files map[string]watchedFile
+}
+
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+	hash    string
+}
+
+// NewWatcher remembers the current state of the project
+func NewWatcher(p *Project) *Watcher {
+	w := &Watcher{p: p, files: map[string]watchedFile{}}
+	w.Poll()
+	return w
+}
+
+// Poll returns the changes since the last call, sorted by name. Files
+// that were only touched without a change in content are left out.
+func (w *Watcher) Poll() ([]Change, error) {
+	files, err := w.p.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	seen := map[string]bool{}
+	for _, f := range files {
+		if f.IsDir {
+			continue
+		}
+		name := filepath.ToSlash(f.Name)
+		seen[name] = true
+
+		old, known := w.files[name]
+		modTime := w.modTime(f.Name)
+		if known && old.size == f.Size && old.modTime.Equal(modTime) {
+			continue
+		}
+
+		content, err := w.p.ReadFile(f.Name)
+		if err != nil {
+			// Removed between listing and reading; the next poll sees it
+			continue
+		}
+		hash := ContentHash(content)
+		w.files[name] = watchedFile{size: f.Size, modTime: modTime, hash: hash}
+		if known && old.hash == hash {
+			continue
+		}
+
+		c := Change{Name: name, Kind: "geaendert", Hash: hash}
+		if !known {
+			c.Kind = "neu"
+		}
+		if c.IsSource() {
+			c.Content = content
+		}
+		changes = append(changes, c)
+	}
+
+	for name := range w.files {
+		if !seen[name] {
+			delete(w.files, name)
+			changes = append(changes, Change{Name: name, Kind: "geloescht"})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes, nil
+}
+
+func (w *Watcher) modTime(name string) time.Time {
+	var t time.Time
+	w.p.withRoot(name, func(root *os.Root, name string) error {
+		info, err := root.Stat(name)
+		if err == nil {
+			t = info.ModTime()
+		}
+		return err
+	})
+	return t
+}
@@ -0,0 +1,51 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	proj := newTestProject(t, map[string]string{
+		"hauptspiel.ben":  "VAR x = 1\n",
+		"bilder/held.png": "held",
+		"alt.ben":         "VAR alt = 1\n",
+	})
+
+	w := NewWatcher(proj)
+	if changes, _ := w.Poll(); len(changes) != 0 {
+		t.Errorf("changes without edits: %+v", changes)
+	}
+
+	// Another editor changes, adds and removes files
+	os.WriteFile(filepath.Join(proj.Path, "hauptspiel.ben"), []byte("VAR x = 2\n"), 0644)
+	os.WriteFile(filepath.Join(proj.Path, "bilder", "neu.png"), []byte("neu"), 0644)
+	os.Remove(filepath.Join(proj.Path, "alt.ben"))
+
+	changes, err := w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{Name: "alt.ben", Kind: "geloescht"},
+		{Name: "bilder/neu.png", Kind: "neu", Hash: ContentHash("neu")},
+		{Name: "hauptspiel.ben", Kind: "geaendert", Hash: ContentHash("VAR x = 2\n"), Content: "VAR x = 2\n"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("got %+v", changes)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+
+	// Touching a file without changing it is not reported
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(proj.Path, "hauptspiel.ben"), later, later)
+	if changes, _ := w.Poll(); len(changes) != 0 {
+		t.Errorf("touched file reported: %+v", changes)
+	}
+}
@@ -0,0 +1,268 @@
+package project
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Limits for imported ZIP files
+const (
+	MaxZipSize     = 50 << 20 // size of the ZIP file itself
+	MaxZipFileSize = 10 << 20 // uncompressed size of one file
+	MaxZipTotal    = 50 << 20 // uncompressed size of all files
+	MaxZipEntries  = 500
+)
+
+// allowedExtensions are the file types a project may contain
+var allowedExtensions = map[string]bool{
+	".ben":  true,
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".gif":  true,
+	".wav":  true,
+	".mp3":  true,
+}
+
+// IsProjectFile reports whether a file type belongs in a project
+func IsProjectFile(name string) bool {
+	return allowedExtensions[strings.ToLower(filepath.Ext(name))]
+}
+
+// ValidName reports whether name can be used as a project folder
+func ValidName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, `/\:`) && !strings.Contains(name, "..")
+}
+
+// WriteZip writes all project files into a ZIP archive. The files are
+// placed in a folder named after the project.
+func (p *Project) WriteZip(w io.Writer) error {
+	files, err := p.ListFiles()
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		if f.IsDir {
+			continue
+		}
+		var info os.FileInfo
+		var data []byte
+		err := p.withRoot(f.Name, func(root *os.Root, name string) error {
+			var err error
+			if info, err = root.Stat(name); err != nil {
+				return err
+			}
+			data, err = root.ReadFile(name)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		entry, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     path.Join(p.Name, filepath.ToSlash(f.Name)),
+			Method:   zip.Deflate,
+			Modified: info.ModTime(),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write(data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// zipEntry is a checked file of an imported archive
+type zipEntry struct {
+	file *zip.File
+	name string // path inside the project
+}
+
+// ImportZip unpacks a project archive into a new folder of workDir. The
+// folder is named after the archive's top-level folder or, if there is none,
+// after name; existing projects are never overwritten.
+func ImportZip(r io.ReaderAt, size int64, workDir, name string) (*Project, error) {
+	if size > MaxZipSize {
+		return nil, fmt.Errorf("Die ZIP-Datei ist zu groß (höchstens %d MB)", MaxZipSize>>20)
+	}
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("Das ist keine gültige ZIP-Datei")
+	}
+
+	entries, folder, err := checkZip(zr)
+	if err != nil {
+		return nil, err
+	}
+	if folder != "" {
+		name = folder
+	}
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	if !ValidName(name) {
+		name = "importiert"
+	}
+
+	// Unpack into a hidden folder first so a failed import leaves nothing behind
+	tmp, err := os.MkdirTemp(workDir, ".import-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	root, err := os.OpenRoot(tmp)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	var total int64
+	for _, e := range entries {
+		n, err := extract(e, root, MaxZipTotal-total)
+		if err != nil {
+			return nil, err
+		}
+		total += n
+	}
+
+	if _, err := loadManifest(root, name); err != nil {
+		return nil, err
+	}
+
+	target, err := freeName(workDir, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(target, 0755); err != nil {
+		return nil, err
+	}
+	return New(target)
+}
+
+// checkZip validates all entries before anything is written and returns
+// the top-level folder shared by all files, if any
+func checkZip(zr *zip.Reader) ([]zipEntry, string, error) {
+	var entries []zipEntry
+	var total uint64
+	folder, shared := "", true
+	hasCode := false
+
+	for _, f := range zr.File {
+		name := f.Name
+		if strings.Contains(name, `\`) || path.IsAbs(name) || strings.HasPrefix(name, "../") || strings.Contains(name, "/../") || name == ".." {
+			return nil, "", fmt.Errorf("'%s': Ungültiger Pfad in der ZIP-Datei", name)
+		}
+		name = path.Clean(name)
+		if f.FileInfo().IsDir() || hiddenPath(name) {
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			return nil, "", fmt.Errorf("'%s' ist keine normale Datei", f.Name)
+		}
+		if !IsProjectFile(name) && path.Base(name) != ManifestFile {
+			return nil, "", fmt.Errorf("'%s': Dieser Dateityp ist in Projekten nicht erlaubt", f.Name)
+		}
+		if f.UncompressedSize64 > MaxZipFileSize {
+			return nil, "", fmt.Errorf("'%s' ist zu groß (höchstens %d MB)", f.Name, MaxZipFileSize>>20)
+		}
+		total += f.UncompressedSize64
+		if total > MaxZipTotal {
+			return nil, "", fmt.Errorf("Der Inhalt der ZIP-Datei ist zu groß (höchstens %d MB)", MaxZipTotal>>20)
+		}
+		if len(entries) == MaxZipEntries {
+			return nil, "", fmt.Errorf("Die ZIP-Datei enthält zu viele Dateien (höchstens %d)", MaxZipEntries)
+		}
+
+		if strings.HasSuffix(strings.ToLower(name), ".ben") {
+			hasCode = true
+		}
+		if first, _, ok := strings.Cut(name, "/"); !ok || (folder != "" && folder != first) {
+			shared = false
+		} else {
+			folder = first
+		}
+		entries = append(entries, zipEntry{file: f, name: name})
+	}
+
+	if !hasCode {
+		return nil, "", fmt.Errorf("Die ZIP-Datei enthält keine .ben-Dateien")
+	}
+	if !shared {
+		return entries, "", nil
+	}
+	for i := range entries {
+		entries[i].name = strings.TrimPrefix(entries[i].name, folder+"/")
+	}
+	return entries, folder, nil
+}
+
+// hiddenPath reports files that are skipped silently, such as .DS_Store
+// or the __MACOSX folder that macOS adds to archives
+func hiddenPath(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
+
+// extract writes one entry below root and returns the number of bytes.
+// The declared size is not trusted, reading stops at the limit.
+func extract(e zipEntry, root *os.Root, remaining int64) (int64, error) {
+	target := filepath.FromSlash(e.name)
+	if err := root.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return 0, err
+	}
+
+	src, err := e.file.Open()
+	if err != nil {
+		return 0, fmt.Errorf("'%s' kann nicht gelesen werden: %v", e.file.Name, err)
+	}
+	defer src.Close()
+
+	dst, err := root.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+
+	limit := int64(MaxZipFileSize)
+	if remaining < limit {
+		limit = remaining
+	}
+	n, err := io.Copy(dst, io.LimitReader(src, limit+1))
+	if err != nil {
+		return 0, fmt.Errorf("'%s' kann nicht gelesen werden: %v", e.file.Name, err)
+	}
+	if n > limit {
+		return 0, fmt.Errorf("'%s' ist zu groß", e.file.Name)
+	}
+	return n, nil
+}
+
+// freeName returns a path in workDir for name that is not taken yet,
+// adding -2, -3, ... if needed
+func freeName(workDir, name string) (string, error) {
+	for i := 1; i <= 1000; i++ {
+		candidate := name
+		if i > 1 {
+			candidate = fmt.Sprintf("%s-%d", name, i)
+		}
+		target := filepath.Join(workDir, candidate)
+		if _, err := os.Lstat(target); os.IsNotExist(err) {
+			return target, nil
+		}
+	}
+	return "", fmt.Errorf("Kein freier Name für '%s' gefunden", name)
+}
@@ -0,0 +1,111 @@
+package project
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func makeZip(t *testing.T, files map[string]string) *bytes.Reader {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestZipRoundTrip(t *testing.T) {
+	src := newTestProject(t, map[string]string{
+		"hauptspiel.ben":  "SPIEL \"Test\"\n",
+		"bilder/held.png": string(testPNG(t, 1, 1)),
+		"notizen.txt":     "nicht im Projekt",
+	})
+
+	var buf bytes.Buffer
+	if err := src.WriteZip(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	workDir := t.TempDir()
+	for _, want := range []string{"spiel", "spiel-2"} {
+		proj, err := ImportZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()), workDir, "upload.zip")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proj.Name != want {
+			t.Errorf("imported as %q, want %q", proj.Name, want)
+		}
+		if code, _ := proj.ReadFile("hauptspiel.ben"); code != "SPIEL \"Test\"\n" {
+			t.Errorf("hauptspiel.ben = %q", code)
+		}
+		if !proj.Exists("bilder/held.png") || proj.Exists("notizen.txt") {
+			t.Error("wrong files imported")
+		}
+	}
+
+	entries, _ := os.ReadDir(workDir)
+	if len(entries) != 2 {
+		t.Errorf("workdir contains %d entries, want 2", len(entries))
+	}
+}
+
+func TestImportZipFlatArchive(t *testing.T) {
+	r := makeZip(t, map[string]string{"hauptspiel.ben": "VAR x = 1\n", "level.ben": ""})
+	proj, err := ImportZip(r, r.Size(), t.TempDir(), "mein-spiel.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proj.Name != "mein-spiel" || !proj.Exists("level.ben") {
+		t.Errorf("unexpected project %q", proj.Name)
+	}
+}
+
+func TestImportZipRejects(t *testing.T) {
+	tests := []struct {
+		files map[string]string
+		want  string
+	}{
+		{map[string]string{"hauptspiel.ben": "", "../boese.ben": ""}, "Ungültiger Pfad"},
+		{map[string]string{"spiel/hauptspiel.ben": "", "/etc/boese.ben": ""}, "Ungültiger Pfad"},
+		{map[string]string{"spiel/../../boese.ben": ""}, "Ungültiger Pfad"},
+		{map[string]string{"hauptspiel.ben": "", "virus.exe": ""}, "nicht erlaubt"},
+		{map[string]string{"bild.png": ""}, "keine .ben"},
+		{map[string]string{"hauptspiel.ben": strings.Repeat("x", MaxZipFileSize+1)}, "zu groß"},
+	}
+	for _, tt := range tests {
+		workDir := t.TempDir()
+		r := makeZip(t, tt.files)
+		_, err := ImportZip(r, r.Size(), workDir, "x.zip")
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: got error %v, want %q", tt.files, err, tt.want)
+		}
+		if entries, _ := os.ReadDir(workDir); len(entries) != 0 {
+			t.Errorf("%v: failed import left %d entries behind", tt.files, len(entries))
+		}
+	}
+}
+
+func TestImportZipSkipsHiddenFiles(t *testing.T) {
+	r := makeZip(t, map[string]string{
+		"spiel/hauptspiel.ben":            "",
+		"spiel/.DS_Store":                 "",
+		"__MACOSX/spiel/._hauptspiel.ben": "",
+	})
+	proj, err := ImportZip(r, r.Size(), t.TempDir(), "x.zip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proj.Name != "spiel" || proj.Exists(".DS_Store") {
+		t.Errorf("unexpected import %q", proj.Name)
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"benlang/internal/auth"
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	csrfCookie = "csrf"         // readable by the page's JavaScript, unlike the session
+	csrfHeader = "X-CSRF-Token" // where the page sends it back
+)
+
+// safeMethod reports whether a request only reads
+func safeMethod(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions
+}
+
+// sameOrigin reports whether a changing request comes from a page of this
+// server, going by its Origin or else its Referer. Requests with neither,
+// like from curl, are not from a browser and pass.
+func sameOrigin(r *http.Request) bool {
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Header.Get("Referer")
+	}
+	if source == "" {
+		return true
+	}
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return false // includes "null" from sandboxed frames
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// checkOrigin refuses changes that other websites make in the browser of
+// someone using the IDE, with or without login
+func checkOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !safeMethod(r) && !sameOrigin(r) {
+			http.Error(w, "Anfrage von einer fremden Seite abgelehnt", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validCSRF reports whether a request of a logged-in user may change
+// something: reading is always allowed, changes need the CSRF token of
+// the session
+func validCSRF(r *http.Request, session string) bool {
+	if safeMethod(r) {
+		return true
+	}
+	want := auth.CSRFToken(session)
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get(csrfHeader)), []byte(want)) == 1
+}
+
+// setCSRFCookie hands the page the CSRF token of the session unless it
+// already has it
+func (s *Server) setCSRFCookie(w http.ResponseWriter, r *http.Request, session string) {
+	token := auth.CSRFToken(session)
+	if cookie, err := r.Cookie(csrfCookie); err == nil && cookie.Value == token {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(s.auth.MaxAge / time.Second),
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCSRFToken(t *testing.T) {
+	s := newTestServer(t, nil, withUsers)
+	handler := s.Handler().ServeHTTP
+	anna := login(t, s, "anna")
+	body, _ := json.Marshal(map[string]string{"name": "neu"})
+
+	// Another website can send the session cookie, but not the token
+	if rec := serveAs(handler, anna[:1], http.MethodPost, "/api/projekte/neu", body); rec.Code != http.StatusForbidden {
+		t.Errorf("without token: status %d", rec.Code)
+	}
+	forged := []*http.Cookie{anna[0], {Name: csrfCookie, Value: "geraten"}}
+	if rec := serveAs(handler, forged, http.MethodPost, "/api/projekte/neu", body); rec.Code != http.StatusForbidden {
+		t.Errorf("wrong token: status %d", rec.Code)
+	}
+	other := login(t, s, "besuch")
+	if rec := serveAs(handler, []*http.Cookie{anna[0], other[1]}, http.MethodPost, "/api/projekte/neu", body); rec.Code != http.StatusForbidden {
+		t.Errorf("token of another session: status %d", rec.Code)
+	}
+	if rec := serveAs(handler, anna, http.MethodPost, "/api/projekte/neu", body); rec.Code != http.StatusOK {
+		t.Errorf("with token: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// A page loaded with the session gets the token, e.g. after a restart
+	// with remembered sessions
+	rec := serveAs(handler, anna[:1], http.MethodGet, "/api/projekte/liste", nil)
+	if cookies := rec.Result().Cookies(); len(cookies) != 1 || cookies[0].Name != csrfCookie || cookies[0].Value != anna[1].Value || cookies[0].HttpOnly {
+		t.Errorf("cookies on reading: %v", cookies)
+	}
+	if rec := serveAs(handler, anna, http.MethodGet, "/api/projekte/liste", nil); len(rec.Result().Cookies()) != 0 {
+		t.Errorf("token sent again: %v", rec.Result().Cookies())
+	}
+}
+
+func TestLogoutNeedsToken(t *testing.T) {
+	s := newTestServer(t, nil, withUsers)
+	handler := s.Handler().ServeHTTP
+	anna := login(t, s, "anna")
+
+	// A link or image on another website
+	if rec := serveAs(handler, anna, http.MethodGet, "/api/logout", nil); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status %d", rec.Code)
+	}
+	if rec := serveAs(handler, anna[:1], http.MethodPost, "/api/logout", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("without token: status %d", rec.Code)
+	}
+	if rec := serveAs(handler, anna, http.MethodGet, "/api/projekte/liste", nil); rec.Code != http.StatusOK {
+		t.Fatalf("logged out by a forged request: status %d", rec.Code)
+	}
+
+	if rec := serveAs(handler, anna, http.MethodPost, "/api/logout", nil); rec.Code != http.StatusOK {
+		t.Errorf("with token: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serveAs(handler, anna, http.MethodGet, "/api/projekte/liste", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("after logout: status %d", rec.Code)
+	}
+}
+
+func TestOriginCheck(t *testing.T) {
+	s := newTestServer(t, nil, withSandbox)
+	handler := s.Handler().ServeHTTP
+	body, _ := json.Marshal(map[string]string{"name": "hauptspiel.ben", "inhalt": "VAR a = 1\n"})
+
+	tests := []struct {
+		header, value string
+		allowed       bool
+	}{
+		{"", "", true}, // curl and other tools
+		{"Origin", "http://example.com", true},
+		{"Origin", "http://boese.example", false},
+		{"Origin", "null", false},
+		{"Referer", "http://example.com/index.html", true},
+		{"Referer", "http://boese.example/seite.html", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/datei", strings.NewReader(string(body)))
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if refused := rec.Code == http.StatusForbidden; refused == tt.allowed {
+			t.Errorf("%s %q: status %d", tt.header, tt.value, rec.Code)
+		}
+	}
+
+	// Reading from another page is harmless
+	req := httptest.NewRequest(http.MethodGet, "/api/dateien", nil)
+	req.Header.Set("Origin", "http://boese.example")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET from another origin: status %d", rec.Code)
+	}
+}
+
+func TestSecureCookies(t *testing.T) {
+	s := newTestServer(t, nil, withUsers)
+	handler := s.Handler().ServeHTTP
+	body, _ := json.Marshal(map[string]string{"username": "anna", "password": "pferd"})
+
+	for _, overTLS := range []bool{false, true} {
+		req := httptest.NewRequest(http.MethodPost, "/api/login", strings.NewReader(string(body)))
+		if overTLS {
+			req.TLS = &tls.ConnectionState{}
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		cookies := rec.Result().Cookies()
+		if rec.Code != http.StatusOK || len(cookies) != 2 {
+			t.Fatalf("login: status %d, cookies %v", rec.Code, cookies)
+		}
+		for _, c := range cookies {
+			if c.Secure != overTLS {
+				t.Errorf("TLS %v: cookie %s has Secure %v", overTLS, c.Name, c.Secure)
+			}
+		}
+	}
+}
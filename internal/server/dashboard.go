@@ -0,0 +1,299 @@
+package server
+
+import (
+	"benlang/internal/auth"
+	"benlang/internal/project"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// compileRecord is how the last start of the game in the IDE went
+type compileRecord struct {
+	Time   time.Time
+	Errors []string
+}
+
+// projectStatus is one project in the class overview
+type projectStatus struct {
+	Name       string     `json:"name"`
+	Saved      *time.Time `json:"gespeichert"` // nil for a project without files
+	Compiled   *time.Time `json:"kompiliert"`  // last start in the IDE, nil if none since the server started
+	Errors     []string   `json:"fehler"`      // of that start, or else of the saved files
+	ErrorCount int        `json:"anzahlFehler"`
+}
+
+// studentStatus is one child in the class overview
+type studentStatus struct {
+	Name      string          `json:"benutzername"`
+	Role      auth.Role       `json:"rolle"`
+	LastSaved *time.Time      `json:"zuletztGespeichert"`
+	Projects  []projectStatus `json:"projekte"`
+	Error     string          `json:"fehler,omitempty"` // the folder could not be read
+}
+
+// savedCompile is the result of compiling a project's saved files, kept
+// until the files change
+type savedCompile struct {
+	files  string // names, sizes and times of the files that were compiled
+	errors []string
+}
+
+// handleKlasse gives teachers an overview of every student and guest:
+// their projects, when they were last saved and whether they compile
+func (s *Server) handleKlasse(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.AuthEnabled {
+		http.Error(w, "Die Anmeldung ist nicht aktiviert", http.StatusNotFound)
+		return
+	}
+
+	s.overviewMu.Lock()
+	previous := s.overview
+	s.overviewMu.Unlock()
+
+	// Only the projects that still exist are remembered for next time
+	next := map[string]savedCompile{}
+	students := []studentStatus{}
+	for _, user := range s.auth.List() {
+		if user.UserRole() == auth.RoleTeacher {
+			continue
+		}
+		student, err := s.studentStatus(user, previous, next)
+		if err != nil {
+			student.Error = err.Error()
+		}
+		students = append(students, student)
+	}
+
+	s.overviewMu.Lock()
+	s.overview = next
+	s.overviewMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"schueler": students})
+}
+
+func (s *Server) studentStatus(user auth.User, previous, next map[string]savedCompile) (studentStatus, error) {
+	student := studentStatus{Name: user.Username, Role: user.UserRole(), Projects: []projectStatus{}}
+
+	// Children who never logged in have no folder yet
+	if _, err := os.Lstat(filepath.Join(s.WorkDir, user.Username)); errors.Is(err, fs.ErrNotExist) {
+		return student, nil
+	}
+	home, err := project.HomeDir(s.WorkDir, user.Username)
+	if err != nil {
+		return student, err
+	}
+	names, err := listProjects(home)
+	if err != nil {
+		return student, err
+	}
+
+	for _, name := range names {
+		status, err := s.projectStatus(home, name, previous, next)
+		if err != nil {
+			continue
+		}
+		student.Projects = append(student.Projects, status)
+		if status.Saved != nil && (student.LastSaved == nil || status.Saved.After(*student.LastSaved)) {
+			student.LastSaved = status.Saved
+		}
+	}
+
+	// What was worked on last comes first
+	sort.SliceStable(student.Projects, func(i, j int) bool {
+		a, b := student.Projects[i].Saved, student.Projects[j].Saved
+		return a != nil && (b == nil || a.After(*b))
+	})
+	return student, nil
+}
+
+// projectStatus describes a project for the class overview. A project
+// that is open in an IDE is read under its lock and shows the last start
+// there. Others are only read: looking at the class does not keep every
+// project open. Without a start, the saved files are compiled, unless
+// they have not changed since the last overview.
+func (s *Server) projectStatus(home, name string, previous, next map[string]savedCompile) (projectStatus, error) {
+	s.mu.RLock()
+	ws := s.workspaces[filepath.Join(home, name)]
+	s.mu.RUnlock()
+
+	var proj *project.Project
+	var record *compileRecord
+	if ws != nil {
+		ws.mu.RLock()
+		proj, record = ws.project, ws.compiled
+	} else {
+		var err error
+		if proj, err = project.InWorkDir(home, name, false); err != nil {
+			return projectStatus{}, err
+		}
+	}
+	status, fingerprint, err := savedStatus(proj)
+	var sources map[string]string
+	manifest := proj.Manifest
+	saved, unchanged := previous[proj.Path]
+	unchanged = unchanged && saved.files == fingerprint
+	if err == nil && record == nil && !unchanged {
+		sources, err = proj.Sources()
+	}
+	if ws != nil {
+		ws.mu.RUnlock()
+	}
+	if err != nil {
+		return status, err
+	}
+
+	switch {
+	case record != nil:
+		compiled := record.Time
+		status.Compiled = &compiled
+		status.Errors = record.Errors
+	case unchanged:
+		status.Errors = saved.errors
+		next[proj.Path] = saved
+	default:
+		_, status.Errors = compileSaved(sources, manifest, nil)
+		next[proj.Path] = savedCompile{fingerprint, status.Errors}
+	}
+	if status.Errors == nil {
+		status.Errors = []string{}
+	}
+	status.ErrorCount = len(status.Errors)
+	return status, nil
+}
+
+// savedStatus returns the project's name and when it was last saved,
+// and a fingerprint of its files that changes with any of them
+func savedStatus(proj *project.Project) (projectStatus, string, error) {
+	status := projectStatus{Name: proj.Name}
+	files, err := proj.ListFiles()
+	if err != nil {
+		return status, "", err
+	}
+	var fingerprint strings.Builder
+	for _, f := range files {
+		if f.IsDir {
+			continue
+		}
+		if status.Saved == nil || f.ModTime.After(*status.Saved) {
+			saved := f.ModTime
+			status.Saved = &saved
+		}
+		fmt.Fprintf(&fingerprint, "%s %d %d\n", f.Name, f.Size, f.ModTime.UnixNano())
+	}
+	return status, fingerprint.String(), nil
+}
+
+// handleVerteilen copies a starter project, one of the teacher's own or an
+// example, to every student. Students who already have a project of that
+// name keep theirs.
+func (s *Server) handleVerteilen(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.AuthEnabled {
+		http.Error(w, "Die Anmeldung ist nicht aktiviert", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Name     string `json:"name"`
+		Projekt  string `json:"projekt"`
+		Beispiel string `json:"beispiel"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var source *workspace
+	var err error
+	switch {
+	case req.Projekt != "" && req.Beispiel == "":
+		var home string
+		if home, err = s.homeDir(r); err == nil {
+			source, err = s.openWorkspace(home, req.Projekt, false)
+		}
+	case req.Beispiel != "" && req.Projekt == "" && s.Examples != "":
+		var example *project.Project
+		if example, err = project.InWorkDir(s.Examples, req.Beispiel, false); err == nil {
+			source = &workspace{project: example}
+		}
+	default:
+		http.Error(w, "projekt oder beispiel angeben", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		fileError(w, err, http.StatusBadRequest)
+		return
+	}
+	if req.Name == "" {
+		req.Name = source.project.Name
+	}
+	if !project.ValidName(req.Name) {
+		http.Error(w, "Ungültiger Projektname", http.StatusBadRequest)
+		return
+	}
+
+	source.mu.RLock()
+	defer source.mu.RUnlock()
+
+	copied, skipped, failed := []string{}, []string{}, map[string]string{}
+	for _, user := range s.auth.List() {
+		if user.UserRole() != auth.RoleStudent {
+			continue
+		}
+		switch err := s.copyTo(user.Username, req.Name, source.project); {
+		case errors.Is(err, fs.ErrExist):
+			skipped = append(skipped, user.Username)
+		case err != nil:
+			failed[user.Username] = err.Error()
+		default:
+			copied = append(copied, user.Username)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"erfolg":        len(failed) == 0,
+		"verteilt":      copied,
+		"uebersprungen": skipped,
+		"fehler":        failed,
+	})
+}
+
+// copyTo copies the project into a new project of a student. It fails with
+// fs.ErrExist if the student already has a project of that name.
+func (s *Server) copyTo(student, name string, source *project.Project) error {
+	home, err := project.HomeDir(s.WorkDir, student)
+	if err != nil {
+		return err
+	}
+	if _, err := project.InWorkDir(home, name, false); err == nil {
+		return fmt.Errorf("'%s' hat schon ein Projekt '%s': %w", student, name, fs.ErrExist)
+	}
+
+	ws, err := s.openWorkspace(home, name, true)
+	if err != nil {
+		return err
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if err := ws.project.CopyFrom(source.Path); err != nil {
+		return err
+	}
+	return ws.project.ReloadManifest()
+}
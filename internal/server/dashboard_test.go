@@ -0,0 +1,148 @@
+package server
+
+import (
+	"benlang/internal/auth"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func classOverview(t *testing.T, handler http.HandlerFunc, cookies []*http.Cookie) map[string]studentStatus {
+	t.Helper()
+	rec := serveAs(handler, cookies, http.MethodGet, "/api/lehrer/klasse", nil)
+	var resp struct {
+		Schueler []studentStatus `json:"schueler"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body.String())
+	}
+	students := map[string]studentStatus{}
+	for _, student := range resp.Schueler {
+		students[student.Name] = student
+	}
+	return students
+}
+
+func TestClassOverview(t *testing.T) {
+	s := newTestServer(t, nil, withUsers)
+	workDir := s.WorkDir
+	handler := s.Handler().ServeHTTP
+	teacher, anna := login(t, s, "frau-meier"), login(t, s, "anna")
+
+	if rec := serveAs(handler, anna, http.MethodGet, "/api/lehrer/klasse", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("student sees the class: status %d", rec.Code)
+	}
+
+	// A broken folder is reported for that child only
+	s.auth.AddUser("ben", "katze", auth.RoleStudent)
+	os.WriteFile(filepath.Join(workDir, "ben"), []byte("kein Ordner"), 0644)
+
+	students := classOverview(t, handler, teacher)
+	if _, ok := students["frau-meier"]; ok || len(students) != 3 {
+		t.Fatalf("overview lists %v", students)
+	}
+	if ben := students["ben"]; ben.Error == "" || len(ben.Projects) != 0 {
+		t.Errorf("ben with a broken folder: %+v", ben)
+	}
+	if guest := students["besuch"]; len(guest.Projects) != 0 || guest.LastSaved != nil {
+		t.Errorf("guest without folder: %+v", guest)
+	}
+	// Without a start in the IDE the saved files are compiled
+	rennspiel := students["anna"].Projects[0]
+	if rennspiel.Name != "rennspiel" || rennspiel.Saved == nil || rennspiel.Compiled != nil || rennspiel.ErrorCount != 0 {
+		t.Errorf("anna's project: %+v", rennspiel)
+	}
+	if len(s.workspaces) != 0 {
+		t.Errorf("the overview opened %d workspaces", len(s.workspaces))
+	}
+
+	// Saved files are compiled again once they change
+	os.WriteFile(filepath.Join(workDir, "anna", "rennspiel", "hauptspiel.ben"), []byte("VAR auto = \n"), 0644)
+	if rennspiel := classOverview(t, handler, teacher)["anna"].Projects[0]; rennspiel.ErrorCount == 0 {
+		t.Errorf("after saving an error: %+v", rennspiel)
+	}
+	os.WriteFile(filepath.Join(workDir, "anna", "rennspiel", "hauptspiel.ben"), []byte("VAR auto = 1\n"), 0644)
+
+	// Anna starts her game with an error in the editor
+	body, _ := json.Marshal(map[string]interface{}{"dateien": map[string]string{"hauptspiel.ben": "VAR auto = \n"}})
+	cookies := append([]*http.Cookie{{Name: projectCookie, Value: "rennspiel"}}, anna...)
+	serveAs(handler, cookies, http.MethodPost, "/api/kompilieren", body)
+
+	rennspiel = classOverview(t, handler, teacher)["anna"].Projects[0]
+	if rennspiel.Compiled == nil || rennspiel.ErrorCount == 0 || len(rennspiel.Errors) != rennspiel.ErrorCount {
+		t.Errorf("after a failed start: %+v", rennspiel)
+	}
+}
+
+func TestDistributeStarterProject(t *testing.T) {
+	s := newTestServer(t, nil, withUsers)
+	workDir := s.WorkDir
+	handler := s.Handler().ServeHTTP
+	teacher := login(t, s, "frau-meier")
+	s.auth.AddUser("ben", "katze", auth.RoleStudent)
+	os.MkdirAll(filepath.Join(workDir, "anna", "pong"), 0755)
+	os.WriteFile(filepath.Join(workDir, "anna", "pong", "hauptspiel.ben"), []byte("VAR meins = 1\n"), 0644)
+
+	body, _ := json.Marshal(map[string]string{"beispiel": "pong"})
+	if rec := serveAs(handler, login(t, s, "anna"), http.MethodPost, "/api/lehrer/verteilen", body); rec.Code != http.StatusForbidden {
+		t.Errorf("student distributes: status %d", rec.Code)
+	}
+	rec := serveAs(handler, teacher, http.MethodPost, "/api/lehrer/verteilen", body)
+	var resp struct {
+		Erfolg        bool     `json:"erfolg"`
+		Verteilt      []string `json:"verteilt"`
+		Uebersprungen []string `json:"uebersprungen"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || !resp.Erfolg {
+		t.Fatalf("distribute: %v: %s", err, rec.Body.String())
+	}
+	if len(resp.Verteilt) != 1 || resp.Verteilt[0] != "ben" || len(resp.Uebersprungen) != 1 || resp.Uebersprungen[0] != "anna" {
+		t.Errorf("distributed to %v, skipped %v", resp.Verteilt, resp.Uebersprungen)
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(workDir, "ben", "pong", "hauptspiel.ben")); string(data) != "VAR ball = 1\n" {
+		t.Errorf("ben's copy: %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "anna", "pong", "hauptspiel.ben")); string(data) != "VAR meins = 1\n" {
+		t.Errorf("anna's project was overwritten: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "besuch", "pong")); err == nil {
+		t.Error("guest got the project")
+	}
+
+	body, _ = json.Marshal(map[string]string{"beispiel": "pong", "name": "../pong"})
+	refused(t, "invalid name", serveAs(handler, teacher, http.MethodPost, "/api/lehrer/verteilen", body))
+}
+
+func TestTeacherDoesNotRunStudentGame(t *testing.T) {
+	s := newTestServer(t, nil, withUsers)
+	handler := s.Handler().ServeHTTP
+	teacher, guest := login(t, s, "frau-meier"), login(t, s, "besuch")
+
+	body, _ := json.Marshal(map[string]string{"name": "rennspiel", "benutzer": "anna"})
+	rec := serveAs(handler, teacher, http.MethodPost, "/api/projekte/oeffnen", body)
+	viewing := append(rec.Result().Cookies(), teacher...)
+
+	// A game could act with the teacher's login, so it gets no code
+	compile := func(cookies []*http.Cookie) (js string, errors []string) {
+		body, _ := json.Marshal(map[string]interface{}{"dateien": map[string]string{"hauptspiel.ben": "VAR auto = 1\n"}})
+		rec := serveAs(handler, cookies, http.MethodPost, "/api/kompilieren", body)
+		var result struct {
+			JS     string   `json:"js"`
+			Fehler []string `json:"fehler"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatalf("%v: %s", err, rec.Body.String())
+		}
+		return result.JS, result.Fehler
+	}
+	if js, errors := compile(viewing); js != "" || len(errors) != 0 {
+		t.Errorf("teacher viewing anna's project: js %q, errors %v", js, errors)
+	}
+	// Guests cannot save, but play their own games
+	if js, _ := compile(guest); js == "" {
+		t.Error("guest gets no code")
+	}
+}
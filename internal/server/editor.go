@@ -0,0 +1,161 @@
+package server
+
+import (
+	"benlang/internal/analysis"
+	"benlang/internal/lexer"
+	"benlang/internal/project"
+	"encoding/json"
+	"net/http"
+)
+
+// editorRequest is the body of the editor intelligence endpoints. Code is
+// the content of Datei in the editor; Dateien may carry other unsaved files.
+type editorRequest struct {
+	Datei   string            `json:"datei"`
+	Code    string            `json:"code"`
+	Zeile   int               `json:"zeile"`
+	Spalte  int               `json:"spalte"`
+	Dateien map[string]string `json:"dateien"`
+}
+
+// readEditorRequest decodes the request and analyzes all .ben files of the
+// project, preferring the editor's content over the saved files
+func (s *Server) readEditorRequest(w http.ResponseWriter, r *http.Request) (*editorRequest, *analysis.Result, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, nil, false
+	}
+
+	var req editorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	sources := map[string]string{}
+	manifest := project.DefaultManifest("")
+	if ws := s.workspace(r); ws != nil {
+		ws.mu.RLock()
+		sources, _ = ws.project.Sources()
+		manifest = ws.project.Manifest
+		ws.mu.RUnlock()
+	}
+	if req.Datei == "" {
+		req.Datei = manifest.Entry
+	}
+	if sources == nil {
+		sources = map[string]string{}
+	}
+
+	for name, content := range req.Dateien {
+		sources[name] = content
+	}
+	sources[req.Datei] = req.Code
+
+	return &req, analysis.Analyze(project.OrderFiles(sources, manifest)), true
+}
+
+// handleVervollstaendigen returns completions for the cursor position
+func (s *Server) handleVervollstaendigen(w http.ResponseWriter, r *http.Request) {
+	req, result, ok := s.readEditorRequest(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"vorschlaege": result.Completions(req.Datei, req.Zeile, req.Spalte),
+	})
+}
+
+// handleHover returns Markdown help for the word under the cursor
+func (s *Server) handleHover(w http.ResponseWriter, r *http.Request) {
+	req, result, ok := s.readEditorRequest(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"inhalt": result.Hover(req.Datei, req.Zeile, req.Spalte),
+	})
+}
+
+// handleDefinition returns where the name under the cursor is declared
+func (s *Server) handleDefinition(w http.ResponseWriter, r *http.Request) {
+	req, result, ok := s.readEditorRequest(w, r)
+	if !ok {
+		return
+	}
+
+	response := map[string]interface{}{"gefunden": false}
+	if sym := result.Definition(req.Datei, req.Zeile, req.Spalte); sym != nil {
+		response["gefunden"] = true
+		response["symbol"] = sym
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleUmbenennen renames the symbol under the cursor in all project files
+// and returns the new content of the changed files
+func (s *Server) handleUmbenennen(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		editorRequest
+		NeuerName string `json:"neuerName"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws := s.workspace(r)
+	if ws == nil {
+		http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
+		return
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	overrides := map[string]string{}
+	for name, content := range req.Dateien {
+		overrides[name] = content
+	}
+	if req.Datei != "" {
+		overrides[req.Datei] = req.Code
+	}
+
+	var changed map[string]string
+	err := recordChange(ws.project, "Umbenannt in allen Dateien: "+req.NeuerName, func() (err error) {
+		changed, err = ws.project.RenameSymbol(req.Datei, req.Zeile, req.Spalte, req.NeuerName, overrides)
+		return err
+	})
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"erfolg": false, "fehler": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"erfolg": true, "dateien": changed})
+}
+
+// handleSprache returns the keywords and built-in functions, so the editor
+// highlights exactly what the compiler understands
+func (s *Server) handleSprache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"schluesselwoerter": lexer.Keywords(),
+		"befehle":           analysis.Builtins,
+		"eigenschaften":     analysis.FigureProperties,
+	})
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"benlang/internal/auth"
+	"benlang/internal/project"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testOption sets up more than the project for newTestServer
+type testOption int
+
+const (
+	// withSandbox puts the project into a WorkDir as spiel. Next to it lies
+	// spiel2, whose path starts with the project's path, and outside of
+	// WorkDir a folder draussen (see outsideDir). The project links into
+	// both.
+	withSandbox testOption = iota
+	// withClass serves a class with login instead of a project: WorkDir
+	// holds a project of anna's, and there is one shared example
+	withClass
+	// withUsers is withClass with the teacher frau-meier, the student anna
+	// and the guest besuch. The credentials are saved in a temporary file.
+	withUsers
+)
+
+// newTestServer serves a project with the given files in a temporary
+// folder, set up as the options say
+func newTestServer(t *testing.T, files map[string]string, options ...testOption) *Server {
+	t.Helper()
+	base := t.TempDir()
+	dir := base
+	s := &Server{}
+	for _, option := range options {
+		switch option {
+		case withSandbox:
+			s.WorkDir = filepath.Join(base, "work")
+			dir = filepath.Join(s.WorkDir, "spiel")
+			sandbox(t, base)
+		case withClass:
+			class(s, base)
+			return s
+		case withUsers:
+			class(s, base)
+			s.auth.Path = filepath.Join(base, "zugangsdaten.json")
+			s.auth.AddUser("frau-meier", "tafel", auth.RoleTeacher)
+			s.auth.AddUser("anna", "pferd", auth.RoleStudent)
+			s.auth.AddUser("besuch", "hallo", auth.RoleGuest)
+			os.WriteFile(filepath.Join(s.WorkDir, "anna", "rennspiel", "hauptspiel.ben"), []byte("VAR auto = 1\n"), 0644)
+			return s
+		}
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	proj, err := project.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.project = proj
+	return s
+}
+
+// sandbox creates the folders of withSandbox in base
+func sandbox(t *testing.T, base string) {
+	workDir := filepath.Join(base, "work")
+	outside := filepath.Join(base, "draussen")
+	for _, dir := range []string{"work/spiel/bilder", "work/spiel2", "draussen"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(workDir, "spiel", "hauptspiel.ben"), []byte("VAR x = 1\n"), 0644)
+	os.WriteFile(filepath.Join(workDir, "spiel2", "geheim.ben"), []byte("VAR geheim = \""+secret+"\"\n"), 0644)
+	os.WriteFile(filepath.Join(outside, "geheim.png"), []byte(secret), 0644)
+
+	links := map[string]string{
+		"work/spiel/link.ben":     filepath.Join(workDir, "spiel2", "geheim.ben"),
+		"work/spiel/draussen":     outside,
+		"work/spiel/bilder/x.png": filepath.Join(outside, "geheim.png"),
+		"work/verlinkt":           outside,
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(base, name)); err != nil {
+			t.Skipf("symbolic links not supported: %v", err)
+		}
+	}
+}
+
+// class sets up the server and folders of withClass in base
+func class(s *Server, base string) {
+	s.WorkDir = filepath.Join(base, "klasse")
+	s.Examples = filepath.Join(base, "beispiele")
+	s.AuthEnabled, s.auth, s.limiter = true, auth.NewCredentials(), auth.NewLimiter()
+	os.MkdirAll(filepath.Join(s.WorkDir, "anna", "rennspiel"), 0755)
+	os.MkdirAll(filepath.Join(s.Examples, "pong", "bilder"), 0755)
+	os.WriteFile(filepath.Join(s.Examples, "pong", "hauptspiel.ben"), []byte("VAR ball = 1\n"), 0644)
+}
+
+// outsideDir is the folder next to the WorkDir of a sandbox server
+func outsideDir(s *Server) string {
+	return filepath.Join(filepath.Dir(s.WorkDir), "draussen")
+}
+
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func postJSON(t *testing.T, handler http.HandlerFunc, body interface{}, v interface{}) {
+	data, _ := json.Marshal(body)
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(data)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEditorEndpoints(t *testing.T) {
+	s := newTestServer(t, map[string]string{
+		"hauptspiel.ben": "// wird vom Editor ersetzt\n",
+		"hilfen.ben":     "FUNKTION verdopple(zahl) {\n    ZURUECK zahl * 2\n}\n",
+	})
+	code := "FIGUR held = LADE_BILD(\"held.png\")\nWENN_START {\n    held.x = verdopple(punkte)\n    ver\n}\n"
+	unsaved := map[string]string{"werte.ben": "VAR punkte = 3\n"}
+
+	var def struct {
+		Gefunden bool `json:"gefunden"`
+		Symbol   struct {
+			Datei string `json:"datei"`
+			Zeile int    `json:"zeile"`
+			Art   string `json:"art"`
+		} `json:"symbol"`
+	}
+	postJSON(t, s.handleDefinition, map[string]interface{}{"datei": "hauptspiel.ben", "code": code, "zeile": 3, "spalte": 16, "dateien": unsaved}, &def)
+	if !def.Gefunden || def.Symbol.Datei != "hilfen.ben" || def.Symbol.Zeile != 1 || def.Symbol.Art != "funktion" {
+		t.Errorf("unexpected definition %+v", def)
+	}
+
+	postJSON(t, s.handleDefinition, map[string]interface{}{"datei": "hauptspiel.ben", "code": code, "zeile": 3, "spalte": 26, "dateien": unsaved}, &def)
+	if !def.Gefunden || def.Symbol.Datei != "werte.ben" {
+		t.Errorf("unsaved file not used: %+v", def)
+	}
+
+	var completions struct {
+		Vorschlaege []struct {
+			Label     string `json:"label"`
+			Einfuegen string `json:"einfuegen"`
+		} `json:"vorschlaege"`
+	}
+	postJSON(t, s.handleVervollstaendigen, map[string]interface{}{"datei": "hauptspiel.ben", "code": code, "zeile": 4, "spalte": 8}, &completions)
+	if len(completions.Vorschlaege) != 1 || completions.Vorschlaege[0].Einfuegen != "verdopple(zahl)" {
+		t.Errorf("unexpected completions %+v", completions)
+	}
+
+	var hover struct {
+		Inhalt string `json:"inhalt"`
+	}
+	postJSON(t, s.handleHover, map[string]interface{}{"datei": "hauptspiel.ben", "code": code, "zeile": 1, "spalte": 16}, &hover)
+	if !strings.Contains(hover.Inhalt, "LADE_BILD(pfad)") {
+		t.Errorf("unexpected hover %q", hover.Inhalt)
+	}
+}
+
+func TestSprache(t *testing.T) {
+	rec := httptest.NewRecorder()
+	(&Server{}).handleSprache(rec, httptest.NewRequest(http.MethodGet, "/api/sprache", nil))
+
+	var lang struct {
+		Schluesselwoerter []string                   `json:"schluesselwoerter"`
+		Befehle           map[string]json.RawMessage `json:"befehle"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &lang); err != nil {
+		t.Fatal(err)
+	}
+	if len(lang.Schluesselwoerter) == 0 || lang.Befehle["ZUFALL"] == nil {
+		t.Errorf("unexpected language description %s", rec.Body.String())
+	}
+}
+
+func TestUmbenennen(t *testing.T) {
+	s := newTestServer(t, map[string]string{
+		"hauptspiel.ben": "VAR x = 1\n",
+		"zeigen.ben":     "SCHREIBE(\"x\" + x)\n",
+	})
+
+	var resp struct {
+		Erfolg  bool              `json:"erfolg"`
+		Fehler  string            `json:"fehler"`
+		Dateien map[string]string `json:"dateien"`
+	}
+	postJSON(t, s.handleUmbenennen, map[string]interface{}{
+		"datei": "hauptspiel.ben", "code": "VAR x = 1\nx = x + 1\n", "zeile": 1, "spalte": 5, "neuerName": "spielerX",
+	}, &resp)
+	if !resp.Erfolg || len(resp.Dateien) != 2 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	saved, _ := s.project.ReadFile("zeigen.ben")
+	if saved != "SCHREIBE(\"x\" + spielerX)\n" {
+		t.Errorf("file not saved: %q", saved)
+	}
+	saved, _ = s.project.ReadFile("hauptspiel.ben")
+	if saved != "VAR spielerX = 1\nspielerX = spielerX + 1\n" {
+		t.Errorf("editor content not used: %q", saved)
+	}
+
+	postJSON(t, s.handleUmbenennen, map[string]interface{}{
+		"datei": "hauptspiel.ben", "code": saved, "zeile": 1, "spalte": 5, "neuerName": "WENN",
+	}, &resp)
+	if resp.Erfolg || resp.Fehler == "" {
+		t.Errorf("expected error, got %+v", resp)
+	}
+}
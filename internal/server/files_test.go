@@ -0,0 +1,239 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const secret = "GEHEIM"
+
+// refused fails the test if the response succeeded or leaked the secret
+func refused(t *testing.T, what string, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	if rec.Code == http.StatusOK && !strings.Contains(rec.Body.String(), `"erfolg":false`) {
+		t.Errorf("%s: status %d, want an error", what, rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), secret) {
+		t.Errorf("%s: response leaks the secret", what)
+	}
+}
+
+func serve(handler http.HandlerFunc, method, target string, body []byte) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(method, target, bytes.NewReader(body)))
+	return rec
+}
+
+func TestDateiGetStaysInProject(t *testing.T) {
+	s := newTestServer(t, nil, withSandbox)
+
+	for _, name := range []string{
+		"../spiel2/geheim.ben",
+		"bilder/../../spiel2/geheim.ben",
+		"/etc/passwd",
+		"link.ben",
+		"draussen/geheim.png",
+		"bilder/x.png",
+	} {
+		rec := serve(s.handleDatei, http.MethodGet, "/api/datei?pfad="+name, nil)
+		refused(t, "GET /api/datei?pfad="+name, rec)
+	}
+
+	if rec := serve(s.handleDatei, http.MethodGet, "/api/datei?pfad=hauptspiel.ben", nil); rec.Code != http.StatusOK {
+		t.Errorf("reading hauptspiel.ben: status %d", rec.Code)
+	}
+}
+
+func TestDateiPostStaysInProject(t *testing.T) {
+	s := newTestServer(t, nil, withSandbox)
+	workDir, outside := s.WorkDir, outsideDir(s)
+
+	for _, name := range []string{"../spiel2/neu.ben", "../spiel2x/neu.ben", "draussen/neu.ben", "link.ben"} {
+		body, _ := json.Marshal(map[string]string{"name": name, "inhalt": "boese"})
+		rec := serve(s.handleDatei, http.MethodPost, "/api/datei", body)
+		refused(t, "POST /api/datei "+name, rec)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("POST /api/datei %s: status %d, want %d", name, rec.Code, http.StatusForbidden)
+		}
+	}
+
+	for _, path := range []string{filepath.Join(workDir, "spiel2", "neu.ben"), filepath.Join(workDir, "spiel2x"), filepath.Join(outside, "neu.ben")} {
+		if _, err := os.Lstat(path); err == nil {
+			t.Errorf("%s was created", path)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "spiel2", "geheim.ben")); !strings.Contains(string(data), secret) {
+		t.Error("file behind a symbolic link was overwritten")
+	}
+}
+
+func TestProjektDateienStaysInProject(t *testing.T) {
+	s := newTestServer(t, nil, withSandbox)
+
+	for _, path := range []string{"/projekt/../spiel2/geheim.ben", "/projekt/link.ben", "/projekt/x.png", "/projekt/bilder/x.png", "/projekt/draussen/geheim.png"} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/projekt/", nil)
+		req.URL.Path = path
+		s.handleProjektDateien(rec, req)
+		refused(t, path, rec)
+	}
+}
+
+func TestDateienListStaysInProject(t *testing.T) {
+	s := newTestServer(t, nil, withSandbox)
+
+	rec := serve(s.handleDateien, http.MethodGet, "/api/dateien", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), secret) || strings.Contains(rec.Body.String(), "link.ben") {
+		t.Errorf("file list contains a file from outside: %s", rec.Body.String())
+	}
+}
+
+func TestBildUploadStaysInProject(t *testing.T) {
+	s := newTestServer(t, nil, withSandbox)
+	workDir, outside := s.WorkDir, outsideDir(s)
+
+	upload := func(filename string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, _ := mw.CreateFormFile("bild", "bild.png")
+		part.Write(testPNG(t, 2, 2))
+		mw.Close()
+		// CreateFormFile escapes the name, so it is patched in afterwards
+		data := strings.Replace(body.String(), `filename="bild.png"`, `filename="`+filename+`"`, 1)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/bild", strings.NewReader(data))
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		rec := httptest.NewRecorder()
+		s.handleBilder(rec, req)
+		return rec
+	}
+
+	rec := upload("../../spiel2/boese.png")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"bilder/boese.png"`) {
+		t.Errorf("upload with traversal: %d %s", rec.Code, rec.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "spiel2", "boese.png")); err == nil {
+		t.Error("upload escaped the project")
+	}
+
+	refused(t, "upload over a symbolic link", upload("x.png"))
+	if data, _ := os.ReadFile(filepath.Join(outside, "geheim.png")); string(data) != secret {
+		t.Error("file behind a symbolic link was overwritten")
+	}
+	refused(t, "upload of a non-image", upload("seite.html"))
+}
+
+func TestUmbenennenStaysInProject(t *testing.T) {
+	s := newTestServer(t, nil, withSandbox)
+	workDir := s.WorkDir
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"datei": "hauptspiel.ben", "code": "VAR x = 1\n", "zeile": 1, "spalte": 5, "neuerName": "y",
+		"dateien": map[string]string{"../spiel2/geheim.ben": "SCHREIBE(x)\n"},
+	})
+	refused(t, "rename with a file outside", serve(s.handleUmbenennen, http.MethodPost, "/api/umbenennen", body))
+	if data, _ := os.ReadFile(filepath.Join(workDir, "spiel2", "geheim.ben")); !strings.Contains(string(data), secret) {
+		t.Error("rename changed a file outside the project")
+	}
+}
+
+func TestExportsStayInProject(t *testing.T) {
+	s := newTestServer(t, nil, withSandbox)
+
+	rec := serve(s.handleExportHTML, http.MethodGet, "/api/export/html", nil)
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "R0VIRUlN") || strings.Contains(rec.Body.String(), secret) {
+		t.Errorf("HTML export: status %d or leaks the secret", rec.Code)
+	}
+
+	rec = serve(s.handleProjekteExport, http.MethodGet, "/api/projekte/export", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ZIP export: status %d", rec.Code)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if strings.Contains(f.Name, "link") || strings.Contains(f.Name, "draussen") || strings.HasSuffix(f.Name, "x.png") {
+			t.Errorf("ZIP export contains %s", f.Name)
+		}
+	}
+
+	for _, name := range []string{"../draussen", "spiel2/..", "verlinkt"} {
+		refused(t, "ZIP export of "+name, serve(s.handleProjekteExport, http.MethodGet, "/api/projekte/export?name="+name, nil))
+	}
+}
+
+func TestProjekteStayInWorkDir(t *testing.T) {
+	s := newTestServer(t, nil, withSandbox)
+	workDir, outside := s.WorkDir, outsideDir(s)
+
+	for _, name := range []string{"../draussen", "spiel/../../draussen", "verlinkt", ".."} {
+		body, _ := json.Marshal(map[string]string{"name": name})
+		refused(t, "open "+name, serve(s.handleProjekteOeffnen, http.MethodPost, "/api/projekte/oeffnen", body))
+		refused(t, "create "+name, serve(s.handleProjekteNeu, http.MethodPost, "/api/projekte/neu", body))
+	}
+	if _, err := os.Stat(filepath.Join(outside, "hauptspiel.ben")); err == nil {
+		t.Error("a project was created outside the workdir")
+	}
+	if s.project.Path != filepath.Join(workDir, "spiel") {
+		t.Errorf("open project changed to %s", s.project.Path)
+	}
+
+	// A ZIP cannot place files outside the workdir either
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	w, _ := zw.Create("../../draussen/boese.ben")
+	w.Write([]byte("boese"))
+	zw.Close()
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("datei", "boese.zip")
+	part.Write(archive.Bytes())
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/projekte/import", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	s.handleProjekteImport(rec, req)
+	refused(t, "import with traversal", rec)
+	if _, err := os.Stat(filepath.Join(outside, "boese.ben")); err == nil {
+		t.Error("import wrote outside the workdir")
+	}
+}
+
+func TestManageStaysInProject(t *testing.T) {
+	s := newTestServer(t, nil, withSandbox)
+
+	for _, c := range []struct {
+		handler http.HandlerFunc
+		method  string
+		target  string
+		body    string
+	}{
+		{s.handleDatei, http.MethodDelete, "/api/datei?pfad=../spiel2/geheim.ben", ""},
+		{s.handleDatei, http.MethodDelete, "/api/datei?pfad=draussen/geheim.png", ""},
+		{s.handleDateiUmbenennen, http.MethodPost, "/api/datei/umbenennen", `{"von":"draussen/geheim.png","nach":"geheim.png"}`},
+		{s.handleDateiUmbenennen, http.MethodPost, "/api/datei/umbenennen", `{"von":"hauptspiel.ben","nach":"../spiel2/neu.ben"}`},
+		{s.handleDateiVerschieben, http.MethodPost, "/api/datei/verschieben", `{"pfad":"hauptspiel.ben","ordner":"draussen"}`},
+		{s.handleOrdner, http.MethodPost, "/api/ordner", `{"name":"../neu"}`},
+		{s.handleWiederherstellen, http.MethodPost, "/api/papierkorb/wiederherstellen", `{"id":"../../spiel2/geheim.ben"}`},
+	} {
+		rec := serve(c.handler, c.method, c.target, []byte(c.body))
+		refused(t, c.target+" "+c.body, rec)
+	}
+	if _, err := os.Stat(filepath.Join(s.WorkDir, "spiel2", "geheim.ben")); err != nil {
+		t.Errorf("file outside the project was touched: %v", err)
+	}
+}
@@ -0,0 +1,232 @@
+package server
+
+import (
+	"benlang/internal/diff"
+	"benlang/internal/project"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// recordChange makes a change to the project between two versions. The
+// one before keeps what the change overwrites if it is not in the history
+// yet, such as edits made outside the IDE; the one after records the
+// change. The caller holds the project's lock.
+func recordChange(proj *project.Project, reason string, change func() error) error {
+	snapshot(proj, "Vor der Änderung: "+reason)
+	if err := change(); err != nil {
+		return err
+	}
+	snapshot(proj, reason)
+	return nil
+}
+
+// snapshot records a version. A failing history must not fail the change
+// around it, so errors are only printed.
+func snapshot(proj *project.Project, reason string) {
+	if _, _, err := proj.Snapshot(reason); err != nil {
+		fmt.Fprintf(os.Stderr, "Warnung: Version konnte nicht gespeichert werden: %v\n", err)
+	}
+}
+
+// handleVerlauf lists the saved versions of the project, newest first
+func (s *Server) handleVerlauf(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ws := s.workspace(r)
+	if ws == nil {
+		http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
+		return
+	}
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	versions, err := ws.project.History()
+	if err != nil {
+		fileError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"versionen": versions,
+	})
+}
+
+// handleVerlaufDatei returns a file as it was in a version
+func (s *Server) handleVerlaufDatei(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, name := r.URL.Query().Get("version"), r.URL.Query().Get("pfad")
+
+	ws := s.workspace(r)
+	if ws == nil {
+		http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
+		return
+	}
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	content, err := ws.project.VersionFileContent(id, name)
+	if err != nil {
+		fileError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"version": id,
+		"name":    name,
+		"inhalt":  content,
+	})
+}
+
+// handleVerlaufVergleich compares two versions, or a version with the
+// current files if bis is empty
+func (s *Server) handleVerlaufVergleich(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	from, to := r.URL.Query().Get("von"), r.URL.Query().Get("bis")
+
+	ws := s.workspace(r)
+	if ws == nil {
+		http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
+		return
+	}
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	diffs, err := ws.project.CompareVersions(from, to)
+	if err != nil {
+		fileError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dateien": diffs,
+	})
+}
+
+// handleVerlaufWiederherstellen brings back a file or, without pfad, the
+// whole project as it was in a version
+func (s *Server) handleVerlaufWiederherstellen(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Version string `json:"version"`
+		Pfad    string `json:"pfad"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws := s.workspace(r)
+	if ws == nil {
+		http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
+		return
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	changed, err := ws.project.RestoreVersion(req.Version, req.Pfad)
+	if err != nil {
+		fileError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"erfolg":    true,
+		"dateien":   changed,
+		"warnungen": assetWarnings(ws.project),
+	})
+}
+
+// handleDiff compares two versions of a file and returns hunks the IDE
+// shows side by side. The texts are sent as alt and neu, or taken from
+// the history with pfad, von and bis (empty bis: the file as it is now).
+// For .ben files re-indentation does not count as a change.
+func (s *Server) handleDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Alt     *string `json:"alt"`
+		Neu     *string `json:"neu"`
+		Pfad    string  `json:"pfad"`
+		Von     string  `json:"von"`
+		Bis     string  `json:"bis"`
+		Kontext *int    `json:"kontext"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	context := 3
+	if req.Kontext != nil && *req.Kontext >= 0 {
+		context = *req.Kontext
+	}
+
+	var old, new string
+	switch {
+	case req.Alt != nil && req.Neu != nil:
+		old, new = *req.Alt, *req.Neu
+	case req.Pfad != "" && req.Von != "":
+		ws := s.workspace(r)
+		if ws == nil {
+			http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
+			return
+		}
+		ws.mu.RLock()
+		var err error
+		old, err = ws.project.VersionFileContent(req.Von, req.Pfad)
+		if err == nil {
+			if req.Bis == "" {
+				new, err = ws.project.ReadFile(req.Pfad)
+			} else {
+				new, err = ws.project.VersionFileContent(req.Bis, req.Pfad)
+			}
+		}
+		ws.mu.RUnlock()
+		if err != nil {
+			fileError(w, err, http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "alt und neu oder pfad und von angeben", http.StatusBadRequest)
+		return
+	}
+
+	var hunks []diff.Hunk
+	if req.Pfad == "" || strings.HasSuffix(req.Pfad, ".ben") {
+		hunks = diff.SourceHunks(old, new, context)
+	} else {
+		hunks = diff.Hunks(diff.Lines(old, new), context)
+	}
+	if hunks == nil {
+		hunks = []diff.Hunk{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"geaendert": len(hunks) > 0,
+		"hunks":     hunks,
+	})
+}
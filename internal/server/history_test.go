@@ -0,0 +1,137 @@
+package server
+
+import (
+	"benlang/internal/diff"
+	"benlang/internal/project"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestVerlauf(t *testing.T) {
+	proj, err := project.New(filepath.Join(t.TempDir(), "spiel"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{project: proj}
+
+	save := func(content string) {
+		body, _ := json.Marshal(map[string]string{"name": "hauptspiel.ben", "inhalt": content})
+		if rec := serve(s.handleDatei, http.MethodPost, "/api/datei", body); rec.Code != http.StatusOK {
+			t.Fatalf("save: %d %s", rec.Code, rec.Body)
+		}
+	}
+	save("VAR punkte = 0\nWENN_START {\n  punkte = 10\n}\n")
+	save("// Ups, alles gelöscht\n")
+
+	rec := serve(s.handleVerlauf, http.MethodGet, "/api/verlauf", nil)
+	var history struct {
+		Versionen []project.VersionInfo `json:"versionen"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &history)
+	if len(history.Versionen) != 2 || history.Versionen[0].Reason != "Gespeichert: hauptspiel.ben" {
+		t.Fatalf("history: %s", rec.Body)
+	}
+	good := history.Versionen[1].ID
+
+	rec = serve(s.handleVerlaufDatei, http.MethodGet, "/api/verlauf/datei?version="+good+"&pfad=hauptspiel.ben", nil)
+	if !strings.Contains(rec.Body.String(), "punkte = 10") {
+		t.Errorf("old content: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(s.handleVerlaufVergleich, http.MethodGet, "/api/verlauf/vergleich?von="+good, nil)
+	if !strings.Contains(rec.Body.String(), `"art":"entfernt"`) || !strings.Contains(rec.Body.String(), `"art":"neu"`) {
+		t.Errorf("compare: %d %s", rec.Code, rec.Body)
+	}
+
+	body, _ := json.Marshal(map[string]string{"version": good})
+	rec = serve(s.handleVerlaufWiederherstellen, http.MethodPost, "/api/verlauf/wiederherstellen", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("restore: %d %s", rec.Code, rec.Body)
+	}
+	if content, _ := proj.ReadFile("hauptspiel.ben"); !strings.Contains(content, "punkte = 10") {
+		t.Errorf("content after restore: %q", content)
+	}
+
+	for _, target := range []string{
+		"/api/verlauf/datei?version=" + good + "&pfad=../../etc/passwd",
+		"/api/verlauf/datei?version=../../x&pfad=hauptspiel.ben",
+	} {
+		if rec := serve(s.handleVerlaufDatei, http.MethodGet, target, nil); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", target, rec.Code)
+		}
+	}
+	if rec := serve(s.handleVerlaufVergleich, http.MethodGet, "/api/verlauf/vergleich?von=gibtsnicht", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown version: status %d, want 404", rec.Code)
+	}
+}
+
+func TestChangesKeepEarlierState(t *testing.T) {
+	s := newTestServer(t, heldFiles)
+
+	// Changed outside the IDE, never recorded
+	outside := "FIGUR held = LADE_BILD(\"held.png\")\nheld.x = 10\n"
+	if err := os.WriteFile(filepath.Join(s.project.Path, "hauptspiel.ben"), []byte(outside), 0644); err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(map[string]string{"name": "hauptspiel.ben", "inhalt": "// neu\n"})
+	if rec := serve(s.handleDatei, http.MethodPost, "/api/datei", body); rec.Code != http.StatusOK {
+		t.Fatalf("save: %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(s.handleDatei, http.MethodDelete, "/api/datei?pfad=bilder/held.png", nil); rec.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", rec.Code, rec.Body)
+	}
+
+	versions, err := s.project.History()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reasons []string
+	for _, v := range versions {
+		reasons = append(reasons, v.Reason)
+	}
+	want := []string{"Gelöscht: bilder/held.png", "Gespeichert: hauptspiel.ben", "Vor der Änderung: Gespeichert: hauptspiel.ben"}
+	if !slices.Equal(reasons, want) {
+		t.Fatalf("versions = %q, want %q", reasons, want)
+	}
+	if content, _ := s.project.VersionFileContent(versions[2].ID, "hauptspiel.ben"); content != outside {
+		t.Errorf("content before the save = %q, want %q", content, outside)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	s := &Server{}
+
+	body, _ := json.Marshal(map[string]string{
+		"alt": "FUNKTION springe() {\ny = y - 10\n}\n",
+		"neu": "FUNKTION springe() {\n    y = y - 20\n}\n",
+	})
+	rec := serve(s.handleDiff, http.MethodPost, "/api/diff", body)
+	var result struct {
+		Geaendert bool        `json:"geaendert"`
+		Hunks     []diff.Hunk `json:"hunks"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	if rec.Code != http.StatusOK || !result.Geaendert || len(result.Hunks) != 1 {
+		t.Fatalf("diff: %d %s", rec.Code, rec.Body)
+	}
+	h := result.Hunks[0]
+	if h.Section != "FUNKTION springe" || len(h.Lines) != 4 || h.Lines[1].Op != diff.Delete || h.Lines[2].Op != diff.Insert {
+		t.Errorf("hunk = %+v", h)
+	}
+
+	body, _ = json.Marshal(map[string]string{"alt": "WENN_START {\nx = 1\n}", "neu": "WENN_START {\n  x = 1\n}"})
+	rec = serve(s.handleDiff, http.MethodPost, "/api/diff", body)
+	if !strings.Contains(rec.Body.String(), `"geaendert":false`) {
+		t.Errorf("re-indentation reported: %s", rec.Body)
+	}
+
+	rec = serve(s.handleDiff, http.MethodPost, "/api/diff", []byte(`{"pfad":"hauptspiel.ben","von":"x"}`))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("history diff without project: status %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"benlang/internal/project"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// LiveInterval is how often watched projects are checked for changes
+var LiveInterval = time.Second
+
+// liveEvent is sent to connected IDEs as a server-sent event
+type liveEvent struct {
+	name string
+	data interface{}
+}
+
+// liveHub keeps a watcher for every project that has connected IDEs
+type liveHub struct {
+	mu       sync.Mutex
+	projects map[string]*liveProject // by project path
+}
+
+type liveProject struct {
+	ws      *workspace
+	watcher *project.Watcher
+	clients map[chan liveEvent]bool
+	done    chan struct{}
+}
+
+// live returns the hub, creating it on first use
+func (s *Server) live() *liveHub {
+	s.liveOnce.Do(func() {
+		s.liveHub = &liveHub{projects: map[string]*liveProject{}}
+	})
+	return s.liveHub
+}
+
+// subscribe registers an IDE for the changes of a project. The first IDE
+// starts the watcher, the last one to leave stops it.
+func (s *Server) subscribe(ws *workspace) (chan liveEvent, func()) {
+	h := s.live()
+	events := make(chan liveEvent, 16)
+	proj := ws.project
+
+	h.mu.Lock()
+	lp := h.projects[proj.Path]
+	if lp == nil {
+		ws.mu.RLock()
+		watcher := project.NewWatcher(proj)
+		ws.mu.RUnlock()
+		lp = &liveProject{ws: ws, watcher: watcher, clients: map[chan liveEvent]bool{}, done: make(chan struct{})}
+		h.projects[proj.Path] = lp
+		go s.watch(lp)
+	}
+	lp.clients[events] = true
+	h.mu.Unlock()
+
+	return events, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(lp.clients, events)
+		if len(lp.clients) == 0 && h.projects[proj.Path] == lp {
+			delete(h.projects, proj.Path)
+			close(lp.done)
+		}
+	}
+}
+
+// broadcast sends an event to all IDEs of a project. IDEs that do not
+// keep up miss the event instead of blocking the others.
+func (s *Server) broadcast(path string, ev liveEvent) {
+	h := s.live()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	lp := h.projects[path]
+	if lp == nil {
+		return
+	}
+	for events := range lp.clients {
+		select {
+		case events <- ev:
+		default:
+		}
+	}
+}
+
+func (s *Server) watch(lp *liveProject) {
+	ticker := time.NewTicker(LiveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lp.done:
+			return
+		case <-ticker.C:
+			s.pollLive(lp)
+		}
+	}
+}
+
+// pollLive sends the files changed on disk and, if code changed, the
+// result of compiling the project. The project is only locked while its
+// files are read; saves do not wait for the compiler.
+func (s *Server) pollLive(lp *liveProject) {
+	proj := lp.ws.project
+	lp.ws.mu.RLock()
+	changes, err := lp.watcher.Poll()
+	lp.ws.mu.RUnlock()
+	if err != nil || len(changes) == 0 {
+		return
+	}
+
+	source := false
+	for _, c := range changes {
+		if c.Name == project.ManifestFile {
+			lp.ws.mu.Lock()
+			proj.ReloadManifest()
+			lp.ws.mu.Unlock()
+		}
+		source = source || c.IsSource()
+	}
+
+	s.broadcast(proj.Path, liveEvent{"dateien", map[string]interface{}{"dateien": changes}})
+	if source {
+		s.broadcast(proj.Path, liveEvent{"kompiliert", compileResult(lp.ws)})
+	}
+}
+
+// compileResult compiles the saved files the way /api/kompilieren answers
+func compileResult(ws *workspace) map[string]interface{} {
+	ws.mu.RLock()
+	sources, err := ws.project.Sources()
+	manifest := ws.project.Manifest
+	warnings := []string{}
+	if err == nil {
+		warnings = compileWarnings(ws.project, project.OrderFiles(sources, manifest))
+	}
+	ws.mu.RUnlock()
+
+	js, messages := compileSaved(sources, manifest, err)
+	return map[string]interface{}{
+		"fehler":    messages,
+		"warnungen": warnings,
+		"js":        js,
+	}
+}
+
+// compileSaved compiles files read with Sources, or reports why they could
+// not be read, and returns the JavaScript and the error messages. It needs
+// no lock.
+func compileSaved(sources map[string]string, m project.Manifest, readErr error) (string, []string) {
+	if readErr != nil {
+		return "", []string{readErr.Error()}
+	}
+	js, errs, err := project.CompileSources(sources, m)
+	if err != nil {
+		return "", []string{err.Error()}
+	}
+	return js, warningMessages(errs)
+}
+
+// conflict refuses a save because the file changed since the editor
+// loaded it. The answer contains the file as it is now; the other IDEs
+// of the project are told as well.
+func (s *Server) conflict(w http.ResponseWriter, proj *project.Project, name, current string) {
+	hash := project.ContentHash(current)
+	s.broadcast(proj.Path, liveEvent{"konflikt", map[string]string{"datei": name, "hash": hash}})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"erfolg":   false,
+		"konflikt": true,
+		"fehler":   fmt.Sprintf("'%s' wurde inzwischen woanders geändert", name),
+		"datei":    name,
+		"inhalt":   current,
+		"hash":     hash,
+	})
+}
+
+// handleLive streams changes of the session's project as server-sent events:
+// "dateien" for files changed on disk, "kompiliert" with the result of
+// compiling them and "konflikt" when a save was refused
+func (s *Server) handleLive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming nicht unterstützt", http.StatusInternalServerError)
+		return
+	}
+
+	ws := s.workspace(r)
+	if ws == nil {
+		http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
+		return
+	}
+
+	events, unsubscribe := s.subscribe(ws)
+	defer unsubscribe()
+	// Like /api/kompilieren, viewers of another child's project get no code
+	withoutCode := s.othersProject(r)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	writeEvent(w, liveEvent{"verbunden", map[string]string{"projekt": ws.project.Name}})
+	flusher.Flush()
+
+	// Comments keep proxies from closing an idle connection
+	keepAlive := time.NewTicker(20 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev := <-events:
+			if result, ok := ev.data.(map[string]interface{}); ok && ev.name == "kompiliert" && withoutCode {
+				// The result is shared with the other IDEs
+				copied := map[string]interface{}{}
+				for key, value := range result {
+					copied[key] = value
+				}
+				copied["js"] = ""
+				ev.data = copied
+			}
+			writeEvent(w, ev)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, ev liveEvent) {
+	data, _ := json.Marshal(ev.data)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, data)
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"benlang/internal/project"
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readEvent reads the next server-sent event
+func readEvent(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+	var name, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && name != "":
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func TestLiveReload(t *testing.T) {
+	old := LiveInterval
+	LiveInterval = 10 * time.Millisecond
+	defer func() { LiveInterval = old }()
+
+	proj, err := project.New(filepath.Join(t.TempDir(), "spiel"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proj.WriteFile("hauptspiel.ben", "VAR x = 1\n")
+	s := &Server{project: proj}
+
+	srv := httptest.NewServer(http.HandlerFunc(s.handleLive))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	events := bufio.NewReader(resp.Body)
+	if name, _ := readEvent(t, events); name != "verbunden" {
+		t.Fatalf("first event = %q", name)
+	}
+
+	// A teacher changes the file with another editor
+	os.WriteFile(filepath.Join(proj.Path, "hauptspiel.ben"), []byte("VAR x = \n"), 0644)
+
+	name, data := readEvent(t, events)
+	var changed struct {
+		Dateien []project.Change `json:"dateien"`
+	}
+	json.Unmarshal([]byte(data), &changed)
+	if name != "dateien" || len(changed.Dateien) != 1 || changed.Dateien[0].Content != "VAR x = \n" {
+		t.Fatalf("event %s: %s", name, data)
+	}
+
+	name, data = readEvent(t, events)
+	if name != "kompiliert" || !strings.Contains(data, "hauptspiel.ben, Zeile") {
+		t.Errorf("event %s: %s", name, data)
+	}
+}
+
+func TestSaveConflict(t *testing.T) {
+	proj, err := project.New(filepath.Join(t.TempDir(), "spiel"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proj.WriteFile("hauptspiel.ben", "VAR x = 1\n")
+	s := &Server{project: proj}
+
+	rec := serve(s.handleDatei, http.MethodGet, "/api/datei?pfad=hauptspiel.ben", nil)
+	var loaded struct {
+		Hash string `json:"hash"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &loaded)
+
+	// Someone else saves in the meantime
+	proj.WriteFile("hauptspiel.ben", "VAR x = 2\n")
+
+	body, _ := json.Marshal(map[string]interface{}{"name": "hauptspiel.ben", "inhalt": "VAR x = 3\n", "basis": loaded.Hash})
+	rec = serve(s.handleDatei, http.MethodPost, "/api/datei", body)
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), `"inhalt":"VAR x = 2\n"`) {
+		t.Errorf("stale save: %d %s", rec.Code, rec.Body)
+	}
+	if content, _ := proj.ReadFile("hauptspiel.ben"); content != "VAR x = 2\n" {
+		t.Errorf("stale save overwrote the file: %q", content)
+	}
+
+	body, _ = json.Marshal(map[string]interface{}{"name": "hauptspiel.ben", "inhalt": "VAR x = 3\n", "basis": loaded.Hash, "erzwingen": true})
+	rec = serve(s.handleDatei, http.MethodPost, "/api/datei", body)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), project.ContentHash("VAR x = 3\n")) {
+		t.Errorf("forced save: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestLiveSendsNoCodeToViewers(t *testing.T) {
+	old := LiveInterval
+	LiveInterval = 10 * time.Millisecond
+	defer func() { LiveInterval = old }()
+
+	s := newTestServer(t, nil, withUsers)
+	teacher := login(t, s, "frau-meier")
+	body, _ := json.Marshal(map[string]string{"name": "rennspiel", "benutzer": "anna"})
+	rec := serveAs(s.Handler().ServeHTTP, teacher, http.MethodPost, "/api/projekte/oeffnen", body)
+	viewing := append(rec.Result().Cookies(), teacher...)
+
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/live", nil)
+	for _, c := range viewing {
+		req.AddCookie(c)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	events := bufio.NewReader(resp.Body)
+	if name, data := readEvent(t, events); name != "verbunden" {
+		t.Fatalf("first event %s: %s", name, data)
+	}
+
+	// Anna changes her game while her teacher looks at it
+	os.WriteFile(filepath.Join(s.WorkDir, "anna", "rennspiel", "hauptspiel.ben"), []byte("VAR auto = 2\n"), 0644)
+	for {
+		name, data := readEvent(t, events)
+		if name != "kompiliert" {
+			continue
+		}
+		if !strings.Contains(data, `"js":""`) {
+			t.Errorf("viewer gets code: %s", data)
+		}
+		break
+	}
+}
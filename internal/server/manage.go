@@ -0,0 +1,338 @@
+package server
+
+import (
+	"benlang/internal/analysis"
+	"benlang/internal/compiler"
+	"benlang/internal/project"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// assetWarnings checks the saved .ben files for images and sounds that are
+// missing. The caller holds the project's lock.
+func assetWarnings(proj *project.Project) []string {
+	sources, err := proj.Sources()
+	if err != nil {
+		return []string{}
+	}
+	return warningMessages(proj.AssetWarnings(proj.AnalysisFiles(sources)))
+}
+
+// compileWarnings are shown next to the compile errors: a broken
+// projekt.json and images or sounds the code loads but the project lacks
+func compileWarnings(proj *project.Project, files []analysis.File) []string {
+	warnings := []string{}
+	if proj.ManifestError != nil {
+		warnings = append(warnings, proj.ManifestError.Error()+"; bis es repariert ist, gelten die Standardwerte")
+	}
+	return append(warnings, warningMessages(proj.AssetWarnings(files))...)
+}
+
+func warningMessages(warnings []compiler.Error) []string {
+	messages := make([]string, len(warnings))
+	for i, w := range warnings {
+		messages[i] = w.Error()
+	}
+	return messages
+}
+
+// handleDateiLoeschen moves a file or folder into the trash
+func (s *Server) handleDateiLoeschen(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("pfad")
+	if name == "" {
+		http.Error(w, "pfad parameter required", http.StatusBadRequest)
+		return
+	}
+
+	ws := s.workspace(r)
+	if ws == nil {
+		http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
+		return
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	var item project.TrashItem
+	err := recordChange(ws.project, "Gelöscht: "+name, func() (err error) {
+		item, err = ws.project.Delete(name)
+		return err
+	})
+	if err != nil {
+		fileError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"erfolg":     true,
+		"papierkorb": item,
+		"warnungen":  assetWarnings(ws.project),
+	})
+}
+
+// handleDateiUmbenennen renames a file or folder
+func (s *Server) handleDateiUmbenennen(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Von  string `json:"von"`
+		Nach string `json:"nach"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws := s.workspace(r)
+	if ws == nil {
+		http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
+		return
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	err := recordChange(ws.project, fmt.Sprintf("Umbenannt: %s in %s", req.Von, req.Nach), func() error {
+		return ws.project.Rename(req.Von, req.Nach)
+	})
+	if err != nil {
+		fileError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"erfolg":    true,
+		"pfad":      req.Nach,
+		"warnungen": assetWarnings(ws.project),
+	})
+}
+
+// handleDateiVerschieben moves a file or folder into another folder
+func (s *Server) handleDateiVerschieben(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Pfad   string `json:"pfad"`
+		Ordner string `json:"ordner"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws := s.workspace(r)
+	if ws == nil {
+		http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
+		return
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	var path string
+	err := recordChange(ws.project, fmt.Sprintf("Verschoben: %s nach %s", req.Pfad, req.Ordner), func() (err error) {
+		path, err = ws.project.Move(req.Pfad, req.Ordner)
+		return err
+	})
+	if err != nil {
+		fileError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"erfolg":    true,
+		"pfad":      path,
+		"warnungen": assetWarnings(ws.project),
+	})
+}
+
+// handleOrdner creates a folder in the project
+func (s *Server) handleOrdner(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws := s.workspace(r)
+	if ws == nil {
+		http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
+		return
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if err := ws.project.CreateFolder(req.Name); err != nil {
+		fileError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"erfolg": true})
+}
+
+// handlePapierkorb lists the deleted files (GET) or removes them for good
+// (DELETE)
+func (s *Server) handlePapierkorb(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ws := s.workspace(r)
+	if ws == nil {
+		http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		ws.mu.Lock()
+		err := ws.project.EmptyTrash()
+		ws.mu.Unlock()
+		if err != nil {
+			fileError(w, err, http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]bool{"erfolg": true})
+		return
+	}
+
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	items, err := ws.project.Trash()
+	if err != nil {
+		fileError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dateien": items,
+	})
+}
+
+// handleWiederherstellen moves a deleted file back into the project
+func (s *Server) handleWiederherstellen(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ws := s.workspace(r)
+	if ws == nil {
+		http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
+		return
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	var name string
+	err := recordChange(ws.project, "Aus dem Papierkorb geholt: "+req.ID, func() (err error) {
+		name, err = ws.project.Restore(req.ID)
+		return err
+	})
+	if err != nil {
+		fileError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"erfolg": true,
+		"pfad":   name,
+	})
+}
+
+// handleMedien uploads an image or sound. The content is checked against
+// the file extension; sounds are saved in toene/, images in bilder/.
+func (s *Server) handleMedien(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filename, content, ok := readUpload(w, r, "datei")
+	if !ok {
+		return
+	}
+
+	ws := s.workspace(r)
+	if ws == nil {
+		http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
+		return
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	var asset project.Asset
+	err := recordChange(ws.project, "Hochgeladen: "+filename, func() (err error) {
+		asset, err = ws.project.SaveAsset(filename, content)
+		return err
+	})
+	if err != nil {
+		fileError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"erfolg": true,
+		"datei":  asset,
+	})
+}
+
+// readUpload reads the file in a form field of a multipart upload, up to
+// the size of one asset. On failure it answers the request itself.
+func readUpload(w http.ResponseWriter, r *http.Request, field string) (filename string, content []byte, ok bool) {
+	// Leave room for the multipart headers around the file
+	r.Body = http.MaxBytesReader(w, r.Body, project.MaxAssetSize+1<<20)
+	if err := r.ParseMultipartForm(project.MaxAssetSize); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("Die Datei ist zu groß, erlaubt sind %d MB", project.MaxAssetSize>>20), http.StatusRequestEntityTooLarge)
+			return "", nil, false
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", nil, false
+	}
+
+	file, header, err := r.FormFile(field)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", nil, false
+	}
+	defer file.Close()
+
+	content, err = io.ReadAll(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return "", nil, false
+	}
+	return header.Filename, content, true
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"benlang/internal/project"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// heldFiles is a project whose code loads an image
+var heldFiles = map[string]string{
+	"hauptspiel.ben":  "FIGUR held = LADE_BILD(\"held.png\")\n",
+	"bilder/held.png": "held",
+}
+
+func TestDeleteWarnsAndRestores(t *testing.T) {
+	s := newTestServer(t, heldFiles)
+
+	rec := serve(s.handleDatei, http.MethodDelete, "/api/datei?pfad=bilder/held.png", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete: status %d: %s", rec.Code, rec.Body)
+	}
+	var deleted struct {
+		Papierkorb project.TrashItem `json:"papierkorb"`
+		Warnungen  []string          `json:"warnungen"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &deleted)
+	if len(deleted.Warnungen) != 1 || !strings.Contains(deleted.Warnungen[0], "hauptspiel.ben, Zeile 1") {
+		t.Errorf("warnings after delete = %q", deleted.Warnungen)
+	}
+
+	rec = serve(s.handleKompilieren, http.MethodPost, "/api/kompilieren", []byte(`{"dateien":{"hauptspiel.ben":"FIGUR held = LADE_BILD(\"held.png\")"}}`))
+	if !strings.Contains(rec.Body.String(), "Papierkorb") {
+		t.Errorf("compile does not warn about the deleted image: %s", rec.Body)
+	}
+
+	rec = serve(s.handlePapierkorb, http.MethodGet, "/api/papierkorb", nil)
+	if !strings.Contains(rec.Body.String(), deleted.Papierkorb.ID) {
+		t.Errorf("trash lacks the deleted file: %s", rec.Body)
+	}
+
+	body, _ := json.Marshal(map[string]string{"id": deleted.Papierkorb.ID})
+	rec = serve(s.handleWiederherstellen, http.MethodPost, "/api/papierkorb/wiederherstellen", body)
+	if rec.Code != http.StatusOK || !s.project.Exists("bilder/held.png") {
+		t.Errorf("restore: status %d: %s", rec.Code, rec.Body)
+	}
+
+	serve(s.handleDatei, http.MethodDelete, "/api/datei?pfad=bilder/held.png", nil)
+	rec = serve(s.handlePapierkorb, http.MethodDelete, "/api/papierkorb", nil)
+	if items, _ := s.project.Trash(); rec.Code != http.StatusOK || len(items) != 0 {
+		t.Errorf("empty trash: status %d, left %+v", rec.Code, items)
+	}
+}
+
+func TestRenameMoveAndFolder(t *testing.T) {
+	s := newTestServer(t, heldFiles)
+
+	rec := serve(s.handleOrdner, http.MethodPost, "/api/ordner", []byte(`{"name":"level"}`))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("folder: status %d: %s", rec.Code, rec.Body)
+	}
+	rec = serve(s.handleOrdner, http.MethodPost, "/api/ordner", []byte(`{"name":"level"}`))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("existing folder: status %d, want 409", rec.Code)
+	}
+
+	s.project.WriteFile("welt.ben", "VAR welt = 1\n")
+	rec = serve(s.handleDateiVerschieben, http.MethodPost, "/api/datei/verschieben", []byte(`{"pfad":"welt.ben","ordner":"level"}`))
+	if rec.Code != http.StatusOK || !s.project.Exists("level/welt.ben") {
+		t.Errorf("move: status %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(s.handleDateiUmbenennen, http.MethodPost, "/api/datei/umbenennen", []byte(`{"von":"level/welt.ben","nach":"hauptspiel.ben"}`))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("rename onto existing file: status %d, want 409", rec.Code)
+	}
+	rec = serve(s.handleDateiUmbenennen, http.MethodPost, "/api/datei/umbenennen", []byte(`{"von":"bilder/held.png","nach":"bilder/heldin.png"}`))
+	var renamed struct {
+		Warnungen []string `json:"warnungen"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &renamed)
+	if rec.Code != http.StatusOK || len(renamed.Warnungen) != 1 {
+		t.Errorf("rename: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestMedienUpload(t *testing.T) {
+	s := newTestServer(t, heldFiles)
+
+	upload := func(filename string, data []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		part, _ := mw.CreateFormFile("datei", filename)
+		part.Write(data)
+		mw.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/medien", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		rec := httptest.NewRecorder()
+		s.handleMedien(rec, req)
+		return rec
+	}
+
+	rec := upload("stern.png", testPNG(t, 20, 10))
+	var result struct {
+		Datei project.Asset `json:"datei"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	if rec.Code != http.StatusOK || result.Datei.Path != "bilder/stern.png" || result.Datei.Width != 20 || result.Datei.Height != 10 {
+		t.Errorf("image upload: %d %s", rec.Code, rec.Body)
+	}
+
+	wav := append([]byte("RIFF\x24\x00\x00\x00WAVEfmt "), make([]byte, 32)...)
+	rec = upload("sprung.wav", wav)
+	if rec.Code != http.StatusOK || !s.project.Exists("toene/sprung.wav") {
+		t.Errorf("sound upload: %d %s", rec.Code, rec.Body)
+	}
+
+	if rec := upload("falsch.png", []byte("<html><script>alert(1)</script>")); rec.Code != http.StatusBadRequest {
+		t.Errorf("disguised upload: status %d, want 400", rec.Code)
+	}
+	if rec := upload("riesig.png", make([]byte, project.MaxAssetSize+2<<20)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized upload: status %d, want 413", rec.Code)
+	}
+}
+
+func TestDateiRefusesAssets(t *testing.T) {
+	s := newTestServer(t, heldFiles)
+
+	save := func(name string, content string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"name": name, "inhalt": content})
+		return serve(s.handleDatei, http.MethodPost, "/api/datei", body)
+	}
+
+	if rec := save("bilder/x.png", "<html><script>alert(1)</script>"); rec.Code != http.StatusBadRequest {
+		t.Errorf("image through /api/datei: status %d, want 400", rec.Code)
+	}
+	if rec := save("toene/x.MP3", "kein ton"); rec.Code != http.StatusBadRequest {
+		t.Errorf("sound through /api/datei: status %d, want 400", rec.Code)
+	}
+	if rec := save("riesig.ben", strings.Repeat("x", project.MaxAssetSize+1)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized file: status %d, want 413", rec.Code)
+	}
+	if rec := save("level.ben", "VAR x = 1\n"); rec.Code != http.StatusOK {
+		t.Errorf("code file: status %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestBilderUploadDoesNotBlockProject(t *testing.T) {
+	s := newTestServer(t, heldFiles)
+
+	// An upload whose body never finishes
+	body, writer := io.Pipe()
+	defer writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/bild", body)
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	go s.handleBilder(httptest.NewRecorder(), req)
+	writer.Write([]byte("--x\r\nContent-Disposition: form-data; name=\"bild\"; filename=\"a.png\"\r\n\r\n"))
+
+	done := make(chan int)
+	go func() {
+		done <- serve(s.handleDatei, http.MethodGet, "/api/datei?pfad=hauptspiel.ben", nil).Code
+	}()
+	select {
+	case code := <-done:
+		if code != http.StatusOK {
+			t.Errorf("read during upload: status %d", code)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("a slow upload blocks reading the project")
+	}
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	part, _ := mw.CreateFormFile("bild", "riesig.png")
+	part.Write(make([]byte, project.MaxAssetSize+2<<20))
+	mw.Close()
+	req = httptest.NewRequest(http.MethodPost, "/api/bild", &form)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	s.handleBilder(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized image: status %d, want 413", rec.Code)
+	}
+}
@@ -2,19 +2,29 @@ package server
 
 import (
 	"benlang/internal/auth"
+	"benlang/internal/blocks"
+	"benlang/internal/compiler"
+	"benlang/internal/export"
 	"benlang/internal/lexer"
 	"benlang/internal/parser"
 	"benlang/internal/project"
 	"benlang/internal/transpiler"
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"log"
+	"mime"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // WebContent will be set from main package with embedded files
@@ -22,41 +32,199 @@ var WebContent fs.FS
 
 // Server represents the BenLang HTTP server
 type Server struct {
-	project     *project.Project
+	project     *project.Project // opened at start, for sessions that did not choose one
 	port        int
 	WorkDir     string
+	Examples    string // shared examples, copied before they are changed
 	AuthEnabled bool
-	auth        *auth.Credentials
-	mu          sync.RWMutex
+	// Logins end after SessionIdle without requests and SessionMaxAge after
+	// logging in; 0 means never. With RememberSessions they are kept in
+	// auth.SessionsFile next to the credentials across restarts.
+	SessionIdle      time.Duration
+	SessionMaxAge    time.Duration
+	RememberSessions bool
+	// With TLSCert and TLSKey the server speaks HTTPS, and HTTP requests
+	// to HTTPPort are sent there; 0 does not listen for HTTP.
+	TLSCert    string
+	TLSKey     string
+	HTTPPort   int
+	auth       *auth.Credentials
+	limiter    *auth.Limiter
+	mu         sync.RWMutex // guards workspaces and imports into WorkDir
+	workspaces map[string]*workspace
+	liveHub    *liveHub
+	liveOnce   sync.Once
+	overviewMu sync.Mutex
+	overview   map[string]savedCompile // by project path, for the class overview
 }
 
 // New creates a new Server
 func New(proj *project.Project, port int) *Server {
-	creds, _ := auth.LoadCredentials()
 	return &Server{
-		project: proj,
-		port:    port,
-		auth:    creds,
+		project:       proj,
+		port:          port,
+		auth:          auth.NewCredentials(),
+		limiter:       auth.NewLimiter(),
+		SessionIdle:   auth.DefaultIdleTimeout,
+		SessionMaxAge: auth.DefaultMaxAge,
 	}
 }
 
-// Start starts the HTTP server
+// LoadCredentials reads the users who may log in from path
+func (s *Server) LoadCredentials(path string) error {
+	creds, err := auth.LoadCredentials(path)
+	if err != nil {
+		return err
+	}
+	s.auth = creds
+	return nil
+}
+
+// SessionCleanupInterval is how often expired logins are removed
+var SessionCleanupInterval = 10 * time.Minute
+
+// startSessions applies the session settings, opens the audit log of the
+// logins and removes expired logins in the background
+func (s *Server) startSessions() error {
+	s.auth.IdleTimeout = s.SessionIdle
+	s.auth.MaxAge = s.SessionMaxAge
+	if s.RememberSessions {
+		if err := s.auth.LoadSessions(s.auth.Beside(auth.SessionsFile)); err != nil {
+			return fmt.Errorf("Gespeicherte Anmeldungen konnten nicht geladen werden: %w", err)
+		}
+	}
+
+	audit, err := os.OpenFile(s.auth.Beside(auth.AuditFile), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("Das Protokoll der Anmeldungen konnte nicht geöffnet werden: %w", err)
+	}
+	s.limiter.Audit = log.New(audit, "", log.LstdFlags)
+
+	go func() {
+		ticker := time.NewTicker(SessionCleanupInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := s.auth.CleanSessions(); err != nil {
+				fmt.Printf("⚠️ Anmeldungen konnten nicht gespeichert werden: %v\n", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Start starts the HTTP server, or the HTTPS server if a certificate is set
 func (s *Server) Start() error {
+	addr := fmt.Sprintf(":%d", s.port)
+	fmt.Printf("🎮 BenLang Server gestartet auf %s\n", s.URL("localhost"))
+	if s.TLSCert != "" {
+		fingerprint, hosts, err := certificateInfo(s.TLSCert)
+		if err != nil {
+			return fmt.Errorf("Zertifikat %s: %w", s.TLSCert, err)
+		}
+		fmt.Printf("🔐 HTTPS mit %s (gilt für %s)\n", s.TLSCert, strings.Join(hosts, ", "))
+		fmt.Printf("   SHA-256: %s\n", fingerprint)
+		if s.HTTPPort != 0 {
+			fmt.Printf("↪️  http://localhost:%d leitet auf HTTPS um\n", s.HTTPPort)
+		}
+	}
+	if s.project != nil {
+		fmt.Printf("📁 Projekt: %s\n", s.project.Path)
+	} else {
+		fmt.Printf("📁 Kein Projekt geladen (Arbeitsverzeichnis: %s)\n", s.WorkDir)
+	}
+	if s.AuthEnabled {
+		if err := s.startSessions(); err != nil {
+			return err
+		}
+		fmt.Println("🔒 Authentifizierung ist AKTIVIERT")
+		fmt.Printf("🔑 Zugangsdaten: %s (%d Benutzer)\n", s.auth.Path, len(s.auth.List()))
+		if len(s.auth.List()) == 0 {
+			fmt.Println("⚠️  Noch keine Benutzer, niemand kann sich anmelden. Anlegen mit: benlang benutzer anlegen <name> --rolle lehrer")
+		}
+		fmt.Printf("👥 Jeder Benutzer arbeitet in einem eigenen Ordner in %s\n", s.WorkDir)
+	}
+	if s.Examples != "" {
+		fmt.Printf("📚 Beispiele: %s\n", s.Examples)
+	}
+	fmt.Println("Drücke Strg+C zum Beenden")
+
+	go func() {
+		ticker := time.NewTicker(WorkspaceIdle)
+		defer ticker.Stop()
+		for range ticker.C {
+			s.closeIdleWorkspaces()
+		}
+	}()
+
+	if s.TLSCert == "" {
+		return http.ListenAndServe(addr, s.Handler())
+	}
+	if s.HTTPPort != 0 {
+		go func() {
+			if err := http.ListenAndServe(fmt.Sprintf(":%d", s.HTTPPort), redirectToHTTPS(s.port)); err != nil {
+				fmt.Printf("⚠️ Umleitung von HTTP auf HTTPS läuft nicht: %v\n", err)
+			}
+		}()
+	}
+	return http.ListenAndServeTLS(addr, s.TLSCert, s.TLSKey, s.Handler())
+}
+
+// URL returns the address of the IDE on the given host
+func (s *Server) URL(host string) string {
+	if s.TLSCert != "" {
+		return fmt.Sprintf("https://%s:%d", host, s.port)
+	}
+	return fmt.Sprintf("http://%s:%d", host, s.port)
+}
+
+// Handler returns all routes of the server behind the login check
+func (s *Server) Handler() http.Handler {
 	mux := http.NewServeMux()
 
 	// API routes
 	mux.HandleFunc("/api/dateien", s.handleDateien)
 	mux.HandleFunc("/api/datei", s.handleDatei)
+	mux.HandleFunc("/api/datei/umbenennen", s.handleDateiUmbenennen)
+	mux.HandleFunc("/api/datei/verschieben", s.handleDateiVerschieben)
+	mux.HandleFunc("/api/ordner", s.handleOrdner)
+	mux.HandleFunc("/api/papierkorb", s.handlePapierkorb)
+	mux.HandleFunc("/api/papierkorb/wiederherstellen", s.handleWiederherstellen)
+	mux.HandleFunc("/api/verlauf", s.handleVerlauf)
+	mux.HandleFunc("/api/verlauf/datei", s.handleVerlaufDatei)
+	mux.HandleFunc("/api/verlauf/vergleich", s.handleVerlaufVergleich)
+	mux.HandleFunc("/api/verlauf/wiederherstellen", s.handleVerlaufWiederherstellen)
+	mux.HandleFunc("/api/diff", s.handleDiff)
+	mux.HandleFunc("/api/live", s.handleLive)
 	mux.HandleFunc("/api/kompilieren", s.handleKompilieren)
+	mux.HandleFunc("/api/ast", s.handleAST)
+	mux.HandleFunc("/api/bloecke", s.handleBloecke)
+	mux.HandleFunc("/api/vervollstaendigen", s.handleVervollstaendigen)
+	mux.HandleFunc("/api/hover", s.handleHover)
+	mux.HandleFunc("/api/definition", s.handleDefinition)
+	mux.HandleFunc("/api/sprache", s.handleSprache)
+	mux.HandleFunc("/api/umbenennen", s.handleUmbenennen)
 	mux.HandleFunc("/api/bild", s.handleBilder)
+	mux.HandleFunc("/api/medien", s.handleMedien)
+	mux.HandleFunc("/api/export/html", s.handleExportHTML)
 	mux.HandleFunc("/api/hilfe", s.handleHilfe)
 	mux.HandleFunc("/api/login", s.handleLogin)
 	mux.HandleFunc("/api/logout", s.handleLogout)
+	mux.HandleFunc("/api/benutzer", s.handleBenutzer)
+	mux.HandleFunc("/api/benutzer/passwort", s.handleBenutzerPasswort)
+	mux.HandleFunc("/api/benutzer/rolle", s.handleBenutzerRolle)
+	mux.HandleFunc("/api/benutzer/abmelden", s.handleBenutzerAbmelden)
+	mux.HandleFunc("/api/benutzer/gesperrt", s.handleBenutzerGesperrt)
+	mux.HandleFunc("/api/benutzer/entsperren", s.handleBenutzerEntsperren)
+	mux.HandleFunc("/api/lehrer/klasse", s.handleKlasse)
+	mux.HandleFunc("/api/lehrer/verteilen", s.handleVerteilen)
 
 	// Projektverwaltung API
 	mux.HandleFunc("/api/projekte/liste", s.handleProjekteListe)
 	mux.HandleFunc("/api/projekte/neu", s.handleProjekteNeu)
 	mux.HandleFunc("/api/projekte/oeffnen", s.handleProjekteOeffnen)
+	mux.HandleFunc("/api/projekte/beispiele", s.handleBeispiele)
+	mux.HandleFunc("/api/projekte/export", s.handleProjekteExport)
+	mux.HandleFunc("/api/projekte/import", s.handleProjekteImport)
 	mux.HandleFunc("/api/system/info", s.handleSystemInfo)
 
 	// Project files (images, sounds)
@@ -104,20 +272,7 @@ func (s *Server) Start() error {
 		w.Write(content)
 	})
 
-	addr := fmt.Sprintf(":%d", s.port)
-	fmt.Printf("🎮 BenLang Server gestartet auf http://localhost%s\n", addr)
-	if s.project != nil {
-		fmt.Printf("📁 Projekt: %s\n", s.project.Path)
-	} else {
-		fmt.Printf("📁 Kein Projekt geladen (Arbeitsverzeichnis: %s)\n", s.WorkDir)
-	}
-	if s.AuthEnabled {
-		fmt.Println("🔒 Authentifizierung ist AKTIVIERT")
-	}
-	fmt.Println("Drücke Strg+C zum Beenden")
-
-	handler := s.wrapAuth(mux)
-	return http.ListenAndServe(addr, handler)
+	return checkOrigin(s.wrapAuth(mux))
 }
 
 func (s *Server) wrapAuth(next http.Handler) http.Handler {
@@ -144,8 +299,9 @@ func (s *Server) wrapAuth(next http.Handler) http.Handler {
 			return
 		}
 
-		_, authenticated := s.auth.GetUsernameFromSession(cookie.Value)
-		if !authenticated {
+		username, authenticated := s.auth.GetUsernameFromSession(cookie.Value)
+		user, exists := s.auth.Lookup(username)
+		if !authenticated || !exists {
 			if strings.HasPrefix(r.URL.Path, "/api/") {
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			} else {
@@ -154,6 +310,16 @@ func (s *Server) wrapAuth(next http.Handler) http.Handler {
 			return
 		}
 
+		if message := s.forbidden(r, user); message != "" {
+			http.Error(w, message, http.StatusForbidden)
+			return
+		}
+		if !validCSRF(r, cookie.Value) {
+			http.Error(w, "Ungültiges CSRF-Token, bitte lade die Seite neu", http.StatusForbidden)
+			return
+		}
+		s.setCSRFCookie(w, r, cookie.Value)
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -174,10 +340,22 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Guessing passwords gets slower with every wrong one
+	addr := clientAddr(r)
+	if err := s.limiter.Check(req.Username, addr); err != nil {
+		var locked *auth.LockedError
+		if errors.As(err, &locked) {
+			w.Header().Set("Retry-After", strconv.Itoa(int((locked.Wait+time.Second-1)/time.Second)))
+		}
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
 	if !s.auth.Verify(req.Username, req.Password) {
+		s.limiter.Fail(req.Username, addr)
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
+	s.limiter.Succeed(req.Username, addr)
 
 	token, err := s.auth.CreateSession(req.Username)
 	if err != nil {
@@ -185,33 +363,55 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The browser forgets the cookie when the session runs out anyway
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session",
 		Value:    token,
 		Path:     "/",
+		MaxAge:   int(s.auth.MaxAge / time.Second),
 		HttpOnly: true,
-		Secure:   false, // Set to true if using HTTPS
+		Secure:   r.TLS != nil,
 		SameSite: http.SameSiteStrictMode,
 	})
+	s.setCSRFCookie(w, r, token)
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// clientAddr returns the IP address a request came from
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// handleLogout ends the session. Only POST with the CSRF token counts, so
+// a link or image on another website cannot log anyone out.
 func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
 	cookie, err := r.Cookie("session")
 	if err == nil {
 		s.auth.DeleteSession(cookie.Value)
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session",
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-	})
+	for _, name := range []string{"session", csrfCookie} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: name == "session",
+			Secure:   r.TLS != nil,
+		})
+	}
 
-	http.Redirect(w, r, "/login.html", http.StatusSeeOther)
+	w.WriteHeader(http.StatusOK)
 }
 
 func (s *Server) handleDateien(w http.ResponseWriter, r *http.Request) {
@@ -220,10 +420,8 @@ func (s *Server) handleDateien(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	if s.project == nil {
+	ws := s.workspace(r)
+	if ws == nil {
 		response := map[string]interface{}{
 			"projekt": nil,
 			"dateien": []interface{}{},
@@ -233,8 +431,11 @@ func (s *Server) handleDateien(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	files, err := s.project.ListFiles()
-	projectName := s.project.Name
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	files, err := ws.project.ListFiles()
+	projectName := ws.project.Name
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -244,16 +445,32 @@ func (s *Server) handleDateien(w http.ResponseWriter, r *http.Request) {
 	// Load content for .ben files
 	for i, f := range files {
 		if strings.HasSuffix(f.Name, ".ben") {
-			content, err := s.project.ReadFile(f.Name)
+			content, err := ws.project.ReadFile(f.Name)
 			if err == nil {
 				files[i].Content = content
+				files[i].Hash = project.ContentHash(content)
 			}
 		}
 	}
 
+	// Order of the .ben files as the compiler joins them
+	order := []string{}
+	sources := map[string]string{}
+	for _, f := range files {
+		if !f.IsDir && strings.HasSuffix(f.Name, ".ben") {
+			sources[f.Name] = f.Content
+		}
+	}
+	for _, f := range ws.project.AnalysisFiles(sources) {
+		order = append(order, f.Name)
+	}
+
 	response := map[string]interface{}{
-		"projekt": projectName,
-		"dateien": files,
+		"projekt":     projectName,
+		"dateien":     files,
+		"manifest":    ws.project.Manifest,
+		"reihenfolge": order,
+		"nurLesen":    s.readOnly(r),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -270,17 +487,17 @@ func (s *Server) handleDatei(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		s.mu.RLock()
-		defer s.mu.RUnlock()
-
-		if s.project == nil {
+		ws := s.workspace(r)
+		if ws == nil {
 			http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
 			return
 		}
+		ws.mu.RLock()
+		defer ws.mu.RUnlock()
 
-		content, err := s.project.ReadFile(name)
+		content, err := ws.project.ReadFile(name)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			fileError(w, err, http.StatusNotFound)
 			return
 		}
 
@@ -288,30 +505,91 @@ func (s *Server) handleDatei(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(map[string]string{
 			"name":   name,
 			"inhalt": content,
+			"hash":   project.ContentHash(content),
 		})
 
 	case http.MethodPost:
+		// JSON escapes make the body longer than the file
+		r.Body = http.MaxBytesReader(w, r.Body, 2*project.MaxAssetSize)
 		var req struct {
 			Name   string `json:"name"`
 			Inhalt string `json:"inhalt"`
+			// Basis is the hash of the content the editor started from. If
+			// the file changed since, saving is refused unless Erzwingen.
+			Basis     string `json:"basis"`
+			Erzwingen bool   `json:"erzwingen"`
 		}
 
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, fmt.Sprintf("Die Datei ist zu groß, erlaubt sind %d MB", project.MaxAssetSize>>20), http.StatusRequestEntityTooLarge)
+				return
+			}
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		s.mu.Lock()
-		err := s.project.WriteFile(req.Name, req.Inhalt)
-		s.mu.Unlock()
+		ws := s.workspace(r)
+		if ws == nil {
+			http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
+			return
+		}
+		ws.mu.Lock()
+
+		if req.Basis != "" && !req.Erzwingen {
+			current, err := ws.project.ReadFile(req.Name)
+			if err == nil && project.ContentHash(current) != req.Basis {
+				ws.mu.Unlock()
+				s.conflict(w, ws.project, req.Name, current)
+				return
+			}
+		}
+
+		// The manifest is checked and takes effect right away
+		if req.Name == project.ManifestFile {
+			manifest, err := project.ParseManifest([]byte(req.Inhalt), ws.project.Name)
+			if err == nil {
+				err = recordChange(ws.project, "Gespeichert: "+req.Name, func() error {
+					return ws.project.SaveManifest(manifest)
+				})
+			}
+			saved := ""
+			if err == nil {
+				// The manifest is written in its own layout
+				saved, _ = ws.project.ReadFile(project.ManifestFile)
+			}
+			ws.mu.Unlock()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"erfolg": true,
+				"hash":   project.ContentHash(saved),
+			})
+			return
+		}
+
+		err := recordChange(ws.project, "Gespeichert: "+req.Name, func() error {
+			return ws.project.SaveText(req.Name, req.Inhalt)
+		})
+		ws.mu.Unlock()
 
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			fileError(w, err, http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]bool{"erfolg": true})
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"erfolg": true,
+			"hash":   project.ContentHash(req.Inhalt),
+		})
+
+	case http.MethodDelete:
+		s.handleDateiLoeschen(w, r)
 
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -326,7 +604,9 @@ func (s *Server) handleKompilieren(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Code string `json:"code"`
+		Code    string            `json:"code"`
+		AST     json.RawMessage   `json:"ast,omitempty"`
+		Dateien map[string]string `json:"dateien,omitempty"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -334,6 +614,64 @@ func (s *Server) handleKompilieren(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The IDE sends all files; they are joined in the order of the manifest
+	if len(req.Dateien) > 0 {
+		manifest := project.DefaultManifest("")
+		warnings := []string{}
+		ws := s.workspace(r)
+		if ws != nil {
+			ws.mu.RLock()
+			manifest = ws.project.Manifest
+			files := project.OrderFiles(req.Dateien, manifest)
+			warnings = compileWarnings(ws.project, files)
+			ws.mu.RUnlock()
+		}
+
+		js, errs := compiler.Compile(project.OrderFiles(req.Dateien, manifest))
+		messages := make([]string, len(errs))
+		for i, e := range errs {
+			messages[i] = e.Error()
+		}
+
+		// Teachers see in the class overview how the last start went
+		if ws != nil && !s.readOnly(r) {
+			ws.mu.Lock()
+			ws.compiled = &compileRecord{Time: time.Now(), Errors: messages}
+			ws.mu.Unlock()
+		}
+		if s.othersProject(r) {
+			js = ""
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"fehler":    messages,
+			"warnungen": warnings,
+			"js":        js,
+		})
+		return
+	}
+
+	// Tools such as a block editor may send an AST instead of source code
+	if len(req.AST) > 0 {
+		program, err := parser.DecodeJSON(req.AST)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"fehler": []string{err.Error()},
+				"js":     "",
+			})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"fehler": []string{},
+			"js":     transpiler.New().Transpile(program),
+		})
+		return
+	}
+
 	// Lexer
 	l := lexer.New(req.Code)
 
@@ -362,77 +700,206 @@ func (s *Server) handleKompilieren(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// handleBilder handles image uploads
-func (s *Server) handleBilder(w http.ResponseWriter, r *http.Request) {
+// handleAST returns the syntax tree of BenLang code as JSON
+func (s *Server) handleAST(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if s.project == nil {
-		http.Error(w, "Kein Projekt geladen", http.StatusForbidden)
-		return
+	var req struct {
+		Code string `json:"code"`
 	}
 
-	// Parse multipart form (max 10MB)
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	file, header, err := r.FormFile("bild")
+	p := parser.New(lexer.New(req.Code))
+	program := p.ParseProgram()
+
+	ast, err := parser.EncodeJSON(program)
 	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"fehler": p.ErrorList(),
+		"ast":    json.RawMessage(ast),
+	})
+}
+
+// handleBloecke converts between BenLang code and a block workspace.
+// A request with "code" returns the blocks, a request with "bloecke"
+// returns the code.
+func (s *Server) handleBloecke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Code    *string           `json:"code"`
+		Bloecke *blocks.Workspace `json:"bloecke"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer file.Close()
 
-	// Read file content
-	content, err := io.ReadAll(file)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	w.Header().Set("Content-Type", "application/json")
+
+	if req.Bloecke != nil {
+		code, err := blocks.ToSource(req.Bloecke)
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"fehler": []parser.ParseError{{Message: err.Error()}},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"fehler": []parser.ParseError{},
+			"code":   code,
+		})
 		return
 	}
 
-	// Save to project
-	err = s.project.SaveImage(header.Filename, content)
+	if req.Code == nil {
+		http.Error(w, "code oder bloecke fehlt", http.StatusBadRequest)
+		return
+	}
+
+	ws, errs := blocks.FromSource(*req.Code)
+	if len(errs) > 0 {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"fehler": errs,
+		})
+		return
+	}
 
+	// The blocks reproduce the formatted code; verlustfrei tells the IDE
+	// whether switching views leaves the text untouched
+	code, _ := blocks.ToSource(ws)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"fehler":      []parser.ParseError{},
+		"bloecke":     ws,
+		"code":        code,
+		"verlustfrei": code == *req.Code,
+	})
+}
+
+// handleBilder handles image uploads
+func (s *Server) handleBilder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The upload is read before the project is locked, so a slow one does
+	// not hold up everyone else working on it
+	filename, content, ok := readUpload(w, r, "bild")
+	if !ok {
+		return
+	}
+
+	ws := s.workspace(r)
+	if ws == nil {
+		http.Error(w, "Kein Projekt geladen", http.StatusForbidden)
+		return
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	var path string
+	err := recordChange(ws.project, "Hochgeladen: "+filename, func() (err error) {
+		path, err = ws.project.SaveImage(filename, content)
+		return err
+	})
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		fileError(w, err, http.StatusBadRequest)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"erfolg": true,
-		"pfad":   "bilder/" + header.Filename,
+		"pfad":   path,
 	})
 }
 
+// fileError reports a failed file operation; paths leading out of the
+// project are forbidden, missing files not found, taken names a conflict
+// and uploads above a quota too large
+func fileError(w http.ResponseWriter, err error, status int) {
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		status = http.StatusForbidden
+	case errors.Is(err, fs.ErrNotExist):
+		status = http.StatusNotFound
+	case errors.Is(err, fs.ErrExist):
+		status = http.StatusConflict
+	case errors.Is(err, project.ErrTooLarge):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, fs.ErrInvalid):
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// handleExportHTML downloads the game as a single HTML file that runs
+// without the server
+func (s *Server) handleExportHTML(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ws := s.workspace(r)
+	if ws == nil {
+		http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
+		return
+	}
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	page, err := export.HTML(ws.project)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": ws.project.Name + ".html"}))
+	w.Write(page)
+}
+
 // handleProjektDateien serves project files (images, sounds)
 func (s *Server) handleProjektDateien(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/projekt/")
 
-	// Security: prevent path traversal
-	cleanPath := filepath.Clean(path)
-	if strings.Contains(cleanPath, "..") {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+	ws := s.workspace(r)
+	if ws == nil {
+		http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
 		return
 	}
 
-	s.mu.RLock()
-	content, err := s.project.ReadFile(cleanPath)
-	if err != nil {
-		// Try in bilder/ directory
-		content, err = s.project.ReadFile(filepath.Join("bilder", cleanPath))
+	ws.mu.RLock()
+	// The project refuses paths outside of it, including symbolic links
+	content, err := ws.project.ReadFile(path)
+	for _, folder := range project.AssetFolders {
+		if !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		content, err = ws.project.ReadFile(filepath.Join(folder, path))
 	}
-	s.mu.RUnlock()
+	ws.mu.RUnlock()
 
 	if err != nil {
-		http.NotFound(w, r)
+		fileError(w, err, http.StatusNotFound)
 		return
 	}
 
@@ -497,31 +964,75 @@ func (s *Server) handleHilfe(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// handleProjekteListe returns a list of directories in WorkDir
+// handleProjekteListe returns the projects of the user: the folders in
+// WorkDir, or with login in the user's own folder. Teachers may ask for
+// the projects of a student with ?benutzer=.
 func (s *Server) handleProjekteListe(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	entries, err := os.ReadDir(s.WorkDir)
+	home, err := s.homeDir(r)
+	status := http.StatusUnauthorized
+	if student := r.URL.Query().Get("benutzer"); student != "" {
+		// Teachers look into a student's folder
+		home, err = s.studentDir(r, student)
+		status = http.StatusForbidden
+	}
+	if err != nil {
+		fileError(w, err, status)
+		return
+	}
+	projekte, err := listProjects(home)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	var projekte []string
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(projekte)
+}
+
+// handleBeispiele lists the shared examples, which can be copied into a
+// new project but not changed
+func (s *Server) handleBeispiele(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	beispiele := []string{}
+	if s.Examples != "" {
+		var err error
+		if beispiele, err = listProjects(s.Examples); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(beispiele)
+}
+
+// listProjects returns the names of the visible folders in dir
+func listProjects(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	projekte := []string{}
 	for _, entry := range entries {
 		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
 			projekte = append(projekte, entry.Name())
 		}
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(projekte)
+	return projekte, nil
 }
 
-// handleProjekteNeu creates a new project
+// handleProjekteNeu creates a new project, empty or as a copy of an
+// example
 func (s *Server) handleProjekteNeu(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -529,7 +1040,8 @@ func (s *Server) handleProjekteNeu(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Name string `json:"name"`
+		Name     string `json:"name"`
+		Beispiel string `json:"beispiel"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -537,35 +1049,63 @@ func (s *Server) handleProjekteNeu(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Security: check name
-	if req.Name == "" || strings.Contains(req.Name, "..") || strings.Contains(req.Name, "/") || strings.Contains(req.Name, "\\") {
+	// Security: the name must be a folder directly inside WorkDir
+	if !project.ValidName(req.Name) {
 		http.Error(w, "Ungültiger Projektname", http.StatusBadRequest)
 		return
 	}
 
-	projectPath := filepath.Join(s.WorkDir, req.Name)
+	home, err := s.homeDir(r)
+	if err != nil {
+		fileError(w, err, http.StatusUnauthorized)
+		return
+	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	var example *project.Project
+	if req.Beispiel != "" {
+		if s.Examples == "" {
+			http.Error(w, "Es gibt keine Beispiele", http.StatusNotFound)
+			return
+		}
+		if example, err = project.InWorkDir(s.Examples, req.Beispiel, false); err != nil {
+			fileError(w, err, http.StatusBadRequest)
+			return
+		}
+		// An example is only copied into a new folder
+		if _, err := project.InWorkDir(home, req.Name, false); err == nil {
+			http.Error(w, fmt.Sprintf("Das Projekt '%s' gibt es schon", req.Name), http.StatusConflict)
+			return
+		}
+	}
 
-	proj, err := project.New(projectPath)
+	ws, err := s.openWorkspace(home, req.Name, true)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		fileError(w, err, http.StatusInternalServerError)
 		return
 	}
 
-	if err := proj.CreateDefaultProject(); err != nil {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if example != nil {
+		err = ws.project.CopyFrom(example.Path)
+		if err == nil {
+			err = ws.project.ReloadManifest()
+		}
+	} else {
+		err = ws.project.CreateDefaultProject()
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	s.project = proj
-
+	setProject(w, r, ws.project.Name)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{"erfolg": true, "projekt": s.project.Name})
+	json.NewEncoder(w).Encode(map[string]interface{}{"erfolg": true, "projekt": ws.project.Name})
 }
 
-// handleProjekteOeffnen switches to an existing project
+// handleProjekteOeffnen switches the session to an existing project
 func (s *Server) handleProjekteOeffnen(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -573,7 +1113,8 @@ func (s *Server) handleProjekteOeffnen(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Name string `json:"name"`
+		Name     string `json:"name"`
+		Benutzer string `json:"benutzer"` // teachers open a student's project to look at it
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -581,27 +1122,115 @@ func (s *Server) handleProjekteOeffnen(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Security: check name
-	if req.Name == "" || strings.Contains(req.Name, "..") || strings.Contains(req.Name, "/") || strings.Contains(req.Name, "\\") {
+	// Security: the name must be a folder directly inside WorkDir
+	if !project.ValidName(req.Name) {
 		http.Error(w, "Ungültiger Projektname", http.StatusBadRequest)
 		return
 	}
 
-	projectPath := filepath.Join(s.WorkDir, req.Name)
+	name := req.Name
+	if req.Benutzer != "" {
+		name = req.Benutzer + "/" + req.Name
+	}
+	home, base, err := s.projectDir(r, name)
+	if err != nil {
+		fileError(w, err, http.StatusForbidden)
+		return
+	}
+	ws, err := s.openWorkspace(home, base, false)
+	if err != nil {
+		fileError(w, err, http.StatusInternalServerError)
+		return
+	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	// Only this browser switches; others keep working on their project
+	setProject(w, r, name)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"erfolg": true, "projekt": ws.project.Name, "nurLesen": req.Benutzer != ""})
+}
 
-	proj, err := project.New(projectPath)
-	if err != nil {
+// handleProjekteExport downloads a project as a ZIP file. Without a name
+// the open project is exported.
+func (s *Server) handleProjekteExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ws := s.workspace(r)
+	if name := r.URL.Query().Get("name"); name != "" {
+		if !project.ValidName(name) {
+			http.Error(w, "Ungültiger Projektname", http.StatusBadRequest)
+			return
+		}
+		home, err := s.homeDir(r)
+		if err != nil {
+			fileError(w, err, http.StatusUnauthorized)
+			return
+		}
+		if ws, err = s.openWorkspace(home, name, false); err != nil {
+			fileError(w, err, http.StatusNotFound)
+			return
+		}
+	}
+	if ws == nil {
+		http.Error(w, "Kein Projekt geladen", http.StatusNotFound)
+		return
+	}
+
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+	proj := ws.project
+
+	var buf bytes.Buffer
+	if err := proj.WriteZip(&buf); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	s.project = proj
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": proj.Name + ".zip"}))
+	w.Write(buf.Bytes())
+}
+
+// handleProjekteImport unpacks an uploaded ZIP file as a new project in
+// WorkDir
+func (s *Server) handleProjekteImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, project.MaxZipSize+1<<20)
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		http.Error(w, "Die ZIP-Datei ist zu groß oder fehlt", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("datei")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	home, err := s.homeDir(r)
+	if err != nil {
+		fileError(w, err, http.StatusUnauthorized)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
+	proj, err := project.ImportZip(file, header.Size, home, header.Filename)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{"erfolg": true, "projekt": s.project.Name})
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"erfolg": false, "fehler": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"erfolg": true, "projekt": proj.Name})
 }
 
 // handleSystemInfo returns information about the server
@@ -611,16 +1240,20 @@ func (s *Server) handleSystemInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.mu.RLock()
 	var projectName interface{} = nil
-	if s.project != nil {
-		projectName = s.project.Name
+	if ws := s.workspace(r); ws != nil {
+		projectName = ws.project.Name
+	}
+	home, err := s.homeDir(r)
+	if err != nil {
+		home = s.WorkDir
 	}
-	s.mu.RUnlock()
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"projekt": projectName,
-		"workdir": s.WorkDir,
+		"projekt":  projectName,
+		"workdir":  home,
+		"benutzer": s.user(r),
+		"rolle":    s.role(r),
 	})
 }
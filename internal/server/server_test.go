@@ -0,0 +1,143 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportHTML(t *testing.T) {
+	s := newTestServer(t, map[string]string{"hauptspiel.ben": "SPIEL \"Test\"\n"})
+
+	rec := httptest.NewRecorder()
+	s.handleExportHTML(rec, httptest.NewRequest(http.MethodGet, "/api/export/html", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	if cd := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment;") || !strings.Contains(cd, ".html") {
+		t.Errorf("Content-Disposition = %q", cd)
+	}
+	if !strings.Contains(rec.Body.String(), `_benlang.spielName = "Test";`) {
+		t.Error("export does not contain the game")
+	}
+
+	s = newTestServer(t, map[string]string{"hauptspiel.ben": "VAR = 1\n"})
+	rec = httptest.NewRecorder()
+	s.handleExportHTML(rec, httptest.NewRequest(http.MethodGet, "/api/export/html", nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status %d for a broken game, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestProjekteExportImport(t *testing.T) {
+	s := newTestServer(t, map[string]string{"hauptspiel.ben": "VAR x = 1\n"})
+	s.WorkDir = t.TempDir()
+
+	rec := httptest.NewRecorder()
+	s.handleProjekteExport(rec, httptest.NewRequest(http.MethodGet, "/api/projekte/export", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("export: status %d, %s", rec.Code, rec.Body.String())
+	}
+	archive := rec.Body.Bytes()
+
+	rec = httptest.NewRecorder()
+	s.handleProjekteExport(rec, httptest.NewRequest(http.MethodGet, "/api/projekte/export?name=../etc", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("export with traversal: status %d", rec.Code)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("datei", "spiel.zip")
+	part.Write(archive)
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/projekte/import", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec = httptest.NewRecorder()
+	s.handleProjekteImport(rec, req)
+
+	var resp struct {
+		Erfolg  bool   `json:"erfolg"`
+		Projekt string `json:"projekt"`
+		Fehler  string `json:"fehler"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("import: %v (%s)", err, rec.Body.String())
+	}
+	if !resp.Erfolg {
+		t.Fatalf("import failed: %s", resp.Fehler)
+	}
+	code, err := os.ReadFile(filepath.Join(s.WorkDir, resp.Projekt, "hauptspiel.ben"))
+	if err != nil || string(code) != "VAR x = 1\n" {
+		t.Errorf("imported hauptspiel.ben = %q, %v", code, err)
+	}
+}
+
+func TestKompilierenDateien(t *testing.T) {
+	s := newTestServer(t, map[string]string{
+		"projekt.json": `{"einstieg": "start.ben", "dateien": ["b.ben", "a.ben"]}`,
+	})
+
+	var resp struct {
+		Fehler []string `json:"fehler"`
+		JS     string   `json:"js"`
+	}
+	postJSON(t, s.handleKompilieren, map[string]interface{}{
+		"dateien": map[string]string{
+			"a.ben":     "VAR a = 1\n",
+			"b.ben":     "VAR b = 2\n",
+			"start.ben": "VAR start = 0\n",
+		},
+	}, &resp)
+	if len(resp.Fehler) > 0 {
+		t.Fatalf("unexpected errors: %v", resp.Fehler)
+	}
+	start, b, a := strings.Index(resp.JS, "var start"), strings.Index(resp.JS, "var b"), strings.Index(resp.JS, "var a")
+	if !(start < b && b < a) {
+		t.Errorf("files not joined in manifest order:\n%s", resp.JS)
+	}
+
+	postJSON(t, s.handleKompilieren, map[string]interface{}{
+		"dateien": map[string]string{"start.ben": "VAR x = 1\n", "a.ben": "\nVAR = 2\n"},
+	}, &resp)
+	if len(resp.Fehler) == 0 || !strings.HasPrefix(resp.Fehler[0], "a.ben, Zeile 2:") {
+		t.Errorf("errors = %v, want a position in a.ben", resp.Fehler)
+	}
+}
+
+func TestKompilierenASTFehler(t *testing.T) {
+	s := newTestServer(t, map[string]string{"hauptspiel.ben": ""})
+
+	var resp struct {
+		Fehler []string `json:"fehler"`
+		JS     string   `json:"js"`
+	}
+	postJSON(t, s.handleKompilieren, map[string]interface{}{
+		"ast": json.RawMessage(`{"version": 1, "program": {"type": "Program", "statements": [{"type": "Zauberei"}]}}`),
+	}, &resp)
+	if len(resp.Fehler) != 1 || resp.JS != "" {
+		t.Errorf("fehler = %v, js = %q; want one message and no code", resp.Fehler, resp.JS)
+	}
+}
+
+func TestKompilierenWarntVorKaputtemManifest(t *testing.T) {
+	s := newTestServer(t, map[string]string{"projekt.json": `{"einstieg": `})
+
+	var resp struct {
+		Fehler    []string `json:"fehler"`
+		Warnungen []string `json:"warnungen"`
+	}
+	postJSON(t, s.handleKompilieren, map[string]interface{}{
+		"dateien": map[string]string{"hauptspiel.ben": "VAR x = 1\n"},
+	}, &resp)
+	if len(resp.Fehler) > 0 || len(resp.Warnungen) != 1 || !strings.Contains(resp.Warnungen[0], "projekt.json ist fehlerhaft") {
+		t.Errorf("fehler = %v, warnungen = %v", resp.Fehler, resp.Warnungen)
+	}
+}
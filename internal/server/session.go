@@ -0,0 +1,218 @@
+package server
+
+import (
+	"benlang/internal/auth"
+	"benlang/internal/project"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// projectCookie names the project a browser works on. A "projekt"
+// parameter in the URL takes precedence, so two tabs can work on
+// different projects.
+const projectCookie = "projekt"
+
+// workspace is a project in use together with its lock. Children working
+// on different projects do not wait for each other, and everyone working
+// on the same project shares one manifest and one lock.
+type workspace struct {
+	mu       sync.RWMutex
+	project  *project.Project
+	compiled *compileRecord // last start of the game in the IDE
+
+	// Guarded by Server.mu, so that closeIdleWorkspaces knows when nobody
+	// works on the project any more
+	users map[string]bool // who opened it; "" without login
+	used  time.Time
+}
+
+// workspace returns the project of the request: the one named in the URL
+// or the session cookie, or else the project the server was started with.
+// With login, names are looked up in the user's own folder and the start
+// project is not shared. It returns nil if there is none.
+func (s *Server) workspace(r *http.Request) *workspace {
+	if name := projectName(r); name != "" {
+		dir, name, err := s.projectDir(r, name)
+		if err != nil {
+			return nil
+		}
+		ws, err := s.openWorkspace(dir, name, false)
+		if err != nil {
+			return nil
+		}
+		user := s.user(r)
+		s.mu.Lock()
+		ws.users[user] = true
+		s.mu.Unlock()
+		return ws
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.project == nil || s.AuthEnabled {
+		return nil
+	}
+	return s.cachedWorkspace(s.project)
+}
+
+// projectName returns the project named in the URL or the session cookie
+func projectName(r *http.Request) string {
+	if name := r.URL.Query().Get(projectCookie); name != "" {
+		return name
+	}
+	if cookie, err := r.Cookie(projectCookie); err == nil {
+		name, _ := url.QueryUnescape(cookie.Value)
+		return name
+	}
+	return ""
+}
+
+// projectDir finds the folder that holds the named project. Teachers name
+// a student's project as "<benutzer>/<projekt>".
+func (s *Server) projectDir(r *http.Request, name string) (dir, base string, err error) {
+	owner, base, ok := strings.Cut(name, "/")
+	if !ok {
+		dir, err := s.homeDir(r)
+		return dir, name, err
+	}
+
+	dir, err = s.studentDir(r, owner)
+	return dir, base, err
+}
+
+// studentDir returns the folder of another user. Only teachers may look
+// into it.
+func (s *Server) studentDir(r *http.Request, owner string) (string, error) {
+	if s.role(r) != auth.RoleTeacher {
+		return "", errTeachersOnly
+	}
+	if _, exists := s.auth.Lookup(owner); !exists {
+		return "", fmt.Errorf("Den Benutzer '%s' gibt es nicht", owner)
+	}
+	return project.HomeDir(s.WorkDir, owner)
+}
+
+// user returns the name of the logged-in user, or "" without login
+func (s *Server) user(r *http.Request) string {
+	if !s.AuthEnabled || s.auth == nil {
+		return ""
+	}
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		return ""
+	}
+	name, _ := s.auth.GetUsernameFromSession(cookie.Value)
+	return name
+}
+
+// homeDir returns the folder with the projects of the request's user.
+// Without login everyone shares WorkDir; with login every user gets a
+// folder of their own inside it.
+func (s *Server) homeDir(r *http.Request) (string, error) {
+	if !s.AuthEnabled {
+		return s.WorkDir, nil
+	}
+	user := s.user(r)
+	if user == "" {
+		return "", errNotLoggedIn
+	}
+	return project.HomeDir(s.WorkDir, user)
+}
+
+var errNotLoggedIn = errors.New("Nicht angemeldet")
+
+// openWorkspace opens a project in dir, creating its folder if create is
+// set
+func (s *Server) openWorkspace(dir, name string, create bool) (*workspace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if dir != "" && project.ValidName(name) {
+		if ws := s.workspaces[filepath.Join(dir, name)]; ws != nil {
+			return ws, nil
+		}
+	}
+	proj, err := project.InWorkDir(dir, name, create)
+	if err != nil {
+		return nil, err
+	}
+	return s.cachedWorkspace(proj), nil
+}
+
+// cachedWorkspace returns the workspace of the project's folder, so that
+// a project opened twice is still locked once. The caller holds s.mu.
+func (s *Server) cachedWorkspace(proj *project.Project) *workspace {
+	if s.workspaces == nil {
+		s.workspaces = map[string]*workspace{}
+	}
+	ws := s.workspaces[proj.Path]
+	if ws == nil {
+		ws = &workspace{project: proj, users: map[string]bool{}}
+		s.workspaces[proj.Path] = ws
+	}
+	ws.used = time.Now()
+	return ws
+}
+
+// WorkspaceIdle is how long an open project is kept after its last use
+// once nobody who opened it is logged in and no IDE watches it
+var WorkspaceIdle = 10 * time.Minute
+
+// closeIdleWorkspaces forgets the projects nobody works on any more, so
+// that a server running for weeks does not keep every project ever opened.
+// Requests still working on a project used it less than WorkspaceIdle
+// ago, so they never hold a lock that a new workspace no longer shares.
+// The start project stays. It returns how many were closed.
+func (s *Server) closeIdleWorkspaces() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	closed := 0
+	for path, ws := range s.workspaces {
+		if ws.project == s.project || time.Since(ws.used) < WorkspaceIdle || s.watched(path) {
+			continue
+		}
+		if s.AuthEnabled && s.anyLoggedIn(ws.users) {
+			continue
+		}
+		delete(s.workspaces, path)
+		closed++
+	}
+	return closed
+}
+
+// watched reports whether an IDE listens to the changes of a project
+func (s *Server) watched(path string) bool {
+	h := s.live()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.projects[path] != nil
+}
+
+// anyLoggedIn reports whether one of the users still has a session
+func (s *Server) anyLoggedIn(users map[string]bool) bool {
+	for user := range users {
+		if s.auth.CountSessions(user) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// setProject remembers the project the browser works on
+func setProject(w http.ResponseWriter, r *http.Request, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     projectCookie,
+		Value:    url.QueryEscape(name),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"benlang/internal/auth"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// serveAs sends a request with the cookies a browser got before. Like the
+// IDE's pages it sends the CSRF token from its cookie along.
+func serveAs(handler http.HandlerFunc, cookies []*http.Cookie, method, target string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	for _, c := range cookies {
+		req.AddCookie(c)
+		if c.Name == csrfCookie {
+			req.Header.Set(csrfHeader, c.Value)
+		}
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func openedProject(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var resp struct {
+		Projekt string `json:"projekt"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%v: %s", err, rec.Body.String())
+	}
+	return resp.Projekt
+}
+
+func TestSessionsWorkOnTheirOwnProject(t *testing.T) {
+	s := newTestServer(t, nil, withSandbox)
+	workDir := s.WorkDir
+	for _, name := range []string{"anna", "über mir"} {
+		os.MkdirAll(filepath.Join(workDir, name), 0755)
+	}
+
+	open := func(name string) []*http.Cookie {
+		body, _ := json.Marshal(map[string]string{"name": name})
+		rec := serve(s.handleProjekteOeffnen, http.MethodPost, "/api/projekte/oeffnen", body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("open %s: status %d: %s", name, rec.Code, rec.Body.String())
+		}
+		return rec.Result().Cookies()
+	}
+	anna := open("anna")
+	other := open("über mir")
+
+	body, _ := json.Marshal(map[string]string{"name": "hauptspiel.ben", "inhalt": "VAR anna = 1\n"})
+	if rec := serveAs(s.handleDatei, anna, http.MethodPost, "/api/datei", body); rec.Code != http.StatusOK {
+		t.Fatalf("save: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if data, err := os.ReadFile(filepath.Join(workDir, "anna", "hauptspiel.ben")); err != nil || string(data) != "VAR anna = 1\n" {
+		t.Errorf("saved into anna: %q, %v", data, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "spiel", "hauptspiel.ben")); string(data) != "VAR x = 1\n" {
+		t.Errorf("start project changed to %q", data)
+	}
+
+	if name := openedProject(t, serveAs(s.handleDateien, other, http.MethodGet, "/api/dateien", nil)); name != "über mir" {
+		t.Errorf("second session works on %q", name)
+	}
+	if name := openedProject(t, serveAs(s.handleDateien, anna, http.MethodGet, "/api/dateien", nil)); name != "anna" {
+		t.Errorf("first session works on %q", name)
+	}
+	if name := openedProject(t, serve(s.handleDateien, http.MethodGet, "/api/dateien", nil)); name != "spiel" {
+		t.Errorf("new session works on %q, want the start project", name)
+	}
+	if name := openedProject(t, serveAs(s.handleDateien, anna, http.MethodGet, "/api/dateien?projekt=spiel", nil)); name != "spiel" {
+		t.Errorf("URL parameter gives %q, want spiel", name)
+	}
+
+	// Sessions on the same project share its lock and manifest
+	first, _ := s.openWorkspace(workDir, "anna", false)
+	second, _ := s.openWorkspace(workDir, "anna", false)
+	if first == nil || first != second {
+		t.Error("project opened twice has two workspaces")
+	}
+}
+
+func TestIdleWorkspacesAreClosed(t *testing.T) {
+	s := newTestServer(t, nil, withUsers)
+	handler := s.Handler().ServeHTTP
+	anna := login(t, s, "anna")
+	if rec := serveAs(handler, anna, http.MethodGet, "/api/dateien?projekt=rennspiel", nil); rec.Code != http.StatusOK {
+		t.Fatalf("open: status %d: %s", rec.Code, rec.Body.String())
+	}
+	ws := s.workspaces[filepath.Join(s.WorkDir, "anna", "rennspiel")]
+	if ws == nil {
+		t.Fatal("no workspace for the opened project")
+	}
+
+	if n := s.closeIdleWorkspaces(); n != 0 {
+		t.Errorf("closed %d workspaces used just now", n)
+	}
+
+	old := WorkspaceIdle
+	WorkspaceIdle = 0
+	defer func() { WorkspaceIdle = old }()
+	if n := s.closeIdleWorkspaces(); n != 0 {
+		t.Errorf("closed %d workspaces while anna is logged in", n)
+	}
+
+	_, unsubscribe := s.subscribe(ws)
+	s.auth.RevokeSessions("anna")
+	if n := s.closeIdleWorkspaces(); n != 0 {
+		t.Errorf("closed %d workspaces an IDE watches", n)
+	}
+	unsubscribe()
+	if n := s.closeIdleWorkspaces(); n != 1 || len(s.workspaces) != 0 {
+		t.Errorf("closed %d workspaces, %d left", n, len(s.workspaces))
+	}
+}
+
+func TestSessionCookieStaysInWorkDir(t *testing.T) {
+	s := newTestServer(t, nil, withSandbox)
+
+	for _, name := range []string{"..", "../draussen", "verlinkt", "spiel/bilder", "fehlt"} {
+		cookies := []*http.Cookie{{Name: projectCookie, Value: name}}
+		rec := serveAs(s.handleDatei, cookies, http.MethodGet, "/api/datei?pfad=geheim.png", nil)
+		refused(t, "cookie "+name, rec)
+		rec = serveAs(s.handleDatei, nil, http.MethodGet, "/api/datei?pfad=geheim.ben&projekt="+name, nil)
+		refused(t, "URL "+name, rec)
+	}
+}
+
+func login(t *testing.T, s *Server, user string) []*http.Cookie {
+	token, err := s.auth.CreateSession(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []*http.Cookie{{Name: "session", Value: token}, {Name: csrfCookie, Value: auth.CSRFToken(token)}}
+}
+
+func TestUsersOnlySeeTheirOwnProjects(t *testing.T) {
+	s := newTestServer(t, nil, withClass)
+	workDir := s.WorkDir
+	anna, ben := login(t, s, "anna"), login(t, s, "ben")
+
+	list := func(cookies []*http.Cookie) []string {
+		rec := serveAs(s.handleProjekteListe, cookies, http.MethodGet, "/api/projekte/liste", nil)
+		var projects []string
+		if err := json.Unmarshal(rec.Body.Bytes(), &projects); err != nil {
+			t.Fatalf("%v: %s", err, rec.Body.String())
+		}
+		return projects
+	}
+	if projects := list(anna); len(projects) != 1 || projects[0] != "rennspiel" {
+		t.Errorf("anna sees %v", projects)
+	}
+	if projects := list(ben); len(projects) != 0 {
+		t.Errorf("ben sees %v", projects)
+	}
+
+	body, _ := json.Marshal(map[string]string{"name": "rennspiel"})
+	if rec := serveAs(s.handleProjekteNeu, ben, http.MethodPost, "/api/projekte/neu", body); rec.Code != http.StatusOK {
+		t.Fatalf("ben creates rennspiel: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "ben", "rennspiel", "hauptspiel.ben")); err != nil {
+		t.Errorf("ben's project not in his folder: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, "anna", "rennspiel", "hauptspiel.ben")); err == nil {
+		t.Error("ben's new project went into anna's folder")
+	}
+
+	// Nobody reaches another user's projects, neither by opening nor by cookie
+	for _, name := range []string{"../anna/rennspiel", "anna"} {
+		body, _ := json.Marshal(map[string]string{"name": name})
+		refused(t, "open "+name, serveAs(s.handleProjekteOeffnen, ben, http.MethodPost, "/api/projekte/oeffnen", body))
+		cookies := append([]*http.Cookie{{Name: projectCookie, Value: name}}, ben...)
+		refused(t, "cookie "+name, serveAs(s.handleDatei, cookies, http.MethodGet, "/api/datei?pfad=hauptspiel.ben", nil))
+	}
+	refused(t, "export", serveAs(s.handleProjekteExport, ben, http.MethodGet, "/api/projekte/export?name=anna", nil))
+
+	// Users whose name is no folder name get no folder
+	refused(t, "odd user", serveAs(s.handleProjekteListe, login(t, s, "../anna"), http.MethodGet, "/api/projekte/liste", nil))
+}
+
+func TestExamplesAreCopied(t *testing.T) {
+	s := newTestServer(t, nil, withClass)
+	workDir, examples := s.WorkDir, s.Examples
+	ben := login(t, s, "ben")
+
+	rec := serveAs(s.handleBeispiele, ben, http.MethodGet, "/api/projekte/beispiele", nil)
+	if rec.Body.String() != "[\"pong\"]\n" {
+		t.Errorf("examples = %s", rec.Body.String())
+	}
+
+	body, _ := json.Marshal(map[string]string{"name": "mein-pong", "beispiel": "pong"})
+	rec = serveAs(s.handleProjekteNeu, ben, http.MethodPost, "/api/projekte/neu", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("copy example: status %d: %s", rec.Code, rec.Body.String())
+	}
+	cookies := append(rec.Result().Cookies(), ben...)
+
+	body, _ = json.Marshal(map[string]string{"name": "hauptspiel.ben", "inhalt": "VAR ball = 2\n"})
+	if rec := serveAs(s.handleDatei, cookies, http.MethodPost, "/api/datei", body); rec.Code != http.StatusOK {
+		t.Fatalf("save: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "ben", "mein-pong", "hauptspiel.ben")); string(data) != "VAR ball = 2\n" {
+		t.Errorf("copy = %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(examples, "pong", "hauptspiel.ben")); string(data) != "VAR ball = 1\n" {
+		t.Errorf("example changed to %q", data)
+	}
+
+	// Copies never overwrite a project, and only examples can be copied
+	body, _ = json.Marshal(map[string]string{"name": "mein-pong", "beispiel": "pong"})
+	refused(t, "copy over project", serveAs(s.handleProjekteNeu, ben, http.MethodPost, "/api/projekte/neu", body))
+	body, _ = json.Marshal(map[string]string{"name": "geklaut", "beispiel": "../klasse/anna/rennspiel"})
+	refused(t, "copy other project", serveAs(s.handleProjekteNeu, ben, http.MethodPost, "/api/projekte/neu", body))
+}
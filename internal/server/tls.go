@@ -0,0 +1,187 @@
+package server
+
+import (
+	"benlang/internal/auth"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	certFile = "benlang-zertifikat.pem"
+	keyFile  = "benlang-schluessel.pem"
+)
+
+// certValidity is how long a generated certificate is valid. Browsers
+// ask about self-signed certificates anyway, so it is long.
+const certValidity = 5 * 365 * 24 * time.Hour
+
+// SelfSignedCertificate returns a certificate for HTTPS without any
+// outside service. It is created in dir on first use for localhost, the
+// computer's name and addresses and the extra hosts. It is kept as long as
+// it is valid, also when the addresses change, because every new
+// certificate has to be trusted in the browsers again. A new one is only
+// created when it runs out or one of the extra hosts is missing.
+func SelfSignedCertificate(dir string, extra []string) (cert, key string, err error) {
+	cert, key = filepath.Join(dir, certFile), filepath.Join(dir, keyFile)
+	if certificateCovers(cert, key, extra, time.Now()) {
+		return cert, key, nil
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", err
+	}
+	certPEM, keyPEM, err := generateCertificate(append(localHosts(), extra...), time.Now())
+	if err != nil {
+		return "", "", err
+	}
+	// A crash between the two leaves a pair that does not match, which
+	// certificateCovers rejects on the next start
+	if err := auth.WriteFileAtomic(key, keyPEM, 0600); err != nil {
+		return "", "", err
+	}
+	if err := auth.WriteFileAtomic(cert, certPEM, 0644); err != nil {
+		return "", "", err
+	}
+	return cert, key, nil
+}
+
+// localHosts returns the names and addresses this computer is reached
+// under: localhost, its name and the addresses of its network cards
+func localHosts() []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "" {
+		hosts = append(hosts, name)
+	}
+	addrs, _ := net.InterfaceAddrs()
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLoopback() && !ipNet.IP.IsLinkLocalUnicast() {
+			hosts = append(hosts, ipNet.IP.String())
+		}
+	}
+	return hosts
+}
+
+// certificateCovers reports whether the saved certificate matches its key,
+// is still valid for a while, is no CA and names all hosts
+func certificateCovers(cert, key string, hosts []string, now time.Time) bool {
+	pair, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return false
+	}
+	// Older versions made a CA; whoever had its key could sign for any site
+	if pair.Leaf.IsCA || now.Add(30*24*time.Hour).After(pair.Leaf.NotAfter) {
+		return false
+	}
+	for _, host := range hosts {
+		if pair.Leaf.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func generateCertificate(hosts []string, now time.Time) (certPEM, keyPEM []byte, err error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"BenLang"}, CommonName: "BenLang Server"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(certValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  false, // trusting it must not let it sign for other sites
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// certificateInfo describes a certificate for the start message: its
+// SHA-256 fingerprint, to compare with what the browser shows, and the
+// hosts it is valid for
+func certificateInfo(cert string) (fingerprint string, hosts []string, err error) {
+	data, err := os.ReadFile(cert)
+	if err != nil {
+		return "", nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return "", nil, errors.New("Keine PEM-Datei")
+	}
+	parsed, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	sum := sha256.Sum256(parsed.Raw)
+	pairs := make([]string, len(sum))
+	for i, b := range sum {
+		pairs[i] = strings.ToUpper(hex.EncodeToString([]byte{b}))
+	}
+	hosts = slices.Clone(parsed.DNSNames)
+	for _, ip := range parsed.IPAddresses {
+		hosts = append(hosts, ip.String())
+	}
+	return strings.Join(pairs, ":"), hosts, nil
+}
+
+// redirectToHTTPS sends browsers that come over HTTP to the HTTPS port
+func redirectToHTTPS(port int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else {
+			host = strings.Trim(host, "[]") // IPv6 without port
+		}
+		if port != 443 {
+			host = net.JoinHostPort(host, fmt.Sprint(port))
+		} else if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusTemporaryRedirect)
+	})
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSelfSignedCertificate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "benlang")
+	cert, key, err := SelfSignedCertificate(dir, []string{"schule.local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		t.Fatalf("certificate and key do not match: %v", err)
+	}
+	for _, host := range append(localHosts(), "schule.local") {
+		if err := pair.Leaf.VerifyHostname(host); err != nil {
+			t.Errorf("not valid for %s: %v", host, err)
+		}
+	}
+	if pair.Leaf.IsCA || pair.Leaf.KeyUsage&x509.KeyUsageCertSign != 0 {
+		t.Error("certificate can sign other certificates")
+	}
+	if info, _ := os.Stat(key); info.Mode().Perm() != 0600 {
+		t.Errorf("key permissions %v", info.Mode().Perm())
+	}
+
+	// The next start keeps it, so browsers do not ask again
+	first, _ := os.ReadFile(cert)
+	if _, _, err := SelfSignedCertificate(dir, []string{"schule.local"}); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := os.ReadFile(cert); !bytes.Equal(first, again) {
+		t.Error("certificate was replaced")
+	}
+
+	// Addresses that came up later do not replace it, a new extra host does
+	if !certificateCovers(cert, key, nil, time.Now()) {
+		t.Error("valid certificate is not kept")
+	}
+	if certificateCovers(cert, key, []string{"schule.example"}, time.Now()) {
+		t.Error("certificate covers an unknown host")
+	}
+	if certificateCovers(cert, key, nil, time.Now().Add(certValidity)) {
+		t.Error("certificate that runs out is kept")
+	}
+}
+
+func TestSelfSignedCertificateReplacesUnusable(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := filepath.Join(dir, certFile), filepath.Join(dir, keyFile)
+
+	// A CA certificate from an older version
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(certValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	priv, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalPKCS8PrivateKey(priv)
+	os.WriteFile(cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	os.WriteFile(key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600)
+	if certificateCovers(cert, key, nil, time.Now()) {
+		t.Error("CA certificate is kept")
+	}
+
+	// A key that does not belong to the certificate, e.g. after a crash
+	if _, _, err := SelfSignedCertificate(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, _ := generateCertificate([]string{"localhost"}, time.Now())
+	os.WriteFile(key, otherKey, 0600)
+	if certificateCovers(cert, key, nil, time.Now()) {
+		t.Error("certificate with a foreign key is kept")
+	}
+	if _, _, err := SelfSignedCertificate(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tls.LoadX509KeyPair(cert, key); err != nil {
+		t.Errorf("pair not replaced: %v", err)
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	tests := []struct {
+		port         int
+		target, want string
+	}{
+		{3443, "http://192.168.1.20:3000/index.html?projekt=pong", "https://192.168.1.20:3443/index.html?projekt=pong"},
+		{443, "http://schule.example/api/dateien", "https://schule.example/api/dateien"},
+		{3443, "http://[fd00::2]:3000/", "https://[fd00::2]:3443/"},
+		{443, "http://[fd00::2]/", "https://[fd00::2]/"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		redirectToHTTPS(tt.port).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.target, nil))
+		if rec.Code != http.StatusTemporaryRedirect || rec.Header().Get("Location") != tt.want {
+			t.Errorf("%s: status %d, location %q", tt.target, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package server
+
+import (
+	"benlang/internal/auth"
+	"benlang/internal/project"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var errTeachersOnly = errors.New("Nur für Lehrkräfte")
+
+// changingPaths are the endpoints that change files of the project a
+// request works on, when not called with GET
+var changingPaths = map[string]bool{
+	"/api/datei":                       true,
+	"/api/datei/umbenennen":            true,
+	"/api/datei/verschieben":           true,
+	"/api/ordner":                      true,
+	"/api/papierkorb":                  true,
+	"/api/papierkorb/wiederherstellen": true,
+	"/api/verlauf/wiederherstellen":    true,
+	"/api/umbenennen":                  true,
+	"/api/bild":                        true,
+	"/api/medien":                      true,
+}
+
+// creatingPaths are the endpoints that add projects to the user's folder
+var creatingPaths = map[string]bool{
+	"/api/projekte/neu":    true,
+	"/api/projekte/import": true,
+}
+
+// role returns the role of the logged-in user, or "" without login
+func (s *Server) role(r *http.Request) auth.Role {
+	if s.auth == nil {
+		return ""
+	}
+	user, exists := s.auth.Lookup(s.user(r))
+	if !exists {
+		return ""
+	}
+	return user.UserRole()
+}
+
+// forbidden checks the role of a logged-in user for a request and returns
+// why it is refused, or "" if it is allowed. Guests change nothing, and a
+// student's project opened by a teacher is only for looking at.
+func (s *Server) forbidden(r *http.Request, user auth.User) string {
+	role := user.UserRole()
+	if teacherPath(r.URL.Path) && role != auth.RoleTeacher {
+		return errTeachersOnly.Error()
+	}
+	if r.Method == http.MethodGet || !changingPaths[r.URL.Path] && !creatingPaths[r.URL.Path] {
+		return ""
+	}
+	if role == auth.RoleGuest {
+		return "Gäste können nichts ändern"
+	}
+	if changingPaths[r.URL.Path] && strings.Contains(projectName(r), "/") {
+		return "Das Projekt eines anderen Kindes kannst du nur anschauen"
+	}
+	return ""
+}
+
+func teacherPath(path string) bool {
+	return path == "/api/benutzer" || strings.HasPrefix(path, "/api/benutzer/") ||
+		strings.HasPrefix(path, "/api/lehrer/") || path == "/lehrer.html"
+}
+
+// readOnly reports whether the request works on a project it may not
+// change
+func (s *Server) readOnly(r *http.Request) bool {
+	return s.AuthEnabled && (s.role(r) == auth.RoleGuest || s.othersProject(r))
+}
+
+// othersProject reports whether the request looks at another user's
+// project, as teachers do. Its code is never sent to run: in the viewer's
+// page a game could act with the viewer's login.
+func (s *Server) othersProject(r *http.Request) bool {
+	return s.AuthEnabled && strings.Contains(projectName(r), "/")
+}
+
+// userInfo is a user as the web UI sees it, without password hash
+type userInfo struct {
+	Name string    `json:"benutzername"`
+	Role auth.Role `json:"rolle"`
+}
+
+// handleBenutzer lists (GET), adds (POST) and deletes (DELETE) users.
+// Only teachers get here.
+func (s *Server) handleBenutzer(w http.ResponseWriter, r *http.Request) {
+	if !s.AuthEnabled {
+		http.Error(w, "Die Anmeldung ist nicht aktiviert", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		users := []userInfo{}
+		for _, u := range s.auth.List() {
+			users = append(users, userInfo{u.Username, u.UserRole()})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(users)
+
+	case http.MethodPost:
+		var req struct {
+			Name     string `json:"benutzername"`
+			Password string `json:"passwort"`
+			Role     string `json:"rolle"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		role, err := auth.ParseRole(req.Role)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Every user gets a folder of the same name
+		if !project.ValidName(req.Name) {
+			http.Error(w, fmt.Sprintf("'%s' geht nicht als Benutzername", req.Name), http.StatusBadRequest)
+			return
+		}
+		if req.Password == "" {
+			http.Error(w, "Das Passwort darf nicht leer sein", http.StatusBadRequest)
+			return
+		}
+		if err := s.auth.AddUser(req.Name, req.Password, role); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		s.saveUsers(w, userInfo{req.Name, role})
+
+	case http.MethodDelete:
+		name := r.URL.Query().Get("benutzername")
+		if name == s.user(r) {
+			http.Error(w, "Du kannst dich nicht selbst löschen", http.StatusBadRequest)
+			return
+		}
+		if s.otherTeacher(r, name) {
+			http.Error(w, "Eine andere Lehrkraft kannst du nicht löschen", http.StatusForbidden)
+			return
+		}
+		if err := s.auth.DeleteUser(name); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.saveUsers(w, nil)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleBenutzerPasswort sets a new password. Teachers may do this for
+// students, guests and themselves, but not for other teachers.
+func (s *Server) handleBenutzerPasswort(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.AuthEnabled {
+		http.Error(w, "Die Anmeldung ist nicht aktiviert", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Name     string `json:"benutzername"`
+		Password string `json:"passwort"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Password == "" {
+		http.Error(w, "Das Passwort darf nicht leer sein", http.StatusBadRequest)
+		return
+	}
+
+	if _, exists := s.auth.Lookup(req.Name); !exists {
+		http.Error(w, "Benutzer existiert nicht", http.StatusNotFound)
+		return
+	}
+	if s.otherTeacher(r, req.Name) {
+		http.Error(w, "Das Passwort einer anderen Lehrkraft kannst du nicht ändern", http.StatusForbidden)
+		return
+	}
+	if err := s.auth.UpdateUser(req.Name, req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	s.saveUsers(w, nil)
+}
+
+// otherTeacher reports whether name belongs to a teacher other than the
+// one asking. Teachers cannot take over each other's accounts through the
+// IDE; that is left to "benlang benutzer" on the server.
+func (s *Server) otherTeacher(r *http.Request, name string) bool {
+	user, exists := s.auth.Lookup(name)
+	return exists && user.UserRole() == auth.RoleTeacher && name != s.user(r)
+}
+
+// handleBenutzerRolle changes the role of a student or guest
+func (s *Server) handleBenutzerRolle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.AuthEnabled {
+		http.Error(w, "Die Anmeldung ist nicht aktiviert", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Name string `json:"benutzername"`
+		Role string `json:"rolle"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	role, err := auth.ParseRole(req.Role)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Otherwise the last teacher could lock everyone out
+	if req.Name == s.user(r) {
+		http.Error(w, "Du kannst deine eigene Rolle nicht ändern", http.StatusBadRequest)
+		return
+	}
+	if s.otherTeacher(r, req.Name) {
+		http.Error(w, "Die Rolle einer anderen Lehrkraft kannst du nicht ändern", http.StatusForbidden)
+		return
+	}
+	if err := s.auth.SetRole(req.Name, role); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	s.saveUsers(w, userInfo{req.Name, role})
+}
+
+// handleBenutzerAbmelden ends all sessions of a user, for example after a
+// password got around or a school computer was left logged in
+func (s *Server) handleBenutzerAbmelden(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.AuthEnabled {
+		http.Error(w, "Die Anmeldung ist nicht aktiviert", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Name string `json:"benutzername"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, exists := s.auth.Lookup(req.Name); !exists {
+		http.Error(w, "Benutzer existiert nicht", http.StatusNotFound)
+		return
+	}
+	if s.otherTeacher(r, req.Name) {
+		http.Error(w, "Eine andere Lehrkraft kannst du nicht abmelden", http.StatusForbidden)
+		return
+	}
+
+	ended, err := s.auth.RevokeSessions(req.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"erfolg": true, "beendet": ended})
+}
+
+// handleBenutzerGesperrt lists the accounts that have to wait after too
+// many wrong passwords
+func (s *Server) handleBenutzerGesperrt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.AuthEnabled {
+		http.Error(w, "Die Anmeldung ist nicht aktiviert", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.limiter.Locked())
+}
+
+// handleBenutzerEntsperren lets a user log in again right away after too
+// many wrong passwords
+func (s *Server) handleBenutzerEntsperren(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.AuthEnabled {
+		http.Error(w, "Die Anmeldung ist nicht aktiviert", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Name string `json:"benutzername"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, exists := s.auth.Lookup(req.Name); !exists {
+		http.Error(w, "Benutzer existiert nicht", http.StatusNotFound)
+		return
+	}
+	// Unlocking would let someone go on guessing the password
+	if s.otherTeacher(r, req.Name) {
+		http.Error(w, "Eine andere Lehrkraft kannst du nicht entsperren", http.StatusForbidden)
+		return
+	}
+
+	waiting := s.limiter.Unlock(req.Name, s.user(r))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"erfolg": true, "warGesperrt": waiting})
+}
+
+// saveUsers writes the changed users to disk and answers the request
+func (s *Server) saveUsers(w http.ResponseWriter, user interface{}) {
+	if err := s.auth.Save(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	response := map[string]interface{}{"erfolg": true}
+	if user != nil {
+		response["benutzer"] = user
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
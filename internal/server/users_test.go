@@ -0,0 +1,219 @@
+package server
+
+import (
+	"benlang/internal/auth"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoleChecks(t *testing.T) {
+	s := newTestServer(t, nil, withUsers)
+	handler := s.Handler().ServeHTTP
+	teacher, anna, guest := login(t, s, "frau-meier"), login(t, s, "anna"), login(t, s, "besuch")
+
+	if rec := serveAs(handler, anna, http.MethodGet, "/api/benutzer", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("student lists users: status %d", rec.Code)
+	}
+	rec := serveAs(handler, teacher, http.MethodGet, "/api/benutzer", nil)
+	var users []userInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &users); err != nil || len(users) != 3 || users[0] != (userInfo{"anna", auth.RoleStudent}) {
+		t.Errorf("teacher lists users: %v, %s", err, rec.Body.String())
+	}
+
+	body, _ := json.Marshal(map[string]string{"name": "neu"})
+	if rec := serveAs(handler, guest, http.MethodPost, "/api/projekte/neu", body); rec.Code != http.StatusForbidden {
+		t.Errorf("guest creates project: status %d", rec.Code)
+	}
+	if rec := serveAs(handler, guest, http.MethodGet, "/api/projekte/liste", nil); rec.Code != http.StatusOK {
+		t.Errorf("guest lists projects: status %d", rec.Code)
+	}
+
+	// A session whose user was deleted is logged out
+	s.auth.DeleteUser("besuch")
+	if rec := serveAs(handler, guest, http.MethodGet, "/api/projekte/liste", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("deleted user: status %d", rec.Code)
+	}
+}
+
+func TestTeacherViewsStudentProject(t *testing.T) {
+	s := newTestServer(t, nil, withUsers)
+	workDir := s.WorkDir
+	handler := s.Handler().ServeHTTP
+	teacher, anna := login(t, s, "frau-meier"), login(t, s, "anna")
+
+	rec := serveAs(handler, teacher, http.MethodGet, "/api/projekte/liste?benutzer=anna", nil)
+	if strings.TrimSpace(rec.Body.String()) != `["rennspiel"]` {
+		t.Errorf("anna's projects: %s", rec.Body.String())
+	}
+	if rec := serveAs(handler, anna, http.MethodGet, "/api/projekte/liste?benutzer=frau-meier", nil); rec.Code != http.StatusForbidden {
+		t.Errorf("student lists teacher's projects: status %d", rec.Code)
+	}
+
+	body, _ := json.Marshal(map[string]string{"name": "rennspiel", "benutzer": "anna"})
+	rec = serveAs(handler, teacher, http.MethodPost, "/api/projekte/oeffnen", body)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"nurLesen":true`) {
+		t.Fatalf("open anna's project: status %d: %s", rec.Code, rec.Body.String())
+	}
+	viewing := append(rec.Result().Cookies(), teacher...)
+
+	rec = serveAs(handler, viewing, http.MethodGet, "/api/datei?pfad=hauptspiel.ben", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "VAR auto") {
+		t.Errorf("teacher reads anna's file: status %d: %s", rec.Code, rec.Body.String())
+	}
+	body, _ = json.Marshal(map[string]string{"name": "hauptspiel.ben", "inhalt": "VAR auto = 2\n"})
+	if rec := serveAs(handler, viewing, http.MethodPost, "/api/datei", body); rec.Code != http.StatusForbidden {
+		t.Errorf("teacher saves anna's file: status %d", rec.Code)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workDir, "anna", "rennspiel", "hauptspiel.ben")); string(data) != "VAR auto = 1\n" {
+		t.Errorf("anna's file changed to %q", data)
+	}
+
+	// Students cannot look into other folders the same way
+	body, _ = json.Marshal(map[string]string{"name": "rennspiel", "benutzer": "anna"})
+	if rec := serveAs(handler, login(t, s, "besuch"), http.MethodPost, "/api/projekte/oeffnen", body); rec.Code != http.StatusForbidden {
+		t.Errorf("guest opens anna's project: status %d", rec.Code)
+	}
+	cookies := []*http.Cookie{{Name: projectCookie, Value: "anna/rennspiel"}, anna[0]}
+	refused(t, "student with cookie", serveAs(handler, cookies, http.MethodGet, "/api/datei?pfad=hauptspiel.ben", nil))
+}
+
+func TestTeacherManagesUsers(t *testing.T) {
+	s := newTestServer(t, nil, withUsers)
+	handler := s.Handler().ServeHTTP
+	teacher := login(t, s, "frau-meier")
+
+	body, _ := json.Marshal(map[string]string{"benutzername": "ben", "passwort": "katze", "rolle": "schüler"})
+	if rec := serveAs(handler, teacher, http.MethodPost, "/api/benutzer", body); rec.Code != http.StatusOK {
+		t.Fatalf("add user: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if data, err := os.ReadFile(s.auth.Path); err != nil || !strings.Contains(string(data), `"ben"`) {
+		t.Errorf("users not saved: %v", err)
+	}
+	for _, name := range []string{"../ben", "ben"} {
+		body, _ := json.Marshal(map[string]string{"benutzername": name, "passwort": "x"})
+		refused(t, "add "+name, serveAs(handler, teacher, http.MethodPost, "/api/benutzer", body))
+	}
+
+	anna := login(t, s, "anna")
+	body, _ = json.Marshal(map[string]string{"benutzername": "anna", "passwort": "neu"})
+	if rec := serveAs(handler, teacher, http.MethodPost, "/api/benutzer/passwort", body); rec.Code != http.StatusOK {
+		t.Errorf("reset password: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if !s.auth.Verify("anna", "neu") {
+		t.Error("anna's new password does not work")
+	}
+	if rec := serveAs(handler, anna, http.MethodGet, "/api/projekte/liste", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("session from before the reset: status %d", rec.Code)
+	}
+	if user, _ := s.auth.Lookup("anna"); user.UserRole() != auth.RoleStudent {
+		t.Errorf("password reset changed the role to %s", user.Role)
+	}
+
+	s.auth.AddUser("herr-kurz", "kreide", auth.RoleTeacher)
+	body, _ = json.Marshal(map[string]string{"benutzername": "herr-kurz", "passwort": "neu"})
+	refused(t, "other teacher's password", serveAs(handler, teacher, http.MethodPost, "/api/benutzer/passwort", body))
+	body, _ = json.Marshal(map[string]string{"benutzername": "herr-kurz", "rolle": "schüler"})
+	refused(t, "other teacher's role", serveAs(handler, teacher, http.MethodPost, "/api/benutzer/rolle", body))
+	refused(t, "delete other teacher", serveAs(handler, teacher, http.MethodDelete, "/api/benutzer?benutzername=herr-kurz", nil))
+	if user, exists := s.auth.Lookup("herr-kurz"); !exists || user.UserRole() != auth.RoleTeacher {
+		t.Error("other teacher was demoted or deleted")
+	}
+	body, _ = json.Marshal(map[string]string{"benutzername": "frau-meier", "rolle": "gast"})
+	refused(t, "own role", serveAs(handler, teacher, http.MethodPost, "/api/benutzer/rolle", body))
+	refused(t, "delete self", serveAs(handler, teacher, http.MethodDelete, "/api/benutzer?benutzername=frau-meier", nil))
+
+	body, _ = json.Marshal(map[string]string{"benutzername": "ben", "rolle": "gast"})
+	if rec := serveAs(handler, teacher, http.MethodPost, "/api/benutzer/rolle", body); rec.Code != http.StatusOK {
+		t.Errorf("change role: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serveAs(handler, teacher, http.MethodDelete, "/api/benutzer?benutzername=ben", nil); rec.Code != http.StatusOK {
+		t.Errorf("delete user: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, exists := s.auth.Lookup("ben"); exists {
+		t.Error("ben still exists")
+	}
+}
+
+func TestTeacherLogsOutStudent(t *testing.T) {
+	s := newTestServer(t, nil, withUsers)
+	handler := s.Handler().ServeHTTP
+	teacher, anna := login(t, s, "frau-meier"), login(t, s, "anna")
+	login(t, s, "anna")
+
+	body, _ := json.Marshal(map[string]string{"benutzername": "frau-meier"})
+	if rec := serveAs(handler, anna, http.MethodPost, "/api/benutzer/abmelden", body); rec.Code != http.StatusForbidden {
+		t.Errorf("student logs out teacher: status %d", rec.Code)
+	}
+
+	body, _ = json.Marshal(map[string]string{"benutzername": "anna"})
+	rec := serveAs(handler, teacher, http.MethodPost, "/api/benutzer/abmelden", body)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"beendet":2`) {
+		t.Errorf("log out anna: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serveAs(handler, anna, http.MethodGet, "/api/projekte/liste", nil); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anna after logout: status %d", rec.Code)
+	}
+	if rec := serveAs(handler, teacher, http.MethodGet, "/api/projekte/liste", nil); rec.Code != http.StatusOK {
+		t.Errorf("teacher was logged out too: status %d", rec.Code)
+	}
+
+	s.auth.AddUser("herr-kurz", "kreide", auth.RoleTeacher)
+	other := login(t, s, "herr-kurz")
+	body, _ = json.Marshal(map[string]string{"benutzername": "herr-kurz"})
+	refused(t, "log out other teacher", serveAs(handler, teacher, http.MethodPost, "/api/benutzer/abmelden", body))
+	if rec := serveAs(handler, other, http.MethodGet, "/api/projekte/liste", nil); rec.Code != http.StatusOK {
+		t.Errorf("other teacher was logged out: status %d", rec.Code)
+	}
+}
+
+func TestLoginLimit(t *testing.T) {
+	s := newTestServer(t, nil, withUsers)
+	handler := s.Handler().ServeHTTP
+	s.limiter.LockAfter = 4
+	s.limiter.BaseDelay, s.limiter.MaxDelay = 0, 0 // only the lockout counts here
+	loginAs := func(user, password string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"username": user, "password": password})
+		return serveAs(handler, nil, http.MethodPost, "/api/login", body)
+	}
+
+	for i := 0; i < 4; i++ {
+		if rec := loginAs("anna", "falsch"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("wrong password %d: status %d", i+1, rec.Code)
+		}
+	}
+	rec := loginAs("anna", "pferd")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" || !strings.Contains(rec.Body.String(), "gesperrt") {
+		t.Fatalf("locked login: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	teacher := login(t, s, "frau-meier")
+	rec = serveAs(handler, teacher, http.MethodGet, "/api/benutzer/gesperrt", nil)
+	if !strings.Contains(rec.Body.String(), `"benutzername":"anna"`) {
+		t.Errorf("locked users: %s", rec.Body.String())
+	}
+	body, _ := json.Marshal(map[string]string{"benutzername": "anna"})
+	if rec := serveAs(handler, login(t, s, "anna"), http.MethodPost, "/api/benutzer/entsperren", body); rec.Code != http.StatusForbidden {
+		t.Errorf("student unlocks: status %d", rec.Code)
+	}
+	if rec := serveAs(handler, teacher, http.MethodPost, "/api/benutzer/entsperren", body); !strings.Contains(rec.Body.String(), `"warGesperrt":true`) {
+		t.Errorf("unlock: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := loginAs("anna", "pferd"); rec.Code != http.StatusOK {
+		t.Errorf("login after unlock: status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	s.auth.AddUser("herr-kurz", "kreide", auth.RoleTeacher)
+	for i := 0; i < 4; i++ {
+		loginAs("herr-kurz", "falsch")
+	}
+	body, _ = json.Marshal(map[string]string{"benutzername": "herr-kurz"})
+	refused(t, "unlock other teacher", serveAs(handler, teacher, http.MethodPost, "/api/benutzer/entsperren", body))
+	if rec := loginAs("herr-kurz", "kreide"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("other teacher after refused unlock: status %d", rec.Code)
+	}
+}
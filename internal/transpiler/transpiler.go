@@ -6,6 +6,43 @@ import (
 	"strings"
 )
 
+// Builtins maps the built-in BenLang functions to their runtime calls
+var Builtins = map[string]string{
+	"LADE_BILD":        "_benlang.ladeBild",
+	"BILD_WECHSELN":    "await _benlang.bildWechseln",
+	"SPIELE_TON":       "_benlang.spieleTon",
+	"ZEIGE_TEXT":       "_benlang.zeigeText",
+	"ZEICHNE_RECHTECK": "_benlang.zeichneRechteck",
+	"ZEICHNE_KREIS":    "_benlang.zeichneKreis",
+	"ZEICHNE_LINIE":    "_benlang.zeichneLinie",
+	"TASTE_GEDRUECKT":  "_benlang.tasteGedrueckt",
+	"TASTE_GEDRÜCKT":   "_benlang.tasteGedrueckt",
+	"TASTE_GETIPPT":    "_benlang.tasteGetippt",
+	"GEDRUECKTE_TASTE": "_benlang.gedrueckteTaste",
+	"GEDRÜCKTE_TASTE":  "_benlang.gedrueckteTaste",
+	"MAUS_X":           "_benlang.mausX",
+	"MAUS_Y":           "_benlang.mausY",
+	"MAUS_GEDRUECKT":   "_benlang.mausGedrueckt",
+	"MAUS_GEDRÜCKT":    "_benlang.mausGedrueckt",
+	"ZUFALL":           "_benlang.zufall",
+	"WARTE":            "await _benlang.warte",
+	"RUNDEN":           "Math.round",
+	"ABSOLUT":          "Math.abs",
+	"WURZEL":           "Math.sqrt",
+	"SINUS":            "Math.sin",
+	"KOSINUS":          "Math.cos",
+	"SCHREIBE":         "console.log",
+	"FRAGE":            "await _benlang.frage",
+	"LOESCHEN":         "_benlang.loescheFigur",
+	"LÖSCHEN":          "_benlang.loescheFigur",
+	"LAENGE":           "_benlang.laenge",
+	"ZEICHEN":          "_benlang.zeichen",
+	"GROSSBUCHSTABEN":  "_benlang.grossbuchstaben",
+	"GEHE_ZU":          "_benlang.geheZu",
+	"DREHE":            "_benlang.drehe",
+	"SKALIERE":         "_benlang.skaliere",
+}
+
 // Transpiler converts BenLang AST to JavaScript
 type Transpiler struct {
 	indentLevel int
@@ -60,6 +97,8 @@ func (t *Transpiler) transpileStatement(stmt parser.Statement) string {
 		return t.transpileGameDeclaration(s)
 	case *parser.EventHandler:
 		return t.transpileEventHandler(s)
+	case *parser.CommentStatement:
+		return t.indent() + "//" + s.Text
 	default:
 		return ""
 	}
@@ -290,6 +329,9 @@ func (t *Transpiler) transpileExpression(expr parser.Expression) string {
 		return t.transpileMemberExpression(e)
 	case *parser.AssignmentExpression:
 		return t.transpileAssignmentExpression(e)
+	case *parser.GroupedExpression:
+		// Operators are parenthesized already, so the grouping needs no output
+		return t.transpileExpression(e.Expression)
 	default:
 		return ""
 	}
@@ -337,49 +379,13 @@ func (t *Transpiler) transpileInfixExpression(ie *parser.InfixExpression) string
 func (t *Transpiler) transpileCallExpression(ce *parser.CallExpression) string {
 	funcName := t.transpileExpression(ce.Function)
 
-	// Map German built-in functions to runtime functions
-	funcMapping := map[string]string{
-		"LADE_BILD":        "_benlang.ladeBild",
-		"BILD_WECHSELN":    "await _benlang.bildWechseln",
-		"SPIELE_TON":       "_benlang.spieleTon",
-		"ZEIGE_TEXT":       "_benlang.zeigeText",
-		"ZEICHNE_RECHTECK": "_benlang.zeichneRechteck",
-		"ZEICHNE_KREIS":    "_benlang.zeichneKreis",
-		"ZEICHNE_LINIE":    "_benlang.zeichneLinie",
-		"TASTE_GEDRUECKT":  "_benlang.tasteGedrueckt",
-		"TASTE_GEDRÜCKT":   "_benlang.tasteGedrueckt",
-		"TASTE_GETIPPT":    "_benlang.tasteGetippt",
-		"GEDRUECKTE_TASTE": "_benlang.gedrueckteTaste",
-		"GEDRÜCKTE_TASTE":  "_benlang.gedrueckteTaste",
-		"MAUS_X":           "_benlang.mausX",
-		"MAUS_Y":           "_benlang.mausY",
-		"MAUS_GEDRUECKT":   "_benlang.mausGedrueckt",
-		"MAUS_GEDRÜCKT":    "_benlang.mausGedrueckt",
-		"ZUFALL":           "_benlang.zufall",
-		"WARTE":            "await _benlang.warte",
-		"RUNDEN":           "Math.round",
-		"ABSOLUT":          "Math.abs",
-		"WURZEL":           "Math.sqrt",
-		"SINUS":            "Math.sin",
-		"KOSINUS":          "Math.cos",
-		"SCHREIBE":         "console.log",
-		"FRAGE":            "await _benlang.frage",
-		"LOESCHEN":         "_benlang.loescheFigur",
-		"LÖSCHEN":          "_benlang.loescheFigur",
-		"LAENGE":           "_benlang.laenge",
-		"ZEICHEN":          "_benlang.zeichen",
-		"GROSSBUCHSTABEN":  "_benlang.grossbuchstaben",
-		"GEHE_ZU":          "_benlang.geheZu",
-		"DREHE":            "_benlang.drehe",
-		"SKALIERE":         "_benlang.skaliere",
-	}
-
 	args := make([]string, len(ce.Arguments))
 	for i, arg := range ce.Arguments {
 		args[i] = t.transpileExpression(arg)
 	}
 
-	if mapped, ok := funcMapping[funcName]; ok {
+	// Map German built-in functions to runtime functions
+	if mapped, ok := Builtins[funcName]; ok {
 		return fmt.Sprintf("%s(%s)", mapped, strings.Join(args, ", "))
 	}
 
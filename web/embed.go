@@ -2,5 +2,5 @@ package web
 
 import "embed"
 
-//go:embed all:index.html all:hilfe.html all:login.html all:css all:js all:assets all:hilfe
+//go:embed all:index.html all:hilfe.html all:login.html all:lehrer.html all:css all:js all:assets all:hilfe
 var Content embed.FS
@@ -0,0 +1,31 @@
+/**
+ * BenLang CSRF protection
+ * Sends the token from the csrf cookie along with every change to this
+ * server, so that other websites cannot change anything in the name of a
+ * logged-in child. Without login there is no cookie and nothing to send.
+ */
+
+(function () {
+  const originalFetch = window.fetch;
+  const safeMethods = ['GET', 'HEAD', 'OPTIONS'];
+
+  function csrfToken() {
+    const match = document.cookie.match(/(?:^|;\s*)csrf=([^;]*)/);
+    return match ? decodeURIComponent(match[1]) : null;
+  }
+
+  window.fetch = function (input, init = {}) {
+    const request = input instanceof Request ? input : null;
+    const method = (init.method || (request ? request.method : 'GET')).toUpperCase();
+    const target = new URL(request ? request.url : input, window.location.href);
+    const token = csrfToken();
+
+    // Never hand the token to other servers
+    if (token && !safeMethods.includes(method) && target.origin === window.location.origin) {
+      const headers = new Headers(init.headers || (request ? request.headers : undefined));
+      headers.set('X-CSRF-Token', token);
+      init = { ...init, headers };
+    }
+    return originalFetch.call(this, input, init);
+  };
+})();
@@ -8,6 +8,12 @@ let monacoEditor = null;
 let currentFile = 'hauptspiel.ben';
 let fileModels = {};  // Monaco models for each file
 let hasUnsavedChanges = false;
+let projectManifest = { einstieg: 'hauptspiel.ben', leinwand: { breite: 800, hoehe: 600 } };
+let fileOrder = [];  // .ben files in the order the compiler joins them
+let fileHashes = {};  // hash of each file as last loaded or saved, to notice changes made elsewhere
+let liveEvents = null;  // connection to /api/live
+let liveProject = null;
+let readOnly = false;  // the project may be looked at but not changed
 
 // DOM Elements
 let editorContainer, consoleOutput, fileList, projectName, gameTitle;
@@ -79,8 +85,12 @@ async function loadFiles() {
     const response = await fetch('/api/dateien');
     const data = await response.json();
 
+    // A student's project opened by a teacher is only for looking at
+    readOnly = !!data.nurLesen;
+    monacoEditor?.updateOptions({ readOnly });
+
     if (data.projekt) {
-      if (projectName) projectName.textContent = data.projekt;
+      if (projectName) projectName.textContent = data.projekt + (readOnly ? ' (nur anschauen)' : '');
     } else {
       if (projectName) projectName.textContent = 'Kein Projekt geladen';
       // Automatically show project modal if no project is loaded
@@ -89,26 +99,27 @@ async function loadFiles() {
     }
 
     fileModels = {};
+    fileHashes = {};
     if (data.dateien && Array.isArray(data.dateien)) {
       data.dateien.forEach(f => {
         fileModels[f.name] = f.inhalt || '';
+        if (f.hash) fileHashes[f.name] = f.hash;
       });
       logToConsole('Dateien geladen: ' + data.dateien.length, 'log');
     }
 
-    // Sort files: hauptspiel.ben first
-    const files = Object.keys(fileModels).filter(f => f.endsWith('.ben')).sort((a, b) => {
-      if (a === 'hauptspiel.ben') return -1;
-      if (b === 'hauptspiel.ben') return 1;
-      return a.localeCompare(b);
-    });
+    // The manifest decides the entry file, file order and canvas size
+    if (data.manifest) projectManifest = data.manifest;
+    fileOrder = data.reihenfolge || [];
+    applyCanvasSize();
 
+    const files = orderedFiles();
     if (files.length > 0) {
       currentFile = files[0];
       const content = fileModels[currentFile] || getDefaultCode();
       setEditorContent(content);
     } else {
-      currentFile = 'hauptspiel.ben';
+      currentFile = projectManifest.einstieg;
       if (data.projekt) {
         // Only set default code if we actually have a project
         fileModels[currentFile] = getDefaultCode();
@@ -123,21 +134,40 @@ async function loadFiles() {
 
     hasUnsavedChanges = false;
     updateFileTab();
+    connectLive(data.projekt);
     return true;
   } catch (err) {
     console.error('Fehler beim Laden der Dateien:', err);
-    currentFile = 'hauptspiel.ben';
+    currentFile = projectManifest.einstieg;
     setEditorContent(getDefaultCode());
     return false;
   }
 }
 
+// .ben files in compile order: the order from the server, new files at the end
+function orderedFiles() {
+  const files = Object.keys(fileModels).filter(f => f.endsWith('.ben'));
+  const rank = f => {
+    const i = fileOrder.indexOf(f);
+    return i < 0 ? fileOrder.length : i;
+  };
+  return files.sort((a, b) => rank(a) - rank(b) || a.localeCompare(b));
+}
+
+function applyCanvasSize() {
+  const canvas = document.getElementById('gameCanvas');
+  const leinwand = projectManifest.leinwand;
+  if (!canvas || !leinwand) return;
+  canvas.width = leinwand.breite;
+  canvas.height = leinwand.hoehe;
+}
+
 function renderFileList() {
   if (!fileList) return;
 
   fileList.innerHTML = '';
 
-  Object.keys(fileModels).filter(f => f.endsWith('.ben')).forEach(filename => {
+  orderedFiles().forEach(filename => {
     const tab = document.createElement('div');
     tab.className = 'file-tab' + (filename === currentFile ? ' active' : '');
     tab.innerHTML = `<span class="name">${filename}</span>`;
@@ -161,29 +191,203 @@ function openFile(filename) {
   renderFileList();
 }
 
-async function saveCurrentFile() {
+async function saveCurrentFile(force = false) {
   if (!currentFile || !monacoEditor) return;
 
-  fileModels[currentFile] = monacoEditor.getValue();
+  const filename = currentFile;
+  fileModels[filename] = monacoEditor.getValue();
 
   try {
-    await fetch('/api/datei', {
+    const response = await fetch('/api/datei', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({
-        name: currentFile,
-        inhalt: fileModels[currentFile]
+        name: filename,
+        inhalt: fileModels[filename],
+        basis: fileHashes[filename] || '',
+        erzwingen: force
       })
     });
 
+    // Someone else saved the file since we loaded it
+    if (response.status === 409) {
+      const conflict = await response.json();
+      logToConsole('Konflikt: ' + conflict.fehler, 'warning');
+      if (confirm(conflict.fehler + '.\n\nOK: Deine Fassung speichern\nAbbrechen: Die andere Fassung laden')) {
+        await saveCurrentFile(true);
+      } else {
+        fileModels[filename] = conflict.inhalt;
+        fileHashes[filename] = conflict.hash;
+        if (currentFile === filename) setEditorContent(conflict.inhalt);
+      }
+      return;
+    }
+
+    if (!response.ok) {
+      logToConsole(await response.text(), 'error');
+      return;
+    }
+
+    const result = await response.json();
+    if (result.hash) fileHashes[filename] = result.hash;
+
     hasUnsavedChanges = false;
     updateFileTab();
-    logToConsole('Datei gespeichert: ' + currentFile, 'success');
+    logToConsole('Datei gespeichert: ' + filename, 'success');
   } catch (err) {
     console.error('Fehler beim Speichern:', err);
   }
 }
 
+// Listen for files changed outside this IDE, e.g. by a teacher
+function connectLive(projekt) {
+  if (typeof EventSource === 'undefined') return;
+  if (liveEvents && liveProject === projekt) return;
+  if (liveEvents) liveEvents.close();
+  liveEvents = null;
+  liveProject = projekt;
+  if (!projekt) return;
+
+  liveEvents = new EventSource('/api/live');
+  liveEvents.addEventListener('dateien', e => applyLiveChanges(JSON.parse(e.data).dateien));
+  liveEvents.addEventListener('kompiliert', e => showLiveCompile(JSON.parse(e.data)));
+  liveEvents.addEventListener('konflikt', e => {
+    const data = JSON.parse(e.data);
+    if (data.hash !== fileHashes[data.datei]) {
+      logToConsole('Konflikt beim Speichern von ' + data.datei + ' in einem anderen Fenster', 'warning');
+    }
+  });
+}
+
+function applyLiveChanges(changes) {
+  let reload = false;
+  changes.forEach(change => {
+    // Our own saves come back with the hash we already know
+    if (change.hash && change.hash === fileHashes[change.datei]) return;
+
+    if (change.datei === 'projekt.json' || !change.datei.endsWith('.ben')) {
+      reload = true;
+      return;
+    }
+
+    if (change.art === 'geloescht') {
+      delete fileModels[change.datei];
+      delete fileHashes[change.datei];
+      logToConsole('Woanders gelöscht: ' + change.datei, 'warning');
+      reload = true;
+      return;
+    }
+
+    if (change.datei === currentFile && hasUnsavedChanges) {
+      // Keep the child's edits; saving will ask which version wins
+      logToConsole('Konflikt: ' + change.datei + ' wurde woanders geändert. Beim Speichern kannst du wählen, welche Fassung bleibt.', 'warning');
+      return;
+    }
+
+    fileModels[change.datei] = change.inhalt;
+    fileHashes[change.datei] = change.hash;
+    if (change.datei === currentFile) {
+      const position = monacoEditor?.getPosition();
+      setEditorContent(change.inhalt);
+      if (position) monacoEditor.setPosition(position);
+    }
+    logToConsole('Woanders geändert: ' + change.datei, 'log');
+  });
+
+  if (reload && !hasUnsavedChanges) {
+    const file = currentFile;
+    loadFiles().then(() => {
+      if (fileModels[file] !== undefined) openFile(file);
+    });
+  } else {
+    renderFileList();
+  }
+}
+
+// Show the result of compiling the changed files; a running game restarts
+function showLiveCompile(result) {
+  if (result.fehler.length > 0) {
+    result.fehler.forEach(err => logToConsole(err, 'error'));
+    return;
+  }
+  result.warnungen.forEach(warning => logToConsole(warning, 'warning'));
+
+  // Other children's projects are never run, see compileAndRun
+  if (readOnly || !result.js) return;
+  if (typeof _benlang !== 'undefined' && _benlang.running && !hasUnsavedChanges) {
+    logToConsole('Spiel wird mit den Änderungen neu gestartet...', 'success');
+    _benlang.stoppen();
+    _benlang.zuruecksetzen();
+    _benlang.init('gameCanvas');
+    try {
+      eval(result.js);
+      _benlang.starten();
+    } catch (evalErr) {
+      logToConsole('Laufzeitfehler beim Spielstart: ' + evalErr.message, 'error');
+    }
+  }
+}
+
+// Download the game as a single HTML file
+async function exportGame() {
+  await saveCurrentFile();
+  const name = await downloadFile('/api/export/html', 'spiel.html');
+  if (name) logToConsole('Spiel exportiert: ' + name, 'success');
+}
+
+// Download the project as a ZIP file, e.g. to continue at home
+async function exportProjectZip() {
+  await saveCurrentFile();
+  const name = await downloadFile('/api/projekte/export', 'projekt.zip');
+  if (name) logToConsole('Projekt gespeichert: ' + name, 'success');
+}
+
+// Fetch a file from the server and save it; returns the file name
+async function downloadFile(url, fallbackName) {
+  try {
+    const response = await fetch(url);
+    if (!response.ok) {
+      logToConsole('Herunterladen fehlgeschlagen: ' + (await response.text()).trim(), 'error');
+      return null;
+    }
+
+    const disposition = response.headers.get('Content-Disposition') || '';
+    const match = disposition.match(/filename\*?=(?:utf-8'')?"?([^";]+)"?/i);
+    const link = document.createElement('a');
+    link.href = URL.createObjectURL(await response.blob());
+    link.download = match ? decodeURIComponent(match[1]) : fallbackName;
+    link.click();
+    setTimeout(() => URL.revokeObjectURL(link.href), 1000);
+    return link.download;
+  } catch (err) {
+    logToConsole('Herunterladen fehlgeschlagen: ' + err.message, 'error');
+    return null;
+  }
+}
+
+// Upload a ZIP file as a new project and open it
+async function importProjectZip(file) {
+  const form = new FormData();
+  form.append('datei', file);
+
+  try {
+    const response = await fetch('/api/projekte/import', { method: 'POST', body: form });
+    if (!response.ok) {
+      alert('Fehler: ' + (await response.text()).trim());
+      return;
+    }
+    const result = await response.json();
+    if (!result.erfolg) {
+      alert('Fehler: ' + result.fehler);
+      return;
+    }
+    logToConsole('Projekt importiert: ' + result.projekt, 'success');
+    await openProject(result.projekt);
+  } catch (err) {
+    console.error('Fehler beim Importieren:', err);
+  }
+}
+
 function updateFileTab() {
   if (!fileList) return;
 
@@ -227,22 +431,16 @@ async function compileAndRun() {
       fileModels[currentFile] = monacoEditor.getValue();
     }
 
-    // Collect all code
-    let allCode = '';
-    const benFiles = Object.keys(fileModels).filter(f => f.endsWith('.ben'));
-    benFiles.sort((a, b) => {
-      if (a === 'hauptspiel.ben') return -1;
-      if (b === 'hauptspiel.ben') return 1;
-      return a.localeCompare(b);
-    });
-    benFiles.forEach(filename => {
-      allCode += fileModels[filename] + '\n';
+    // Collect all code; the server joins the files in the project's order
+    const dateien = {};
+    orderedFiles().forEach(filename => {
+      dateien[filename] = fileModels[filename];
     });
 
     const response = await fetch('/api/kompilieren', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
-      body: JSON.stringify({ code: allCode })
+      body: JSON.stringify({ dateien: dateien })
     });
 
     const result = await response.json();
@@ -252,7 +450,19 @@ async function compileAndRun() {
         logToConsole(err, 'error');
       });
       // Ensure editor is NOT locked if compilation fails
-      if (monacoEditor) monacoEditor.updateOptions({ readOnly: false });
+      if (monacoEditor) monacoEditor.updateOptions({ readOnly });
+      return;
+    }
+
+    // Missing images do not stop the game, but the player should know
+    (result.warnungen || []).forEach(warning => {
+      logToConsole(warning, 'warning');
+    });
+
+    // The server sends no code for other children's projects: it would
+    // run with our login
+    if (!result.js) {
+      logToConsole('Projekte anderer werden nur angezeigt und geprüft, nicht gestartet.', 'warning');
       return;
     }
 
@@ -277,17 +487,17 @@ async function compileAndRun() {
       } catch (evalErr) {
         logToConsole('Laufzeitfehler beim Spielstart: ' + evalErr.message, 'error');
         // Unlock on start failure
-        if (monacoEditor) monacoEditor.updateOptions({ readOnly: false });
+        if (monacoEditor) monacoEditor.updateOptions({ readOnly });
       }
     } else {
       logToConsole('Fehler: Game Engine nicht geladen', 'error');
-      if (monacoEditor) monacoEditor.updateOptions({ readOnly: false });
+      if (monacoEditor) monacoEditor.updateOptions({ readOnly });
     }
 
   } catch (err) {
     logToConsole('Kompilierungsfehler: ' + err.message, 'error');
     console.error(err);
-    if (monacoEditor) monacoEditor.updateOptions({ readOnly: false });
+    if (monacoEditor) monacoEditor.updateOptions({ readOnly });
   } finally {
     isCompiling = false;
   }
@@ -300,7 +510,7 @@ function stopGame() {
 
   // Unlock editor
   if (monacoEditor) {
-    monacoEditor.updateOptions({ readOnly: false });
+    monacoEditor.updateOptions({ readOnly });
     monacoEditor.focus();
   }
 
@@ -332,35 +542,254 @@ async function createNewFile() {
   openFile(filename);
 }
 
-// Upload image
-async function uploadImage(file) {
-  const formData = new FormData();
-  formData.append('bild', file);
-
+// Sends a file operation and reports errors and warnings in the console
+async function fileRequest(url, options) {
   try {
-    const response = await fetch('/api/bild', {
-      method: 'POST',
-      body: formData
-    });
-
+    const response = await fetch(url, options);
     if (!response.ok) {
       const text = await response.text();
-      throw new Error(text || 'Server-Fehler');
+      throw new Error(text.trim() || 'Server-Fehler');
     }
-
     const data = await response.json();
+    (data.warnungen || []).forEach(warning => logToConsole(warning, 'warning'));
+    return data;
+  } catch (err) {
+    logToConsole('Fehler: ' + err.message, 'error');
+    return null;
+  }
+}
 
-    if (data.pfad) {
-      logToConsole('Bild hochgeladen: ' + data.pfad, 'success');
-      return data.pfad;
-    } else if (data.fehler) {
-      logToConsole('Fehler: ' + data.fehler, 'error');
+// Rename the current file; a name with a folder moves it there
+async function renameCurrentFile() {
+  if (!currentFile) return;
+  const target = prompt('Neuer Name (mit Ordner, z.B. level/welt1.ben):', currentFile);
+  if (!target || target === currentFile) return;
+
+  if (hasUnsavedChanges) await saveCurrentFile();
+  const data = await fileRequest('/api/datei/umbenennen', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ von: currentFile, nach: target })
+  });
+  if (!data) return;
+
+  logToConsole('Umbenannt: ' + currentFile + ' → ' + data.pfad, 'success');
+  await loadFiles();
+  if (fileModels[data.pfad] !== undefined) openFile(data.pfad);
+}
+
+// Move the current file into the trash
+async function deleteCurrentFile() {
+  if (!currentFile) return;
+  if (!confirm('"' + currentFile + '" in den Papierkorb legen?')) return;
+
+  const data = await fileRequest('/api/datei?pfad=' + encodeURIComponent(currentFile), {
+    method: 'DELETE'
+  });
+  if (!data) return;
+
+  logToConsole('In den Papierkorb gelegt: ' + currentFile, 'success');
+  await loadFiles();
+}
+
+async function showTrash() {
+  const modal = document.getElementById('trashModal');
+  const list = document.getElementById('trashList');
+  if (!modal || !list) return;
+
+  const data = await fileRequest('/api/papierkorb');
+  if (!data) return;
+
+  list.innerHTML = '';
+  if (data.dateien.length === 0) {
+    list.innerHTML = '<p class="hint">Der Papierkorb ist leer.</p>';
+  }
+  data.dateien.forEach(item => {
+    const entry = document.createElement('div');
+    entry.className = 'project-item';
+    entry.textContent = (item.isDir ? '📁 ' : '') + item.name + ' (' + new Date(item.geloescht).toLocaleString('de-DE') + ')';
+    entry.addEventListener('click', () => restoreFile(item));
+    list.appendChild(entry);
+  });
+  modal.classList.add('show');
+}
+
+async function restoreFile(item) {
+  const data = await fileRequest('/api/papierkorb/wiederherstellen', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ id: item.id })
+  });
+  if (!data) return;
+
+  logToConsole('Zurückgeholt: ' + data.pfad, 'success');
+  document.getElementById('trashModal')?.classList.remove('show');
+  await loadFiles();
+}
+
+async function emptyTrash() {
+  if (!confirm('Alle Dateien im Papierkorb endgültig löschen?')) return;
+
+  const data = await fileRequest('/api/papierkorb', { method: 'DELETE' });
+  if (!data) return;
+
+  logToConsole('Papierkorb geleert', 'success');
+  document.getElementById('trashModal')?.classList.remove('show');
+}
+
+async function showHistory() {
+  const modal = document.getElementById('historyModal');
+  const list = document.getElementById('historyList');
+  if (!modal || !list) return;
+
+  const data = await fileRequest('/api/verlauf');
+  if (!data) return;
+
+  list.innerHTML = '';
+  if (data.versionen.length === 0) {
+    list.innerHTML = '<p class="hint">Noch keine Versionen gespeichert.</p>';
+  }
+  data.versionen.forEach(version => {
+    const entry = document.createElement('div');
+    entry.className = 'project-item';
+
+    const text = document.createElement('span');
+    text.textContent = new Date(version.zeit).toLocaleString('de-DE') + ' – ' + version.anlass +
+      (version.geaendert.length ? ' (' + version.geaendert.join(', ') + ')' : '');
+    entry.appendChild(text);
+
+    const diffButton = document.createElement('button');
+    diffButton.className = 'btn btn-small';
+    diffButton.textContent = 'Vergleichen';
+    diffButton.addEventListener('click', () => showDiff(version, currentFile));
+    entry.appendChild(diffButton);
+
+    const fileButton = document.createElement('button');
+    fileButton.className = 'btn btn-small';
+    fileButton.textContent = currentFile + ' zurückholen';
+    fileButton.addEventListener('click', () => restoreVersion(version.id, currentFile));
+    entry.appendChild(fileButton);
+
+    const projectButton = document.createElement('button');
+    projectButton.className = 'btn btn-small';
+    projectButton.textContent = 'Ganzes Projekt';
+    projectButton.addEventListener('click', () => restoreVersion(version.id, ''));
+    entry.appendChild(projectButton);
+
+    list.appendChild(entry);
+  });
+  modal.classList.add('show');
+}
+
+// Compare a file in a version with the saved file, side by side
+async function showDiff(version, pfad) {
+  const view = document.getElementById('diffView');
+  if (!view) return;
+
+  const data = await fileRequest('/api/diff', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ pfad: pfad, von: version.id })
+  });
+  if (!data) return;
+
+  const title = document.getElementById('diffTitle');
+  if (title) title.textContent = pfad + ': ' + new Date(version.zeit).toLocaleString('de-DE') + ' ↔ jetzt';
+
+  view.innerHTML = '';
+  if (!data.geaendert) {
+    view.innerHTML = '<p class="hint">Keine Unterschiede (Einrückung zählt nicht).</p>';
+  }
+  data.hunks.forEach(hunk => {
+    const header = document.createElement('div');
+    header.className = 'diff-section';
+    header.textContent = 'Zeile ' + hunk.neuStart + (hunk.bereich ? ' – ' + hunk.bereich : '');
+    view.appendChild(header);
+    view.appendChild(renderHunk(hunk));
+  });
+  document.getElementById('diffModal')?.classList.add('show');
+}
+
+// Removed and added lines next to each other are shown in one row
+function renderHunk(hunk) {
+  const table = document.createElement('table');
+  table.className = 'diff-table';
+
+  const cell = (row, text, className) => {
+    const td = document.createElement('td');
+    td.className = className;
+    td.textContent = text;
+    row.appendChild(td);
+  };
+  const addRow = (left, right) => {
+    const row = document.createElement('tr');
+    cell(row, left ? left.alt : '', 'nr');
+    cell(row, left ? (left.altText || left.text) : '', left ? left.art : 'leer');
+    cell(row, right ? right.neu : '', 'nr');
+    cell(row, right ? right.text : '', right ? right.art : 'leer');
+    table.appendChild(row);
+  };
+
+  const lines = hunk.zeilen;
+  for (let i = 0; i < lines.length;) {
+    if (lines[i].art === 'gleich') {
+      addRow(lines[i], lines[i]);
+      i++;
+      continue;
+    }
+    const removed = [], added = [];
+    while (i < lines.length && lines[i].art === 'entfernt') removed.push(lines[i++]);
+    while (i < lines.length && lines[i].art === 'neu') added.push(lines[i++]);
+    for (let k = 0; k < Math.max(removed.length, added.length); k++) {
+      addRow(removed[k], added[k]);
     }
-  } catch (err) {
-    logToConsole('Fehler beim Hochladen: ' + err.message, 'error');
-    console.error('Fehler beim Hochladen:', err);
   }
-  return null;
+  return table;
+}
+
+async function restoreVersion(id, pfad) {
+  const what = pfad ? '"' + pfad + '"' : 'das ganze Projekt';
+  if (!confirm(what + ' auf den Stand vom ' + id + ' zurücksetzen?')) return;
+
+  // Unsaved changes become a version of their own
+  if (hasUnsavedChanges) await saveCurrentFile();
+
+  const data = await fileRequest('/api/verlauf/wiederherstellen', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ version: id, pfad: pfad })
+  });
+  if (!data) return;
+
+  if (data.dateien.length === 0) {
+    logToConsole('Keine Änderungen: Der Stand ist schon so.', 'log');
+  } else {
+    logToConsole('Zurückgeholt: ' + data.dateien.join(', '), 'success');
+  }
+  document.getElementById('historyModal')?.classList.remove('show');
+  const file = currentFile;
+  await loadFiles();
+  if (fileModels[file] !== undefined) openFile(file);
+}
+
+// Upload an image or sound; the server checks the content and picks the folder
+async function uploadAsset(file) {
+  const formData = new FormData();
+  formData.append('datei', file);
+
+  const data = await fileRequest('/api/medien', {
+    method: 'POST',
+    body: formData
+  });
+  if (!data) return null;
+
+  const asset = data.datei;
+  if (asset.art === 'bild') {
+    logToConsole('Bild hochgeladen: ' + asset.pfad + ' (' + asset.breite + ' × ' + asset.hoehe + ' Pixel)', 'success');
+  } else {
+    logToConsole('Ton hochgeladen: ' + asset.pfad, 'success');
+  }
+  return asset;
 }
 
 // Initialize Monaco Editor
@@ -399,15 +828,8 @@ function createEditor(container, initialContent) {
     tabCompletion: 'on'
   });
 
-  // Update symbol table and unsaved changes on content change
+  // Track unsaved changes on content change
   monacoEditor.onDidChangeModelContent(() => {
-    const content = monacoEditor.getValue();
-
-    // Update symbol table for autocomplete
-    if (typeof updateSymbolTable === 'function') {
-      updateSymbolTable(content);
-    }
-
     hasUnsavedChanges = true;
     updateFileTab();
   });
@@ -513,6 +935,8 @@ document.addEventListener('DOMContentLoaded', () => {
   document.getElementById('btnRun')?.addEventListener('click', compileAndRun);
   document.getElementById('btnStop')?.addEventListener('click', stopGame);
   document.getElementById('btnSave')?.addEventListener('click', saveCurrentFile);
+  document.getElementById('btnExport')?.addEventListener('click', exportGame);
+  showTeacherButton();
   document.getElementById('btnHelp')?.addEventListener('click', () => {
     document.getElementById('helpModal')?.classList.add('show');
   });
@@ -520,6 +944,20 @@ document.addEventListener('DOMContentLoaded', () => {
     document.getElementById('helpModal')?.classList.remove('show');
   });
   document.getElementById('btnNewFile')?.addEventListener('click', createNewFile);
+  document.getElementById('btnRenameFile')?.addEventListener('click', renameCurrentFile);
+  document.getElementById('btnDeleteFile')?.addEventListener('click', deleteCurrentFile);
+  document.getElementById('btnTrash')?.addEventListener('click', showTrash);
+  document.getElementById('btnEmptyTrash')?.addEventListener('click', emptyTrash);
+  document.getElementById('btnHistory')?.addEventListener('click', showHistory);
+  document.getElementById('closeDiff')?.addEventListener('click', () => {
+    document.getElementById('diffModal')?.classList.remove('show');
+  });
+  document.getElementById('closeHistory')?.addEventListener('click', () => {
+    document.getElementById('historyModal')?.classList.remove('show');
+  });
+  document.getElementById('closeTrash')?.addEventListener('click', () => {
+    document.getElementById('trashModal')?.classList.remove('show');
+  });
   document.getElementById('closeUpload')?.addEventListener('click', () => {
     document.getElementById('uploadModal')?.classList.remove('show');
   });
@@ -574,12 +1012,14 @@ document.addEventListener('DOMContentLoaded', () => {
   document.getElementById('imageInput')?.addEventListener('change', async (e) => {
     const file = e.target.files?.[0];
     if (file) {
-      const path = await uploadImage(file);
-      if (path) {
-        logToConsole('Verwende: LADE_BILD("' + path + '")', 'log');
+      const asset = await uploadAsset(file);
+      if (asset) {
+        const befehl = asset.art === 'bild' ? 'LADE_BILD' : 'SPIELE_TON';
+        logToConsole('Verwende: ' + befehl + '("' + asset.pfad + '")', 'log');
         document.getElementById('uploadModal')?.classList.remove('show');
         await loadFiles();
       }
+      e.target.value = '';
     }
   });
 
@@ -604,6 +1044,16 @@ document.addEventListener('DOMContentLoaded', () => {
 
   document.getElementById('btnCreateProject')?.addEventListener('click', createProject);
 
+  document.getElementById('btnExportZip')?.addEventListener('click', exportProjectZip);
+  document.getElementById('btnImportZip')?.addEventListener('click', () => {
+    document.getElementById('importZipInput')?.click();
+  });
+  document.getElementById('importZipInput')?.addEventListener('change', (e) => {
+    const file = e.target.files[0];
+    e.target.value = '';
+    if (file) importProjectZip(file);
+  });
+
   // Close modals on outside click
   document.querySelectorAll('.modal').forEach(modal => {
     modal.addEventListener('click', (e) => {
@@ -707,6 +1157,18 @@ function insertAtCursor(text) {
   monacoEditor.focus();
 }
 
+// Teachers get a link to the class management
+async function showTeacherButton() {
+  const button = document.getElementById('btnTeacher');
+  if (!button) return;
+  try {
+    const info = await (await fetch('/api/system/info')).json();
+    button.hidden = info.rolle !== 'lehrer';
+  } catch (err) {
+    console.error('Fehler beim Laden der Systeminfo:', err);
+  }
+}
+
 async function loadProjectList() {
   try {
     const response = await fetch('/api/projekte/liste');
@@ -729,11 +1191,56 @@ async function loadProjectList() {
       item.onclick = () => openProject(p);
       list.appendChild(item);
     });
+
+    await loadExampleList();
   } catch (err) {
     console.error('Fehler beim Laden der Projektliste:', err);
   }
 }
 
+// Shared examples are never changed; opening one makes a copy
+async function loadExampleList() {
+  const section = document.getElementById('exampleSection');
+  const list = document.getElementById('exampleList');
+  if (!section || !list) return;
+
+  const response = await fetch('/api/projekte/beispiele');
+  const examples = response.ok ? await response.json() : [];
+  section.hidden = examples.length === 0;
+
+  list.innerHTML = '';
+  examples.forEach(example => {
+    const item = document.createElement('div');
+    item.className = 'project-item';
+    item.innerHTML = `<div class="icon">📚</div><div class="name"></div>`;
+    item.querySelector('.name').textContent = example;
+    item.onclick = () => copyExample(example);
+    list.appendChild(item);
+  });
+}
+
+async function copyExample(example) {
+  const name = prompt('Wie soll deine Kopie von "' + example + '" heißen?', 'mein-' + example);
+  if (!name) return;
+
+  try {
+    const response = await fetch('/api/projekte/neu', {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ name: name.trim(), beispiel: example })
+    });
+    if (!response.ok) {
+      logToConsole(await response.text(), 'error');
+      return;
+    }
+    document.getElementById('projectModal').classList.remove('show');
+    await loadFiles();
+    logToConsole('Kopie von ' + example + ' angelegt: ' + name, 'success');
+  } catch (err) {
+    console.error('Fehler beim Kopieren des Beispiels:', err);
+  }
+}
+
 async function openProject(name) {
   try {
     const response = await fetch('/api/projekte/oeffnen', {
@@ -6,137 +6,93 @@
 let monacoLoaded = false;
 let monacoReadyCallbacks = [];
 
-// BenLang Keywords
-const keywords = [
-  'WENN', 'SONST', 'SOLANGE', 'FUER', 'FUER', 'VON', 'BIS', 'WIEDERHOLE',
-  'FUNKTION', 'ZURUECK', 'ZURUECK', 'VAR', 'VARIABLE', 'FIGUR',
-  'WAHR', 'FALSCH', 'UND', 'ODER', 'NICHT',
-  'SPIEL', 'WENN_START', 'WENN_IMMER', 'WENN_TASTE', 'WENN_KOLLISION'
-];
-
-const builtinFunctions = [
-  'LADE_BILD', 'BILD_WECHSELN', 'SPIELE_TON', 'ZEIGE_TEXT', 'ZEICHNE_RECHTECK', 'ZEICHNE_KREIS',
-  'ZEICHNE_LINIE', 'TASTE_GEDRUECKT', 'TASTE_GETIPPT', 'GEDRUECKTE_TASTE', 'MAUS_X', 'MAUS_Y',
-  'MAUS_GEDRUECKT', 'ZUFALL', 'RUNDEN', 'ABSOLUT',
-  'WURZEL', 'SINUS', 'KOSINUS', 'SCHREIBE', 'FRAGE', 'WARTE',
-  'LOESCHEN', 'LADE_LEVEL', 'GEHE_ZU', 'DREHE', 'SKALIERE',
-  'LAENGE', 'ZEICHEN', 'GROSSBUCHSTABEN'
-];
-
-const builtinFunctionSignatures = {
-  'LADE_BILD': { params: '(pfad)', desc: 'Laedt ein Bild und gibt eine Figur zurueck' },
-  'BILD_WECHSELN': { params: '(figur, bild)', desc: 'Wechselt das Bild einer Figur' },
-  'SPIELE_TON': { params: '(pfad)', desc: 'Spielt eine Sound-Datei ab' },
-  'ZEIGE_TEXT': { params: '(text, x, y, farbe, groesse)', desc: 'Zeigt Text an' },
-  'ZEICHNE_RECHTECK': { params: '(x, y, breite, hoehe, farbe)', desc: 'Zeichnet ein Rechteck' },
-  'ZEICHNE_KREIS': { params: '(x, y, radius, farbe)', desc: 'Zeichnet einen Kreis' },
-  'ZEICHNE_LINIE': { params: '(x1, y1, x2, y2, farbe)', desc: 'Zeichnet eine Linie' },
-  'TASTE_GEDRUECKT': { params: '(taste)', desc: 'Gibt WAHR zurueck wenn Taste gedrueckt ist' },
-  'TASTE_GETIPPT': { params: '(taste)', desc: 'Gibt nur beim ersten Druecken WAHR zurueck' },
-  'MAUS_X': { params: '()', desc: 'X-Position der Maus' },
-  'MAUS_Y': { params: '()', desc: 'Y-Position der Maus' },
-  'MAUS_GEDRUECKT': { params: '()', desc: 'Gibt WAHR zurueck wenn Maustaste gedrueckt' },
-  'ZUFALL': { params: '(min, max)', desc: 'Zufaellige Ganzzahl zwischen min und max' },
-  'RUNDEN': { params: '(zahl)', desc: 'Rundet zur naechsten Ganzzahl' },
-  'ABSOLUT': { params: '(zahl)', desc: 'Gibt den positiven Wert zurueck' },
-  'WURZEL': { params: '(zahl)', desc: 'Quadratwurzel der Zahl' },
-  'SINUS': { params: '(zahl)', desc: 'Sinus der Zahl (in Grad)' },
-  'KOSINUS': { params: '(zahl)', desc: 'Kosinus der Zahl (in Grad)' },
-  'SCHREIBE': { params: '(text)', desc: 'Schreibt Text in die Konsole' },
-  'FRAGE': { params: '(frage)', desc: 'Zeigt eine Eingabeaufforderung' },
-  'WARTE': { params: '(millisekunden)', desc: 'Wartet die angegebene Zeit' },
-  'LOESCHEN': { params: '(figur)', desc: 'Entfernt eine Figur aus dem Spiel' },
-  'LADE_LEVEL': { params: '(nummer)', desc: 'Laedt ein Level' },
-  'GEHE_ZU': { params: '(figur, x, y)', desc: 'Bewegt eine Figur zu einer Position' },
-  'DREHE': { params: '(figur, winkel)', desc: 'Dreht eine Figur' },
-  'SKALIERE': { params: '(figur, faktor)', desc: 'Skaliert eine Figur' },
-  'GEDRUECKTE_TASTE': { params: '()', desc: 'Gibt die zuletzt gedrueckte Taste zurueck' },
-  'LAENGE': { params: '(text)', desc: 'Gibt die Laenge eines Textes zurueck' },
-  'ZEICHEN': { params: '(text, index)', desc: 'Gibt das Zeichen an einer Position zurueck' },
-  'GROSSBUCHSTABEN': { params: '(text)', desc: 'Wandelt Text in Grossbuchstaben um' }
+// Keywords and built-in functions come from the server (/api/sprache),
+// so the editor knows exactly what the compiler understands
+let sprache = {
+  schluesselwoerter: [],
+  befehle: {},
+  eigenschaften: {}
 };
 
-const figurProperties = [
-  '.x', '.y', '.breite', '.hoehe', '.sichtbar', '.drehung',
-  '.geschwindigkeitX', '.geschwindigkeitY',
-  '.GEHE_ZU(x, y)', '.DREHE(winkel)', '.SKALIERE(faktor)', '.LOESCHEN()'
-];
-
-// Symbol table for user-defined symbols
-let symbolTable = {
-  variables: [],
-  figurs: [],
-  functions: [],
-  loopVariables: []
-};
-
-function parseCodeForSymbols(code) {
-  const symbols = {
-    variables: [],
-    figurs: [],
-    functions: [],
-    loopVariables: [],
-    braceDepth: 0,
-    currentFunction: null
-  };
-
-  const lines = code.split('\n');
-  lines.forEach((line, lineIndex) => {
-    const trimmed = line.trim();
-    const beforeBrace = symbols.braceDepth;
-
-    symbols.braceDepth += (line.match(/\{/g) || []).length;
-    symbols.braceDepth -= (line.match(/\}/g) || []).length;
-
-    const isGlobal = symbols.braceDepth === 0;
-
-    if (trimmed.startsWith('FUNKTION ')) {
-      const match = trimmed.match(/FUNKTION\s+(\w+)\s*\(([^)]*)\)/);
-      if (match) {
-        const params = match[2].split(',').map(p => p.trim()).filter(p => p);
-        symbols.currentFunction = { name: match[1], params, line: lineIndex };
-        symbols.functions.push(symbols.currentFunction);
-      }
-    }
-
-    if (trimmed.startsWith('FUER ') || trimmed.startsWith('FUER ')) {
-      const match = trimmed.match(/FUER\s+(\w+)\s+VON/);
-      if (match) {
-        symbols.loopVariables.push({ name: match[1], line: lineIndex });
-      }
-    }
-
-    if (trimmed === '}' && symbols.currentFunction && symbols.braceDepth === 0) {
-      symbols.currentFunction = null;
-    }
+async function loadSprache() {
+  try {
+    const response = await fetch('/api/sprache');
+    if (!response.ok) return;
+    sprache = await response.json();
+    registerTokenizer();
+  } catch (err) {
+    console.error('Sprachbeschreibung konnte nicht geladen werden:', err);
+  }
+}
 
-    if (symbols.braceDepth <= 1) {
-      const varMatch = line.match(/(?:VAR|VARIABLE)\s+(\w+)\s*=/);
-      if (varMatch) {
-        symbols.variables.push({ name: varMatch[1], line: lineIndex });
-      }
+// Sends the current file, all other open files and the cursor position
+// to an editor endpoint
+async function askServer(endpoint, model, position, extra) {
+  const dateien = {};
+  if (typeof fileModels !== 'undefined') {
+    Object.keys(fileModels).filter(f => f.endsWith('.ben') && f !== currentFile).forEach(f => {
+      dateien[f] = fileModels[f];
+    });
+  }
 
-      const figurMatch = line.match(/FIGUR\s+(\w+)\s*=/);
-      if (figurMatch) {
-        symbols.figurs.push({ name: figurMatch[1], line: lineIndex });
-      }
-    }
+  const response = await fetch(endpoint, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({
+      datei: typeof currentFile !== 'undefined' ? currentFile : 'hauptspiel.ben',
+      code: model.getValue(),
+      zeile: position.lineNumber,
+      spalte: position.column,
+      dateien: dateien,
+      ...extra
+    })
+  });
+  if (!response.ok) return null;
+  return response.json();
+}
 
-    if (symbols.currentFunction && symbols.braceDepth > 0 && beforeBrace === 0) {
-      symbols.currentFunction.params.forEach(param => {
-        if (!symbols.variables.find(v => v.name === param)) {
-          symbols.variables.push({ name: param, line: lineIndex, isParam: true });
-        }
-      });
+function registerTokenizer() {
+  monaco.languages.setMonarchTokensProvider('benlang', {
+    defaultToken: '',
+    tokenPostfix: '.benlang',
+    keywords: sprache.schluesselwoerter,
+    builtins: Object.keys(sprache.befehle),
+    operators: [
+      '=', '==', '!=', '<', '<=', '>', '>=',
+      '+', '-', '*', '/', '%',
+      '.', '(', ')', '{', '}', '[', ']', ',', ':'
+    ],
+    symbols: /[=><!+\-*/%.,(){}[\]]+/,
+    tokenizer: {
+      root: [
+        [/[{}]/, 'delimiter.bracket'],
+        [/[()]/, 'delimiter.parenthesis'],
+        [/\/\/.*/, 'comment'],
+        [/"(#[0-9A-Fa-f]{3,8})"/, { token: 'string.hexcolor' }],
+        [/"[^"]*"/, 'string'],
+        [/\d+/, 'number'],
+        [/[a-zA-Z_][a-zA-Z0-9_]*/, {
+          cases: {
+            '@keywords': 'keyword',
+            '@builtins': 'function',
+            '@default': 'identifier'
+          }
+        }]
+      ]
     }
   });
-
-  return symbols;
 }
 
-function updateSymbolTable(code) {
-  symbolTable = parseCodeForSymbols(code);
-}
+const completionKinds = {
+  schluesselwort: 'Keyword',
+  befehl: 'Function',
+  funktion: 'Function',
+  figur: 'Variable',
+  variable: 'Variable',
+  parameter: 'Variable',
+  zaehlvariable: 'Variable',
+  eigenschaft: 'Property'
+};
 
 function initMonaco() {
   if (typeof monaco === 'undefined') {
@@ -173,35 +129,8 @@ function initMonaco() {
 
   monaco.languages.register({ id: 'benlang' });
 
-  monaco.languages.setMonarchTokensProvider('benlang', {
-    defaultToken: '',
-    tokenPostfix: '.benlang',
-    keywords: keywords,
-    builtins: builtinFunctions,
-    operators: [
-      '=', '==', '!=', '<', '<=', '>', '>=',
-      '+', '-', '*', '/', '%',
-      '.', '(', ')', '{', '}', '[', ']', ',', ':'
-    ],
-    symbols: /[=><!+\-*/%.,(){}[\]]+/,
-    tokenizer: {
-      root: [
-        [/[{}]/, 'delimiter.bracket'],
-        [/[()]/, 'delimiter.parenthesis'],
-        [/\/\/.*/, 'comment'],
-        [/"(#[0-9A-Fa-f]{3,8})"/, { token: 'string.hexcolor' }],
-        [/"[^"]*"/, 'string'],
-        [/\d+/, 'number'],
-        [/[a-zA-Z_][a-zA-Z0-9_]*/, {
-          cases: {
-            '@keywords': 'keyword',
-            '@builtins': 'function',
-            '@default': 'identifier'
-          }
-        }]
-      ]
-    }
-  });
+  registerTokenizer();
+  loadSprache();
 
   // Native Monaco color provider: shows VSCode-style colored swatches
   // and opens the built-in color picker on click.
@@ -248,110 +177,100 @@ function initMonaco() {
   });
 
   monaco.languages.registerCompletionItemProvider('benlang', {
-    provideCompletionItems: function (model, position) {
-      const textUntilPosition = model.getValueInRange({
-        startLineNumber: 1,
-        startColumn: 1,
+    provideCompletionItems: async function (model, position) {
+      const word = model.getWordUntilPosition(position);
+      const range = {
+        startLineNumber: position.lineNumber,
         endLineNumber: position.lineNumber,
-        endColumn: position.column
-      });
+        startColumn: word.startColumn,
+        endColumn: word.endColumn
+      };
+
+      const antwort = await askServer('/api/vervollstaendigen', model, position);
+      if (!antwort) return { suggestions: [] };
+
+      return {
+        suggestions: antwort.vorschlaege.map(v => ({
+          label: v.label,
+          kind: monaco.languages.CompletionItemKind[completionKinds[v.art] || 'Text'],
+          detail: v.detail,
+          documentation: v.doku,
+          insertText: v.einfuegen,
+          range: range
+        }))
+      };
+    },
+    triggerCharacters: ['.']
+  });
 
-      const wordMatch = textUntilPosition.match(/(\w*)$/);
-      const partialWord = wordMatch ? wordMatch[1] : '';
-
-      const dotMatch = textUntilPosition.match(/(\w*)\.(\w*)$/);
-      let suggestions = [];
-
-      if (dotMatch) {
-        const baseIdentifier = dotMatch[1];
-        const partialProp = dotMatch[2] || '';
-
-        if (symbolTable.figurs.some(f => f.name === baseIdentifier)) {
-          figurProperties.forEach(prop => {
-            const propName = prop.substring(1);
-            if (propName.toLowerCase().startsWith(partialProp.toLowerCase())) {
-              const isMethod = prop.includes('(');
-              suggestions.push({
-                label: prop,
-                kind: isMethod ? monaco.languages.CompletionItemKind.Method : monaco.languages.CompletionItemKind.Property,
-                insertText: isMethod ? prop.substring(1).replace(/\(\w+(, \w+)*\)/, (match) => {
-                  const params = match.slice(1, -1).split(', ');
-                  return '(' + params.map((p, i) => `\${${i + 1}:${p}}`).join(', ') + ')';
-                }) : prop.substring(1),
-                insertTextRules: isMethod ? monaco.languages.CompletionItemInsertTextRule.InsertAsSnippet : monaco.languages.CompletionItemInsertTextRule.None
-              });
-            }
-          });
-        }
-      } else if (partialWord.length >= 1) {
-        keywords.forEach(kw => {
-          if (kw.toLowerCase().startsWith(partialWord.toLowerCase())) {
-            suggestions.push({
-              label: kw,
-              kind: monaco.languages.CompletionItemKind.Keyword,
-              insertText: kw + ' ',
-              insertTextRules: monaco.languages.CompletionItemInsertTextRule.None
-            });
-          }
-        });
+  monaco.languages.registerHoverProvider('benlang', {
+    provideHover: async function (model, position) {
+      const antwort = await askServer('/api/hover', model, position);
+      if (!antwort || !antwort.inhalt) return null;
+      return { contents: [{ value: antwort.inhalt }] };
+    }
+  });
 
-        Object.keys(builtinFunctionSignatures).forEach(fn => {
-          if (fn.toLowerCase().startsWith(partialWord.toLowerCase())) {
-            const sig = builtinFunctionSignatures[fn];
-            suggestions.push({
-              label: fn + sig.params,
-              kind: monaco.languages.CompletionItemKind.Function,
-              documentation: sig.desc,
-              detail: sig.params,
-              insertText: fn + sig.params,
-              insertTextRules: monaco.languages.CompletionItemInsertTextRule.None
-            });
-          }
-        });
+  monaco.languages.registerDefinitionProvider('benlang', {
+    provideDefinition: async function (model, position) {
+      const antwort = await askServer('/api/definition', model, position);
+      if (!antwort || !antwort.gefunden) return null;
 
-        symbolTable.variables.forEach(v => {
-          if (v.name.toLowerCase().startsWith(partialWord.toLowerCase())) {
-            suggestions.push({
-              label: v.name,
-              kind: v.isParam ? monaco.languages.CompletionItemKind.Parameter : monaco.languages.CompletionItemKind.Variable,
-              insertText: v.name
-            });
-          }
-        });
+      const symbol = antwort.symbol;
+      const range = new monaco.Range(symbol.zeile, symbol.spalte, symbol.zeile, symbol.spalte + symbol.name.length);
 
-        symbolTable.figurs.forEach(f => {
-          if (f.name.toLowerCase().startsWith(partialWord.toLowerCase())) {
-            suggestions.push({
-              label: f.name,
-              kind: monaco.languages.CompletionItemKind.Variable,
-              insertText: f.name
-            });
-          }
-        });
+      if (typeof currentFile === 'undefined' || symbol.datei === currentFile) {
+        return { uri: model.uri, range: range };
       }
 
-      return { suggestions: suggestions };
-    },
-    triggerCharacters: ['.', 'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 'I', 'J', 'K', 'L', 'M', 'N', 'O', 'P', 'Q', 'R', 'S', 'T', 'U', 'V', 'W', 'X', 'Y', 'Z', 'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h', 'i', 'j', 'k', 'l', 'm', 'n', 'o', 'p', 'q', 'r', 's', 't', 'u', 'v', 'w', 'x', 'y', 'z', '_']
+      // The editor shows one file at a time; other files get a hidden
+      // model so Monaco can peek into them, and the opener below switches
+      const uri = monaco.Uri.parse('benlang:///' + symbol.datei);
+      const inhalt = fileModels[symbol.datei] || '';
+      const fremd = monaco.editor.getModel(uri) || monaco.editor.createModel(inhalt, 'benlang', uri);
+      if (fremd.getValue() !== inhalt) fremd.setValue(inhalt);
+      return { uri: uri, range: range };
+    }
   });
 
-  monaco.languages.registerHoverProvider('benlang', {
-    provideHover: function (model, position) {
-      const word = model.getWordAtPosition(position);
-      if (!word) return null;
-
-      const fn = builtinFunctionSignatures[word.word.toUpperCase()];
-      if (fn) {
-        return {
-          contents: [
-            { value: `**${word.word.toUpperCase()}**` },
-            { value: fn.params },
-            { value: fn.desc }
-          ]
-        };
-      }
+  monaco.languages.registerRenameProvider('benlang', {
+    provideRenameEdits: async function (model, position, newName) {
+      const antwort = await askServer('/api/umbenennen', model, position, { neuerName: newName });
+      if (!antwort) return { edits: [], rejectReason: 'Umbenennen hat nicht geklappt' };
+      if (!antwort.erfolg) return { edits: [], rejectReason: antwort.fehler };
+
+      // The server has saved all files; other files only need a fresh copy
+      const edits = [];
+      Object.keys(antwort.dateien).forEach(datei => {
+        if (datei === currentFile) {
+          edits.push({
+            resource: model.uri,
+            versionId: undefined,
+            textEdit: { range: model.getFullModelRange(), text: antwort.dateien[datei] }
+          });
+        } else if (typeof fileModels !== 'undefined') {
+          fileModels[datei] = antwort.dateien[datei];
+        }
+      });
+      return { edits: edits };
+    }
+  });
 
-      return null;
+  monaco.editor.registerEditorOpener({
+    openCodeEditor: function (source, resource, selectionOrPosition) {
+      if (resource.scheme !== 'benlang') return false;
+
+      openFile(resource.path.replace(/^\//, ''));
+      if (selectionOrPosition) {
+        if (monaco.Range.isIRange(selectionOrPosition)) {
+          monacoEditor.setSelection(selectionOrPosition);
+          monacoEditor.revealRangeInCenter(selectionOrPosition);
+        } else {
+          monacoEditor.setPosition(selectionOrPosition);
+          monacoEditor.revealPositionInCenter(selectionOrPosition);
+        }
+      }
+      return true;
     }
   });
 
@@ -364,29 +283,32 @@ function initMonaco() {
         endColumn: position.column
       });
 
-      const match = textUntilPosition.match(/(\w+)\([^)]*$/);
+      const match = textUntilPosition.match(/(\w+)\(([^)]*)$/);
       if (match) {
         const fnName = match[1].toUpperCase();
-        const fn = builtinFunctionSignatures[fnName];
+        const fn = sprache.befehle[fnName];
         if (fn) {
-          const paramNames = fn.params.slice(1, -1).split(',').map(p => p.trim());
+          const paramNames = fn.parameter.slice(1, -1).split(',').map(p => p.trim());
           return {
-            activeSignature: 0,
-            activeParameter: paramNames.length - 1,
-            signatures: [{
-              label: fnName + fn.params,
-              documentation: fn.desc,
-              parameters: paramNames.map((p, i) => ({
-                label: p,
-                documentation: `Parameter ${i + 1}`
-              }))
-            }]
+            value: {
+              activeSignature: 0,
+              activeParameter: Math.min(match[2].split(',').length - 1, paramNames.length - 1),
+              signatures: [{
+                label: fnName + fn.parameter,
+                documentation: fn.beschreibung,
+                parameters: paramNames.map((p, i) => ({
+                  label: p,
+                  documentation: `Parameter ${i + 1}`
+                }))
+              }]
+            },
+            dispose: function () { }
           };
         }
       }
       return null;
     },
-    signatureHelpTriggerCharacters: ['(']
+    signatureHelpTriggerCharacters: ['(', ',']
   });
 
   monacoLoaded = true;
@@ -420,8 +342,4 @@ function loadMonaco() {
 if (typeof window !== 'undefined') {
   window.loadMonaco = loadMonaco;
   window.onMonacoReady = onMonacoReady;
-  window.updateSymbolTable = updateSymbolTable;
-  window.builtinFunctionSignatures = builtinFunctionSignatures;
-  window.symbolTable = symbolTable;
-  window.figurProperties = figurProperties;
 }
@@ -16,6 +16,7 @@
     figuren: [],
     bilder: {},
     toene: {},
+    dateien: {}, // Embedded project files (path -> data URI) of exported games
     tasten: {},
     tastenFrame: {}, // Keys pressed in the current frame
     maus: { x: 0, y: 0, gedrueckt: false },
@@ -424,7 +425,7 @@
         // Create a colored rectangle as fallback
         figur.bild = this.createFallbackImage(figur.breite, figur.hoehe, '#ff6b6b');
       };
-      img.src = '/projekt/' + pfad;
+      img.src = this.dateiUrl(pfad);
 
       this.figuren.push(figur);
 
@@ -549,7 +550,7 @@
 
     spieleTon: function (pfad) {
       if (!this.toene[pfad]) {
-        this.toene[pfad] = new Audio('/projekt/' + pfad);
+        this.toene[pfad] = new Audio(this.dateiUrl(pfad));
       }
       this.toene[pfad].currentTime = 0;
       this.toene[pfad].play().catch(e => {
@@ -584,12 +585,20 @@
           console.warn('Bild konnte nicht geladen werden:', pfad);
           reject(new Error('Bild konnte nicht geladen werden: ' + pfad));
         };
-        img.src = '/projekt/' + pfad;
+        img.src = this.dateiUrl(pfad);
       });
     },
 
     // ========== Utility Functions ==========
 
+    /**
+     * URL of a project file: embedded in exported games, served by the IDE otherwise
+     * @param {string} pfad - Path inside the project
+     */
+    dateiUrl: function (pfad) {
+      return this.dateien[pfad] || '/projekt/' + pfad;
+    },
+
     zufall: function (min, max) {
       return Math.floor(Math.random() * (max - min + 1)) + min;
     },